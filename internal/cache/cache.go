@@ -8,12 +8,19 @@ import (
 	"crypto/subtle"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sync"
+	"syscall"
 	"time"
 )
 
+// cacheSecretSize is the length of the per-user secret mixed into the cache key
+const cacheSecretSize = 32
+
 // CacheEntry represents a cached password entry stored on disk
 type CacheEntry struct {
 	EncryptedPassword []byte    `json:"encrypted_password"`
@@ -55,6 +62,13 @@ func (pc *PasswordCache) Get() (string, bool) {
 	pc.mu.Lock()
 	defer pc.mu.Unlock()
 
+	// Without a lock file there is nothing on disk to read
+	unlock, err := pc.lockDisk(false)
+	if err != nil {
+		return "", false
+	}
+	defer unlock()
+
 	if password, loaded := pc.loadFromDisk(); loaded {
 		// Update in-memory cache
 		pc.cachedPassword = password
@@ -80,11 +94,51 @@ func (pc *PasswordCache) Set(password string) error {
 	pc.cachedPassword = password
 	pc.expiration = time.Now().Add(pc.cacheTimeout)
 
+	// A store that cannot be written to, e.g. a read-only mount, keeps the
+	// password in memory for this process only
+	if err := pc.persist(password); err != nil && !isUnwritable(err) {
+		return err
+	}
+	return nil
+}
+
+// Refresh extends a valid cached password to a full timeout from now, on
+// disk too, so an active user is not asked for it again mid-task. It returns
+// false, changing nothing, when there is no valid cached password: then the
+// master password has to be entered again.
+func (pc *PasswordCache) Refresh() bool {
+	password, ok := pc.Get()
+	if !ok {
+		return false
+	}
+
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.cacheTimeout <= 0 {
+		return false
+	}
+	pc.cachedPassword = password
+	pc.expiration = time.Now().Add(pc.cacheTimeout)
+	if err := pc.persist(password); err != nil && !isUnwritable(err) {
+		return false
+	}
+	return true
+}
+
+// persist saves the password and session ID under the cache directory
+func (pc *PasswordCache) persist(password string) error {
 	// Create cache directory if it doesn't exist
 	if err := os.MkdirAll(pc.cacheDir, 0700); err != nil {
 		return err
 	}
 
+	unlock, err := pc.lockDisk(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	// Save to disk for persistence across processes
 	if err := pc.saveToDisk(password); err != nil {
 		return err
@@ -92,7 +146,59 @@ func (pc *PasswordCache) Set(password string) error {
 
 	// Write session ID to file for process verification
 	sessionFile := filepath.Join(pc.cacheDir, "session")
-	return os.WriteFile(sessionFile, []byte(pc.sessionID), 0600)
+	return writeFileAtomic(sessionFile, []byte(pc.sessionID))
+}
+
+// writeFileAtomic writes a private file through a temporary file renamed
+// into place, so a crash mid-write leaves the previous file rather than a
+// truncated one
+func writeFileAtomic(path string, data []byte) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	// CreateTemp already uses 0600
+	_, err = tmpFile.Write(data)
+	if err == nil {
+		err = tmpFile.Sync()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+	}
+	return err
+}
+
+// lockDisk takes the lock that serializes chowkidaar processes reading and
+// writing the cache files, shared for readers and exclusive for writers. A
+// missing cache directory is not created.
+func (pc *PasswordCache) lockDisk(exclusive bool) (func(), error) {
+	f, err := os.OpenFile(filepath.Join(pc.cacheDir, "lock"), os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil && !exclusive && isUnwritable(err) {
+		// On a read-only store no process can be writing the cache
+		return func() {}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := flock(f, exclusive); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to lock the password cache: %w", err)
+	}
+	// Closing the file releases the lock
+	return func() { f.Close() }, nil
+}
+
+// isUnwritable reports whether err means a path cannot be written to
+func isUnwritable(err error) bool {
+	return errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS)
 }
 
 // Clear removes the cached password
@@ -104,6 +210,10 @@ func (pc *PasswordCache) Clear() {
 	pc.expiration = time.Time{}
 	pc.sessionID = ""
 
+	if unlock, err := pc.lockDisk(true); err == nil {
+		defer unlock()
+	}
+
 	// Remove cache files
 	sessionFile := filepath.Join(pc.cacheDir, "session")
 	cacheFile := filepath.Join(pc.cacheDir, "password.cache")
@@ -145,6 +255,12 @@ func (pc *PasswordCache) ValidateSession() bool {
 		return false
 	}
 
+	unlock, err := pc.lockDisk(false)
+	if err != nil {
+		return false
+	}
+	defer unlock()
+
 	sessionFile := filepath.Join(pc.cacheDir, "session")
 	data, err := os.ReadFile(sessionFile)
 	if err != nil {
@@ -169,19 +285,197 @@ func (pc *PasswordCache) GetTimeout() time.Duration {
 	return pc.cacheTimeout
 }
 
-// generateCacheKey creates a key for encrypting the cached password
-func (pc *PasswordCache) generateCacheKey() []byte {
-	// Use a combination of session ID and a fixed string to derive encryption key
+// Diagnostics describes the state of the cache, see DiagnosticInfo
+type Diagnostics struct {
+	InMemory        bool          // This process holds an unexpired password
+	OnDisk          bool          // A password.cache file exists
+	DiskExpiration  time.Time     // Expiry recorded on disk, zero if unreadable
+	DiskReadable    bool          // The file decrypts with this user's secret on this boot
+	DiskProblem     string        // Why the file cannot be used, if it cannot
+	SessionValid    bool          // The session file matches this process's session
+	SessionIDPrefix string        // Start of the session ID, enough to tell sessions apart
+	SecretPath      string        // Where the per-user cache secret is kept
+	SecretPresent   bool          // Whether that secret exists
+	Timeout         time.Duration // Timeout applied to a newly cached password
+}
+
+// DiagnosticInfo inspects the cache without changing it: unlike Get, an
+// expired or unreadable file on disk is reported rather than removed
+func (pc *PasswordCache) DiagnosticInfo() Diagnostics {
+	info := Diagnostics{SessionValid: pc.ValidateSession(), SecretPath: cacheSecretPath()}
+
+	pc.mu.RLock()
+	info.InMemory = pc.cachedPassword != "" && time.Now().Before(pc.expiration)
+	sessionID := pc.sessionID
+	info.Timeout = pc.cacheTimeout
+	pc.mu.RUnlock()
+
+	if _, err := loadCacheSecret(false); err == nil {
+		info.SecretPresent = true
+	}
+
+	if unlock, err := pc.lockDisk(false); err == nil {
+		defer unlock()
+	}
+
+	data, err := os.ReadFile(filepath.Join(pc.cacheDir, "password.cache"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			info.OnDisk = true
+			info.DiskProblem = err.Error()
+		}
+		info.SessionIDPrefix = sessionPrefix(sessionID)
+		return info
+	}
+	info.OnDisk = true
+
+	var entry CacheEntry
+	if err := json.Unmarshal(data, &entry); err != nil {
+		info.DiskProblem = "the file is corrupt"
+		info.SessionIDPrefix = sessionPrefix(sessionID)
+		return info
+	}
+	if sessionID == "" {
+		sessionID = entry.SessionID
+	}
+	info.SessionIDPrefix = sessionPrefix(sessionID)
+	info.DiskExpiration = entry.Expiration
+
+	if time.Now().After(entry.Expiration) {
+		info.DiskProblem = "it has expired"
+		return info
+	}
+	key, err := deriveCacheKey(entry.SessionID, false)
+	if err != nil {
+		info.DiskProblem = "the cache secret is missing, e.g. after logging out or rebooting"
+		return info
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		info.DiskProblem = err.Error()
+		return info
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		info.DiskProblem = err.Error()
+		return info
+	}
+	if _, err := gcm.Open(nil, entry.Nonce, entry.EncryptedPassword, nil); err != nil {
+		info.DiskProblem = "it was written with another cache secret or before a reboot"
+		return info
+	}
+	info.DiskReadable = true
+	return info
+}
+
+// sessionPrefix shortens a session ID for display
+func sessionPrefix(sessionID string) string {
+	if len(sessionID) > 8 {
+		return sessionID[:8]
+	}
+	return sessionID
+}
+
+// generateCacheKey creates a key for encrypting the cached password. The
+// session ID is stored next to the cache, so the key also mixes in a secret
+// kept outside the store and, where available, the boot ID: copying the
+// .cache directory is not enough to decrypt the password. With create set, a
+// missing secret is generated.
+func (pc *PasswordCache) generateCacheKey(create bool) ([]byte, error) {
+	return deriveCacheKey(pc.sessionID, create)
+}
+
+// deriveCacheKey derives the cache key of a session, see generateCacheKey
+func deriveCacheKey(sessionID string, create bool) ([]byte, error) {
+	secret, err := loadCacheSecret(create)
+	if err != nil {
+		return nil, err
+	}
+
 	h := sha256.New()
-	h.Write([]byte(pc.sessionID))
+	h.Write([]byte(sessionID))
+	h.Write(secret)
+	if bootID, err := os.ReadFile("/proc/sys/kernel/random/boot_id"); err == nil {
+		h.Write(bootID)
+	}
 	h.Write([]byte("chowkidaar-cache-key"))
-	return h.Sum(nil)
+	return h.Sum(nil), nil
+}
+
+// cacheSecretPath returns where the per-user cache secret is kept: the
+// runtime directory, which is private to the user and cleared on logout or
+// reboot, or else the user's cache directory. The shared temp dir is only a
+// last resort, where loadCacheSecret's checks keep other users out.
+func cacheSecretPath() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "chowkidaar", "cache.key")
+	}
+	if cacheDir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(cacheDir, "chowkidaar", "cache.key")
+	}
+	return filepath.Join(os.TempDir(), fmt.Sprintf("chowkidaar-%d", os.Getuid()), "cache.key")
+}
+
+// loadCacheSecret reads the per-user cache secret, generating it if create
+// is set. The secret and its directory must be private to the user before
+// anything is read, so that a secret another user prepared or can read is
+// never used.
+func loadCacheSecret(create bool) ([]byte, error) {
+	path := cacheSecretPath()
+	dir := filepath.Dir(path)
+	if create {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, err
+		}
+	}
+	if err := checkPrivate(dir, os.ModeDir|0700); err != nil {
+		if os.IsNotExist(err) && !create {
+			return nil, errors.New("no cache secret")
+		}
+		return nil, err
+	}
+
+	switch err := checkPrivate(path, 0600); {
+	case err == nil:
+		if secret, err := os.ReadFile(path); err == nil && len(secret) == cacheSecretSize {
+			return secret, nil
+		}
+	case !os.IsNotExist(err):
+		return nil, err
+	}
+	if !create {
+		return nil, errors.New("no cache secret")
+	}
+
+	secret := make([]byte, cacheSecretSize)
+	if _, err := rand.Read(secret); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, secret, 0600); err != nil {
+		return nil, err
+	}
+	return secret, nil
+}
+
+// checkPrivate refuses a path that is not of the given type and permissions
+// (e.g. os.ModeDir|0700), is a symlink or is owned by another user
+func checkPrivate(path string, mode os.FileMode) error {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&(os.ModeType|os.ModePerm) != mode || !ownedByUser(info) {
+		return fmt.Errorf("refusing to use %s for the cache secret: not private to this user", path)
+	}
+	return nil
 }
 
 // saveToDisk encrypts and saves the password to disk
 func (pc *PasswordCache) saveToDisk(password string) error {
-	// Generate encryption key from session ID
-	key := pc.generateCacheKey()
+	key, err := pc.generateCacheKey(true)
+	if err != nil {
+		return err
+	}
 
 	// Create AES cipher
 	block, err := aes.NewCipher(key)
@@ -220,7 +514,7 @@ func (pc *PasswordCache) saveToDisk(password string) error {
 
 	// Write to cache file
 	cacheFile := filepath.Join(pc.cacheDir, "password.cache")
-	return os.WriteFile(cacheFile, data, 0600)
+	return writeFileAtomic(cacheFile, data)
 }
 
 // loadFromDisk loads and decrypts the password from disk
@@ -241,7 +535,9 @@ func (pc *PasswordCache) loadFromDisk() (string, bool) {
 	// Unmarshal JSON
 	var entry CacheEntry
 	if err := json.Unmarshal(data, &entry); err != nil {
-		// Invalid cache file, remove it
+		// Writes are atomic, so this is damage rather than a missing cache:
+		// say so, then remove the file and carry on as if nothing was cached
+		fmt.Fprintf(os.Stderr, "Warning: discarding corrupt password cache %s: %v\n", cacheFile, err)
 		os.Remove(cacheFile)
 		return "", false
 	}
@@ -257,8 +553,12 @@ func (pc *PasswordCache) loadFromDisk() (string, bool) {
 	pc.sessionID = entry.SessionID
 	pc.expiration = entry.Expiration
 
-	// Generate decryption key
-	key := pc.generateCacheKey()
+	// Generate decryption key; without the secret the cache cannot be read
+	key, err := pc.generateCacheKey(false)
+	if err != nil {
+		os.Remove(cacheFile)
+		return "", false
+	}
 
 	// Create AES cipher
 	block, err := aes.NewCipher(key)
@@ -0,0 +1,234 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestCacheNeedsSecretOutsideStore(t *testing.T) {
+	storeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	if err := NewPasswordCache(storeDir, time.Minute).Set("hunter2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Another process of the same user reads the cache
+	if got, ok := NewPasswordCache(storeDir, time.Minute).Get(); !ok || got != "hunter2" {
+		t.Fatalf("Get = %q, %v, want hunter2", got, ok)
+	}
+
+	// Someone with only a copy of the store cannot
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if got, ok := NewPasswordCache(storeDir, time.Minute).Get(); ok {
+		t.Fatalf("Get without the cache secret = %q, want nothing", got)
+	}
+}
+
+func TestCacheFallsBackToMemory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+	storeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	os.Chmod(storeDir, 0500)
+	t.Cleanup(func() { os.Chmod(storeDir, 0700) })
+
+	pc := NewPasswordCache(storeDir, time.Minute)
+	if err := pc.Set("hunter2"); err != nil {
+		t.Fatalf("Set on a read-only store: %v", err)
+	}
+	if got, ok := pc.Get(); !ok || got != "hunter2" {
+		t.Errorf("Get = %q, %v, want hunter2 from memory", got, ok)
+	}
+}
+
+func TestCacheConcurrentProcesses(t *testing.T) {
+	storeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	if err := NewPasswordCache(storeDir, time.Minute).Set("hunter2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Separate caches stand in for separate processes: they share only the
+	// files on disk, so a torn write would make a reader drop the cache
+	var wg sync.WaitGroup
+	failed := make(chan string, 100)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if err := NewPasswordCache(storeDir, time.Minute).Set("hunter2"); err != nil {
+					failed <- err.Error()
+				}
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 25; j++ {
+				if got, ok := NewPasswordCache(storeDir, time.Minute).Get(); !ok || got != "hunter2" {
+					failed <- fmt.Sprintf("Get = %q, %v", got, ok)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	close(failed)
+
+	for msg := range failed {
+		t.Error(msg)
+	}
+}
+
+func TestDiagnosticInfo(t *testing.T) {
+	storeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	if info := NewPasswordCache(storeDir, time.Minute).DiagnosticInfo(); info.OnDisk || info.InMemory || info.SessionValid {
+		t.Errorf("DiagnosticInfo of an empty cache = %+v", info)
+	}
+
+	writer := NewPasswordCache(storeDir, time.Minute)
+	if err := writer.Set("hunter2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+	info := writer.DiagnosticInfo()
+	if !info.InMemory || !info.OnDisk || !info.DiskReadable || !info.SessionValid || len(info.SessionIDPrefix) != 8 || !info.SecretPresent {
+		t.Errorf("DiagnosticInfo after Set = %+v", info)
+	}
+
+	// Another process has nothing in memory until it reads the disk
+	info = NewPasswordCache(storeDir, time.Minute).DiagnosticInfo()
+	if info.InMemory || !info.DiskReadable || info.SessionValid {
+		t.Errorf("DiagnosticInfo of another process = %+v", info)
+	}
+
+	// Without the cache secret the file is reported, not removed
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	info = NewPasswordCache(storeDir, time.Minute).DiagnosticInfo()
+	if !info.OnDisk || info.DiskReadable || info.DiskProblem == "" || info.SecretPresent {
+		t.Errorf("DiagnosticInfo without the secret = %+v", info)
+	}
+	if _, err := os.Stat(storeDir + "/.cache/password.cache"); err != nil {
+		t.Errorf("DiagnosticInfo removed the cache file: %v", err)
+	}
+}
+
+func TestCacheRecoversFromCorruptFile(t *testing.T) {
+	storeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	if err := NewPasswordCache(storeDir, time.Minute).Set("hunter2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// A file cut short, as a crash during a non-atomic write would leave it
+	cacheFile := filepath.Join(storeDir, ".cache", "password.cache")
+	data, err := os.ReadFile(cacheFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(cacheFile, data[:len(data)/2], 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	pc := NewPasswordCache(storeDir, time.Minute)
+	if info := pc.DiagnosticInfo(); info.DiskProblem != "the file is corrupt" {
+		t.Errorf("DiagnosticInfo of a truncated file = %+v", info)
+	}
+	if got, ok := pc.Get(); ok {
+		t.Errorf("Get of a truncated file = %q, want nothing", got)
+	}
+	if _, err := os.Stat(cacheFile); !os.IsNotExist(err) {
+		t.Errorf("truncated cache file was not removed: %v", err)
+	}
+
+	// The cache works again afterwards and leaves no temporary files behind
+	if err := pc.Set("hunter2"); err != nil {
+		t.Fatalf("Set after recovery: %v", err)
+	}
+	if got, ok := NewPasswordCache(storeDir, time.Minute).Get(); !ok || got != "hunter2" {
+		t.Errorf("Get after recovery = %q, %v, want hunter2", got, ok)
+	}
+	if tmp, _ := filepath.Glob(filepath.Join(storeDir, ".cache", "*.tmp")); len(tmp) != 0 {
+		t.Errorf("temporary files left behind: %v", tmp)
+	}
+}
+
+func TestCacheRefresh(t *testing.T) {
+	storeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	if NewPasswordCache(storeDir, time.Minute).Refresh() {
+		t.Error("Refresh of an empty cache = true")
+	}
+
+	if err := NewPasswordCache(storeDir, 2*time.Second).Set("hunter2"); err != nil {
+		t.Fatalf("Set: %v", err)
+	}
+
+	// Another process with a longer timeout extends the cache on disk
+	if !NewPasswordCache(storeDir, time.Hour).Refresh() {
+		t.Fatal("Refresh of a valid cache = false")
+	}
+	reader := NewPasswordCache(storeDir, time.Minute)
+	if got, ok := reader.Get(); !ok || got != "hunter2" {
+		t.Fatalf("Get after Refresh = %q, %v, want hunter2", got, ok)
+	}
+	if remaining := reader.GetRemainingTime(); remaining < 59*time.Minute {
+		t.Errorf("remaining time after Refresh = %v, want about an hour", remaining)
+	}
+
+	pc := NewPasswordCache(storeDir, time.Minute)
+	pc.Clear()
+	if pc.Refresh() {
+		t.Error("Refresh after Clear = true")
+	}
+}
+
+func TestCacheRefusesSharedSecret(t *testing.T) {
+	runtimeDir := t.TempDir()
+	t.Setenv("XDG_RUNTIME_DIR", runtimeDir)
+
+	// A directory and a known secret that other users can read and replace
+	dir := filepath.Join(runtimeDir, "chowkidaar")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "cache.key"), make([]byte, cacheSecretSize), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, create := range []bool{false, true} {
+		if _, err := loadCacheSecret(create); err == nil {
+			t.Errorf("loadCacheSecret(%v) accepted a world-writable directory", create)
+		}
+	}
+	if info := NewPasswordCache(t.TempDir(), time.Minute).DiagnosticInfo(); info.SecretPresent {
+		t.Error("DiagnosticInfo reports the shared secret as present")
+	}
+
+	// A private directory still refuses a readable secret
+	if err := os.Chmod(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loadCacheSecret(true); err == nil {
+		t.Error("loadCacheSecret accepted a world-readable secret")
+	}
+
+	if err := os.Chmod(filepath.Join(dir, "cache.key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if secret, err := loadCacheSecret(false); err != nil || len(secret) != cacheSecretSize {
+		t.Errorf("loadCacheSecret on a private secret = %d bytes, %v", len(secret), err)
+	}
+}
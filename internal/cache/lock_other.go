@@ -0,0 +1,11 @@
+//go:build !unix
+
+package cache
+
+import "os"
+
+// flock is a no-op where advisory file locks are not available; processes
+// are then only serialized by the in-process mutex
+func flock(f *os.File, exclusive bool) error {
+	return nil
+}
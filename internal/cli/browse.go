@@ -0,0 +1,326 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+
+	"chowkidaar/internal/clipboard"
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/list"
+	"chowkidaar/internal/store"
+	"chowkidaar/internal/termwidth"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var browseCmd = &cobra.Command{
+	Use:   "browse",
+	Short: "Browse passwords interactively",
+	Long: `Open a full-screen browser for the password store.
+
+Type to search, use the arrow keys (or Ctrl+N/Ctrl+P) to move, Enter to reveal
+the selected password and Esc to hide it again. Ctrl+Y copies the first line of
+the selected password to the clipboard. Esc on an empty search, Ctrl+C or Ctrl+Q
+quits. The master password is asked for once when the browser starts.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if !term.IsTerminal(int(syscall.Stdin)) || !term.IsTerminal(int(syscall.Stdout)) {
+			return fmt.Errorf("browse requires an interactive terminal")
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		options := list.DefaultOptions()
+		builder, err := newListBuilder(cfg, options)
+		if err != nil {
+			return err
+		}
+		names, err := builder.EntryNames("")
+		if err != nil {
+			return err
+		}
+
+		// Prompt for master password once for the whole session
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		if err := passwordStore.VerifyMasterPassword(masterPassword); err != nil {
+			return err
+		}
+
+		b := &browser{store: passwordStore, masterPassword: masterPassword, names: names}
+		return b.run()
+	},
+}
+
+// browser holds the state of the interactive store browser
+type browser struct {
+	store          *store.Store
+	masterPassword string
+	names          []string
+
+	query    string
+	matches  []string
+	selected int
+	offset   int
+
+	revealedName string
+	revealed     string
+	message      string
+}
+
+// run takes over the terminal until the user quits
+func (b *browser) run() error {
+	oldState, err := term.MakeRaw(int(syscall.Stdin))
+	if err != nil {
+		return err
+	}
+
+	// Use the alternate screen so nothing is left in the scrollback
+	fmt.Print("\033[?1049h\033[?25l")
+	defer func() {
+		b.revealed = ""
+		b.masterPassword = ""
+		fmt.Print("\033[2J\033[H\033[?25h\033[?1049l")
+		term.Restore(int(syscall.Stdin), oldState)
+	}()
+
+	b.filter()
+	b.render()
+
+	var buf [8]byte
+	for {
+		n, err := os.Stdin.Read(buf[:])
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			continue
+		}
+
+		switch {
+		case n >= 3 && buf[0] == 27 && buf[1] == '[' && buf[2] == 'A':
+			b.move(-1)
+		case n >= 3 && buf[0] == 27 && buf[1] == '[' && buf[2] == 'B':
+			b.move(1)
+		case n == 1 && buf[0] == 27: // Esc
+			if b.revealed != "" {
+				b.hide()
+			} else if b.query != "" {
+				b.query = ""
+				b.filter()
+			} else {
+				return nil
+			}
+		case buf[0] == 3, buf[0] == 17: // Ctrl+C, Ctrl+Q
+			return nil
+		case buf[0] == 14: // Ctrl+N
+			b.move(1)
+		case buf[0] == 16: // Ctrl+P
+			b.move(-1)
+		case buf[0] == 13, buf[0] == 10: // Enter
+			b.reveal()
+		case buf[0] == 25: // Ctrl+Y
+			b.copy()
+		case buf[0] == 127, buf[0] == 8: // Backspace
+			if len(b.query) > 0 {
+				b.query = b.query[:len(b.query)-1]
+				b.filter()
+			}
+		case buf[0] == 27:
+			// Ignore other escape sequences (Left/Right, Home, Alt+key...)
+			// instead of adding their bytes to the search
+		default:
+			for _, char := range buf[:n] {
+				if char >= 32 && char <= 126 {
+					b.query += string(char)
+				}
+			}
+			b.filter()
+		}
+
+		b.render()
+	}
+}
+
+// filter recomputes the entries matching the search query
+func (b *browser) filter() {
+	b.hide()
+	b.matches = b.matches[:0]
+	query := strings.ToLower(b.query)
+	for _, name := range b.names {
+		if strings.Contains(strings.ToLower(name), query) {
+			b.matches = append(b.matches, name)
+		}
+	}
+	b.selected = 0
+	b.offset = 0
+}
+
+// move changes the selection by delta, keeping it in range
+func (b *browser) move(delta int) {
+	if len(b.matches) == 0 {
+		return
+	}
+	b.hide()
+	b.selected += delta
+	if b.selected < 0 {
+		b.selected = 0
+	}
+	if b.selected >= len(b.matches) {
+		b.selected = len(b.matches) - 1
+	}
+}
+
+// reveal decrypts the selected entry
+func (b *browser) reveal() {
+	if len(b.matches) == 0 {
+		return
+	}
+
+	name := b.matches[b.selected]
+	password, err := b.store.Show(name, b.masterPassword)
+	if err != nil {
+		b.message = "Error: " + err.Error()
+		return
+	}
+	if store.IsSensitiveEntry(password) {
+		b.message = fmt.Sprintf("'%s' is marked sensitive; use 'chowkidaar show'", name)
+		return
+	}
+
+	b.revealedName = name
+	b.revealed = store.EntryText(password)
+}
+
+// copy puts the first line of the selected entry, or the password of a JSON
+// entry, on the clipboard
+func (b *browser) copy() {
+	if len(b.matches) == 0 {
+		return
+	}
+
+	name := b.matches[b.selected]
+	password, err := b.store.Show(name, b.masterPassword)
+	if err != nil {
+		b.message = "Error: " + err.Error()
+		return
+	}
+	if store.IsSensitiveEntry(password) {
+		b.message = fmt.Sprintf("'%s' is marked sensitive; use 'chowkidaar show'", name)
+		return
+	}
+
+	firstLine, _, _ := strings.Cut(password, "\n")
+	if store.IsJSONEntry(password) {
+		firstLine = store.TrimSecret(password)
+	}
+	if err := clipboard.Copy(firstLine); err != nil {
+		b.message = "Error: " + err.Error()
+		return
+	}
+	b.message = fmt.Sprintf("Copied '%s' to clipboard", name)
+}
+
+// hide forgets any revealed secret
+func (b *browser) hide() {
+	b.revealedName = ""
+	b.revealed = ""
+	b.message = ""
+}
+
+// render redraws the whole screen
+func (b *browser) render() {
+	width, height := 80, 24
+	if w, h, err := term.GetSize(int(syscall.Stdout)); err == nil && w > 0 {
+		width, height = w, h
+	}
+
+	var out strings.Builder
+	out.WriteString("\033[2J\033[H")
+
+	if width < 30 || height < 8 {
+		out.WriteString(truncate("Terminal too small", width))
+		fmt.Print(out.String())
+		return
+	}
+
+	out.WriteString("\033[1mCHOWKIDAAR\033[0m " + truncate("type to search · ↑/↓ move · Enter reveal · ^Y copy · Esc back", width-11) + "\r\n")
+	out.WriteString(truncate("Search: "+b.query, width) + "\r\n")
+	out.WriteString(strings.Repeat("─", width) + "\r\n")
+
+	// Reserve space for the reveal panel at the bottom
+	panelHeight := 3
+	if b.revealed != "" {
+		panelHeight += strings.Count(b.revealed, "\n") + 1
+		if panelHeight > height/2 {
+			panelHeight = height / 2
+		}
+	}
+	rows := height - 3 - panelHeight
+	if rows < 1 {
+		rows = 1
+	}
+
+	// Keep the selection visible
+	if b.selected < b.offset {
+		b.offset = b.selected
+	}
+	if b.selected >= b.offset+rows {
+		b.offset = b.selected - rows + 1
+	}
+
+	for i := 0; i < rows; i++ {
+		index := b.offset + i
+		if index >= len(b.matches) {
+			out.WriteString("\r\n")
+			continue
+		}
+		if index == b.selected {
+			out.WriteString("\033[7m" + truncate("> "+b.matches[index], width) + "\033[0m\r\n")
+		} else {
+			out.WriteString(truncate("  "+b.matches[index], width) + "\r\n")
+		}
+	}
+
+	out.WriteString(strings.Repeat("─", width) + "\r\n")
+	switch {
+	case b.message != "":
+		out.WriteString(truncate(b.message, width))
+	case b.revealed != "":
+		out.WriteString(truncate(b.revealedName+":", width) + "\r\n")
+		lines := strings.Split(strings.TrimSuffix(b.revealed, "\n"), "\n")
+		for i, line := range lines {
+			if i >= panelHeight-2 {
+				break
+			}
+			out.WriteString(truncate(line, width) + "\r\n")
+		}
+	default:
+		out.WriteString(truncate(fmt.Sprintf("%d of %d passwords", len(b.matches), len(b.names)), width))
+	}
+
+	fmt.Print(out.String())
+}
+
+// truncate shortens text to fit within width terminal columns, counting
+// wide characters such as CJK and emoji as two
+func truncate(text string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	return termwidth.Truncate(text, width)
+}
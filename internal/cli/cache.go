@@ -2,8 +2,11 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
+	"chowkidaar/internal/cache"
 	"chowkidaar/internal/config"
 	"chowkidaar/internal/store"
 
@@ -16,26 +19,57 @@ var cacheCmd = &cobra.Command{
 	Long: `Manage the master password cache. This command allows you to:
 - Check cache status and remaining time
 - Clear the cached master password
+- Extend the cached master password to a full timeout
 - Configure cache timeout`,
 }
 
 var cacheStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show cache status",
-	Long:  `Display the current status of the master password cache, including remaining time.`,
+	Long: `Display the current status of the master password cache, including remaining time.
+
+With --all the cache internals are shown too, to find out why the master
+password is asked for again: whether this process holds the password in
+memory, whether the encrypted copy on disk exists and can be decrypted, the
+session it belongs to, the per-user cache secret and where the timeout comes
+from. The cache is not changed.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.Load()
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		passwordStore, err := store.NewWithGitConfig(cfg.StoreDir, cfg.CacheTimeout, cfg.GitURL, cfg.GitAutoSync)
+		passwordStore, err := store.NewFromConfig(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to initialize store: %w", err)
 		}
 
 		isValid, remaining := passwordStore.GetCacheStatus()
 
+		if jsonOutput {
+			result := map[string]interface{}{
+				"cached":            isValid,
+				"remaining_seconds": int(remaining.Seconds()),
+				"timeout_minutes":   cfg.CacheTimeout,
+			}
+			if cacheStatusAll {
+				info := passwordStore.CacheDiagnostics()
+				result["timeout_source"] = cacheTimeoutSource(cfg)
+				result["in_memory"] = info.InMemory
+				result["on_disk"] = info.OnDisk
+				result["disk_readable"] = info.DiskReadable
+				result["disk_problem"] = info.DiskProblem
+				result["session_valid"] = info.SessionValid
+				result["session_id_prefix"] = info.SessionIDPrefix
+				result["secret_path"] = info.SecretPath
+				result["secret_present"] = info.SecretPresent
+				if !info.DiskExpiration.IsZero() {
+					result["disk_expiration"] = info.DiskExpiration
+				}
+			}
+			return printJSON(result)
+		}
+
 		if isValid {
 			minutes := int(remaining.Minutes())
 			seconds := int(remaining.Seconds()) % 60
@@ -45,10 +79,50 @@ var cacheStatusCmd = &cobra.Command{
 		}
 
 		fmt.Printf("Cache timeout configured for: %d minutes\n", cfg.CacheTimeout)
+		if cacheStatusAll {
+			printCacheDiagnostics(cfg, passwordStore.CacheDiagnostics())
+		}
 		return nil
 	},
 }
 
+// printCacheDiagnostics prints the details shown by cache status --all
+func printCacheDiagnostics(cfg *config.Config, info cache.Diagnostics) {
+	yesNo := map[bool]string{true: "yes", false: "no"}
+
+	fmt.Printf("\nTimeout source: %s\n", cacheTimeoutSource(cfg))
+	fmt.Printf("In memory:      %s\n", yesNo[info.InMemory])
+
+	disk := yesNo[info.OnDisk]
+	if !info.DiskExpiration.IsZero() {
+		disk += fmt.Sprintf(", expires %s", info.DiskExpiration.Local().Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("On disk:        %s\n", disk)
+	if info.OnDisk {
+		if info.DiskReadable {
+			fmt.Println("Disk copy:      readable")
+		} else {
+			fmt.Printf("Disk copy:      not usable, %s\n", info.DiskProblem)
+		}
+	}
+
+	session := "none"
+	if info.SessionIDPrefix != "" {
+		session = info.SessionIDPrefix + "..., session file " + map[bool]string{true: "matches", false: "does not match"}[info.SessionValid]
+	}
+	fmt.Printf("Session:        %s\n", session)
+	fmt.Printf("Cache secret:   %s (%s)\n", info.SecretPath, map[bool]string{true: "present", false: "missing"}[info.SecretPresent])
+}
+
+// cacheTimeoutSource names where the configured cache timeout comes from.
+// cache timeout only changes it for its own process, so it never shows here.
+func cacheTimeoutSource(cfg *config.Config) string {
+	if value := os.Getenv("PASSWORD_STORE_CACHE_TIMEOUT"); value != "" && value == strconv.Itoa(cfg.CacheTimeout) {
+		return "PASSWORD_STORE_CACHE_TIMEOUT"
+	}
+	return "default"
+}
+
 var cacheClearCmd = &cobra.Command{
 	Use:   "clear",
 	Short: "Clear cached master password",
@@ -59,7 +133,7 @@ var cacheClearCmd = &cobra.Command{
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		passwordStore, err := store.NewWithGitConfig(cfg.StoreDir, cfg.CacheTimeout, cfg.GitURL, cfg.GitAutoSync)
+		passwordStore, err := store.NewFromConfig(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to initialize store: %w", err)
 		}
@@ -70,6 +144,45 @@ var cacheClearCmd = &cobra.Command{
 	},
 }
 
+var cacheRefreshCmd = &cobra.Command{
+	Use:   "refresh",
+	Short: "Extend the cached master password to a full timeout",
+	Long: `Reset the expiry of the cached master password to a full timeout from now,
+without entering it again, e.g. before a long task that would otherwise be
+interrupted by a prompt. This only works while the cached password is still
+valid; once it has expired, the next command asks for it as usual.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		refreshed := passwordStore.RefreshCache()
+		_, remaining := passwordStore.GetCacheStatus()
+		if jsonOutput {
+			if err := printJSON(map[string]interface{}{"refreshed": refreshed, "remaining_seconds": int(remaining.Seconds())}); err != nil {
+				return err
+			}
+			if !refreshed {
+				return &reportedError{fmt.Errorf("no valid cached master password to refresh")}
+			}
+			return nil
+		}
+
+		if !refreshed {
+			return fmt.Errorf("no valid cached master password to refresh; it is asked for on the next command")
+		}
+		fmt.Printf("Master password cached for another %d minutes\n", int(remaining.Round(time.Minute).Minutes()))
+		return nil
+	},
+}
+
 var cacheTimeoutCmd = &cobra.Command{
 	Use:   "timeout [minutes]",
 	Short: "Set cache timeout duration",
@@ -88,7 +201,7 @@ To permanently change the timeout, set the PASSWORD_STORE_CACHE_TIMEOUT environm
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		passwordStore, err := store.NewWithGitConfig(cfg.StoreDir, cfg.CacheTimeout, cfg.GitURL, cfg.GitAutoSync)
+		passwordStore, err := store.NewFromConfig(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to initialize store: %w", err)
 		}
@@ -110,5 +223,10 @@ func init() {
 	// Add subcommands to cache command
 	cacheCmd.AddCommand(cacheStatusCmd)
 	cacheCmd.AddCommand(cacheClearCmd)
+	cacheCmd.AddCommand(cacheRefreshCmd)
 	cacheCmd.AddCommand(cacheTimeoutCmd)
+
+	cacheStatusCmd.Flags().BoolVar(&cacheStatusAll, "all", false, "Also show memory and disk cache state, session and timeout source")
 }
+
+var cacheStatusAll bool
@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"fmt"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var commitCmd = &cobra.Command{
+	Use:   "commit",
+	Short: "Commit pending changes to Git",
+	Long: `Commit every pending change in the store as one commit, whether or not
+auto-sync is enabled. Nothing is pushed; use 'chowkidaar git push' for that.
+
+This pairs with --no-commit, which makes write commands leave their changes
+uncommitted. A script can then make many changes and record them once:
+
+  chowkidaar --no-commit insert ...
+  chowkidaar --no-commit remove ...
+  chowkidaar commit -m "Rotate team credentials"
+
+Changes made with --no-commit stay out of Git, and off other devices, until
+they are committed this way or by a later 'git push' or 'git sync'.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		count, err := passwordStore.CommitPending(commitMessage)
+		if err != nil {
+			return fmt.Errorf("failed to commit changes: %w", err)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]int{"committed": count})
+		}
+		if count == 0 {
+			fmt.Println("Nothing to commit")
+			return nil
+		}
+		fmt.Printf("Committed %s\n", plural(count, "change"))
+		return nil
+	},
+}
+
+var commitMessage string
+
+func init() {
+	commitCmd.Flags().StringVarP(&commitMessage, "message", "m", "Update password store", "Commit message")
+}
@@ -0,0 +1,53 @@
+package cli
+
+import (
+	"fmt"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var convertCmd = &cobra.Command{
+	Use:   "convert [pass-name]",
+	Short: "Convert a plain entry to a JSON entry",
+	Long: `Rewrite a plain entry as a structured JSON entry, like those stored with
+insert --json-entry.
+
+The first line becomes the password, a "username:" (or "user:", "login:") line
+the username, other "key: value" lines fields of the same name and any other
+lines the "notes" field. The plain version is kept in the entry's history.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passName := args[0]
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		if err := passwordStore.ConvertToJSON(passName, masterPassword); err != nil {
+			return fmt.Errorf("failed to convert password: %w", err)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]string{"name": passName, "status": "converted"})
+		}
+		fmt.Printf("'%s' converted to a JSON entry\n", passName)
+		return nil
+	},
+}
@@ -0,0 +1,70 @@
+package cli
+
+import (
+	"fmt"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var describeCmd = &cobra.Command{
+	Use:   "describe [folder] [description]",
+	Short: "Describe a folder, shown by list --descriptions",
+	Long: `Attach a one-line description to a folder, e.g.
+chowkidaar describe Work "corporate accounts", so that
+chowkidaar list --descriptions shows "Work (corporate accounts)".
+
+Without a description the current one is printed; an empty description ("")
+removes it. Descriptions are stored unencrypted in a .desc file in the folder
+and synced with Git like the entries, so they are not available in stores
+that hide their names. No master password is needed.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := args[0]
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		if len(args) == 1 {
+			description, err := passwordStore.GetDirDescription(dir)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(map[string]string{"folder": dir, "description": description})
+			}
+			if description == "" {
+				fmt.Printf("Folder '%s' has no description\n", dir)
+			} else {
+				fmt.Println(description)
+			}
+			return nil
+		}
+
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+		if err := passwordStore.SetDirDescription(dir, args[1]); err != nil {
+			return fmt.Errorf("failed to describe folder: %w", err)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]string{"folder": dir, "description": args[1]})
+		}
+		if args[1] == "" {
+			fmt.Printf("Description of '%s' removed\n", dir)
+		} else {
+			fmt.Printf("Folder '%s' described\n", dir)
+		}
+		return nil
+	},
+}
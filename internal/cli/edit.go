@@ -1,7 +1,9 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"os"
 
 	"chowkidaar/internal/config"
 	"chowkidaar/internal/store"
@@ -15,6 +17,17 @@ var editCmd = &cobra.Command{
 	Long: `Insert a new password or edit an existing password using your default editor.
 The password will be encrypted and stored in the password store.
 
+Use --editor to pick a different editor for one edit, e.g. --editor nano or
+--editor "code --wait". Like git, the editor is run by the shell, so paths with
+spaces may be quoted. The default is $EDITOR.
+
+A name that does not exist yet creates a new password; edit says so before
+the editor opens, so a typo is noticed. Use --no-create to fail instead.
+Closing the editor on an empty new password saves nothing.
+
+A new password in a missing folder follows PASSWORD_STORE_CREATE_DIRS and the
+--parents and --no-create-dirs flags as for insert.
+
 The master password will be cached for 5 minutes (configurable) to avoid repeated prompts.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -25,22 +38,69 @@ The master password will be cached for 5 minutes (configurable) to avoid repeate
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		passwordStore, err := store.NewWithGitConfig(cfg.StoreDir, cfg.CacheTimeout, cfg.GitURL, cfg.GitAutoSync)
+		passwordStore, err := store.NewFromConfig(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to initialize store: %w", err)
 		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		// A partial name that matches nothing creates a new password
+		resolved, err := resolveName(passwordStore, passName)
+		var notFound *store.NotFoundError
+		if err != nil && !errors.As(err, &notFound) {
+			return err
+		}
+		isNew := err != nil
+		if !isNew {
+			passName = resolved
+		} else if editNoCreate {
+			return err
+		} else if ok, err := allowNewFolder(cfg, passwordStore, passName); err != nil || !ok {
+			if err == nil {
+				fmt.Fprintln(os.Stderr, "Edit cancelled.")
+			}
+			return err
+		}
+		if isNew {
+			fmt.Fprintf(os.Stderr, "Creating new entry '%s'\n", passName)
+		}
 
 		// Prompt for master password
-		masterPassword, err := passwordStore.PromptMasterPassword("Enter master password: ")
+		masterPassword, err := promptMasterPassword(passwordStore)
 		if err != nil {
 			return fmt.Errorf("failed to read master password: %w", err)
 		}
 
-		if err := passwordStore.Edit(passName, masterPassword, cfg.Editor); err != nil {
+		editor := cfg.Editor
+		if editorOverride != "" {
+			editor = editorOverride
+		}
+
+		if err := passwordStore.Edit(passName, masterPassword, editor); err != nil {
 			return fmt.Errorf("failed to edit password: %w", err)
 		}
 
-		fmt.Printf("Password for '%s' updated successfully\n", passName)
+		status := "updated"
+		if isNew {
+			status = "created"
+		}
+		if jsonOutput {
+			return printJSON(map[string]string{"name": passName, "status": status})
+		}
+
+		fmt.Printf("Password for '%s' %s successfully\n", passName, status)
 		return nil
 	},
 }
+
+var (
+	editorOverride string
+	editNoCreate   bool
+)
+
+func init() {
+	editCmd.Flags().StringVar(&editorOverride, "editor", "", "Editor command to use instead of $EDITOR for this edit")
+	editCmd.Flags().BoolVar(&editNoCreate, "no-create", false, "Fail if the password does not exist instead of creating it")
+}
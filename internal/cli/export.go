@@ -0,0 +1,255 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [file]",
+	Short: "Export the store for another device or password manager",
+	Long: `Write the whole store to a new file in one of these formats:
+
+  archive      encrypted entries and history in a tar.gz (default); only
+               readable with this store's keyfile and master password
+  json         plaintext JSON array of {name, password, username, fields}
+  keepass-csv  plaintext CSV with KeePassXC's Group, Title, Username,
+               Password, URL and Notes columns
+
+The plaintext formats decrypt every entry, so they ask for confirmation
+unless --yes is given. Delete such a file as soon as it has been imported.
+The file, given as an argument or with --out (-o) as for show, must not
+exist yet and is created readable only by you. It cannot be written inside
+the store, where Git could commit it.
+
+With --sizes the export also reports how many bytes the entries of each
+folder take, decrypted and encrypted on disk, largest first. This shows
+which entries hold large blobs that bloat the Git repository. The entries
+are decrypted in memory for it, so --sizes works with the archive format,
+and without a file it only prints the report and writes nothing.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputPath := exportOut
+		if len(args) > 0 {
+			if exportOut != "" {
+				return fmt.Errorf("give the export file as an argument or with --out, not both")
+			}
+			outputPath = args[0]
+		}
+		if outputPath == "" && !exportSizes {
+			return fmt.Errorf("missing export file, give it as an argument or with --out")
+		}
+
+		if !slices.Contains(store.ExportFormats, exportFormat) {
+			return fmt.Errorf("unknown export format '%s' (available: %s)", exportFormat, strings.Join(store.ExportFormats, ", "))
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		if outputPath == "" {
+			entries, err := exportEntries(cmd, passwordStore)
+			if err != nil {
+				return err
+			}
+			return printExported("", len(entries), store.FolderSizes(entries))
+		}
+		if err := checkExportPath(cfg.StoreDir, outputPath); err != nil {
+			return err
+		}
+
+		if exportFormat == store.ExportArchive {
+			// Sizes come first so a wrong master password writes nothing
+			var sizes []store.FolderSize
+			if exportSizes {
+				entries, err := exportEntries(cmd, passwordStore)
+				if err != nil {
+					return err
+				}
+				sizes = store.FolderSizes(entries)
+			}
+			count, err := passwordStore.ExportArchive(outputPath)
+			if err != nil {
+				return fmt.Errorf("failed to export store: %w", err)
+			}
+			return printExported(outputPath, count, sizes)
+		}
+
+		if !assumeYes && jsonOutput {
+			return fmt.Errorf("refusing to prompt for confirmation in JSON mode, use --yes")
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %s will hold every password in plaintext.\n", outputPath)
+		if !confirm("Write an unencrypted export?") {
+			fmt.Fprintln(os.Stderr, "Export cancelled.")
+			return nil
+		}
+
+		entries, err := exportEntries(cmd, passwordStore)
+		if err != nil {
+			return err
+		}
+
+		if err := writePlaintextExport(outputPath, entries); err != nil {
+			return err
+		}
+		var sizes []store.FolderSize
+		if exportSizes {
+			sizes = store.FolderSizes(entries)
+		}
+		return printExported(outputPath, len(entries), sizes)
+	},
+}
+
+// exportEntries asks for the master password and decrypts every entry in
+// memory
+func exportEntries(cmd *cobra.Command, passwordStore *store.Store) ([]store.ExportedEntry, error) {
+	masterPassword, err := promptMasterPassword(passwordStore)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	ctx, stop := interruptContext(cmd)
+	defer stop()
+	progress, finish := newProgress()
+	passwordStore.SetProgress(progress)
+
+	entries, err := passwordStore.ExportEntries(ctx, masterPassword)
+	finish()
+	if errors.Is(err, context.Canceled) {
+		return nil, fmt.Errorf("export interrupted, nothing written")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to export store: %w", err)
+	}
+	return entries, nil
+}
+
+// checkExportPath refuses an export inside the store directory
+func checkExportPath(storeDir, outputPath string) error {
+	absStore, err := filepath.Abs(storeDir)
+	if err != nil {
+		return err
+	}
+	absOutput, err := filepath.Abs(outputPath)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(absStore, absOutput)
+	if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("refusing to export into the store directory %s, choose a path outside it", storeDir)
+	}
+	return nil
+}
+
+// writePlaintextExport writes entries in the selected plaintext format to a
+// new 0600 file, removing it again if writing fails
+func writePlaintextExport(outputPath string, entries []store.ExportedEntry) error {
+	file, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create export file: %w", err)
+	}
+
+	if exportFormat == store.ExportKeePassCSV {
+		err = store.WriteKeePassCSV(file, entries)
+	} else {
+		err = store.WriteExportJSON(file, entries)
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("failed to write export file: %w", err)
+	}
+	return nil
+}
+
+// printExported reports how many entries an export holds, and with --sizes
+// how large they are. Without an outputPath only the sizes are reported.
+func printExported(outputPath string, count int, sizes []store.FolderSize) error {
+	if jsonOutput {
+		result := map[string]interface{}{"entries": count}
+		if outputPath != "" {
+			result["file"] = outputPath
+			result["format"] = exportFormat
+		}
+		if exportSizes {
+			result["sizes"] = sizes
+		}
+		return printJSON(result)
+	}
+
+	if outputPath != "" {
+		fmt.Printf("Exported %s to %s (%s)\n", plural(count, "password"), outputPath, exportFormat)
+		if exportSizes {
+			fmt.Println()
+		}
+	}
+	if exportSizes {
+		printFolderSizes(sizes)
+	}
+	return nil
+}
+
+// printFolderSizes prints a table of folder sizes followed by their total
+func printFolderSizes(sizes []store.FolderSize) {
+	total := store.FolderSize{Folder: "Total"}
+	fmt.Printf("%-30s %8s %10s %10s\n", "Folder", "Entries", "Decrypted", "On disk")
+	for _, size := range sizes {
+		folder := size.Folder
+		if folder == "" {
+			folder = "(top level)"
+		}
+		fmt.Printf("%-30s %8d %10s %10s\n", folder, size.Entries, formatBytes(size.Size), formatBytes(size.DiskSize))
+		total.Entries += size.Entries
+		total.Size += size.Size
+		total.DiskSize += size.DiskSize
+	}
+	fmt.Printf("%-30s %8d %10s %10s\n", total.Folder, total.Entries, formatBytes(total.Size), formatBytes(total.DiskSize))
+}
+
+// formatBytes formats a byte count such as "512 B" or "1.5 KiB"
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	value := float64(n) / unit
+	for _, suffix := range []string{"KiB", "MiB"} {
+		if value < unit {
+			return fmt.Sprintf("%.1f %s", value, suffix)
+		}
+		value /= unit
+	}
+	return fmt.Sprintf("%.1f GiB", value)
+}
+
+var (
+	exportFormat string
+	exportSizes  bool
+	exportOut    string
+)
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "format", store.ExportArchive, "Export format: archive, json or keepass-csv")
+	exportCmd.Flags().StringVarP(&exportOut, "out", "o", "", "File to export to, instead of the argument")
+	exportCmd.Flags().BoolVar(&exportSizes, "sizes", false, "Report the decrypted and on-disk size of each folder")
+}
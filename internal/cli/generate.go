@@ -0,0 +1,214 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"chowkidaar/internal/clipboard"
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var generateCmd = &cobra.Command{
+	Use:   "generate [pass-name]",
+	Short: "Generate and store a new random password",
+	Long: `Generate a random password, store it as pass-name and print it. With
+--clip it is copied to the clipboard instead of printed.
+
+With --batch, a password is generated for every name in a file, one name
+per line; blank lines and lines starting with # are skipped. The master
+password is entered once, each password is drawn separately, and all new
+entries are committed together. The generated passwords are printed as a
+table of name and password, so redirect it somewhere safe. --clip cannot be
+used with --batch.
+
+An existing entry is never overwritten. In a batch it fails the whole batch
+before anything is stored, unless --skip-existing is given to leave it alone
+and generate the rest.
+
+Missing folders follow PASSWORD_STORE_CREATE_DIRS as for insert: a batch
+asks once about all of them, and --parents (-p) or --no-create-dirs
+overrides the setting.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if generateBatch != "" {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.ExactArgs(1)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if generateBatch != "" && generateClip {
+			return fmt.Errorf("--clip cannot be used with --batch")
+		}
+		if generateSkipExisting && generateBatch == "" {
+			return fmt.Errorf("--skip-existing needs --batch")
+		}
+		if generateLength <= 0 {
+			return fmt.Errorf("--length must be positive")
+		}
+
+		var names []string
+		if generateBatch != "" {
+			var err error
+			if names, err = readNameFile(generateBatch); err != nil {
+				return err
+			}
+			if len(names) == 0 {
+				return fmt.Errorf("no names in %s", generateBatch)
+			}
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+		if generateBatch == "" {
+			names = args
+		}
+		if ok, err := allowNewFolder(cfg, passwordStore, names...); err != nil || !ok {
+			if err == nil {
+				fmt.Fprintln(os.Stderr, "Generate cancelled.")
+			}
+			return err
+		}
+
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		if generateBatch != "" {
+			return generateNames(passwordStore, names, masterPassword)
+		}
+
+		passName := args[0]
+		password, err := passwordStore.Generate(passName, generateLength, generateNoSymbols, false, masterPassword)
+		if err != nil {
+			return err
+		}
+
+		if generateClip {
+			if err := clipboard.Copy(password); err != nil {
+				return fmt.Errorf("password for '%s' was stored but could not be copied to clipboard: %w", passName, err)
+			}
+			if jsonOutput {
+				return printJSON(map[string]string{"name": passName, "status": "generated", "clipboard": "copied"})
+			}
+			fmt.Printf("Password for '%s' generated and copied to clipboard\n", passName)
+			return nil
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]string{"name": passName, "status": "generated", "password": password})
+		}
+		fmt.Printf("Password for '%s' generated:\n%s\n", passName, password)
+		return nil
+	},
+}
+
+// generateNames runs a batch generate and prints the table of new passwords
+func generateNames(passwordStore *store.Store, names []string, masterPassword string) error {
+	opts := store.GenerateOptions{
+		Length:       generateLength,
+		NoSymbols:    generateNoSymbols,
+		SkipExisting: generateSkipExisting,
+	}
+	generated, err := passwordStore.GenerateBatch(names, opts, masterPassword)
+	var batchErr *store.BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		return fmt.Errorf("failed to generate passwords: %w", err)
+	}
+
+	var failures []string
+	if batchErr != nil {
+		for _, failure := range batchErr.Failures {
+			failures = append(failures, failure.Err.Error())
+		}
+	}
+	skipped := len(names) - len(generated) - len(failures)
+
+	if jsonOutput {
+		rows := make([]map[string]string, 0, len(generated))
+		for _, entry := range generated {
+			rows = append(rows, map[string]string{"name": entry.Name, "password": entry.Password})
+		}
+		if failures == nil {
+			failures = []string{}
+		}
+		if err := printJSON(map[string]interface{}{"generated": rows, "skipped": skipped, "errors": failures}); err != nil {
+			return err
+		}
+		if len(failures) > 0 {
+			return &reportedError{batchErr}
+		}
+		return nil
+	}
+
+	width := 0
+	for _, entry := range generated {
+		width = max(width, len(entry.Name))
+	}
+	for _, entry := range generated {
+		fmt.Printf("%-*s  %s\n", width, entry.Name, entry.Password)
+	}
+	for _, failure := range failures {
+		fmt.Fprintf(os.Stderr, "Failed: %s\n", failure)
+	}
+	fmt.Fprintf(os.Stderr, "Generated %s, %d skipped\n", plural(len(generated), "password"), skipped)
+	if len(failures) > 0 {
+		return batchErr
+	}
+	return nil
+}
+
+// readNameFile reads entry names from a file, one per line, skipping blank
+// lines and # comments
+func readNameFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	var names []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		name := strings.TrimSpace(scanner.Text())
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		names = append(names, name)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return names, nil
+}
+
+var (
+	generateLength       int
+	generateNoSymbols    bool
+	generateClip         bool
+	generateBatch        string
+	generateSkipExisting bool
+)
+
+func init() {
+	generateCmd.Flags().IntVarP(&generateLength, "length", "l", 20, "Length of the generated password")
+	generateCmd.Flags().BoolVarP(&generateNoSymbols, "no-symbols", "n", false, "Use only letters and digits")
+	generateCmd.Flags().BoolVarP(&generateClip, "clip", "c", false, "Copy the password to the clipboard instead of printing it")
+	generateCmd.Flags().StringVar(&generateBatch, "batch", "", "Generate a password for every name in this file, one per line")
+	generateCmd.Flags().BoolVar(&generateSkipExisting, "skip-existing", false, "With --batch, skip names that already exist instead of failing")
+}
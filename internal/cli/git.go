@@ -1,12 +1,24 @@
 package cli
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"slices"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"chowkidaar/internal/config"
 	"chowkidaar/internal/gitsync"
+	"chowkidaar/internal/hooks"
+	"chowkidaar/internal/list"
+	"chowkidaar/internal/notify"
+	"chowkidaar/internal/store"
 
+	gogit "github.com/go-git/go-git/v5"
 	"github.com/spf13/cobra"
 )
 
@@ -20,73 +32,140 @@ similar to the Unix 'pass' password manager.
 Available commands:
   status  - Show Git repository status
   push    - Push changes to remote repository  
-  pull    - Pull changes from remote repository
-  sync    - Pull then push (full synchronization)`,
+  pull    - Pull changes from remote repository (--force resets to it)
+  sync    - Pull then push (full synchronization)
+  watch   - Pull periodically until interrupted
+  log     - Show the commit history, optionally of one entry
+  set-url - Change the remote repository URL`,
 }
 
 var gitStatusCmd = &cobra.Command{
 	Use:   "status",
 	Short: "Show Git repository status",
-	Long:  `Display the current Git status of the password store, showing modified, added, and deleted files.`,
+	Long: `Display the current Git status of the password store, showing modified, added, and deleted files.
+
+--porcelain prints one sorted line per changed file for scripts and status
+bars, e.g. "M Email/gmail", "A Bank/chase" or "D Old/thing", without .enc,
+colors or a summary. New files count as added whether or not they are staged.
+
+With --exit-code the command exits with status 1 when there are changes and 0
+when the store is clean.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.Load()
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		gitSync := gitsync.NewGitSync(cfg.StoreDir, cfg.GitURL)
+		gitSync := newGitSync(cfg)
 
 		if !gitSync.IsGitEnabled() {
 			return fmt.Errorf("Git is not initialized for this password store. Run 'chowkidaar init --git-url <url>' to enable Git sync")
 		}
+		warnRemoteDrift(gitSync)
 
 		status, err := gitSync.Status()
 		if err != nil {
 			return fmt.Errorf("failed to get Git status: %w", err)
 		}
 
-		if len(status) == 0 {
-			fmt.Println("Working tree clean - no changes to commit")
-			return nil
+		if err := printGitStatus(gitSync, status); err != nil {
+			return err
 		}
+		if statusExitCode && len(status) > 0 {
+			cmd.SilenceUsage = true
+			return &reportedError{fmt.Errorf("the password store has uncommitted changes")}
+		}
+		return nil
+	},
+}
 
-		fmt.Println("Changes in password store:")
+// printGitStatus prints the changed files as JSON, porcelain lines or text
+func printGitStatus(gitSync *gitsync.GitSync, status gogit.Status) error {
+	if jsonOutput {
+		changes := make(map[string]string, len(status))
 		for file, fileStatus := range status {
-			var statusStr string
-			switch {
-			case fileStatus.Staging != 0:
-				statusStr = "staged"
-			case fileStatus.Worktree != 0:
-				statusStr = "modified"
-			default:
-				statusStr = "unknown"
-			}
-
-			// Remove .enc extension for cleaner output
-			displayName := strings.TrimSuffix(file, ".enc")
-			fmt.Printf("  %s: %s\n", statusStr, displayName)
+			changes[strings.TrimSuffix(file, ".enc")] = string([]byte{byte(fileStatus.Staging), byte(fileStatus.Worktree)})
+		}
+		return printJSON(map[string]interface{}{
+			"clean":   len(status) == 0,
+			"changes": changes,
+			"remote":  gitSync.GetRemoteURL(),
+		})
+	}
+
+	if statusPorcelain {
+		files := make([]string, 0, len(status))
+		for file := range status {
+			files = append(files, file)
 		}
+		sort.Strings(files)
+		for _, file := range files {
+			fmt.Printf("%c %s\n", porcelainCode(status[file]), strings.TrimSuffix(file, ".enc"))
+		}
+		return nil
+	}
 
-		fmt.Printf("\nRemote repository: %s\n", gitSync.GetRemoteURL())
+	if len(status) == 0 {
+		fmt.Println("Working tree clean - no changes to commit")
 		return nil
-	},
+	}
+
+	fmt.Println("Changes in password store:")
+	for file, fileStatus := range status {
+		var statusStr string
+		switch {
+		case fileStatus.Staging != 0:
+			statusStr = "staged"
+		case fileStatus.Worktree != 0:
+			statusStr = "modified"
+		default:
+			statusStr = "unknown"
+		}
+
+		// Remove .enc extension for cleaner output
+		displayName := strings.TrimSuffix(file, ".enc")
+		fmt.Printf("  %s: %s\n", statusStr, displayName)
+	}
+
+	fmt.Printf("\nRemote repository: %s\n", gitSync.GetRemoteURL())
+	return nil
+}
+
+// porcelainCode sums up a file's status in one letter, preferring the staged
+// change. Untracked files are reported as added.
+func porcelainCode(fileStatus *gogit.FileStatus) byte {
+	code := fileStatus.Staging
+	if code == gogit.Unmodified || code == gogit.Untracked {
+		code = fileStatus.Worktree
+	}
+	if code == gogit.Untracked {
+		return byte(gogit.Added)
+	}
+	return byte(code)
 }
 
 var gitPushCmd = &cobra.Command{
 	Use:   "push",
 	Short: "Push changes to remote repository",
-	Long:  `Commit any local changes and push them to the remote Git repository.`,
+	Long: `Commit any local changes and push them to the remote Git repository.
+
+With --all they are also pushed to every backup mirror listed in
+PASSWORD_STORE_GIT_MIRRORS (or "mirrors" in .git-config), each with its own
+credentials, and the outcome for each remote is reported. A mirror that
+fails does not stop the others, but makes the command fail. Pull always
+uses origin.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.Load()
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		gitSync := gitsync.NewGitSync(cfg.StoreDir, cfg.GitURL)
+		gitSync := newGitSync(cfg)
 
 		if !gitSync.IsGitEnabled() {
 			return fmt.Errorf("Git is not initialized for this password store. Run 'chowkidaar init --git-url <url>' to enable Git sync")
 		}
+		warnRemoteDrift(gitSync)
 
 		// Check if there are any changes to commit
 		status, err := gitSync.Status()
@@ -94,7 +173,16 @@ var gitPushCmd = &cobra.Command{
 			return fmt.Errorf("failed to get Git status: %w", err)
 		}
 
-		if len(status) > 0 {
+		if pushAll {
+			if len(status) > 0 {
+				if err := gitSync.Commit("Update password store"); err != nil {
+					return fmt.Errorf("failed to commit changes: %w", err)
+				}
+			}
+			if err := pushToAll(gitSync); err != nil {
+				return err
+			}
+		} else if len(status) > 0 {
 			// Commit changes with a generic message
 			if err := gitSync.CommitAndPushChanges("Update password store"); err != nil {
 				return fmt.Errorf("failed to commit and push changes: %w", err)
@@ -106,6 +194,7 @@ var gitPushCmd = &cobra.Command{
 			}
 		}
 
+		hooks.Run(cfg.Hooks, hooks.PostSync)
 		return nil
 	},
 }
@@ -113,23 +202,47 @@ var gitPushCmd = &cobra.Command{
 var gitPullCmd = &cobra.Command{
 	Use:   "pull",
 	Short: "Pull changes from remote repository",
-	Long:  `Pull and merge changes from the remote Git repository into the local password store.`,
+	Long: `Pull and merge changes from the remote Git repository into the local password store.
+
+With --force the store is made to match the remote instead: it fetches and
+hard-resets to the remote branch, discarding local commits and uncommitted
+changes to tracked entries. Files Git does not track, like the keyfile, are
+kept. This asks for confirmation unless --yes is given; copy the store
+directory first if anything local might still be needed.
+
+With --verify, or always when PASSWORD_STORE_GIT_VERIFY_PULL is set, every
+incoming commit must be signed by a key in the key ring named by
+PASSWORD_STORE_GIT_TRUSTED_KEYS. The pull is aborted before anything is
+merged if one is not, so a compromised remote cannot slip in entries under
+unsigned commits. This also applies to --force.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.Load()
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		gitSync := gitsync.NewGitSync(cfg.StoreDir, cfg.GitURL)
+		gitSync := newGitSync(cfg)
 
 		if !gitSync.IsGitEnabled() {
 			return fmt.Errorf("Git is not initialized for this password store. Run 'chowkidaar init --git-url <url>' to enable Git sync")
 		}
+		warnRemoteDrift(gitSync)
+
+		if err := setupPullVerification(cfg, gitSync, pullVerify); err != nil {
+			return err
+		}
 
+		if pullForce {
+			return forcePull(cfg, gitSync)
+		}
+
+		before, _ := gitSync.HeadCommit()
 		if err := gitSync.Pull(); err != nil {
 			return fmt.Errorf("failed to pull changes: %w", err)
 		}
 
+		hooks.Run(cfg.Hooks, hooks.PostSync)
+		notifySync(cfg, gitSync, before, true)
 		return nil
 	},
 }
@@ -149,14 +262,19 @@ This ensures your local store is up-to-date and your changes are backed up.`,
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		gitSync := gitsync.NewGitSync(cfg.StoreDir, cfg.GitURL)
+		gitSync := newGitSync(cfg)
 
 		if !gitSync.IsGitEnabled() {
 			return fmt.Errorf("Git is not initialized for this password store. Run 'chowkidaar init --git-url <url>' to enable Git sync")
 		}
+		warnRemoteDrift(gitSync)
+		if err := setupPullVerification(cfg, gitSync, false); err != nil {
+			return err
+		}
 
 		// Step 1: Pull changes from remote
 		fmt.Println("Step 1: Pulling changes from remote...")
+		before, _ := gitSync.HeadCommit()
 		if err := gitSync.Pull(); err != nil {
 			return fmt.Errorf("failed to pull changes: %w", err)
 		}
@@ -178,14 +296,475 @@ This ensures your local store is up-to-date and your changes are backed up.`,
 		}
 
 		fmt.Println("Synchronization completed successfully!")
+		hooks.Run(cfg.Hooks, hooks.PostSync)
+		notifySync(cfg, gitSync, before, true)
 		return nil
 	},
 }
 
 func init() {
+	gitCmd.PersistentFlags().DurationVar(&gitTimeout, "timeout", 0, "Limit for each network operation, e.g. 10s or 2m, 0 for no limit (defaults to PASSWORD_STORE_GIT_TIMEOUT or 30s)")
+
 	// Add subcommands to git command
 	gitCmd.AddCommand(gitStatusCmd)
 	gitCmd.AddCommand(gitPushCmd)
 	gitCmd.AddCommand(gitPullCmd)
 	gitCmd.AddCommand(gitSyncCmd)
+	gitCmd.AddCommand(gitSetURLCmd)
+	gitCmd.AddCommand(gitWatchCmd)
+	gitCmd.AddCommand(gitLogCmd)
+
+	gitStatusCmd.Flags().BoolVar(&statusPorcelain, "porcelain", false, "Print one stable 'M name' line per change for scripts")
+	gitStatusCmd.Flags().BoolVar(&statusExitCode, "exit-code", false, "Exit with status 1 when there are uncommitted changes")
+	gitPushCmd.Flags().BoolVar(&pushAll, "all", false, "Also push to every configured backup mirror")
+	gitPullCmd.Flags().BoolVar(&pullForce, "force", false, "Discard local changes and reset the store to the remote branch")
+	gitPullCmd.Flags().BoolVar(&pullVerify, "verify", false, "Abort unless every incoming commit is signed by a trusted key")
+	gitLogCmd.Flags().BoolVar(&logReverse, "reverse", false, "Show the oldest commits first")
+	gitLogCmd.Flags().IntVarP(&logLimit, "limit", "n", 0, "Show only the newest N matching commits (0 for all)")
+	gitLogCmd.Flags().StringVar(&logSince, "since", "", "Only commits after this time (e.g. 2024-05-01, 30d)")
+	gitLogCmd.Flags().StringVar(&logUntil, "until", "", "Only commits before this time (e.g. 2024-06-01, 7d)")
+	gitWatchCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Minute, "Time between pulls, e.g. 30s or 5m")
+}
+
+var gitSetURLCmd = &cobra.Command{
+	Use:   "set-url [new-url]",
+	Short: "Change the remote repository URL",
+	Long: `Point the password store at a new remote repository, for example after an
+organization rename or when switching from HTTPS to SSH. Both the repository's
+origin and the saved chowkidaar configuration are updated.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		newURL := args[0]
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		gitSync := newGitSync(cfg)
+
+		if !gitSync.IsGitEnabled() {
+			return fmt.Errorf("Git is not initialized for this password store. Run 'chowkidaar init --git-url <url>' to enable Git sync")
+		}
+
+		oldURL := gitSync.GetRemoteURL()
+		if err := gitSync.SetRemoteURL(newURL); err != nil {
+			return fmt.Errorf("failed to set remote URL: %w", err)
+		}
+
+		cfg.GitURL = newURL
+		if err := cfg.SaveGitConfig(); err != nil {
+			return fmt.Errorf("failed to save Git configuration: %w", err)
+		}
+		if os.Getenv("PASSWORD_STORE_GIT_URL") != "" {
+			fmt.Fprintln(os.Stderr, "Warning: PASSWORD_STORE_GIT_URL is set and overrides the saved remote URL")
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]string{"remote": newURL, "previous": oldURL})
+		}
+
+		fmt.Printf("Remote URL changed to %s\n", newURL)
+		return nil
+	},
+}
+
+var (
+	pullForce  bool
+	pullVerify bool
+)
+
+var pushAll bool
+
+// pushToAll pushes to origin and every mirror and reports each remote
+func pushToAll(gitSync *gitsync.GitSync) error {
+	results, err := gitSync.PushAll()
+	if err != nil {
+		return fmt.Errorf("failed to push changes: %w", err)
+	}
+
+	failed := 0
+	fmt.Println()
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			fmt.Printf("%-8s %s: failed: %v\n", result.Remote, result.URL, result.Err)
+		} else {
+			fmt.Printf("%-8s %s: ok\n", result.Remote, result.URL)
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("push failed for %d of %d remotes", failed, len(results))
+	}
+	return nil
+}
+
+var (
+	statusPorcelain bool
+	statusExitCode  bool
+)
+
+// forcePull resets the store to its remote after confirmation, listing the
+// local changes and commits that will be lost
+func forcePull(cfg *config.Config, gitSync *gitsync.GitSync) error {
+	if !assumeYes && jsonOutput {
+		return fmt.Errorf("refusing to prompt for confirmation in JSON mode, use --yes")
+	}
+
+	status, err := gitSync.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get Git status: %w", err)
+	}
+	ahead, _, err := gitSync.AheadBehind()
+	if err != nil {
+		ahead = 0
+	}
+
+	changed := 0
+	for _, fileStatus := range status {
+		if fileStatus.Worktree != gogit.Untracked {
+			changed++
+		}
+	}
+	fmt.Fprintf(os.Stderr, "This discards %d uncommitted change(s) and %d local commit(s) not on the remote.\n", changed, ahead)
+	fmt.Fprintf(os.Stderr, "Copy %s first if anything local might still be needed.\n", cfg.StoreDir)
+	if !confirm("Reset the store to the remote?") {
+		fmt.Fprintln(os.Stderr, "Reset cancelled.")
+		return nil
+	}
+
+	before, _ := gitSync.HeadCommit()
+	if err := gitSync.ResetToRemote(); err != nil {
+		return fmt.Errorf("failed to reset to remote: %w", err)
+	}
+
+	hooks.Run(cfg.Hooks, hooks.PostSync)
+	notifySync(cfg, gitSync, before, true)
+	if jsonOutput {
+		after, _ := gitSync.HeadCommit()
+		return printJSON(map[string]string{"status": "reset", "previous": before, "head": after})
+	}
+	return nil
+}
+
+var gitTimeout time.Duration
+
+// newGitSync creates a GitSync for the store with the configured commit signing
+func newGitSync(cfg *config.Config) *gitsync.GitSync {
+	gitSync := gitsync.NewGitSync(cfg.StoreDir, cfg.GitURL)
+	gitSync.SetSigningKey(cfg.GitSignKey, cfg.GitSignStrict)
+	gitSync.SetMirrors(cfg.GitMirrors)
+	gitSync.SetTimeout(cfg.GitTimeout)
+	gitSync.SetAllowPlaintext(allowPlaintext)
+
+	// An explicit --timeout wins, and --timeout 0 disables the limit
+	if gitCmd.PersistentFlags().Changed("timeout") {
+		gitSync.SetTimeout(gitTimeout)
+	}
+	return gitSync
+}
+
+// setupPullVerification loads the trusted keys when pulls are verified,
+// because the configuration requires it or required is set by --verify
+func setupPullVerification(cfg *config.Config, gitSync *gitsync.GitSync, required bool) error {
+	if !required && !cfg.GitVerifyPull {
+		return nil
+	}
+	if cfg.GitTrustedKeys == "" {
+		return fmt.Errorf("verifying pulled commits needs trusted keys, set PASSWORD_STORE_GIT_TRUSTED_KEYS to a public key ring")
+	}
+
+	keys, err := gitsync.LoadTrustedKeys(cfg.GitTrustedKeys)
+	if err != nil {
+		return err
+	}
+	gitSync.SetVerifyKeys(keys)
+	return nil
+}
+
+// warnRemoteDrift tells the user when the configured remote URL no longer
+// matches the repository's origin
+func warnRemoteDrift(gitSync *gitsync.GitSync) {
+	if configured, drifted := gitSync.RemoteURLDrift(); drifted {
+		fmt.Fprintf(os.Stderr, "Warning: configured remote %s differs from repository origin %s, using origin. Run 'chowkidaar git set-url' to change it.\n", configured, gitSync.GetRemoteURL())
+	}
+}
+
+var watchInterval time.Duration
+
+var gitWatchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Pull changes periodically until interrupted",
+	Long: `Keep the store fresh on an always-on device by pulling from the remote
+every --interval until interrupted with Ctrl+C. New changes are reported as
+they arrive and run the post_sync hook.
+
+A pull still running when the next one is due is not started twice, and
+repeated failures back off up to 16 times the interval. With
+PASSWORD_STORE_NOTIFICATIONS=true, new changes also show a desktop
+notification.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if watchInterval <= 0 {
+			return fmt.Errorf("--interval must be positive")
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		gitSync := newGitSync(cfg)
+
+		if !gitSync.IsGitEnabled() {
+			return fmt.Errorf("Git is not initialized for this password store. Run 'chowkidaar init --git-url <url>' to enable Git sync")
+		}
+		warnRemoteDrift(gitSync)
+		if err := setupPullVerification(cfg, gitSync, false); err != nil {
+			return err
+		}
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		fmt.Printf("Watching %s every %s, press Ctrl+C to stop\n", gitSync.GetRemoteURL(), watchInterval)
+
+		results := make(chan error, 1)
+		running := false
+		startPull := func() {
+			running = true
+			go func() { results <- watchPull(cfg, gitSync) }()
+		}
+
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+
+		failures := 0
+		var retryAt time.Time
+		startPull()
+		for {
+			select {
+			case <-ctx.Done():
+				if running {
+					fmt.Println("Waiting for the current pull to finish...")
+					<-results
+				}
+				fmt.Println("Stopped watching.")
+				return nil
+
+			case err := <-results:
+				running = false
+				if err == nil {
+					failures, retryAt = 0, time.Time{}
+					continue
+				}
+				failures++
+				backoff := watchInterval << min(failures-1, 4)
+				retryAt = time.Now().Add(backoff)
+				watchLog("Pull failed: %v (retrying in %s)", err, backoff)
+
+			case <-ticker.C:
+				switch {
+				case running:
+					watchLog("Previous pull still running, skipping")
+				case time.Now().Before(retryAt):
+					// Backing off after failures
+				default:
+					startPull()
+				}
+			}
+		}
+	},
+}
+
+// watchPull pulls once and reports whether new commits arrived
+func watchPull(cfg *config.Config, gitSync *gitsync.GitSync) error {
+	before, _ := gitSync.HeadCommit()
+	if err := gitSync.Pull(); err != nil {
+		return err
+	}
+
+	after, err := gitSync.HeadCommit()
+	if err != nil {
+		return err
+	}
+	if after != before {
+		watchLog("New changes pulled (now at %s)", after[:8])
+		hooks.Run(cfg.Hooks, hooks.PostSync)
+		notifySync(cfg, gitSync, before, false)
+	}
+	return nil
+}
+
+// maxNotifiedNames is how many entry names a sync notification lists
+const maxNotifiedNames = 3
+
+// notifySync shows a desktop notification with PASSWORD_STORE_NOTIFICATIONS
+// counting the entries a sync changed since the before commit. A sync that
+// changed nothing is only reported when always is set. Names are listed only
+// where commits would name them too: not in stores that hide names, nor with
+// a commit template that leaves out {name}.
+func notifySync(cfg *config.Config, gitSync *gitsync.GitSync, before string, always bool) {
+	if !cfg.Notifications {
+		return
+	}
+
+	var changes gitsync.Changes
+	if after, err := gitSync.HeadCommit(); err == nil && before != "" && after != before {
+		if changes, err = gitSync.ChangesBetween(before, after); err != nil {
+			return
+		}
+	}
+	if changes.Count() == 0 {
+		if always {
+			notify.Notify("Password store synced", "No new changes")
+		}
+		return
+	}
+
+	var counts []string
+	for _, count := range []struct {
+		names []string
+		label string
+	}{{changes.Added, "new"}, {changes.Modified, "updated"}, {changes.Removed, "removed"}} {
+		if len(count.names) > 0 {
+			counts = append(counts, fmt.Sprintf("%d %s", len(count.names), count.label))
+		}
+	}
+	body := strings.Join(counts, ", ")
+
+	if notifyNames(cfg) {
+		names := slices.Concat(changes.Added, changes.Modified, changes.Removed)
+		sort.Strings(names)
+		if len(names) > maxNotifiedNames {
+			names = append(names[:maxNotifiedNames], fmt.Sprintf("%d more", len(names)-maxNotifiedNames))
+		}
+		body += ": " + strings.Join(names, ", ")
+	}
+	notify.Notify("Password store synced", body)
+}
+
+// notifyNames reports whether sync notifications may name entries
+func notifyNames(cfg *config.Config) bool {
+	if cfg.GitCommitTemplate != "" && !strings.Contains(cfg.GitCommitTemplate, "{name}") {
+		return false
+	}
+	passwordStore, err := store.NewFromConfig(cfg)
+	return err == nil && !passwordStore.HiddenNames()
+}
+
+// watchLog prints a timestamped watch message
+func watchLog(format string, args ...interface{}) {
+	fmt.Printf("[%s] %s\n", time.Now().Format("15:04:05"), fmt.Sprintf(format, args...))
+}
+
+var (
+	logReverse bool
+	logLimit   int
+	logSince   string
+	logUntil   string
+)
+
+var gitLogCmd = &cobra.Command{
+	Use:   "log [pass-name]",
+	Short: "Show the commit history, optionally of one entry",
+	Long: `List the commits of the password store, newest first, with their hash,
+date, author and message. Given a password name, only the commits that
+changed that entry are shown, including ones from before it was removed; a
+rename starts a new history. Nothing is decrypted and no master password is
+needed.
+
+--since and --until take a date such as 2024-05-01 or an age such as 7d or
+2w; --since last-sync starts at the last pull or push. They combine with a
+password name, e.g. "git log --since 7d Email/gmail" for what changed in an
+entry this week, and the range is printed above the commits. --limit keeps
+the newest N matches and --reverse shows them oldest first.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		gitSync := newGitSync(cfg)
+
+		if !gitSync.IsGitEnabled() {
+			return fmt.Errorf("Git is not initialized for this password store. Run 'chowkidaar init --git-url <url>' to enable Git sync")
+		}
+
+		filter := gitsync.LogFilter{Limit: logLimit, Reverse: logReverse}
+		if logSince != "" {
+			if filter.Since, err = parseSince(cfg, logSince); err != nil {
+				return err
+			}
+		}
+		if logUntil != "" {
+			if filter.Until, err = parseSince(cfg, logUntil); err != nil {
+				return err
+			}
+		}
+		if !filter.Since.IsZero() && !filter.Until.IsZero() && filter.Until.Before(filter.Since) {
+			return fmt.Errorf("--until (%s) is before --since (%s)", formatLogTime(filter.Until), formatLogTime(filter.Since))
+		}
+		if len(args) > 0 {
+			passwordStore, err := store.NewFromConfig(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to initialize store: %w", err)
+			}
+			if filter.Path, err = passwordStore.EntryFile(args[0]); err != nil {
+				return err
+			}
+		}
+
+		commits, err := gitSync.Log(filter)
+		if err != nil {
+			return err
+		}
+
+		if jsonOutput {
+			if commits == nil {
+				commits = []gitsync.LogEntry{}
+			}
+			result := map[string]interface{}{"commits": commits}
+			if !filter.Since.IsZero() {
+				result["since"] = filter.Since
+			}
+			if !filter.Until.IsZero() {
+				result["until"] = filter.Until
+			}
+			return printJSON(result)
+		}
+
+		if header := logRange(filter.Since, filter.Until); header != "" {
+			fmt.Println(header)
+		}
+		if len(commits) == 0 {
+			fmt.Println("No matching commits.")
+			return nil
+		}
+		for _, commit := range commits {
+			subject, _, _ := strings.Cut(commit.Message, "\n")
+			fmt.Printf("%s %s %-20s %s\n", commit.Hash[:8], formatLogTime(commit.Date), commit.Author, subject)
+		}
+		return nil
+	},
+}
+
+// logRange describes the dates git log --since and --until select, or
+// returns "" when neither is set
+func logRange(since, until time.Time) string {
+	switch {
+	case !since.IsZero() && !until.IsZero():
+		return fmt.Sprintf("Commits from %s to %s:", formatLogTime(since), formatLogTime(until))
+	case !since.IsZero():
+		return fmt.Sprintf("Commits since %s (%s ago):", formatLogTime(since), list.FormatAge(since))
+	case !until.IsZero():
+		return fmt.Sprintf("Commits until %s:", formatLogTime(until))
+	}
+	return ""
+}
+
+// formatLogTime formats a time as git log prints commit dates
+func formatLogTime(t time.Time) string {
+	return t.Local().Format("2006-01-02 15:04")
 }
@@ -0,0 +1,86 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/list"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var hideNamesCmd = &cobra.Command{
+	Use:   "hide-names",
+	Short: "Hide entry names and folder structure on disk",
+	Long: `Move every password to a flat file name derived from its real name with an
+HMAC keyed by the keyfile, so the store directory and Git repository no longer
+reveal what is stored. The real names are kept in .names.idx, encrypted with
+the keyfile, so listing does not need the master password.
+
+New stores can start in this mode with 'chowkidaar init --hide-names'.
+Unless PASSWORD_STORE_AUTO_BACKUP=false is set, a backup is written to
+.backups/ first. Running the command again resumes an interrupted migration.
+Names already recorded in earlier Git commits remain in the repository history.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		files, err := passwordStore.EntryFiles()
+		if err != nil {
+			return err
+		}
+
+		if !confirm(fmt.Sprintf("This will rename %d password files to hidden names. Continue?", len(files))) {
+			fmt.Fprintln(os.Stderr, "Hiding entry names cancelled.")
+			return nil
+		}
+
+		if cfg.AutoBackupBeforeBulk {
+			backupPath, err := passwordStore.Backup()
+			if err != nil {
+				return fmt.Errorf("failed to back up password store: %w", err)
+			}
+			fmt.Printf("Backup written to %s\n", backupPath)
+		}
+
+		count, err := passwordStore.HideNames()
+		if err != nil {
+			return fmt.Errorf("failed to hide entry names after migrating %d passwords: %w", count, err)
+		}
+
+		fmt.Printf("Entry names hidden, %d passwords migrated\n", count)
+		return nil
+	},
+}
+
+// newListBuilder returns a list builder for the store, reading entry names
+// from the encrypted index when the store hides them
+func newListBuilder(cfg *config.Config, options *list.ListOptions) (*list.ListBuilder, error) {
+	passwordStore, err := store.NewFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize store: %w", err)
+	}
+
+	if !passwordStore.HiddenNames() {
+		return list.NewListBuilder(cfg.StoreDir, options), nil
+	}
+
+	files, err := passwordStore.NameMap()
+	if err != nil {
+		return nil, err
+	}
+	return list.NewIndexedListBuilder(cfg.StoreDir, files, options), nil
+}
@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"fmt"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var historyCmd = &cobra.Command{
+	Use:   "history",
+	Short: "Manage previous versions of passwords",
+	Long: `Manage previous versions of passwords. Each time a password is updated or
+edited, the old encrypted version is kept under .history/ in the store.
+The number of versions kept per entry is set with PASSWORD_STORE_HISTORY_DEPTH
+(default 5, 0 disables history). History is never synced to Git.`,
+}
+
+var historyListCmd = &cobra.Command{
+	Use:   "list [name]",
+	Short: "List the stored versions of a password",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		versions, err := passwordStore.Versions(name)
+		if err != nil {
+			return fmt.Errorf("failed to read history: %w", err)
+		}
+
+		if jsonOutput {
+			if versions == nil {
+				versions = []string{}
+			}
+			return printJSON(map[string]interface{}{"name": name, "versions": versions})
+		}
+
+		if len(versions) == 0 {
+			fmt.Printf("No history for '%s'\n", name)
+			return nil
+		}
+		for _, version := range versions {
+			fmt.Println(version)
+		}
+		return nil
+	},
+}
+
+var historyPruneCmd = &cobra.Command{
+	Use:   "prune [name]",
+	Short: "Trim history to the configured depth",
+	Long: `Remove old versions beyond PASSWORD_STORE_HISTORY_DEPTH for a single
+password, or for every password with --all.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		all, _ := cmd.Flags().GetBool("all")
+		if all == (len(args) == 1) {
+			return fmt.Errorf("specify either a password name or --all")
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		var removed int
+		if all {
+			removed, err = passwordStore.PruneAllHistory()
+		} else {
+			removed, err = passwordStore.PruneHistory(args[0])
+		}
+		if err != nil {
+			return fmt.Errorf("failed to prune history: %w", err)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]int{"removed": removed, "depth": cfg.HistoryDepth})
+		}
+
+		fmt.Printf("Removed %s (keeping %d per entry)\n", plural(removed, "stale version"), cfg.HistoryDepth)
+		return nil
+	},
+}
+
+func init() {
+	historyPruneCmd.Flags().Bool("all", false, "Prune the history of every password")
+
+	historyCmd.AddCommand(historyListCmd)
+	historyCmd.AddCommand(historyPruneCmd)
+}
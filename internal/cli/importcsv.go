@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var importCSVCmd = &cobra.Command{
+	Use:   "import-csv [file]",
+	Short: "Insert passwords from a CSV or TSV file",
+	Long: `Insert many passwords at once from a spreadsheet export. Each row is
+path,password[,notes]; notes are stored on the lines after the password.
+Fields may be quoted, so passwords containing commas work. A header row
+starting with "path" or "name" is skipped. Files ending in .tsv are read
+as tab-separated.
+
+The master password is entered once. Rows that cannot be stored (bad path,
+duplicate, existing entry without --force) are reported without stopping
+the import, and all stored entries are committed together.
+
+Missing folders follow PASSWORD_STORE_CREATE_DIRS as for insert, asking once
+about all of them with "confirm"; --parents (-p) or --no-create-dirs
+overrides the setting.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path := args[0]
+
+		entries, rowErrors, err := readBatchFile(path, importTSV || strings.HasSuffix(strings.ToLower(path), ".tsv"))
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+		names := make([]string, len(entries))
+		for i, entry := range entries {
+			names[i] = entry.Name
+		}
+		if ok, err := allowNewFolder(cfg, passwordStore, names...); err != nil || !ok {
+			if err == nil {
+				fmt.Fprintln(os.Stderr, "Import cancelled.")
+			}
+			return err
+		}
+
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		ctx, stop := interruptContext(cmd)
+		defer stop()
+		progress, finish := newProgress()
+		passwordStore.SetProgress(progress)
+
+		count, err := passwordStore.InsertBatch(ctx, entries, masterPassword, importForce)
+		finish()
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("import interrupted, %s stored and committed", plural(count, "password"))
+		}
+		var batchErr *store.BatchError
+		if err != nil && !errors.As(err, &batchErr) {
+			return fmt.Errorf("failed to import passwords: %w", err)
+		}
+		if batchErr != nil {
+			for _, failure := range batchErr.Failures {
+				rowErrors = append(rowErrors, fmt.Sprintf("line %d: %v", failure.Entry.Line, failure.Err))
+			}
+		}
+
+		if jsonOutput {
+			if rowErrors == nil {
+				rowErrors = []string{}
+			}
+			if err := printJSON(map[string]interface{}{"imported": count, "errors": rowErrors}); err != nil {
+				return err
+			}
+			if len(rowErrors) > 0 {
+				return &reportedError{fmt.Errorf("%d rows were not imported", len(rowErrors))}
+			}
+			return nil
+		}
+
+		for _, rowError := range rowErrors {
+			fmt.Fprintf(os.Stderr, "Skipped %s\n", rowError)
+		}
+		fmt.Printf("Imported %s, %d skipped\n", plural(count, "password"), len(rowErrors))
+		if len(rowErrors) > 0 {
+			return fmt.Errorf("%d rows were not imported", len(rowErrors))
+		}
+		return nil
+	},
+}
+
+// readBatchFile parses a CSV or TSV file into batch entries. Rows that
+// cannot be parsed are returned as messages instead of failing the import.
+func readBatchFile(path string, tabSeparated bool) ([]store.BatchEntry, []string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1 // Notes are optional
+	if tabSeparated {
+		reader.Comma = '\t'
+	}
+
+	var entries []store.BatchEntry
+	var rowErrors []string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, fmt.Errorf("failed to read %s: %w", path, err)
+			}
+			rowErrors = append(rowErrors, fmt.Sprintf("line %d: %v", parseErr.Line, parseErr.Err))
+			continue
+		}
+		line, _ := reader.FieldPos(0)
+
+		name := strings.TrimSpace(record[0])
+		if len(entries) == 0 && len(rowErrors) == 0 && (strings.EqualFold(name, "path") || strings.EqualFold(name, "name")) {
+			continue // Header row
+		}
+		if len(record) < 2 {
+			rowErrors = append(rowErrors, fmt.Sprintf("line %d: expected path,password[,notes]", line))
+			continue
+		}
+
+		entry := store.BatchEntry{Name: name, Password: record[1], Line: line}
+		if len(record) > 2 {
+			// Unquoted notes may have been split on the separator
+			entry.Notes = strings.Join(record[2:], string(reader.Comma))
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, rowErrors, nil
+}
+
+var importForce bool
+var importTSV bool
+
+func init() {
+	importCSVCmd.Flags().BoolVar(&importForce, "force", false, "Overwrite passwords that already exist")
+	importCSVCmd.Flags().BoolVar(&importTSV, "tsv", false, "Read tab-separated values")
+}
@@ -2,6 +2,7 @@ package cli
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
 	"os"
 	"strings"
@@ -10,18 +11,24 @@ import (
 	"chowkidaar/internal/config"
 	"chowkidaar/internal/crypto"
 	"chowkidaar/internal/gitsync"
+	"chowkidaar/internal/store"
 
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
 var gitURL string
+var hideNames bool
+var kdfName string
+var noKeyFile bool
+var shallowClone bool
+var importArchive string
 
 // promptPasswordInput prompts the user for a password without echoing it to the terminal
 func promptPasswordInput(prompt string) (string, error) {
-	fmt.Print(prompt)
+	fmt.Fprint(os.Stderr, prompt)
 	password, err := term.ReadPassword(int(syscall.Stdin))
-	fmt.Println() // Add newline after password input
+	fmt.Fprintln(os.Stderr) // Add newline after password input
 	if err != nil {
 		return "", err
 	}
@@ -39,12 +46,37 @@ If --git-url is provided, the command will:
 - Initialize a new Git repository and link it to the remote if the repository is empty
 - Sync existing passwords from the remote repository
 
+With --shallow only the latest commit is cloned, which is much faster for a
+store with a long history. Passwords, pull and push work as usual; what is
+missing is the Git log from before the clone, so 'git log' and ahead/behind
+counts only reach back that far. Run 'git fetch --unshallow' in the store
+directory to fetch the rest later.
+
 For existing stores (with .enc files), you'll need to enter the 12-word recovery phrase.
-For new stores, a recovery phrase will be generated and displayed.
+For new stores, a recovery phrase will be generated and displayed. If a keyfile
+exists without any passwords, e.g. after an interrupted init, you are offered a
+new recovery phrase and keyfile, or can abort and keep it.
+
+With --no-keyfile, a new store uses the master password alone: there is no
+keyfile or recovery phrase, and anyone who obtains the encrypted files (for
+example from the Git remote) only has to guess the master password. Choose a
+long one. Hidden entry names need a keyfile and are not available.
+
+With --import, a new store is filled from an archive made by 'chowkidaar
+export', for setting up a device without Git. Instead of generating a new
+recovery phrase, init asks for the phrase of the exported store, since the
+archive's entries need its keyfile, and then for the new master password.
+Each entry is decrypted and encrypted again with it. If the archive was
+exported under a different master password, you are asked for that one too.
+Use --no-keyfile for an archive of a store without a keyfile.
 
 Examples:
   chowkidaar init                                    # Initialize local store only
-  chowkidaar init --git-url https://github.com/user/passwords.git  # Clone or init with Git sync`,
+  chowkidaar init --git-url https://github.com/user/passwords.git  # Clone or init with Git sync
+  chowkidaar init --git-url https://github.com/user/passwords.git --shallow  # Clone the latest commit only
+  chowkidaar init --hide-names                       # Keep entry names out of file names
+  chowkidaar init --no-keyfile                       # Master password only, no keyfile
+  chowkidaar init --import backup.tar.gz             # New device from an exported archive`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.Load()
@@ -53,11 +85,21 @@ Examples:
 		}
 
 		storeDir := cfg.StoreDir
+		if shallowClone && gitURL == "" {
+			return fmt.Errorf("--shallow needs --git-url")
+		}
+		if importArchive != "" {
+			if _, err := os.Stat(importArchive); err != nil {
+				return fmt.Errorf("cannot read archive: %w", err)
+			}
+		}
 
 		// Initialize Git sync if URL is provided
 		var gitSync *gitsync.GitSync
 		if gitURL != "" {
 			gitSync = gitsync.NewGitSync(storeDir, gitURL)
+			gitSync.SetTimeout(cfg.GitTimeout)
+			gitSync.SetShallow(shallowClone)
 
 			// Initialize or clone the repository
 			if err := gitSync.InitializeWithRemote(); err != nil {
@@ -73,37 +115,60 @@ Examples:
 		// Initialize crypto handler
 		cryptoHandler := crypto.New(storeDir)
 
-		// Check if this is an existing store (has encrypted passwords)
-		hasEncryptedPasswords, err := cryptoHandler.HasEncryptedPasswords()
+		// Work out how far the store has been set up
+		state, err := cryptoHandler.StoreState()
 		if err != nil {
 			return fmt.Errorf("failed to check for encrypted passwords: %w", err)
 		}
 
-		if hasEncryptedPasswords {
+		// A complete store has nothing to restore; a wrong recovery phrase
+		// would only replace a working keyfile
+		if state == crypto.StateHasPasswords && cryptoHandler.UsesKeyFile() {
+			return fmt.Errorf("password store already initialized at %s", storeDir)
+		}
+
+		if state == crypto.StateMissingKeyFile || state == crypto.StateHasPasswords {
 			// SCENARIO: Cloning existing password store
+			if hideNames {
+				return fmt.Errorf("--hide-names only applies to new stores; run 'chowkidaar hide-names' after restoring the keyfile")
+			}
+			if cmd.Flags().Changed("kdf") {
+				return fmt.Errorf("--kdf only applies to new stores; an existing store keeps the KDF recorded in .crypto.json")
+			}
+			if noKeyFile {
+				return fmt.Errorf("--no-keyfile only applies to new stores")
+			}
+			if importArchive != "" {
+				return fmt.Errorf("--import only applies to new stores; the store at %s already has passwords", storeDir)
+			}
+
 			fmt.Println("\n🔐 Existing password store detected!")
-			fmt.Println("To access these passwords, you need the 12-word recovery phrase.")
-			fmt.Println()
+			if cryptoHandler.UsesKeyFile() {
+				fmt.Println("To access these passwords, you need the 12-word recovery phrase.")
+				fmt.Println()
 
-			// Prompt for mnemonic
-			reader := bufio.NewReader(os.Stdin)
-			fmt.Print("Enter your 12-word recovery phrase: ")
-			mnemonicInput, err := reader.ReadString('\n')
-			if err != nil {
-				return fmt.Errorf("failed to read recovery phrase: %w", err)
-			}
-			mnemonic := strings.TrimSpace(mnemonicInput)
+				// Prompt for mnemonic
+				reader := bufio.NewReader(os.Stdin)
+				fmt.Fprint(os.Stderr, "Enter your 12-word recovery phrase: ")
+				mnemonicInput, err := reader.ReadString('\n')
+				if err != nil {
+					return fmt.Errorf("failed to read recovery phrase: %w", err)
+				}
+				mnemonic := strings.TrimSpace(mnemonicInput)
 
-			// Create keyfile from mnemonic
-			if err := cryptoHandler.CreateKeyFileFromMnemonic(mnemonic); err != nil {
-				return fmt.Errorf("failed to create keyfile from recovery phrase: %w", err)
+				// Create keyfile from mnemonic
+				if err := cryptoHandler.CreateKeyFileFromMnemonic(mnemonic); err != nil {
+					return fmt.Errorf("failed to create keyfile from recovery phrase: %w", err)
+				}
+			} else {
+				fmt.Println("This store uses the master password only, so no recovery phrase is needed.")
 			}
 
 			// Save Git configuration
 			if gitURL != "" {
 				cfg.GitURL = gitURL
 				if err := cfg.SaveGitConfig(); err != nil {
-					fmt.Printf("Warning: failed to save Git configuration: %v\n", err)
+					fmt.Fprintf(os.Stderr, "Warning: failed to save Git configuration: %v\n", err)
 				}
 			}
 
@@ -124,22 +189,58 @@ Examples:
 
 		// SCENARIO: Creating new password store
 		
-		// Check if keyfile already exists (store was previously initialized)
-		if cryptoHandler.HasKeyFile() {
+		switch state {
+		case crypto.StateEmpty:
 			return fmt.Errorf("password store already initialized at %s", storeDir)
+		case crypto.StateKeyFileOnly:
+			// The phrase cannot be recovered from the keyfile, so the only
+			// repair is a new phrase and keyfile while nothing uses it yet
+			fmt.Println("\n⚠️  A keyfile exists but the store has no passwords yet.")
+			fmt.Println("If an earlier init was interrupted, its recovery phrase may never have been written down.")
+			if !confirm("Replace the keyfile with one from a new recovery phrase?") {
+				fmt.Println("Init aborted; the existing keyfile was left in place.")
+				return nil
+			}
+		}
+
+		if noKeyFile && hideNames {
+			return fmt.Errorf("--hide-names needs a keyfile and cannot be combined with --no-keyfile")
+		}
+
+		// Record the KDF for new entries before anything is encrypted
+		if err := cryptoHandler.SetKDF(kdfName); err != nil {
+			return fmt.Errorf("failed to set KDF: %w", err)
+		}
+		if noKeyFile {
+			if err := cryptoHandler.DisableKeyFile(); err != nil {
+				return fmt.Errorf("failed to record password-only mode: %w", err)
+			}
 		}
 
 		fmt.Println("\n🆕 Creating new password store...")
 		
-		// Generate BIP-39 mnemonic
-		mnemonic, err := cryptoHandler.GenerateMnemonic()
-		if err != nil {
-			return fmt.Errorf("failed to generate recovery phrase: %w", err)
-		}
+		// Generate BIP-39 mnemonic and the keyfile derived from it. An
+		// imported archive needs the keyfile of the store it came from.
+		var mnemonic string
+		if !noKeyFile && importArchive != "" {
+			fmt.Println("The archive can only be read with the keyfile of the store it was exported from.")
+			fmt.Fprint(os.Stderr, "Enter the 12-word recovery phrase of that store: ")
+			mnemonicInput, err := bufio.NewReader(os.Stdin).ReadString('\n')
+			if err != nil {
+				return fmt.Errorf("failed to read recovery phrase: %w", err)
+			}
+			if err := cryptoHandler.CreateKeyFileFromMnemonic(strings.TrimSpace(mnemonicInput)); err != nil {
+				return fmt.Errorf("failed to create keyfile from recovery phrase: %w", err)
+			}
+		} else if !noKeyFile {
+			mnemonic, err = cryptoHandler.GenerateMnemonic()
+			if err != nil {
+				return fmt.Errorf("failed to generate recovery phrase: %w", err)
+			}
 
-		// Create keyfile from mnemonic
-		if err := cryptoHandler.CreateKeyFileFromMnemonic(mnemonic); err != nil {
-			return fmt.Errorf("failed to create keyfile: %w", err)
+			if err := cryptoHandler.CreateKeyFileFromMnemonic(mnemonic); err != nil {
+				return fmt.Errorf("failed to create keyfile: %w", err)
+			}
 		}
 
 		// Prompt for master password
@@ -167,10 +268,38 @@ Examples:
 		if gitURL != "" {
 			cfg.GitURL = gitURL
 			if err := cfg.SaveGitConfig(); err != nil {
-				fmt.Printf("Warning: failed to save Git configuration: %v\n", err)
+				fmt.Fprintf(os.Stderr, "Warning: failed to save Git configuration: %v\n", err)
+			}
+		}
+
+		// A new store starts in the current format
+		if err := store.WriteStoreVersion(storeDir); err != nil {
+			return err
+		}
+
+		// Start with hidden entry names if requested
+		if hideNames {
+			passwordStore, err := store.NewFromConfig(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to initialize store: %w", err)
+			}
+			if _, err := passwordStore.HideNames(); err != nil {
+				return fmt.Errorf("failed to enable hidden entry names: %w", err)
 			}
 		}
 
+		if importArchive != "" {
+			passwordStore, err := store.NewFromConfig(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to initialize store: %w", err)
+			}
+			count, err := importInitArchive(cmd, passwordStore, masterPassword)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("\nImported %s from %s\n", plural(count, "password"), importArchive)
+		}
+
 		// Display success message with recovery phrase
 		fmt.Printf("\n✅ Password store initialized successfully!\n")
 		fmt.Printf("Store location: %s\n", storeDir)
@@ -178,6 +307,27 @@ Examples:
 			fmt.Printf("Git remote: %s\n", gitURL)
 		}
 		
+		if noKeyFile {
+			fmt.Println("\n⚠️  This store uses the master password only: there is no keyfile or")
+			fmt.Println("recovery phrase, and anyone with the encrypted files only has to guess it.")
+			fmt.Printf("\nYou can now:\n")
+			fmt.Printf("- Add passwords: chowkidaar insert <name>\n")
+			fmt.Printf("- View passwords: chowkidaar list\n")
+			if gitURL != "" {
+				fmt.Printf("- Sync changes: chowkidaar git push/pull\n")
+			}
+			return nil
+		}
+
+		// The imported store's phrase is already written down
+		if mnemonic == "" {
+			fmt.Printf("\nThe store uses the same recovery phrase as the exported one.\n")
+			fmt.Printf("\nYou can now:\n")
+			fmt.Printf("- View passwords: chowkidaar list\n")
+			fmt.Printf("- Show a password: chowkidaar show <name>\n")
+			return nil
+		}
+
 		fmt.Println("\n" + strings.Repeat("=", 70))
 		fmt.Println("⚠️  IMPORTANT: Write down your 12-word recovery phrase!")
 		fmt.Println(strings.Repeat("=", 70))
@@ -199,6 +349,37 @@ Examples:
 	},
 }
 
+// importInitArchive imports the --import archive into a new store. The
+// archive is tried with the new master password first and, if it was
+// exported under another one, with that password.
+func importInitArchive(cmd *cobra.Command, passwordStore *store.Store, masterPassword string) (int, error) {
+	ctx, stop := interruptContext(cmd)
+	defer stop()
+
+	count, err := passwordStore.ImportArchive(ctx, importArchive, masterPassword, masterPassword)
+	if errors.Is(err, crypto.ErrAuthFailed) {
+		fmt.Println("\nThe archive was not exported under this master password.")
+		archivePassword, promptErr := promptPasswordInput("Enter the master password of the exported store: ")
+		if promptErr != nil {
+			return 0, fmt.Errorf("failed to read archive password: %w", promptErr)
+		}
+		count, err = passwordStore.ImportArchive(ctx, importArchive, archivePassword, masterPassword)
+	}
+	if errors.Is(err, crypto.ErrAuthFailed) {
+		return 0, fmt.Errorf("cannot decrypt %s: the master password or recovery phrase does not match the exported store; "+
+			"the new store is empty, so run 'chowkidaar init --import' again to start over: %w", importArchive, err)
+	}
+	if err != nil {
+		return count, fmt.Errorf("failed to import %s: %w", importArchive, err)
+	}
+	return count, nil
+}
+
 func init() {
 	initCmd.Flags().StringVar(&gitURL, "git-url", "", "Git repository URL to clone existing passwords or sync new ones")
+	initCmd.Flags().BoolVar(&hideNames, "hide-names", false, "Hide entry names and folder structure on disk (new stores only)")
+	initCmd.Flags().BoolVar(&noKeyFile, "no-keyfile", false, "Derive keys from the master password alone, without a keyfile or recovery phrase (new stores only)")
+	initCmd.Flags().BoolVar(&shallowClone, "shallow", false, "Clone only the latest commit of --git-url, without older history")
+	initCmd.Flags().StringVar(&importArchive, "import", "", "Fill the new store from an archive made by 'chowkidaar export' (asks for its recovery phrase)")
+	initCmd.Flags().StringVar(&kdfName, "kdf", crypto.KDFArgon2id, "Key derivation function for new entries: argon2id or scrypt (new stores only)")
 }
@@ -1,12 +1,22 @@
 package cli
 
 import (
+	"bufio"
 	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"syscall"
 
+	"chowkidaar/internal/clipboard"
 	"chowkidaar/internal/config"
+	"chowkidaar/internal/list"
 	"chowkidaar/internal/store"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var insertCmd = &cobra.Command{
@@ -15,6 +25,38 @@ var insertCmd = &cobra.Command{
 	Long: `Insert a new password into the password store.
 The password name should be in the format of a file path (e.g., Email/gmail.com).
 
+With --template, the editor opens on a template instead, with a generated
+password on the first line and stubs for the other fields, e.g.
+chowkidaar insert --template login Email/gmail. Built-in templates are login
+and wifi; more can be defined in .templates.json in the store directory.
+
+With --from-clipboard the password is read from the clipboard instead of
+typed, e.g. right after generating it in a browser; add --clear-clipboard to
+empty the clipboard once it is stored.
+
+With --json-entry the entry is a JSON document read from stdin, e.g.
+{"password":"...","username":"...","fields":{"url":"..."}}. JSON entries keep
+their structure: show --field reads a value from them directly (the global
+--json flag still selects JSON output). Pipe the
+document in once the master password is cached, or type it and press Ctrl+D.
+
+A missing folder in the name is created, unless PASSWORD_STORE_CREATE_DIRS
+says otherwise: with "confirm" you are asked first, and with "never" the
+insert fails, which catches typos such as Emial/gmail. --parents (-p) creates
+the folders anyway and --no-create-dirs refuses for this insert.
+
+A typed password is hidden and asked for twice, so a typo is caught before it
+is encrypted; --echo shows it while typing and --no-confirm asks only once.
+Piped input is read as a single line without confirmation.
+
+An existing entry is only replaced with --force (-f); its previous version
+stays in the git history. With PASSWORD_STORE_CONFIRM_OVERWRITE=true you are
+asked first, shown when the entry was last modified; --yes skips the question.
+
+With --show the new entry is decrypted again and printed, and with --clip its
+first line is copied to the clipboard. Either proves it can be read back with
+the current keyfile and master password.
+
 The master password will be cached for 5 minutes (configurable) to avoid repeated prompts.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -25,33 +67,315 @@ The master password will be cached for 5 minutes (configurable) to avoid repeate
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		passwordStore, err := store.NewWithGitConfig(cfg.StoreDir, cfg.CacheTimeout, cfg.GitURL, cfg.GitAutoSync)
+		passwordStore, err := store.NewFromConfig(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to initialize store: %w", err)
 		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+		if insertShow && insertClip {
+			return fmt.Errorf("--show and --clip cannot be used together")
+		}
+		if fromClipboard && templateName != "" {
+			return fmt.Errorf("--from-clipboard and --template cannot be used together")
+		}
+		if clearClipboard && !fromClipboard {
+			return fmt.Errorf("--clear-clipboard needs --from-clipboard")
+		}
+		if insertJSON && (fromClipboard || templateName != "") {
+			return fmt.Errorf("--json-entry cannot be used with --from-clipboard or --template")
+		}
+		if insertForce && templateName != "" {
+			return fmt.Errorf("--force cannot be used with --template")
+		}
+		overwrite := insertForce && passwordStore.Exists(passName)
+		if ok, err := allowNewFolder(cfg, passwordStore, passName); err != nil || !ok {
+			if err == nil {
+				fmt.Fprintln(os.Stderr, "Insert cancelled.")
+			}
+			return err
+		}
+
+		// Read the clipboard first so an empty one fails before any prompt
+		var password string
+		if fromClipboard {
+			if password, err = clipboard.Paste(); err != nil {
+				return fmt.Errorf("failed to read clipboard: %w", err)
+			}
+			if strings.TrimSpace(password) == "" {
+				return fmt.Errorf("clipboard is empty")
+			}
+		}
+
+		// Read and check the document first so a bad one fails before any prompt
+		if insertJSON {
+			if term.IsTerminal(int(syscall.Stdin)) {
+				fmt.Fprintf(os.Stderr, "Enter JSON for %s, then press Ctrl+D:\n", passName)
+			}
+			data, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read JSON entry: %w", err)
+			}
+			entry, err := store.ParseJSONEntry(string(data))
+			if err != nil {
+				return err
+			}
+			if password, err = entry.Encode(); err != nil {
+				return fmt.Errorf("failed to encode entry: %w", err)
+			}
+		}
+
+		var template string
+		if templateName != "" {
+			var ok bool
+			if template, ok = cfg.Templates[templateName]; !ok {
+				return fmt.Errorf("unknown template '%s' (available: %s)", templateName, strings.Join(templateNames(cfg), ", "))
+			}
+		}
 
 		// Prompt for master password
-		masterPassword, err := passwordStore.PromptMasterPassword("Enter master password: ")
+		masterPassword, err := promptMasterPassword(passwordStore)
 		if err != nil {
 			return fmt.Errorf("failed to read master password: %w", err)
 		}
 
+		if overwrite && cfg.ConfirmOverwrite {
+			if ok, err := confirmOverwrite(passwordStore, passName, masterPassword); err != nil || !ok {
+				if err == nil {
+					fmt.Fprintln(os.Stderr, "Insert cancelled.")
+				}
+				return err
+			}
+		}
+
+		if templateName != "" {
+			if err := passwordStore.InsertTemplate(passName, template, masterPassword, cfg.Editor); err != nil {
+				return fmt.Errorf("failed to insert password: %w", err)
+			}
+			return printInserted(passwordStore, passName, masterPassword)
+		}
+
 		// Prompt for password to store
-		fmt.Printf("Enter password for %s: ", passName)
-		var password string
-		fmt.Scanln(&password)
+		if !fromClipboard && !insertJSON {
+			if password, err = readInsertPassword(passName); err != nil {
+				return err
+			}
+		}
 
-		if err := passwordStore.Insert(passName, password, masterPassword); err != nil {
+		write := passwordStore.Insert
+		if overwrite {
+			write = passwordStore.Update
+		}
+		if err := write(passName, password, masterPassword); err != nil {
 			return fmt.Errorf("failed to insert password: %w", err)
 		}
 
+		if clearClipboard {
+			if err := clipboard.Copy(""); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: failed to clear clipboard: %v\n", err)
+			}
+		}
+
+		return printInserted(passwordStore, passName, masterPassword)
+	},
+}
+
+// confirmOverwrite asks before insert --force replaces an entry, for
+// PASSWORD_STORE_CONFIRM_OVERWRITE. The question says how big the entry is
+// and when it last changed; an empty entry is replaced without asking.
+func confirmOverwrite(passwordStore *store.Store, name, masterPassword string) (bool, error) {
+	content, err := passwordStore.Show(name, masterPassword)
+	if err != nil {
+		return false, err
+	}
+	if strings.TrimSpace(content) == "" {
+		return true, nil
+	}
+	modTime, err := passwordStore.ModTime(name)
+	if err != nil {
+		return false, err
+	}
+
+	if !assumeYes && jsonOutput {
+		return false, fmt.Errorf("'%s' already exists; refusing to prompt for confirmation in JSON mode, use --yes", name)
+	}
+	lines := strings.Count(strings.TrimRight(content, "\n"), "\n") + 1
+	return confirm(fmt.Sprintf("'%s' already exists (%s, last modified %s, %s ago). Overwrite it?",
+		name, plural(lines, "line"), modTime.Local().Format("2006-01-02 15:04"), list.FormatAge(modTime))), nil
+}
+
+// readInsertPassword reads the password to store. On a terminal it is typed
+// hidden, or visibly with --echo, and a second time to catch typos unless
+// --no-confirm is given. Piped input is read as a single line.
+func readInsertPassword(passName string) (string, error) {
+	prompt := fmt.Sprintf("Enter password for %s: ", passName)
+	if !term.IsTerminal(int(syscall.Stdin)) {
+		return readVisibleInput(prompt)
+	}
+
+	read := promptPasswordInput
+	if insertEcho {
+		read = readVisibleInput
+	}
+
+	password, err := read(prompt)
+	if err != nil {
+		return "", fmt.Errorf("failed to read password: %w", err)
+	}
+	if insertNoConfirm {
+		return password, nil
+	}
+
+	confirmPassword, err := read(fmt.Sprintf("Retype password for %s: ", passName))
+	if err != nil {
+		return "", fmt.Errorf("failed to read password confirmation: %w", err)
+	}
+	if password != confirmPassword {
+		return "", fmt.Errorf("passwords do not match, nothing was stored")
+	}
+	return password, nil
+}
+
+// readVisibleInput prompts on stderr and reads one line from stdin as typed
+func readVisibleInput(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// allowNewFolder applies the folder creation setting, or the --parents and
+// --no-create-dirs flags, to new entries. It reports false when the user
+// declines to create the missing folders, and otherwise lets the store create
+// them.
+func allowNewFolder(cfg *config.Config, passwordStore *store.Store, names ...string) (bool, error) {
+	mode := cfg.CreateDirs
+	switch {
+	case createParents && noCreateDirs:
+		return false, fmt.Errorf("--parents and --no-create-dirs cannot be used together")
+	case createParents:
+		passwordStore.AllowNewFolders()
+		return true, nil
+	case noCreateDirs:
+		mode = config.CreateDirsNever
+	}
+	if mode == config.CreateDirsAlways {
+		return true, nil
+	}
+
+	var folders []string
+	for _, name := range names {
+		folder, err := passwordStore.MissingFolder(name)
+		if err != nil {
+			continue // Invalid names are reported when the entry is written
+		}
+		if folder != "" && !slices.Contains(folders, folder) {
+			folders = append(folders, folder)
+		}
+	}
+	if len(folders) == 0 {
+		return true, nil
+	}
+	if mode == config.CreateDirsNever {
+		return false, fmt.Errorf("folder '%s' does not exist; check the name for typos or add --parents (-p) to create it", folders[0])
+	}
+	if !assumeYes && jsonOutput {
+		return false, fmt.Errorf("refusing to prompt for confirmation in JSON mode, use --parents or --yes")
+	}
+
+	question := fmt.Sprintf("Folder '%s' does not exist. Create it?", folders[0])
+	if len(folders) > 1 {
+		question = fmt.Sprintf("Folders '%s' do not exist. Create them?", strings.Join(folders, "', '"))
+	}
+	if !confirm(question) {
+		return false, nil
+	}
+	passwordStore.AllowNewFolders()
+	return true, nil
+}
+
+// printInserted reports a successful insert. With --show or --clip the entry
+// is read back first, so a keyfile mismatch is caught right away.
+func printInserted(passwordStore *store.Store, passName, masterPassword string) error {
+	if !insertShow && !insertClip {
+		if jsonOutput {
+			return printJSON(map[string]string{"name": passName, "status": "inserted"})
+		}
+
 		fmt.Printf("Password for '%s' inserted successfully\n", passName)
 		return nil
-	},
+	}
+
+	content, err := passwordStore.Show(passName, masterPassword)
+	if err != nil {
+		return fmt.Errorf("password for '%s' was stored but cannot be read back: %w", passName, err)
+	}
+
+	if insertClip {
+		firstLine, _, _ := strings.Cut(content, "\n")
+		if store.IsJSONEntry(content) {
+			firstLine = store.TrimSecret(content)
+		}
+		if err := clipboard.Copy(firstLine); err != nil {
+			return fmt.Errorf("failed to copy to clipboard: %w", err)
+		}
+		if jsonOutput {
+			return printJSON(map[string]string{"name": passName, "status": "inserted", "clipboard": "copied"})
+		}
+		fmt.Printf("Password for '%s' inserted, read back and copied to clipboard\n", passName)
+		return nil
+	}
+
+	content = store.EntryText(content)
+	if jsonOutput {
+		return printJSON(map[string]string{"name": passName, "status": "inserted", "password": content})
+	}
+	fmt.Printf("Password for '%s' inserted and read back:\n%s\n", passName, strings.TrimSuffix(content, "\n"))
+	return nil
 }
 
-var multiline bool
+// templateNames returns the sorted names of the available entry templates
+func templateNames(cfg *config.Config) []string {
+	names := make([]string, 0, len(cfg.Templates))
+	for name := range cfg.Templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+var (
+	multiline       bool
+	templateName    string
+	insertShow      bool
+	insertClip      bool
+	fromClipboard   bool
+	clearClipboard  bool
+	insertJSON      bool
+	insertEcho      bool
+	insertNoConfirm bool
+	createParents   bool
+	noCreateDirs    bool
+	insertForce     bool
+)
 
 func init() {
 	insertCmd.Flags().BoolVarP(&multiline, "multiline", "m", false, "Enable multiline password entry")
+	insertCmd.Flags().BoolVar(&insertShow, "show", false, "Decrypt and print the entry after storing it")
+	insertCmd.Flags().BoolVar(&insertClip, "clip", false, "Decrypt the entry after storing it and copy its first line to the clipboard")
+	insertCmd.Flags().BoolVar(&fromClipboard, "from-clipboard", false, "Store the password currently on the clipboard")
+	insertCmd.Flags().BoolVar(&clearClipboard, "clear-clipboard", false, "Clear the clipboard after storing a password read with --from-clipboard")
+	insertCmd.Flags().BoolVar(&insertJSON, "json-entry", false, "Store a JSON document read from stdin as a structured entry")
+	insertCmd.Flags().BoolVar(&insertEcho, "echo", false, "Show the password as it is typed")
+	insertCmd.Flags().BoolVar(&insertNoConfirm, "no-confirm", false, "Type the password once instead of twice")
+	insertCmd.Flags().BoolVarP(&insertForce, "force", "f", false, "Replace the entry if it exists (previous version kept in history)")
+	insertCmd.Flags().StringVarP(&templateName, "template", "t", "", "Fill in a template in the editor (login, wifi or one from .templates.json)")
+
+	for _, cmd := range []*cobra.Command{insertCmd, editCmd, generateCmd, importCSVCmd} {
+		cmd.Flags().BoolVarP(&createParents, "parents", "p", false, "Create missing folders without asking")
+		cmd.Flags().BoolVar(&noCreateDirs, "no-create-dirs", false, "Fail instead of creating a missing folder")
+	}
 }
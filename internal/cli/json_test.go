@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"chowkidaar/internal/crypto"
+
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+func TestJSONWriteInGitStore(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	t.Setenv("XDG_CACHE_HOME", filepath.Join(home, ".cache"))
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = Test\n\temail = test@example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// A store with a keyfile and a Git repository, so every write commits
+	storeDir := filepath.Join(home, "store")
+	if err := os.Mkdir(storeDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PASSWORD_STORE_DIR", storeDir)
+	cryptoHandler := crypto.New(storeDir)
+	mnemonic, err := cryptoHandler.GenerateMnemonic()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cryptoHandler.CreateKeyFileFromMnemonic(mnemonic); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := gogit.PlainInit(storeDir, false); err != nil {
+		t.Fatal(err)
+	}
+	remoteDir := filepath.Join(home, "remote.git")
+	if _, err := gogit.PlainInit(remoteDir, true); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PASSWORD_STORE_GIT_URL", remoteDir)
+	masterFile := filepath.Join(home, "master")
+	if err := os.WriteFile(masterFile, []byte("correct horse battery staple\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"--json", "generate", "Email/gmail", "--master-file", masterFile},
+		{"--json", "rm", "--yes", "Email/gmail"},
+	} {
+		stdout := captureStdout(t, func() {
+			rootCmd.SetArgs(args)
+			if err := Execute(); err != nil {
+				t.Errorf("%v: %v", args, err)
+			}
+		})
+
+		decoder := json.NewDecoder(bytes.NewReader(stdout))
+		var value interface{}
+		if err := decoder.Decode(&value); err != nil {
+			t.Errorf("%v: stdout is not JSON: %v\n%s", args, err, stdout)
+			continue
+		}
+		if _, err := decoder.Token(); err != io.EOF {
+			t.Errorf("%v: stdout holds more than one JSON value:\n%s", args, stdout)
+		}
+	}
+	// Both writes were committed
+	repository, err := gogit.PlainOpen(storeDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	commits, err := repository.Log(&gogit.LogOptions{})
+	if err != nil {
+		t.Fatalf("Log: %v", err)
+	}
+	count := 0
+	commits.ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
+	if count != 2 {
+		t.Errorf("got %d commits, want 2", count)
+	}
+}
+
+// captureStdout returns what run writes to os.Stdout
+func captureStdout(t *testing.T, run func()) []byte {
+	t.Helper()
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	defer func() { os.Stdout = stdout }()
+
+	done := make(chan []byte)
+	go func() {
+		data, _ := io.ReadAll(reader)
+		done <- data
+	}()
+	run()
+	writer.Close()
+	return <-done
+}
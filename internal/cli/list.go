@@ -2,9 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"time"
 
 	"chowkidaar/internal/config"
 	"chowkidaar/internal/list"
+	"chowkidaar/internal/store"
 
 	"github.com/spf13/cobra"
 )
@@ -15,7 +18,16 @@ var listCmd = &cobra.Command{
 	Long: `List names of passwords inside the tree at subfolder with a clean, modern view.
 If no subfolder is provided, list all passwords.
 
-The list command provides a beautiful tree view with icons and colors for easy navigation.`,
+The list command provides a beautiful tree view with icons and colors for easy navigation.
+
+With --flat, passwords are printed as the store is walked rather than after
+the whole tree is built, so very large stores start listing at once.
+
+With --paths, only the full name of each password is printed, one per line
+in sorted order, without icons or colors. Names with spaces or other special
+characters are single-quoted, so the output can be piped on:
+
+  chowkidaar list --paths | fzf | xargs chowkidaar show --clip`,
 	Aliases: []string{"ls"},
 	Args:    cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
@@ -48,11 +60,94 @@ The list command provides a beautiful tree view with icons and colors for easy n
 		if maxDepth, _ := cmd.Flags().GetInt("max-depth"); maxDepth >= 0 {
 			options.MaxDepth = maxDepth
 		}
+		if treeDepth, _ := cmd.Flags().GetInt("tree-depth"); treeDepth >= 0 {
+			options.MaxDepth = treeDepth
+		}
+		if dirsOnly, _ := cmd.Flags().GetBool("dirs-only"); dirsOnly {
+			options.DirsOnly = true
+		}
+		if paths, _ := cmd.Flags().GetBool("paths"); paths {
+			if options.Flat || options.ShowDetails || options.DirsOnly || options.ShowAge {
+				return fmt.Errorf("--paths cannot be used with --flat, --details, --dirs-only or --age")
+			}
+			options.PathsOnly = true
+		}
 		if filter, _ := cmd.Flags().GetString("filter"); filter != "" {
 			options.SearchFilter = filter
 		}
+		if age, _ := cmd.Flags().GetBool("age"); age {
+			options.ShowAge = true
+		}
+		if olderThan, _ := cmd.Flags().GetString("older-than"); olderThan != "" {
+			duration, err := list.ParseAge(olderThan)
+			if err != nil {
+				return err
+			}
+			options.OlderThan = duration
+		}
+		since, _ := cmd.Flags().GetString("since")
+		if modified, _ := cmd.Flags().GetBool("modified"); modified {
+			if since != "" {
+				return fmt.Errorf("--modified cannot be combined with --since")
+			}
+			since = "last-sync"
+		}
+		if since != "" {
+			t, err := parseSince(cfg, since)
+			if err != nil {
+				return err
+			}
+			options.Since = t
+		}
 
-		return list.GenerateWithOptions(cfg.StoreDir, subfolder, options)
+		if descriptions, _ := cmd.Flags().GetBool("descriptions"); descriptions {
+			passwordStore, err := store.NewFromConfig(cfg)
+			if err != nil {
+				return fmt.Errorf("failed to initialize store: %w", err)
+			}
+			if options.Descriptions, err = passwordStore.DirDescriptions(); err != nil {
+				return err
+			}
+		}
+
+		builder, err := newListBuilder(cfg, options)
+		if err != nil {
+			return err
+		}
+
+		if count, _ := cmd.Flags().GetBool("count"); count {
+			passwords, folders, err := builder.Count(subfolder)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				return printJSON(map[string]int{"passwords": passwords, "folders": folders})
+			}
+			fmt.Printf("%s in %s\n", plural(passwords, "password"), plural(folders, "folder"))
+			return nil
+		}
+
+		if jsonOutput {
+			names, err := builder.EntryNames(subfolder)
+			if err != nil {
+				return err
+			}
+			result := map[string]interface{}{"entries": names}
+			if options.Descriptions != nil {
+				result["descriptions"] = options.Descriptions
+			}
+			return printJSON(result)
+		}
+
+		if options.PathsOnly {
+			return builder.WritePaths(subfolder, os.Stdout)
+		}
+
+		// Flat listings are printed while the store is walked
+		if options.Flat {
+			return builder.StreamFlat(subfolder, os.Stdout)
+		}
+		return builder.Generate(subfolder)
 	},
 }
 
@@ -62,5 +157,43 @@ func init() {
 	listCmd.Flags().Bool("no-icons", false, "Disable emoji icons")
 	listCmd.Flags().Bool("no-colors", false, "Disable color output")
 	listCmd.Flags().Int("max-depth", -1, "Maximum depth to display (-1 for unlimited)")
+	listCmd.Flags().IntP("tree-depth", "L", -1, "Alias for --max-depth")
+	listCmd.Flags().Bool("dirs-only", false, "Only show directories with their password counts")
+	listCmd.Flags().Bool("paths", false, "Print only full password names, one per line, quoted for the shell")
 	listCmd.Flags().String("filter", "", "Filter entries by name")
+	listCmd.Flags().Bool("age", false, "Show how long ago each password was modified")
+	listCmd.Flags().Bool("count", false, "Only print the number of passwords and folders")
+	listCmd.Flags().String("older-than", "", "Only show passwords not modified within this age (e.g. 90d, 6mo)")
+	listCmd.Flags().String("since", "", "Only show passwords modified after this time (e.g. 2024-05-01, 7d, last-sync)")
+	listCmd.Flags().Bool("descriptions", false, "Show folder descriptions set with 'chowkidaar describe'")
+	listCmd.Flags().Bool("modified", false, "Only show passwords modified since the last git sync")
+}
+
+// parseSince resolves a --since value, where last-sync means the time of the
+// last git pull or push
+func parseSince(cfg *config.Config, since string) (time.Time, error) {
+	if since != "last-sync" {
+		return list.ParseSince(since, time.Now())
+	}
+
+	gitSync := newGitSync(cfg)
+	if !gitSync.IsGitEnabled() {
+		return time.Time{}, fmt.Errorf("git is not enabled for this store, so there is no last sync")
+	}
+	lastSync, err := gitSync.LastSyncTime()
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to read last sync time: %w", err)
+	}
+	if lastSync.IsZero() {
+		return time.Time{}, fmt.Errorf("the store has never been synced; use --since with a date instead")
+	}
+	return lastSync, nil
+}
+
+// plural formats a count with a singular or plural noun
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
 }
@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"syscall"
+	"time"
+
+	"chowkidaar/internal/clipboard"
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var loginCmd = &cobra.Command{
+	Use:   "login [pass-name]",
+	Short: "Copy the username, then the password, for a login form",
+	Long: `Copy the username of an entry to the clipboard, wait until you have pasted
+it, then copy the password:
+
+    chowkidaar login gmail
+
+On a terminal the password is copied when you press Enter; with --delay, or
+when stdin is not a terminal, it is copied after a fixed wait instead. An entry
+without a username only has its password copied.
+
+The username is replaced on the clipboard by the password, and the password is
+cleared after --clear-after (default 45s) unless something else has been
+copied meanwhile. Use --clear-after 0 to leave it on the clipboard.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		if loginDelay < 0 || loginClearAfter < 0 {
+			return fmt.Errorf("--delay and --clear-after cannot be negative")
+		}
+
+		names, isGlob, err := expandGlob(passwordStore, args[0])
+		if err != nil {
+			return err
+		}
+		if isGlob {
+			return fmt.Errorf("login needs a single password, but '%s' matches %d", args[0], len(names))
+		}
+		passName := names[0]
+
+		fromCache, _ := passwordStore.GetCacheStatus()
+		fromCache = fromCache && !noCache && !masterSupplied()
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		content, err := passwordStore.Show(passName, masterPassword)
+		if err != nil {
+			return fmt.Errorf("failed to retrieve password: %w", err)
+		}
+		entry, err := store.ParseEntry(content)
+		if err != nil {
+			return err
+		}
+
+		if entry.Sensitive() {
+			ok, err := confirmSensitive(passwordStore, passName, masterPassword, fromCache, "Copy its username and password to the clipboard")
+			if err != nil {
+				return err
+			}
+			if !ok {
+				fmt.Fprintln(os.Stderr, "Login cancelled.")
+				return nil
+			}
+		}
+
+		if entry.Username != "" {
+			if err := clipboard.Copy(entry.Username); err != nil {
+				return fmt.Errorf("failed to copy to clipboard: %w", err)
+			}
+			if err := waitForPaste(passName); err != nil {
+				return err
+			}
+		} else if !jsonOutput {
+			fmt.Fprintf(os.Stderr, "'%s' has no username, copying only the password\n", passName)
+		}
+
+		if err := clipboard.Copy(entry.Password); err != nil {
+			return fmt.Errorf("failed to copy to clipboard: %w", err)
+		}
+		if !jsonOutput {
+			fmt.Printf("Copied password of '%s' to clipboard\n", passName)
+		}
+
+		cleared := false
+		if loginClearAfter > 0 {
+			if !jsonOutput {
+				fmt.Fprintf(os.Stderr, "Clearing the clipboard in %s\n", loginClearAfter)
+			}
+			time.Sleep(loginClearAfter)
+			if cleared, err = clearCopied(entry.Password); err != nil {
+				return fmt.Errorf("failed to clear clipboard: %w", err)
+			}
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]interface{}{
+				"name":     passName,
+				"status":   "copied",
+				"username": entry.Username != "",
+				"cleared":  cleared,
+			})
+		}
+		if cleared {
+			fmt.Println("Clipboard cleared")
+		}
+		return nil
+	},
+}
+
+// waitForPaste gives the user time to paste the username: until Enter is
+// pressed on a terminal, otherwise for --delay (or a few seconds)
+func waitForPaste(name string) error {
+	if loginDelay == 0 && term.IsTerminal(int(syscall.Stdin)) {
+		fmt.Fprintf(os.Stderr, "Copied username of '%s' to clipboard, press Enter to copy the password ", name)
+		if _, err := bufio.NewReader(os.Stdin).ReadString('\n'); err != nil {
+			return fmt.Errorf("failed to wait for Enter: %w", err)
+		}
+		return nil
+	}
+
+	delay := loginDelay
+	if delay == 0 {
+		delay = defaultLoginDelay
+	}
+	fmt.Fprintf(os.Stderr, "Copied username of '%s' to clipboard, copying the password in %s\n", name, delay)
+	time.Sleep(delay)
+	return nil
+}
+
+// clearCopied empties the clipboard if it still holds value, so that
+// something copied since is left alone. It reports whether it cleared it.
+func clearCopied(value string) (bool, error) {
+	if current, err := clipboard.Paste(); err == nil && current != value {
+		return false, nil
+	}
+	if err := clipboard.Copy(""); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// defaultLoginDelay is how long login waits before copying the password when
+// it cannot wait for Enter
+const defaultLoginDelay = 5 * time.Second
+
+var (
+	loginDelay      time.Duration
+	loginClearAfter time.Duration
+)
+
+func init() {
+	loginCmd.Flags().DurationVar(&loginDelay, "delay", 0, "Copy the password after this long instead of waiting for Enter")
+	loginCmd.Flags().DurationVar(&loginClearAfter, "clear-after", 45*time.Second, "Clear the password from the clipboard after this long (0 keeps it)")
+}
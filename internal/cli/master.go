@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+)
+
+var (
+	masterFD   int
+	masterFile string
+
+	suppliedOnce     sync.Once
+	suppliedPassword string
+	suppliedErr      error
+)
+
+// masterSupplied reports whether --master-fd or --master-file was given
+func masterSupplied() bool {
+	return masterFD >= 0 || masterFile != ""
+}
+
+// suppliedMasterPassword returns the master password given out-of-band with
+// --master-fd or --master-file. Only the first line is used, so a trailing
+// newline does not matter. It is read once, however often it is asked for.
+func suppliedMasterPassword() (string, error) {
+	suppliedOnce.Do(func() {
+		suppliedPassword, suppliedErr = readSuppliedMasterPassword()
+	})
+	return suppliedPassword, suppliedErr
+}
+
+// readSuppliedMasterPassword reads the first line of the master password file
+// or file descriptor
+func readSuppliedMasterPassword() (string, error) {
+	if masterFD >= 0 && masterFile != "" {
+		return "", fmt.Errorf("--master-fd and --master-file cannot be used together")
+	}
+
+	var source *os.File
+	if masterFile != "" {
+		file, err := os.Open(masterFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to open master password file: %w", err)
+		}
+		defer file.Close()
+		if info, err := file.Stat(); err == nil && info.Mode().Perm()&0077 != 0 {
+			fmt.Fprintf(os.Stderr, "Warning: %s can be read by other users (mode %04o)\n", masterFile, info.Mode().Perm())
+		}
+		source = file
+	} else {
+		source = os.NewFile(uintptr(masterFD), fmt.Sprintf("fd %d", masterFD))
+		if source == nil {
+			return "", fmt.Errorf("invalid file descriptor %d", masterFD)
+		}
+		defer source.Close()
+	}
+
+	// Stop at the first newline so a writer that keeps the pipe open does
+	// not make us wait for EOF
+	line, err := bufio.NewReader(source).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", fmt.Errorf("failed to read master password from %s: %w", source.Name(), err)
+	}
+	password := strings.TrimRight(line, "\r\n")
+	if password == "" {
+		return "", fmt.Errorf("no master password in %s", source.Name())
+	}
+	return password, nil
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Upgrade the store to the current on-disk format",
+	Long: `Bring a store written by an older chowkidaar up to the current on-disk
+format. The store's format version is kept in .store-version, which init
+writes for new stores; a store without it is at version 1. Each needed step
+is applied in order and the version is bumped after it, then the changes are
+committed together.
+
+Every step skips what is already done, so a migration stopped with Ctrl+C or
+by an error can simply be run again. --dry-run lists the pending steps
+without asking for the master password. Unless PASSWORD_STORE_AUTO_BACKUP=false
+is set, a timestamped backup is written to .backups/ first.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		version, err := passwordStore.StoreVersion()
+		if err != nil {
+			return err
+		}
+		pending, err := passwordStore.PendingMigrations()
+		if err != nil {
+			return err
+		}
+
+		if len(pending) == 0 || migrateDryRun {
+			return printMigrations(version, pending, nil, 0)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		if cfg.AutoBackupBeforeBulk {
+			backupPath, err := passwordStore.Backup()
+			if err != nil {
+				return fmt.Errorf("failed to back up password store: %w", err)
+			}
+			if !jsonOutput {
+				fmt.Printf("Backup written to %s\n", backupPath)
+			}
+		}
+
+		ctx, stop := interruptContext(cmd)
+		defer stop()
+		progress, finish := newProgress()
+		passwordStore.SetProgress(progress)
+
+		applied, rewritten, err := passwordStore.Migrate(ctx, masterPassword)
+		finish()
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("migration interrupted after rewriting %s; run 'chowkidaar migrate' again to finish", plural(rewritten, "file"))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to migrate store after rewriting %s: %w", plural(rewritten, "file"), err)
+		}
+
+		return printMigrations(version, pending, applied, rewritten)
+	},
+}
+
+// printMigrations reports the store's format version and the migrations it
+// needs or that were just applied
+func printMigrations(version int, pending, applied []store.Migration, rewritten int) error {
+	if jsonOutput {
+		result := map[string]interface{}{
+			"version": version,
+			"current": store.CurrentStoreVersion,
+			"pending": pending,
+		}
+		if applied != nil {
+			result["applied"] = applied
+			result["rewritten"] = rewritten
+		}
+		return printJSON(result)
+	}
+
+	if len(pending) == 0 {
+		fmt.Printf("Store is at format version %d, nothing to migrate\n", version)
+		return nil
+	}
+	if applied == nil {
+		fmt.Printf("Store is at format version %d; migrating to %d would:\n", version, store.CurrentStoreVersion)
+		for _, migration := range pending {
+			fmt.Printf("  %d: %s\n", migration.Version, migration.Description)
+		}
+		return nil
+	}
+
+	for _, migration := range applied {
+		fmt.Printf("Version %d: %s\n", migration.Version, migration.Description)
+	}
+	fmt.Printf("Store migrated from format version %d to %d, %s rewritten\n", version, store.CurrentStoreVersion, plural(rewritten, "file"))
+	return nil
+}
+
+var migrateDryRun bool
+
+func init() {
+	migrateCmd.Flags().BoolVar(&migrateDryRun, "dry-run", false, "List the pending migration steps without applying them")
+}
@@ -0,0 +1,128 @@
+package cli
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var moveCmd = &cobra.Command{
+	Use:     "mv [old-name] [new-name]",
+	Aliases: []string{"move", "rename"},
+	Short:   "Move or rename a password or directory",
+	Long: `Move or rename a password, or a whole directory of passwords.
+Changing only the case of a name (e.g. Email -> email) is supported,
+including on case-insensitive filesystems such as macOS and Windows.
+With Git enabled a renamed password is moved in the Git index too, like git
+mv, so 'git log --follow' shows its history across the rename.
+
+With --to-store DIR a single password is moved into another store, e.g.
+from a personal store to a work store. The new name is optional there. The
+stores have their own keyfiles, so both master passwords are needed (each
+store's cache is used if warm); the entry is decrypted here, encrypted for the
+other store and only then removed from this one. Its history is not moved.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if targetStore != "" {
+			return cobra.RangeArgs(1, 2)(cmd, args)
+		}
+		return cobra.ExactArgs(2)(cmd, args)
+	},
+	RunE: func(cmd *cobra.Command, args []string) error {
+		oldName, newName := args[0], args[0]
+		if len(args) == 2 {
+			newName = args[1]
+		}
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		if targetStore != "" {
+			return moveToStore(cfg, passwordStore, oldName, newName)
+		}
+
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+		if err := passwordStore.Rename(oldName, newName); err != nil {
+			return fmt.Errorf("failed to move password: %w", err)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]string{"name": newName, "from": oldName, "status": "moved"})
+		}
+
+		fmt.Printf("Moved '%s' to '%s'\n", oldName, newName)
+		return nil
+	},
+}
+
+// moveToStore moves one password into the store at targetStore
+func moveToStore(cfg *config.Config, passwordStore *store.Store, oldName, newName string) error {
+	targetDir, err := filepath.Abs(targetStore)
+	if err != nil {
+		return err
+	}
+	if sourceDir, err := filepath.Abs(cfg.StoreDir); err == nil && sourceDir == targetDir {
+		return fmt.Errorf("--to-store is the current store; use mv without it to rename")
+	}
+
+	targetCfg, err := config.LoadForStore(targetDir)
+	if err != nil {
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+	target, err := store.NewFromConfig(targetCfg)
+	if err != nil || (target.UsesKeyFile() && !target.HasKeyFile()) {
+		return fmt.Errorf("store %s is not initialized; run 'PASSWORD_STORE_DIR=%s chowkidaar init' first", targetDir, targetDir)
+	}
+
+	if err := requireWritable(passwordStore); err != nil {
+		return err
+	}
+	if target.IsReadOnly() {
+		return fmt.Errorf("store %s: %w", targetDir, store.ErrReadOnly)
+	}
+	if target.Exists(newName) {
+		return fmt.Errorf("password '%s' %w in %s", newName, store.ErrExists, targetDir)
+	}
+
+	if masterSupplied() {
+		return fmt.Errorf("--master-fd and --master-file cannot be used with --to-store, which needs two master passwords")
+	}
+	masterPassword, err := promptMasterPassword(passwordStore)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+	// The target's prompt names it, see config.LoadForStore
+	targetPassword, err := promptMasterPassword(target)
+	if err != nil {
+		return fmt.Errorf("failed to read master password: %w", err)
+	}
+
+	if err := passwordStore.MoveTo(target, oldName, newName, masterPassword, targetPassword); err != nil {
+		return fmt.Errorf("failed to move password: %w", err)
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]string{"name": newName, "from": oldName, "store": targetDir, "status": "moved"})
+	}
+
+	fmt.Printf("Moved '%s' to '%s' in %s\n", oldName, newName, targetDir)
+	return nil
+}
+
+var targetStore string
+
+func init() {
+	moveCmd.Flags().StringVar(&targetStore, "to-store", "", "Move a password into the store in this directory")
+}
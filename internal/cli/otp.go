@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"fmt"
+	"sort"
+
+	"chowkidaar/internal/clipboard"
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var otpCmd = &cobra.Command{
+	Use:   "otp",
+	Short: "Manage TOTP secrets stored with passwords",
+	Long: `Store two-factor (TOTP) secrets in entries as otpauth:// URIs, in the otp:
+field that the login template also uses, and compute their current codes.`,
+}
+
+var otpImportCmd = &cobra.Command{
+	Use:   "import [pass-name]",
+	Short: "Store a base32 TOTP secret in an entry as an otpauth:// URI",
+	Long: `Validate a bare base32 secret, as shown by sites that offer "enter this key
+manually", build an otpauth://totp/ URI from it and store it in the otp: field
+of an existing entry, replacing any previous one. For JSON entries the otp
+field is set. The previous version is kept in the history, e.g.
+
+    chowkidaar otp import gmail --secret JBSWY3DPEHPK3PXP --issuer Google
+
+Leave out --secret to be asked for it, which keeps it out of the shell history.
+Services that use SHA256 or SHA512, 8-digit codes or another period say so
+next to the secret; pass --algo, --digits or --period to match, e.g.
+
+    chowkidaar otp import aws --algo SHA256 --digits 8`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passName := args[0]
+
+		passwordStore, err := openOTPStore()
+		if err != nil {
+			return err
+		}
+
+		secret := otpSecret
+		if secret == "" {
+			if secret, err = promptPasswordInput("Enter TOTP secret: "); err != nil {
+				return fmt.Errorf("failed to read TOTP secret: %w", err)
+			}
+		}
+		// Fail on a mistyped secret before asking for the master password
+		if _, err := store.NormalizeTOTPSecret(secret); err != nil {
+			return err
+		}
+		options := store.TOTPOptions{Algorithm: otpAlgorithm, Digits: otpDigits, Period: otpPeriod}
+		if err := options.Validate(); err != nil {
+			return err
+		}
+
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		if err := passwordStore.ImportTOTP(passName, secret, otpIssuer, options, masterPassword); err != nil {
+			return fmt.Errorf("failed to import TOTP secret: %w", err)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]string{"name": passName, "status": "imported"})
+		}
+		fmt.Printf("TOTP secret stored in '%s'\n", passName)
+		return nil
+	},
+}
+
+var otpMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Wrap bare base32 secrets in otp: fields as otpauth:// URIs",
+	Long: `Find entries whose otp: field holds a bare base32 secret rather than an
+otpauth:// URI and, after asking for each, replace it with a URI whose account
+is the entry name. Every entry is decrypted to look, so this takes a while on
+large stores.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passwordStore, err := openOTPStore()
+		if err != nil {
+			return err
+		}
+
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		bare, err := passwordStore.BareTOTPSecrets(masterPassword)
+		if err != nil {
+			return err
+		}
+		names := make([]string, 0, len(bare))
+		for name := range bare {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		var migrated []string
+		for _, name := range names {
+			if !confirm(fmt.Sprintf("'%s' has a bare TOTP secret. Wrap it as an otpauth:// URI?", name)) {
+				continue
+			}
+			if err := passwordStore.ImportTOTP(name, bare[name], otpIssuer, store.TOTPOptions{}, masterPassword); err != nil {
+				return fmt.Errorf("failed to migrate '%s': %w", name, err)
+			}
+			migrated = append(migrated, name)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]interface{}{"found": names, "migrated": migrated})
+		}
+		if len(names) == 0 {
+			fmt.Println("No bare TOTP secrets found")
+			return nil
+		}
+		fmt.Printf("%d of %d entries migrated\n", len(migrated), len(names))
+		return nil
+	},
+}
+
+var otpCodeCmd = &cobra.Command{
+	Use:   "code [pass-name]",
+	Short: "Print the current TOTP code of an entry",
+	Long: `Compute the current two-factor code from the otp: field of an entry, an
+otpauth:// URI or a bare base32 secret. The algorithm, digits and period of
+the URI are honoured. With --clip the code is copied instead of printed.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		passName, err := resolveName(passwordStore, args[0])
+		if err != nil {
+			return err
+		}
+
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		code, remaining, err := passwordStore.ShowTOTP(passName, masterPassword)
+		if err != nil {
+			return err
+		}
+
+		if otpClip {
+			if err := clipboard.Copy(code); err != nil {
+				return fmt.Errorf("failed to copy to clipboard: %w", err)
+			}
+		}
+		if jsonOutput {
+			result := map[string]interface{}{"name": passName, "expires_in": int(remaining.Seconds())}
+			if otpClip {
+				result["status"] = "copied"
+			} else {
+				result["code"] = code
+			}
+			return printJSON(result)
+		}
+		if otpClip {
+			fmt.Printf("Copied TOTP code of '%s' to clipboard (valid for %ds)\n", passName, int(remaining.Seconds()))
+			return nil
+		}
+		fmt.Println(code)
+		return nil
+	},
+}
+
+// openOTPStore opens the store for the otp commands, which write to it
+func openOTPStore() (*store.Store, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	passwordStore, err := store.NewFromConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize store: %w", err)
+	}
+	if err := requireWritable(passwordStore); err != nil {
+		return nil, err
+	}
+	return passwordStore, nil
+}
+
+var (
+	otpSecret    string
+	otpIssuer    string
+	otpAlgorithm string
+	otpDigits    int
+	otpPeriod    int
+	otpClip      bool
+)
+
+func init() {
+	otpImportCmd.Flags().StringVar(&otpSecret, "secret", "", "Base32 TOTP secret (asked for if left out)")
+	otpImportCmd.Flags().StringVar(&otpIssuer, "issuer", "", "Service the secret belongs to, e.g. Google")
+	otpImportCmd.Flags().StringVar(&otpAlgorithm, "algo", store.TOTPSHA1, "HMAC algorithm of the codes: SHA1, SHA256 or SHA512")
+	otpImportCmd.Flags().IntVar(&otpDigits, "digits", 6, "Number of digits in a code (6 to 8)")
+	otpImportCmd.Flags().IntVar(&otpPeriod, "period", 30, "Seconds each code is valid")
+	otpCodeCmd.Flags().BoolVarP(&otpClip, "clip", "c", false, "Copy the code to the clipboard instead of printing it")
+	otpMigrateCmd.Flags().StringVar(&otpIssuer, "issuer", "", "Issuer to record in every migrated URI")
+
+	otpCmd.AddCommand(otpImportCmd)
+	otpCmd.AddCommand(otpMigrateCmd)
+	otpCmd.AddCommand(otpCodeCmd)
+}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var changePasswordCmd = &cobra.Command{
+	Use:     "change-password",
+	Aliases: []string{"passwd"},
+	Short:   "Change the master password",
+	Long: `Re-encrypt every password in the store, and its saved history, with a new
+master password.
+
+Before anything is rewritten you are shown how many entries will be re-encrypted
+and asked to confirm. Unless PASSWORD_STORE_AUTO_BACKUP=false is set, a timestamped
+backup of all encrypted entries and history is written to .backups/ first (never
+synced to Git).`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		files, err := passwordStore.EntryFiles()
+		if err != nil {
+			return err
+		}
+
+		if !confirm(fmt.Sprintf("This will re-encrypt %d passwords with a new master password. Continue?", len(files))) {
+			fmt.Fprintln(os.Stderr, "Master password change cancelled.")
+			return nil
+		}
+
+		oldPassword, err := promptPasswordInput("Enter current master password: ")
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		newPassword, err := promptPasswordInput("Enter new master password: ")
+		if err != nil {
+			return fmt.Errorf("failed to read new master password: %w", err)
+		}
+
+		if len(newPassword) == 0 {
+			return fmt.Errorf("master password cannot be empty")
+		}
+
+		confirmPassword, err := promptPasswordInput("Confirm new master password: ")
+		if err != nil {
+			return fmt.Errorf("failed to read password confirmation: %w", err)
+		}
+
+		if newPassword != confirmPassword {
+			return fmt.Errorf("passwords do not match")
+		}
+
+		if cfg.AutoBackupBeforeBulk {
+			backupPath, err := passwordStore.Backup()
+			if err != nil {
+				return fmt.Errorf("failed to back up password store: %w", err)
+			}
+			fmt.Printf("Backup written to %s\n", backupPath)
+		}
+
+		ctx, stop := interruptContext(cmd)
+		defer stop()
+		progress, finish := newProgress()
+		passwordStore.SetProgress(progress)
+
+		count, err := passwordStore.ChangeMasterPassword(ctx, oldPassword, newPassword)
+		finish()
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("master password change interrupted, no passwords were changed")
+		}
+		if err != nil {
+			return fmt.Errorf("failed to change master password after re-encrypting %d passwords: %w", count, err)
+		}
+
+		fmt.Printf("Master password changed, %d passwords re-encrypted\n", count)
+		return nil
+	},
+}
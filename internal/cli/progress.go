@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"chowkidaar/internal/list"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+// interruptContext returns a context cancelled by Ctrl+C, so bulk
+// operations can stop between entries instead of being killed mid-write
+func interruptContext(cmd *cobra.Command) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+}
+
+// newProgress returns a progress reporter printing "Processing n/total..."
+// on stderr, and a function to end its line. It stays silent with --quiet
+// or --json. On a terminal the line is redrawn in place; elsewhere it is
+// printed at most every few seconds.
+func newProgress() (store.ProgressFunc, func()) {
+	if quiet || jsonOutput {
+		return nil, func() {}
+	}
+
+	terminal := list.IsTerminal(os.Stderr)
+	interval := 100 * time.Millisecond
+	if !terminal {
+		interval = 5 * time.Second
+	}
+
+	var last time.Time
+	shown := false
+	report := func(done, total int) {
+		if done < total && time.Since(last) < interval {
+			return
+		}
+		last = time.Now()
+		shown = true
+		if terminal {
+			fmt.Fprintf(os.Stderr, "\rProcessing %d/%d...", done, total)
+		} else {
+			fmt.Fprintf(os.Stderr, "Processing %d/%d...\n", done, total)
+		}
+	}
+	finish := func() {
+		if shown && terminal {
+			fmt.Fprintln(os.Stderr)
+		}
+	}
+	return report, finish
+}
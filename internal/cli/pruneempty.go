@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var pruneEmptyCmd = &cobra.Command{
+	Use:   "prune-empty",
+	Short: "Remove empty directories from the store",
+	Long: `Walk the whole store and remove every empty directory, such as those left
+behind by manual git operations or failed syncs. Removing a password already
+cleans up its own directory; this catches the rest. .git and .cache are not
+touched. No master password is needed.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		count, err := passwordStore.PruneEmptyDirs()
+		if err != nil {
+			return fmt.Errorf("failed to prune empty directories after removing %d: %w", count, err)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]int{"removed": count})
+		}
+		if count == 1 {
+			fmt.Println("1 empty directory removed")
+		} else {
+			fmt.Printf("%d empty directories removed\n", count)
+		}
+		return nil
+	},
+}
@@ -0,0 +1,80 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var reencryptCmd = &cobra.Command{
+	Use:   "reencrypt [subfolder]",
+	Short: "Upgrade old entries to the current encryption settings",
+	Long: `Re-encrypt entries that were written with an older KDF or older KDF
+parameters, keeping the master password. Each entry's header records how it
+was encrypted, so entries already at the current settings are skipped.
+
+Give a subfolder to limit the upgrade to the entries below it. Unless
+PASSWORD_STORE_AUTO_BACKUP=false is set, a timestamped backup is written to
+.backups/ first. Ctrl+C stops between entries; the ones upgraded so far are
+kept and committed.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		subfolder := ""
+		if len(args) == 1 {
+			subfolder = args[0]
+		}
+
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		if cfg.AutoBackupBeforeBulk {
+			backupPath, err := passwordStore.Backup()
+			if err != nil {
+				return fmt.Errorf("failed to back up password store: %w", err)
+			}
+			if !jsonOutput {
+				fmt.Printf("Backup written to %s\n", backupPath)
+			}
+		}
+
+		ctx, stop := interruptContext(cmd)
+		defer stop()
+		progress, finish := newProgress()
+		passwordStore.SetProgress(progress)
+
+		upgraded, checked, err := passwordStore.Reencrypt(ctx, subfolder, masterPassword)
+		finish()
+		if errors.Is(err, context.Canceled) {
+			return fmt.Errorf("re-encryption interrupted, %s upgraded and committed", plural(upgraded, "password"))
+		}
+		if err != nil {
+			return fmt.Errorf("failed to re-encrypt passwords after upgrading %d: %w", upgraded, err)
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]int{"upgraded": upgraded, "checked": checked})
+		}
+		fmt.Printf("%s upgraded, %d already current\n", plural(upgraded, "password"), checked-upgraded)
+		return nil
+	},
+}
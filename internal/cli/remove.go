@@ -1,12 +1,18 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
 
 	"chowkidaar/internal/config"
 	"chowkidaar/internal/store"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var removeCmd = &cobra.Command{
@@ -14,7 +20,19 @@ var removeCmd = &cobra.Command{
 	Aliases: []string{"rm", "delete"},
 	Short:   "Remove existing password",
 	Long: `Remove the password named pass-name from the password store.
-This command will prompt for confirmation before removing the password.`,
+This command will prompt for confirmation before removing the password.
+
+pass-name may be a glob such as 'Old/*' or 'Old/**' (quote it so the shell
+does not expand it). Matching passwords are listed before confirmation. An
+existing password whose name contains glob characters is removed literally.
+
+A partial name is resolved as in show and confirmed by its full name. With
+--force the exact name is required.
+
+Folders left empty by the removal are deleted too. A folder that still holds
+hidden files, such as a description set with describe, counts as non-empty
+and is kept. --keep-empty-dirs keeps empty folders as well; Git does not
+track them, so they are not synced.`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		passName := args[0]
@@ -24,32 +42,139 @@ This command will prompt for confirmation before removing the password.`,
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		passwordStore, err := store.NewWithGitConfig(cfg.StoreDir, cfg.CacheTimeout, cfg.GitURL, cfg.GitAutoSync)
+		passwordStore, err := store.NewFromConfig(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to initialize store: %w", err)
 		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
 
-		if !force {
-			fmt.Printf("Are you sure you want to delete '%s'? [y/N]: ", passName)
-			var response string
-			fmt.Scanln(&response)
-			if response != "y" && response != "Y" && response != "yes" {
-				fmt.Println("Password removal cancelled.")
-				return nil
+		if !force && !assumeYes && jsonOutput {
+			return fmt.Errorf("refusing to prompt for confirmation in JSON mode, use --force")
+		}
+
+		// --force skips the confirmation that shows what a partial name
+		// resolved to, so it only removes passwords given by their exact name
+		if !force || store.IsGlob(passName) {
+			names, isGlob, err := expandGlob(passwordStore, passName)
+			if err != nil {
+				return err
+			}
+			if isGlob {
+				return removeMatches(passwordStore, passName, names)
 			}
+			passName = names[0]
 		}
 
-		if err := passwordStore.Remove(passName); err != nil {
+		if !force && !confirm(fmt.Sprintf("Are you sure you want to delete '%s'?", passName)) {
+			fmt.Fprintln(os.Stderr, "Password removal cancelled.")
+			return nil
+		}
+
+		if err := passwordStore.Remove(passName, keepEmptyDirs); err != nil {
 			return fmt.Errorf("failed to remove password: %w", err)
 		}
 
+		if jsonOutput {
+			return printJSON(map[string]string{"name": passName, "status": "removed"})
+		}
+
 		fmt.Printf("Password '%s' removed successfully\n", passName)
 		return nil
 	},
 }
 
-var force bool
+// expandGlob returns the passwords a name argument refers to. An existing
+// password is taken literally even if its name contains glob characters, and
+// any other name without them is resolved as a partial name.
+func expandGlob(passwordStore *store.Store, name string) ([]string, bool, error) {
+	if passwordStore.Exists(name) {
+		return []string{name}, false, nil
+	}
+	if !store.IsGlob(name) {
+		resolved, err := resolveName(passwordStore, name)
+		return []string{resolved}, false, err
+	}
+
+	names, err := passwordStore.Glob(name)
+	if err != nil {
+		return nil, true, err
+	}
+	if len(names) == 0 {
+		return nil, true, fmt.Errorf("no passwords match '%s'", name)
+	}
+	return names, true, nil
+}
+
+// resolveName resolves a partial name with Store.Resolve. When it matches
+// several entries the user picks one on a terminal; in JSON mode or without
+// a terminal the ambiguity is an error.
+func resolveName(passwordStore *store.Store, name string) (string, error) {
+	resolved, err := passwordStore.Resolve(name)
+	var ambiguous *store.AmbiguousNameError
+	if errors.As(err, &ambiguous) && !jsonOutput && term.IsTerminal(int(syscall.Stdin)) {
+		resolved, err = chooseName(ambiguous)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if normalized, _ := store.NormalizeName(name); resolved != normalized {
+		fmt.Fprintf(os.Stderr, "Using '%s' for '%s'\n", resolved, name)
+	}
+	return resolved, nil
+}
+
+// chooseName asks which of the entries matching a partial name was meant
+func chooseName(ambiguous *store.AmbiguousNameError) (string, error) {
+	fmt.Fprintf(os.Stderr, "'%s' matches %s:\n", ambiguous.Name, plural(len(ambiguous.Matches), "password"))
+	for i, match := range ambiguous.Matches {
+		fmt.Fprintf(os.Stderr, "  %d) %s\n", i+1, match)
+	}
+
+	answer, err := readVisibleInput(fmt.Sprintf("Choose 1-%d: ", len(ambiguous.Matches)))
+	if err != nil {
+		return "", err
+	}
+	choice, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || choice < 1 || choice > len(ambiguous.Matches) {
+		return "", fmt.Errorf("no password chosen for '%s'", ambiguous.Name)
+	}
+	return ambiguous.Matches[choice-1], nil
+}
+
+// removeMatches removes the passwords matched by a glob after listing them
+// and asking for confirmation
+func removeMatches(passwordStore *store.Store, pattern string, names []string) error {
+	if !force {
+		prompt := fmt.Sprintf("%s match '%s':\n  %s\nAre you sure you want to delete them?",
+			plural(len(names), "password"), pattern, strings.Join(names, "\n  "))
+		if !confirm(prompt) {
+			fmt.Fprintln(os.Stderr, "Password removal cancelled.")
+			return nil
+		}
+	}
+
+	removed, err := passwordStore.RemoveBatch(names, keepEmptyDirs)
+	if err != nil {
+		return err
+	}
+
+	if jsonOutput {
+		return printJSON(map[string]interface{}{"removed": removed})
+	}
+
+	fmt.Printf("Removed %s matching '%s'\n", plural(len(removed), "password"), pattern)
+	return nil
+}
+
+var (
+	force         bool
+	keepEmptyDirs bool
+)
 
 func init() {
 	removeCmd.Flags().BoolVarP(&force, "force", "f", false, "Force removal without confirmation")
+	removeCmd.Flags().BoolVar(&keepEmptyDirs, "keep-empty-dirs", false, "Keep folders left empty by the removal")
 }
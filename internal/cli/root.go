@@ -1,6 +1,16 @@
 package cli
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"chowkidaar/internal/clipboard"
+	"chowkidaar/internal/crypto"
+	"chowkidaar/internal/gitsync"
+	"chowkidaar/internal/store"
+
 	"github.com/spf13/cobra"
 )
 
@@ -18,21 +28,185 @@ Features:
 - Master password cached for 5 minutes by default
 
 Use 'chowkidaar cache' commands to manage the cache behavior.`,
+	// Execute reports errors itself, once, as text or JSON
+	SilenceErrors: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		if jsonOutput {
+			cmd.Root().SilenceUsage = true
+		}
+		if primarySelection {
+			clipboard.SetSelection(clipboard.SelectionPrimary)
+		}
+	},
 }
 
-// Execute runs the CLI
+var jsonOutput bool
+var assumeYes bool
+var quiet bool
+var noCache bool
+var forceUnlock bool
+var noCommit bool
+var allowPlaintext bool
+var primarySelection bool
+
+// Execute runs the CLI and reports any error on stderr, or as JSON on
+// stdout with --json. The caller only has to set the exit status.
 func Execute() error {
-	return rootCmd.Execute()
+	err := rootCmd.Execute()
+	var reported *reportedError
+	if err != nil && !errors.As(err, &reported) {
+		if jsonOutput {
+			printJSON(map[string]interface{}{"error": err.Error(), "exit_code": ExitCode(err)})
+		} else {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+	}
+	return err
+}
+
+// reportedError is returned by commands that already printed the details of
+// a failure, such as a JSON report, and only need a non-zero exit status
+type reportedError struct {
+	err error
+}
+
+func (e *reportedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *reportedError) Unwrap() error {
+	return e.err
+}
+
+// Exit statuses, so that scripts can tell failures apart. They are part of
+// the command line interface: do not renumber them.
+const (
+	ExitError    = 1 // Any other failure, including usage errors
+	ExitNotFound = 2 // The entry or folder does not exist
+	ExitAuth     = 3 // The master password, secret or keyfile did not decrypt
+	ExitRemote   = 4 // A Git remote could not be reached or refused the operation
+	ExitExists   = 5 // The entry is already there
+)
+
+// ExitCode returns the exit status for an error returned by Execute, 0 for nil
+func ExitCode(err error) int {
+	switch {
+	case err == nil:
+		return 0
+	case errors.Is(err, store.ErrNotFound):
+		return ExitNotFound
+	case errors.Is(err, crypto.ErrAuthFailed), errors.Is(err, crypto.ErrKeyFileChanged):
+		return ExitAuth
+	case errors.Is(err, gitsync.ErrRemote), errors.Is(err, gitsync.ErrUntrustedCommit):
+		return ExitRemote
+	case errors.Is(err, store.ErrExists):
+		return ExitExists
+	default:
+		return ExitError
+	}
+}
+
+// confirm asks a yes/no question on stderr that defaults to no. With --yes
+// the question is logged with the automatic answer, so unattended runs still
+// record what was confirmed.
+func confirm(prompt string) bool {
+	if assumeYes {
+		fmt.Fprintf(os.Stderr, "%s [y/N]: y (--yes)\n", prompt)
+		return true
+	}
+
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", prompt)
+	var response string
+	fmt.Scanln(&response)
+	return response == "y" || response == "Y" || response == "yes"
+}
+
+// requireWritable fails early for commands that write, so a read-only store
+// is reported before the user is asked for anything. With --force-unlock it
+// also breaks a store lock left by another process.
+func requireWritable(passwordStore *store.Store) error {
+	if passwordStore.IsReadOnly() {
+		return fmt.Errorf("%w, only show, list and other read commands work", store.ErrReadOnly)
+	}
+	if forceUnlock {
+		if err := passwordStore.ForceUnlock(); err != nil {
+			return err
+		}
+		fmt.Fprintln(os.Stderr, "Warning: store lock removed with --force-unlock")
+	}
+	if noCommit {
+		passwordStore.DisableAutoCommit()
+	}
+	if allowPlaintext {
+		passwordStore.AllowPlaintextCommits()
+	}
+	return nil
+}
+
+// promptMasterPassword asks for the master password with the store's
+// configured prompt, skipping the cache when --no-cache is set. A password
+// given with --master-fd or --master-file is used instead of prompting.
+func promptMasterPassword(passwordStore *store.Store) (string, error) {
+	if masterSupplied() {
+		if noCache {
+			passwordStore.DisableCache()
+		}
+		return suppliedMasterPassword()
+	}
+	if noCache {
+		return passwordStore.PromptMasterPasswordNoCache(passwordStore.MasterPrompt())
+	}
+	return passwordStore.PromptMasterPassword(passwordStore.MasterPrompt())
+}
+
+// printJSON writes a value to stdout as a single line of JSON
+func printJSON(v interface{}) error {
+	return json.NewEncoder(os.Stdout).Encode(v)
 }
 
 func init() {
+	rootCmd.PersistentFlags().BoolVar(&jsonOutput, "json", false, "Emit machine-readable JSON output instead of human text")
+	rootCmd.PersistentFlags().BoolVarP(&assumeYes, "yes", "y", false, "Answer yes to confirmation prompts")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Do not report the progress of bulk operations")
+	rootCmd.PersistentFlags().BoolVar(&forceUnlock, "force-unlock", false, "Remove a store lock left by a stopped or hung chowkidaar process")
+	rootCmd.PersistentFlags().BoolVar(&noCommit, "no-commit", false, "Leave changes uncommitted; commit them later with 'chowkidaar commit'")
+	rootCmd.PersistentFlags().BoolVar(&allowPlaintext, "allow-plaintext", false, "Commit files that are not encrypted entries, which are refused by default")
+
+	for _, cmd := range []*cobra.Command{showCmd, loginCmd, insertCmd, editCmd, shareCmd, browseCmd, importCSVCmd, reencryptCmd, moveCmd, convertCmd, otpImportCmd, otpCodeCmd, otpMigrateCmd, exportCmd, generateCmd, migrateCmd} {
+		cmd.Flags().BoolVar(&noCache, "no-cache", false, "Ask for the master password even if it is cached, and do not cache it")
+		cmd.Flags().IntVar(&masterFD, "master-fd", -1, "Read the master password from this file descriptor instead of prompting")
+		cmd.Flags().StringVar(&masterFile, "master-file", "", "Read the master password from the first line of this file instead of prompting")
+	}
+
+	for _, cmd := range []*cobra.Command{showCmd, loginCmd, browseCmd, generateCmd, insertCmd, otpCodeCmd} {
+		cmd.Flags().BoolVar(&primarySelection, "primary", false, "Use the PRIMARY selection (middle click) instead of the clipboard on X11 and Wayland")
+	}
+
 	// Add subcommands
 	rootCmd.AddCommand(initCmd)
 	rootCmd.AddCommand(insertCmd)
 	rootCmd.AddCommand(showCmd)
+	rootCmd.AddCommand(loginCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(removeCmd)
 	rootCmd.AddCommand(editCmd)
 	rootCmd.AddCommand(cacheCmd)
 	rootCmd.AddCommand(gitCmd)
+	rootCmd.AddCommand(commitCmd)
+	rootCmd.AddCommand(shareCmd)
+	rootCmd.AddCommand(changePasswordCmd)
+	rootCmd.AddCommand(browseCmd)
+	rootCmd.AddCommand(moveCmd)
+	rootCmd.AddCommand(historyCmd)
+	rootCmd.AddCommand(hideNamesCmd)
+	rootCmd.AddCommand(importCSVCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(reencryptCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(convertCmd)
+	rootCmd.AddCommand(pruneEmptyCmd)
+	rootCmd.AddCommand(otpCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(describeCmd)
+	rootCmd.AddCommand(generateCmd)
 }
@@ -0,0 +1,66 @@
+package cli
+
+import (
+	"fmt"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var shareCmd = &cobra.Command{
+	Use:   "share [pass-name]",
+	Short: "Share a password using a shared secret",
+	Long: `Write an additional copy of a password encrypted with a shared secret.
+The copy is stored as .shared/<pass-name>.enc and can be read by
+anyone who knows the shared secret, without your master password or keyfile:
+
+  chowkidaar show --shared <pass-name>
+
+Your own encrypted entry is not modified.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passName := args[0]
+
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+		if err := requireWritable(passwordStore); err != nil {
+			return err
+		}
+
+		// Prompt for master password
+		masterPassword, err := promptMasterPassword(passwordStore)
+		if err != nil {
+			return fmt.Errorf("failed to read master password: %w", err)
+		}
+
+		sharedSecret, err := promptPasswordInput("Enter shared secret: ")
+		if err != nil {
+			return fmt.Errorf("failed to read shared secret: %w", err)
+		}
+
+		confirmSecret, err := promptPasswordInput("Confirm shared secret: ")
+		if err != nil {
+			return fmt.Errorf("failed to read shared secret confirmation: %w", err)
+		}
+
+		if sharedSecret != confirmSecret {
+			return fmt.Errorf("shared secrets do not match")
+		}
+
+		if err := passwordStore.ShareEntry(passName, sharedSecret, masterPassword); err != nil {
+			return fmt.Errorf("failed to share password: %w", err)
+		}
+
+		fmt.Printf("Password for '%s' shared successfully\n", passName)
+		return nil
+	},
+}
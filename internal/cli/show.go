@@ -2,11 +2,19 @@ package cli
 
 import (
 	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
+	"chowkidaar/internal/clipboard"
 	"chowkidaar/internal/config"
 	"chowkidaar/internal/store"
 
 	"github.com/spf13/cobra"
+	"golang.org/x/term"
 )
 
 var showCmd = &cobra.Command{
@@ -15,16 +23,54 @@ var showCmd = &cobra.Command{
 	Long: `Decrypt and print a password to stdout.
 If no password name is provided, list all passwords.
 
-The master password will be cached for 5 minutes (configurable) to avoid repeated prompts.`,
+The password is printed exactly as stored and no newline is added. Entries
+saved by editors often end in a newline, and many keep notes below the
+password; use --trim to print only the first line without trailing
+whitespace, e.g. pw=$(chowkidaar show --trim gmail).
+
+pass-name may be a glob such as 'Email/*' or 'Email/**'; every match is
+printed under its name. An existing password is always shown literally.
+Any other name may be partial: "gm" shows Email/gmail when no other entry
+starts with or contains it. When several do, you are asked to pick one.
+
+When stdout is a terminal, where the password would stay in the scrollback,
+show masks it as with --mask unless --stdout (or --print) is given; piped or
+redirected output is printed as usual. Set PASSWORD_STORE_ALLOW_TTY_PRINT=true
+to always print.
+
+With --clip the first line is copied to the clipboard instead. Use --clip=N
+(or "show <name> --clip N") to copy line N of a multi-line entry. With
+PASSWORD_STORE_COPY_ON_SHOW=true, show always copies unless --print (or
+--out or --mask) is given, so pipes keep working: show --print x | ...
+
+The master password will be cached for 5 minutes (configurable) to avoid repeated prompts.
+With --mask the password line is printed as asterisks and the lines below it
+(username:, url:, ...) in the clear. Press r to reveal the whole entry for 10
+seconds; the screen is cleared afterwards. Glob matches are only masked.
+
+Use --no-cache to be asked for it anyway, without caching it.
+
+--peek shows the entry on the terminal's alternate screen for 10 seconds,
+or --peek=N for N seconds, then clears it, for a shared screen. Nothing is
+left in the scrollback, and any key or Ctrl+C hides it at once.
+
+An entry with a "sensitive: true" line is handled more strictly: a cached
+master password is not enough, it must be typed again, and the entry is only
+shown or copied after you confirm. Glob matches show it masked.
+
+--field prints a single value: password, username or a field name. Plain
+entries are read as a password line followed by "key: value" lines; JSON
+entries (see insert --json-entry) are read from their document. With --clip the
+value is copied instead. For JSON entries a plain --clip copies the password.`,
 	Aliases: []string{"view", "get"},
-	Args:    cobra.MaximumNArgs(1),
+	Args:    cobra.RangeArgs(0, 2),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := config.Load()
 		if err != nil {
 			return fmt.Errorf("failed to load config: %w", err)
 		}
 
-		passwordStore, err := store.NewWithGitConfig(cfg.StoreDir, cfg.CacheTimeout, cfg.GitURL, cfg.GitAutoSync)
+		passwordStore, err := store.NewFromConfig(cfg)
 		if err != nil {
 			return fmt.Errorf("failed to initialize store: %w", err)
 		}
@@ -36,15 +82,202 @@ The master password will be cached for 5 minutes (configurable) to avoid repeate
 
 		passName := args[0]
 
-		// Prompt for master password
-		masterPassword, err := passwordStore.PromptMasterPassword("Enter master password: ")
+		if cmd.Flags().Changed("clip") && outputPath != "" {
+			return fmt.Errorf("--clip and --out cannot be used together")
+		}
+		if printOutput && cmd.Flags().Changed("clip") {
+			return fmt.Errorf("--print (or --stdout) and --clip cannot be used together")
+		}
+		if maskOutput && (outputPath != "" || jsonOutput || trimOutput) {
+			return fmt.Errorf("--mask cannot be used with --out, --json or --trim")
+		}
+		if showField != "" && (maskOutput || trimOutput) {
+			return fmt.Errorf("--field cannot be used with --mask or --trim")
+		}
+		peek := cmd.Flags().Changed("peek")
+		if peek && (cmd.Flags().Changed("clip") || outputPath != "" || jsonOutput || maskOutput) {
+			return fmt.Errorf("--peek cannot be used with --clip, --out, --json or --mask")
+		}
+		if peek && peekSeconds <= 0 {
+			return fmt.Errorf("--peek needs a positive number of seconds")
+		}
+		if peek && !term.IsTerminal(int(syscall.Stdout)) {
+			return fmt.Errorf("--peek needs a terminal")
+		}
+
+		// Allow "show gmail --clip 2" as well as "--clip=2"
+		if len(args) == 2 {
+			line, err := strconv.Atoi(args[1])
+			if !cmd.Flags().Changed("clip") || err != nil {
+				return fmt.Errorf("unexpected argument '%s'", args[1])
+			}
+			clipLine = line
+		}
+
+		names, isGlob, err := expandGlob(passwordStore, passName)
 		if err != nil {
-			return fmt.Errorf("failed to read master password: %w", err)
+			return err
+		}
+		if isGlob && (cmd.Flags().Changed("clip") || outputPath != "" || showField != "" || peek) {
+			return fmt.Errorf("--clip, --out, --field and --peek need a single password, but '%s' matches %d", passName, len(names))
+		}
+		if !isGlob {
+			passName = names[0]
 		}
 
-		password, err := passwordStore.Show(passName, masterPassword)
+		// PASSWORD_STORE_COPY_ON_SHOW turns a plain show into --clip
+		clip := cmd.Flags().Changed("clip")
+		if cfg.CopyOnShow && !clip && !printOutput && outputPath == "" && !maskOutput && !peek {
+			if isGlob {
+				return fmt.Errorf("'%s' matches %d passwords, but only one can be copied; use --print to print them", passName, len(names))
+			}
+			clip = true
+		}
+
+		// Secrets printed to a terminal stay in its scrollback
+		if !clip && outputPath == "" && !printOutput && !jsonOutput && !peek && !cfg.AllowTTYPrint && term.IsTerminal(int(syscall.Stdout)) {
+			if strings.EqualFold(showField, "password") {
+				return fmt.Errorf("refusing to print the password to a terminal; use --stdout to print it or --clip to copy it")
+			}
+			if showField == "" && !maskOutput {
+				maskOutput = true
+				fmt.Fprintln(os.Stderr, "Masked because stdout is a terminal; use --stdout to print the password or --clip to copy it")
+			}
+		}
+
+		// reveal decrypts one password with the shared secret or master password
+		var reveal func(name string) (string, error)
+		var masterPassword string
+		fromCache := false
+		if sharedFlag {
+			sharedSecret, err := promptPasswordInput("Enter shared secret: ")
+			if err != nil {
+				return fmt.Errorf("failed to read shared secret: %w", err)
+			}
+			reveal = func(name string) (string, error) {
+				password, err := passwordStore.ShowShared(name, sharedSecret)
+				if err != nil {
+					return "", fmt.Errorf("failed to retrieve shared password: %w", err)
+				}
+				return password, nil
+			}
+		} else {
+			// Prompt for master password
+			fromCache, _ = passwordStore.GetCacheStatus()
+			fromCache = fromCache && !noCache && !masterSupplied()
+			masterPassword, err = promptMasterPassword(passwordStore)
+			if err != nil {
+				return fmt.Errorf("failed to read master password: %w", err)
+			}
+			reveal = func(name string) (string, error) {
+				password, err := passwordStore.Show(name, masterPassword)
+				if err != nil {
+					return "", fmt.Errorf("failed to retrieve password: %w", err)
+				}
+				return password, nil
+			}
+		}
+
+		if isGlob {
+			return showMatches(names, reveal)
+		}
+
+		password, err := reveal(passName)
 		if err != nil {
-			return fmt.Errorf("failed to retrieve password: %w", err)
+			return err
+		}
+
+		if store.IsSensitiveEntry(password) {
+			action := "Show it"
+			if clip {
+				action = "Copy it to the clipboard"
+			}
+			if ok, err := confirmSensitive(passwordStore, passName, masterPassword, fromCache, action); err != nil || !ok {
+				if err == nil {
+					fmt.Fprintln(os.Stderr, "Show cancelled.")
+				}
+				return err
+			}
+		}
+
+		if showField != "" {
+			entry, err := store.ParseEntry(password)
+			if err != nil {
+				return err
+			}
+			value, ok := entry.Field(showField)
+			if !ok {
+				return fmt.Errorf("'%s' has no field '%s' (available: %s)", passName, showField, strings.Join(entry.FieldNames(), ", "))
+			}
+			if clip {
+				if err := clipboard.Copy(value); err != nil {
+					return fmt.Errorf("failed to copy to clipboard: %w", err)
+				}
+				if jsonOutput {
+					return printJSON(map[string]string{"name": passName, "status": "copied", "field": showField})
+				}
+				fmt.Printf("Copied %s of '%s' to clipboard\n", showField, passName)
+				return nil
+			}
+			if jsonOutput && outputPath == "" {
+				return printJSON(map[string]string{"name": passName, "field": showField, "value": value})
+			}
+			password = value
+		} else if clip && store.IsJSONEntry(password) {
+			if cmd.Flags().Changed("clip") && clipLine != 1 {
+				return fmt.Errorf("'%s' is a JSON entry; use --field to pick what to copy", passName)
+			}
+			if err := clipboard.Copy(store.TrimSecret(password)); err != nil {
+				return fmt.Errorf("failed to copy to clipboard: %w", err)
+			}
+			if jsonOutput {
+				return printJSON(map[string]string{"name": passName, "status": "copied", "field": "password"})
+			}
+			fmt.Printf("Copied password of '%s' to clipboard\n", passName)
+			return nil
+		}
+
+		if clip {
+			lines := strings.Split(strings.TrimSuffix(password, "\n"), "\n")
+			if clipLine < 1 || clipLine > len(lines) {
+				return fmt.Errorf("line %d out of range: '%s' has %d lines", clipLine, passName, len(lines))
+			}
+			if err := clipboard.Copy(lines[clipLine-1]); err != nil {
+				return fmt.Errorf("failed to copy to clipboard: %w", err)
+			}
+			if jsonOutput {
+				return printJSON(map[string]interface{}{"name": passName, "status": "copied", "line": clipLine})
+			}
+			fmt.Printf("Copied line %d of '%s' to clipboard\n", clipLine, passName)
+			return nil
+		}
+
+		if maskOutput {
+			return showMasked(password)
+		}
+
+		if trimOutput {
+			password = store.TrimSecret(password)
+		}
+		password = store.EntryText(password)
+
+		if peek {
+			return showPeek(password, time.Duration(peekSeconds)*time.Second)
+		}
+
+		if outputPath != "" {
+			if err := store.WriteSecretFile(outputPath, []byte(password), outputForce); err != nil {
+				return fmt.Errorf("failed to write password: %w", err)
+			}
+			if jsonOutput {
+				return printJSON(map[string]string{"name": passName, "status": "written", "path": outputPath})
+			}
+			fmt.Printf("Password for '%s' written to %s\n", passName, outputPath)
+			return nil
+		}
+
+		if jsonOutput {
+			return printJSON(map[string]string{"name": passName, "password": password})
 		}
 
 		fmt.Print(password)
@@ -52,8 +285,166 @@ The master password will be cached for 5 minutes (configurable) to avoid repeate
 	},
 }
 
-var clipboardFlag bool
+// showMatches prints every password matched by a glob under its name.
+// Sensitive entries are always masked; show them one at a time instead.
+func showMatches(names []string, reveal func(name string) (string, error)) error {
+	entries := make([]map[string]string, 0, len(names))
+	for _, name := range names {
+		password, err := reveal(name)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		if trimOutput {
+			password = store.TrimSecret(password)
+		}
+		if maskOutput || store.IsSensitiveEntry(password) {
+			password = store.MaskSecret(password)
+		}
+		password = store.EntryText(password)
+		entries = append(entries, map[string]string{"name": name, "password": password})
+	}
+
+	if jsonOutput {
+		return printJSON(entries)
+	}
+
+	for _, entry := range entries {
+		fmt.Printf("%s:\n%s\n", entry["name"], strings.TrimSuffix(entry["password"], "\n"))
+	}
+	return nil
+}
+
+// confirmSensitive applies the stricter handling of an entry marked
+// sensitive before it is shown or copied: a master password that came from
+// the cache must be typed again, and the user must confirm the action.
+func confirmSensitive(passwordStore *store.Store, name, masterPassword string, fromCache bool, action string) (bool, error) {
+	if fromCache {
+		fmt.Fprintf(os.Stderr, "'%s' is marked sensitive, so the cached master password is not used.\n", name)
+		again, err := passwordStore.PromptMasterPasswordNoCache(passwordStore.MasterPrompt())
+		if err != nil {
+			return false, fmt.Errorf("failed to read master password: %w", err)
+		}
+		if again != masterPassword {
+			return false, fmt.Errorf("wrong master password for sensitive entry '%s'", name)
+		}
+	}
+
+	if !assumeYes && jsonOutput {
+		return false, fmt.Errorf("'%s' is marked sensitive; refusing to prompt for confirmation in JSON mode, use --yes", name)
+	}
+	return confirm(fmt.Sprintf("'%s' is marked sensitive. %s?", name, action)), nil
+}
+
+// showMasked prints an entry with its password masked. On a terminal it then
+// offers to reveal the whole entry for maskRevealTime on the alternate screen,
+// which is cleared afterwards like the password banner.
+func showMasked(content string) error {
+	fmt.Println(strings.TrimSuffix(store.MaskSecret(content), "\n"))
+	if !term.IsTerminal(int(syscall.Stdin)) || !term.IsTerminal(int(syscall.Stdout)) {
+		return nil
+	}
+
+	fmt.Fprintf(os.Stderr, "Press r to reveal the password for %d seconds, any other key to continue ", int(maskRevealTime.Seconds()))
+	oldState, err := term.MakeRaw(int(syscall.Stdin))
+	if err != nil {
+		return err
+	}
+	defer term.Restore(int(syscall.Stdin), oldState)
+
+	keys := readKeys()
+	key := <-keys
+	fmt.Fprint(os.Stderr, "\r\033[K")
+	if key != 'r' && key != 'R' {
+		return nil
+	}
+
+	revealTemporarily(store.EntryText(content), maskRevealTime, keys, nil)
+	return nil
+}
+
+// showPeek shows an entry on the alternate screen for a while, for show
+// --peek. Stdin is put in raw mode when it is a terminal so that any key,
+// Ctrl+C included, hides the entry; a signal does too.
+func showPeek(content string, duration time.Duration) error {
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
+	var keys <-chan byte
+	if term.IsTerminal(int(syscall.Stdin)) {
+		oldState, err := term.MakeRaw(int(syscall.Stdin))
+		if err != nil {
+			return err
+		}
+		defer term.Restore(int(syscall.Stdin), oldState)
+		keys = readKeys()
+	}
+
+	revealTemporarily(content, duration, keys, interrupt)
+	return nil
+}
+
+// readKeys reads stdin one byte at a time in the background, so a reveal can
+// time out while waiting for a key. The channel is closed at end of input.
+func readKeys() <-chan byte {
+	keys := make(chan byte, 1)
+	go func() {
+		var buf [1]byte
+		for {
+			n, err := os.Stdin.Read(buf[:])
+			if err != nil {
+				close(keys)
+				return
+			}
+			if n > 0 {
+				keys <- buf[0]
+			}
+		}
+	}()
+	return keys
+}
+
+// revealTemporarily prints content on the alternate screen, which keeps it
+// out of the scrollback, until the duration passes, a key is read or a
+// signal arrives, then clears it. A nil keys or interrupt channel never fires.
+func revealTemporarily(content string, duration time.Duration, keys <-chan byte, interrupt <-chan os.Signal) {
+	fmt.Print("\033[?1049h\033[2J\033[H")
+	fmt.Print(strings.ReplaceAll(strings.TrimSuffix(content, "\n"), "\n", "\r\n"))
+	if keys != nil {
+		fmt.Printf("\r\n\r\nHidden in %d seconds, press any key to hide now", int(duration.Seconds()))
+	}
+	select {
+	case <-keys:
+	case <-interrupt:
+	case <-time.After(duration):
+	}
+	fmt.Print("\033[2J\033[H\033[?1049l")
+}
+
+// maskRevealTime is how long show --mask reveals a password
+const maskRevealTime = 10 * time.Second
+
+var clipLine int
+var sharedFlag bool
+var trimOutput bool
+var maskOutput bool
+var printOutput bool
+var showField string
+var outputPath string
+var outputForce bool
+var peekSeconds int
 
 func init() {
-	showCmd.Flags().BoolVarP(&clipboardFlag, "clip", "c", false, "Copy password to clipboard")
+	showCmd.Flags().IntVarP(&clipLine, "clip", "c", 1, "Copy line N of the password (default first) to clipboard")
+	showCmd.Flags().Lookup("clip").NoOptDefVal = "1"
+	showCmd.Flags().BoolVar(&trimOutput, "trim", false, "Print only the first line, without trailing whitespace")
+	showCmd.Flags().BoolVar(&printOutput, "print", false, "Print the password even when PASSWORD_STORE_COPY_ON_SHOW is set")
+	showCmd.Flags().BoolVar(&printOutput, "stdout", false, "Print the password even when stdout is a terminal (same as --print)")
+	showCmd.Flags().BoolVar(&maskOutput, "mask", false, "Print the password line as asterisks and the other lines in the clear")
+	showCmd.Flags().StringVar(&showField, "field", "", "Print only this value of the entry: password, username or a field name")
+	showCmd.Flags().BoolVar(&sharedFlag, "shared", false, "Read the shared copy of the password using a shared secret")
+	showCmd.Flags().StringVarP(&outputPath, "out", "o", "", "Write password to a file with 0600 permissions")
+	showCmd.Flags().BoolVar(&outputForce, "force", false, "Overwrite the --out file if it already exists")
+	showCmd.Flags().IntVar(&peekSeconds, "peek", int(maskRevealTime.Seconds()), "Show the entry for N seconds on the alternate screen, then clear it")
+	showCmd.Flags().Lookup("peek").NoOptDefVal = strconv.Itoa(int(maskRevealTime.Seconds()))
 }
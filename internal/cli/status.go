@@ -0,0 +1,175 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/list"
+	"chowkidaar/internal/store"
+
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Summarize the health of the password store",
+	Long: `Show where the store lives, how many passwords it holds, whether the
+keyfile is present, whether the master password is cached and how the store
+compares with its Git remote, including when this device last synced.
+
+Ahead/behind counts are as of the last pull; status never contacts the remote
+and never asks for the master password.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+
+		passwordStore, err := store.NewFromConfig(cfg)
+		if err != nil {
+			return fmt.Errorf("failed to initialize store: %w", err)
+		}
+
+		status := map[string]interface{}{"store": cfg.StoreDir, "hidden_names": passwordStore.HiddenNames(), "read_only": passwordStore.IsReadOnly()}
+		if cfg.MarkerFile != "" {
+			status["selected_by"] = cfg.MarkerFile
+		}
+
+		// Hidden names can only be read with the keyfile
+		namesReadable := !passwordStore.HiddenNames() || passwordStore.HasKeyFile()
+
+		entries := "unknown (hidden names: keyfile missing)"
+		builder, err := newListBuilder(cfg, list.DefaultOptions())
+		if err == nil && namesReadable {
+			var passwords, folders int
+			passwords, folders, err = builder.Count("")
+			if err == nil {
+				status["passwords"], status["folders"] = passwords, folders
+				entries = fmt.Sprintf("%s in %s", plural(passwords, "password"), plural(folders, "folder"))
+			}
+		}
+		if err != nil && namesReadable {
+			entries = fmt.Sprintf("unknown (%v)", err)
+		}
+
+		keyfile := "missing (run 'chowkidaar init' with your recovery phrase to restore it)"
+		status["keyfile"] = passwordStore.HasKeyFile()
+		if !passwordStore.UsesKeyFile() {
+			keyfile = "not used (master password only)"
+			status["password_only"] = true
+		} else if passwordStore.HasKeyFile() {
+			fingerprint, err := passwordStore.KeyFileFingerprint()
+			if err != nil {
+				keyfile = fmt.Sprintf("unreadable (%v)", err)
+			} else {
+				status["keyfile_fingerprint"] = fingerprint
+				keyfile = "present, fingerprint " + fingerprint
+			}
+		}
+
+		format := ""
+		switch version, err := passwordStore.StoreVersion(); {
+		case err != nil:
+			format = fmt.Sprintf("unknown (%v)", err)
+		case version < store.CurrentStoreVersion:
+			status["format_version"] = version
+			format = fmt.Sprintf("version %d, run 'chowkidaar migrate' to upgrade to %d", version, store.CurrentStoreVersion)
+		case version > store.CurrentStoreVersion:
+			status["format_version"] = version
+			format = fmt.Sprintf("version %d, newer than this chowkidaar supports (%d)", version, store.CurrentStoreVersion)
+		default:
+			status["format_version"] = version
+			format = fmt.Sprintf("version %d", version)
+		}
+
+		cache := "master password not cached"
+		cached, remaining := passwordStore.GetCacheStatus()
+		status["cached"] = cached
+		if cached {
+			status["cache_remaining_seconds"] = int(remaining.Seconds())
+			cache = fmt.Sprintf("master password cached for %d minutes and %d seconds", int(remaining.Minutes()), int(remaining.Seconds())%60)
+		}
+
+		git := "disabled"
+		synced := ""
+		gitSync := newGitSync(cfg)
+		status["git"] = gitSync.IsGitEnabled()
+		if gitSync.IsGitEnabled() {
+			git = "enabled"
+			if remoteURL := gitSync.GetRemoteURL(); remoteURL != "" {
+				status["remote"] = remoteURL
+				git += ", remote " + remoteURL
+			}
+			ahead, behind, err := gitSync.AheadBehind()
+			if err != nil {
+				git += fmt.Sprintf(", ahead/behind unknown (%v)", err)
+			} else {
+				status["ahead"], status["behind"] = ahead, behind
+				git += fmt.Sprintf(", %d ahead, %d behind", ahead, behind)
+			}
+			if gitSync.IsShallow() {
+				status["shallow"] = true
+				git += ", shallow clone"
+			}
+
+			lastSync, err := gitSync.LastSyncTime()
+			switch {
+			case err != nil:
+				synced = fmt.Sprintf("unknown (%v)", err)
+			case lastSync.IsZero():
+				synced = "never"
+			default:
+				status["last_sync"] = lastSync.Format(time.RFC3339)
+				synced = fmt.Sprintf("%s ago (%s)", list.FormatAge(lastSync), lastSync.Local().Format("2006-01-02 15:04"))
+			}
+		}
+
+		gpg := ""
+		if cfg.GPGRead && !passwordStore.HiddenNames() {
+			status["gpg_read"] = true
+			switch names, err := passwordStore.GPGEntryNames(); {
+			case !store.GPGAvailable():
+				gpg = "reading .gpg entries, but gpg was not found on the PATH"
+			case err != nil:
+				gpg = fmt.Sprintf("reading .gpg entries, count unknown (%v)", err)
+			default:
+				status["gpg_entries"] = len(names)
+				gpg = fmt.Sprintf("reading .gpg entries, %s left to migrate", plural(len(names), "password"))
+			}
+		}
+
+		if jsonOutput {
+			return printJSON(status)
+		}
+
+		storeLine := cfg.StoreDir
+		if passwordStore.IsReadOnly() {
+			storeLine += " (read-only)"
+		}
+		if cfg.MarkerFile != "" {
+			storeLine += ", selected by " + cfg.MarkerFile
+		}
+		fmt.Printf("Store:    %s\n", storeLine)
+		fmt.Printf("Entries:  %s\n", entries)
+		if passwordStore.HiddenNames() {
+			if namesReadable {
+				fmt.Println("Names:    hidden")
+			} else {
+				fmt.Println("Names:    hidden, keyfile missing")
+			}
+		}
+		fmt.Printf("Keyfile:  %s\n", keyfile)
+		fmt.Printf("Format:   %s\n", format)
+		fmt.Printf("Cache:    %s\n", cache)
+		fmt.Printf("Git:      %s\n", git)
+		if gpg != "" {
+			fmt.Printf("GPG:      %s\n", gpg)
+		}
+		if synced != "" {
+			fmt.Printf("Synced:   %s\n", synced)
+		}
+		return nil
+	},
+}
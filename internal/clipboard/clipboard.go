@@ -0,0 +1,140 @@
+package clipboard
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// Selection is the selection that Copy and Paste use on X11 and Wayland,
+// where Ctrl+V pastes the CLIPBOARD and a middle click the PRIMARY selection
+type Selection int
+
+const (
+	SelectionClipboard Selection = iota // Pasted with Ctrl+V, the default
+	SelectionPrimary                    // Pasted with a middle click
+)
+
+// selection is set by SetSelection
+var selection = SelectionClipboard
+
+// SetSelection chooses the selection Copy and Paste use for the rest of the
+// process. macOS, Windows and WSL have a single clipboard and ignore it.
+func SetSelection(s Selection) {
+	selection = s
+}
+
+// copyCommand returns the program and arguments that write the clipboard on
+// the current platform
+func copyCommand() (string, []string, error) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbcopy"}}
+	case "windows":
+		candidates = [][]string{{"clip.exe"}}
+	default:
+		primary := selection == SelectionPrimary
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, withFlag([]string{"wl-copy"}, primary, "--primary"))
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", xclipSelection()},
+			[]string{"xsel", xselSelection(), "--input"},
+			[]string{"clip.exe"}, // WSL
+		)
+	}
+	return findCommand(candidates)
+}
+
+// pasteCommand returns the program and arguments that read the clipboard on
+// the current platform
+func pasteCommand() (string, []string, error) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = [][]string{{"pbpaste"}}
+	case "windows":
+		candidates = [][]string{{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"}}
+	default:
+		primary := selection == SelectionPrimary
+		if os.Getenv("WAYLAND_DISPLAY") != "" {
+			candidates = append(candidates, withFlag([]string{"wl-paste", "--no-newline"}, primary, "--primary"))
+		}
+		candidates = append(candidates,
+			[]string{"xclip", "-selection", xclipSelection(), "-out"},
+			[]string{"xsel", xselSelection(), "--output"},
+			[]string{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard"}, // WSL
+		)
+	}
+	return findCommand(candidates)
+}
+
+// xclipSelection names the selection for xclip -selection
+func xclipSelection() string {
+	if selection == SelectionPrimary {
+		return "primary"
+	}
+	return "clipboard"
+}
+
+// xselSelection returns the xsel option for the selection
+func xselSelection() string {
+	if selection == SelectionPrimary {
+		return "--primary"
+	}
+	return "--clipboard"
+}
+
+// withFlag appends flag to command when set is true
+func withFlag(command []string, set bool, flag string) []string {
+	if set {
+		return append(command, flag)
+	}
+	return command
+}
+
+// findCommand returns the first candidate program found on the PATH
+func findCommand(candidates [][]string) (string, []string, error) {
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate[0]); err == nil {
+			return path, candidate[1:], nil
+		}
+	}
+
+	return "", nil, fmt.Errorf("no clipboard utility found (install wl-clipboard, xclip or xsel)")
+}
+
+// Copy places text on the system clipboard
+func Copy(text string) error {
+	name, args, err := copyCommand()
+	if err != nil {
+		return err
+	}
+
+	// Output is not captured: xclip and wl-copy fork a process that keeps
+	// serving the selection, and waiting on its pipes would hang
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(text)
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%s failed: %w", name, err)
+	}
+	return nil
+}
+
+// Paste returns the text on the system clipboard. A trailing line break,
+// which some clipboard tools add, is removed.
+func Paste() (string, error) {
+	name, args, err := pasteCommand()
+	if err != nil {
+		return "", err
+	}
+
+	output, err := exec.Command(name, args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("%s failed: %w", name, err)
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
@@ -0,0 +1,61 @@
+package clipboard
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestPasteTrimsLineBreak(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("uses a fake xclip")
+	}
+
+	// A stand-in xclip that prints the clipboard with a CRLF, as some tools do
+	dir := t.TempDir()
+	script := "#!/bin/sh\nprintf 'hunter2\\r\\n'\n"
+	if err := os.WriteFile(filepath.Join(dir, "xclip"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	got, err := Paste()
+	if err != nil || got != "hunter2" {
+		t.Errorf("Paste = %q, %v, want hunter2", got, err)
+	}
+}
+
+func TestPrimarySelection(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("uses a fake xclip")
+	}
+
+	// A stand-in xclip that records its arguments
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\necho \"$@\" >> " + argsFile + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "xclip"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+	t.Setenv("WAYLAND_DISPLAY", "")
+
+	SetSelection(SelectionPrimary)
+	defer SetSelection(SelectionClipboard)
+	if err := Copy("hunter2"); err != nil {
+		t.Fatalf("Copy: %v", err)
+	}
+	if _, err := Paste(); err != nil {
+		t.Fatalf("Paste: %v", err)
+	}
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "-selection primary\n-selection primary -out\n"; string(args) != want {
+		t.Errorf("xclip was run with %q, want %q", args, want)
+	}
+}
@@ -5,6 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
+	"unicode"
 )
 
 // Config holds configuration for the password manager
@@ -15,10 +18,77 @@ type Config struct {
 	CacheTimeout int    // Cache timeout in minutes
 	GitURL       string // Git repository URL for sync
 	GitAutoSync  bool   // Automatically sync changes to Git
+
+	GitSignKey    string // Key file or gpg key ID used to sign commits
+	GitSignStrict bool   // Fail commits instead of committing unsigned when the key is unavailable
+
+	GitTrustedKeys string // Public key ring that pulled commits are verified against
+	GitVerifyPull  bool   // Reject pulls bringing commits not signed by a trusted key
+
+	GitCommitTemplate string // Auto-commit message with {action} and {name}, empty for the built-in messages
+
+	GitMirrors []string // Backup remote URLs that 'git push --all' pushes to besides origin
+
+	GitTimeout time.Duration // Limit for each Git network operation (0 for none)
+
+	AutoBackupBeforeBulk bool // Back up all entries before bulk re-encryption
+	HistoryDepth         int  // Number of previous versions kept per entry (0 disables history)
+
+	Hooks map[string]string // Commands run after operations, keyed by event (post_insert, post_remove, post_sync)
+
+	Templates map[string]string // Entry templates for insert --template, keyed by name
+
+	CopyOnShow bool // show copies to the clipboard instead of printing unless --print is given
+
+	AllowTTYPrint bool // show prints secrets to a terminal without --stdout, as it used to
+
+	GPGRead bool // show reads .gpg entries left by pass with gpg, for stores being migrated
+
+	Notifications bool // Show a desktop notification after git pull, sync and watch bring in changes
+
+	CreateDirs string // Whether new entries may create missing folders: always, confirm or never
+
+	ConfirmOverwrite bool // insert --force asks before replacing an existing, non-empty entry
+
+	MarkerFile string // .chowkidaar file that selected the store, if any
+
+	MirrorDir string // Directory that receives a copy of every changed entry's ciphertext
+
+	MasterPrompt   string // Prompt in the master password banner
+	BannerTitle    string // First title line of the master password banner
+	BannerSubtitle string // Second title line of the master password banner
+	BannerTemplate string // File the master password banner is drawn from instead of the default box
+	HideAsterisks  bool   // Show nothing while the master password is typed
+}
+
+// Values of CreateDirs
+const (
+	CreateDirsAlways  = "always"
+	CreateDirsConfirm = "confirm"
+	CreateDirsNever   = "never"
+)
+
+// DefaultTemplates are the built-in entry templates. {{password}} is replaced
+// with a freshly generated password when a template is used.
+var DefaultTemplates = map[string]string{
+	"login": "{{password}}\nusername: \nurl: \notp: \n",
+	"wifi":  "{{password}}\nssid: \nsecurity: WPA2\n",
 }
 
 // Load loads configuration from environment variables and defaults
 func Load() (*Config, error) {
+	return load("")
+}
+
+// LoadForStore loads the configuration of another store, e.g. the target of
+// mv --to-store. The environment applies as for Load, but the Git remote and
+// signing key come from that store's own Git config.
+func LoadForStore(storeDir string) (*Config, error) {
+	return load(storeDir)
+}
+
+// load builds the configuration, for storeDir when it is set
+func load(storeDir string) (*Config, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		return nil, err
@@ -30,6 +100,21 @@ func Load() (*Config, error) {
 		Editor:       getEnvDefault("EDITOR", "vim"),
 		CacheTimeout: 5,    // Default 5 minutes
 		GitAutoSync:  true, // Auto-sync enabled by default
+		GitTimeout:   30 * time.Second,
+
+		AutoBackupBeforeBulk: true,
+		HistoryDepth:         5,
+		CreateDirs:           CreateDirsAlways,
+		Hooks:                make(map[string]string),
+		Templates:            make(map[string]string),
+
+		BannerTitle:    "CHOWKIDAAR",
+		BannerSubtitle: "Password Manager",
+	}
+	defaultStoreDir := cfg.StoreDir
+
+	for name, body := range DefaultTemplates {
+		cfg.Templates[name] = body
 	}
 
 	// Override with environment variables if set
@@ -57,16 +142,172 @@ func Load() (*Config, error) {
 		}
 	}
 
+	if timeoutStr := os.Getenv("PASSWORD_STORE_GIT_TIMEOUT"); timeoutStr != "" {
+		if timeout, err := time.ParseDuration(timeoutStr); err == nil && timeout >= 0 {
+			cfg.GitTimeout = timeout
+		} else if seconds, err := strconv.Atoi(timeoutStr); err == nil && seconds >= 0 {
+			cfg.GitTimeout = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if signKey := os.Getenv("PASSWORD_STORE_GIT_SIGN_KEY"); signKey != "" {
+		cfg.GitSignKey = signKey
+	}
+
+	if signStrictStr := os.Getenv("PASSWORD_STORE_GIT_SIGN_STRICT"); signStrictStr != "" {
+		if strict, err := strconv.ParseBool(signStrictStr); err == nil {
+			cfg.GitSignStrict = strict
+		}
+	}
+
+	if trustedKeys := os.Getenv("PASSWORD_STORE_GIT_TRUSTED_KEYS"); trustedKeys != "" {
+		cfg.GitTrustedKeys = trustedKeys
+	}
+
+	if verifyPullStr := os.Getenv("PASSWORD_STORE_GIT_VERIFY_PULL"); verifyPullStr != "" {
+		if verifyPull, err := strconv.ParseBool(verifyPullStr); err == nil {
+			cfg.GitVerifyPull = verifyPull
+		}
+	}
+
+	if commitTemplate := os.Getenv("PASSWORD_STORE_GIT_COMMIT_TEMPLATE"); commitTemplate != "" {
+		cfg.GitCommitTemplate = commitTemplate
+	}
+
+	// Mirrors are separated by commas or whitespace
+	if mirrors := os.Getenv("PASSWORD_STORE_GIT_MIRRORS"); mirrors != "" {
+		cfg.GitMirrors = strings.FieldsFunc(mirrors, func(r rune) bool {
+			return r == ',' || unicode.IsSpace(r)
+		})
+	}
+
+	if autoBackupStr := os.Getenv("PASSWORD_STORE_AUTO_BACKUP"); autoBackupStr != "" {
+		if autoBackup, err := strconv.ParseBool(autoBackupStr); err == nil {
+			cfg.AutoBackupBeforeBulk = autoBackup
+		}
+	}
+
+	if historyDepthStr := os.Getenv("PASSWORD_STORE_HISTORY_DEPTH"); historyDepthStr != "" {
+		if depth, err := strconv.Atoi(historyDepthStr); err == nil && depth >= 0 {
+			cfg.HistoryDepth = depth
+		}
+	}
+
+	if copyOnShowStr := os.Getenv("PASSWORD_STORE_COPY_ON_SHOW"); copyOnShowStr != "" {
+		if copyOnShow, err := strconv.ParseBool(copyOnShowStr); err == nil {
+			cfg.CopyOnShow = copyOnShow
+		}
+	}
+
+	if allowTTYPrintStr := os.Getenv("PASSWORD_STORE_ALLOW_TTY_PRINT"); allowTTYPrintStr != "" {
+		if allowTTYPrint, err := strconv.ParseBool(allowTTYPrintStr); err == nil {
+			cfg.AllowTTYPrint = allowTTYPrint
+		}
+	}
+
+	if confirmOverwriteStr := os.Getenv("PASSWORD_STORE_CONFIRM_OVERWRITE"); confirmOverwriteStr != "" {
+		if confirmOverwrite, err := strconv.ParseBool(confirmOverwriteStr); err == nil {
+			cfg.ConfirmOverwrite = confirmOverwrite
+		}
+	}
+
+	if notificationsStr := os.Getenv("PASSWORD_STORE_NOTIFICATIONS"); notificationsStr != "" {
+		if notifications, err := strconv.ParseBool(notificationsStr); err == nil {
+			cfg.Notifications = notifications
+		}
+	}
+
+	if gpgReadStr := os.Getenv("PASSWORD_STORE_GPG_READ"); gpgReadStr != "" {
+		if gpgRead, err := strconv.ParseBool(gpgReadStr); err == nil {
+			cfg.GPGRead = gpgRead
+		}
+	}
+
+	switch createDirs := strings.ToLower(os.Getenv("PASSWORD_STORE_CREATE_DIRS")); createDirs {
+	case CreateDirsAlways, CreateDirsConfirm, CreateDirsNever:
+		cfg.CreateDirs = createDirs
+	}
+
+	cfg.MirrorDir = os.Getenv("PASSWORD_STORE_MIRROR_DIR")
+
+	cfg.MasterPrompt = os.Getenv("PASSWORD_STORE_PROMPT")
+	if title := os.Getenv("PASSWORD_STORE_BANNER_TITLE"); title != "" {
+		cfg.BannerTitle = title
+	}
+	if subtitle, ok := os.LookupEnv("PASSWORD_STORE_BANNER_SUBTITLE"); ok {
+		cfg.BannerSubtitle = subtitle
+	}
+	cfg.BannerTemplate = os.Getenv("PASSWORD_STORE_BANNER_TEMPLATE")
+	if hideAsterisksStr := os.Getenv("PASSWORD_STORE_HIDE_ASTERISKS"); hideAsterisksStr != "" {
+		if hideAsterisks, err := strconv.ParseBool(hideAsterisksStr); err == nil {
+			cfg.HideAsterisks = hideAsterisks
+		}
+	}
+
+	for _, event := range []string{"post_insert", "post_remove", "post_sync"} {
+		if command := os.Getenv("PASSWORD_STORE_HOOK_" + strings.ToUpper(event)); command != "" {
+			cfg.Hooks[event] = command
+		}
+	}
+
+	// A .chowkidaar file in the working directory or above picks the store,
+	// unless PASSWORD_STORE_DIR does. Like any other store it gets its remote
+	// from its own Git config.
+	if storeDir == "" && os.Getenv("PASSWORD_STORE_DIR") == "" {
+		markerStore, markerFile, err := findMarkerStore()
+		if err != nil {
+			return nil, err
+		}
+		if markerStore != "" {
+			cfg.MarkerFile = markerFile
+			if filepath.Clean(markerStore) != defaultStoreDir {
+				storeDir = markerStore
+			}
+		}
+	}
+
+	// The environment's remote and key belong to the default store
+	if storeDir != "" {
+		cfg.StoreDir = storeDir
+		cfg.GitURL = ""
+		cfg.GitSignKey = ""
+		cfg.GitTrustedKeys = ""
+		cfg.GitMirrors = nil
+		cfg.MirrorDir = ""
+		cfg.MasterPrompt = ""
+	}
+
+	// Name any store but the default one, so the wrong master password is
+	// not typed into it
+	if cfg.MasterPrompt == "" {
+		cfg.MasterPrompt = "Enter master password: "
+		if storeDir != "" || filepath.Clean(cfg.StoreDir) != defaultStoreDir {
+			cfg.MasterPrompt = "Enter master password of " + filepath.Base(filepath.Clean(cfg.StoreDir)) + ": "
+		}
+	}
+
 	// Load Git configuration from store directory if it exists
 	cfg.loadGitConfig()
 
+	// Templates kept in the store are shared with every synced device
+	cfg.loadTemplates()
+
 	return cfg, nil
 }
 
 // GitConfig represents the Git configuration stored in the password store
 type GitConfig struct {
-	URL      string `json:"url"`
-	AutoSync bool   `json:"auto_sync"`
+	URL        string `json:"url"`
+	AutoSync   bool   `json:"auto_sync"`
+	SignKey    string `json:"sign_key,omitempty"`
+	SignStrict bool   `json:"sign_strict,omitempty"`
+
+	TrustedKeys string `json:"trusted_keys,omitempty"`
+	VerifyPull  bool   `json:"verify_pull,omitempty"`
+
+	CommitTemplate string `json:"commit_template,omitempty"`
+
+	Mirrors []string `json:"mirrors,omitempty"`
 }
 
 // loadGitConfig loads Git configuration from the store directory
@@ -89,6 +330,24 @@ func (cfg *Config) loadGitConfig() {
 	if os.Getenv("PASSWORD_STORE_GIT_AUTO_SYNC") == "" {
 		cfg.GitAutoSync = gitConfig.AutoSync
 	}
+	if cfg.GitSignKey == "" {
+		cfg.GitSignKey = gitConfig.SignKey
+	}
+	if os.Getenv("PASSWORD_STORE_GIT_SIGN_STRICT") == "" {
+		cfg.GitSignStrict = gitConfig.SignStrict
+	}
+	if cfg.GitTrustedKeys == "" {
+		cfg.GitTrustedKeys = gitConfig.TrustedKeys
+	}
+	if os.Getenv("PASSWORD_STORE_GIT_VERIFY_PULL") == "" {
+		cfg.GitVerifyPull = gitConfig.VerifyPull
+	}
+	if cfg.GitCommitTemplate == "" {
+		cfg.GitCommitTemplate = gitConfig.CommitTemplate
+	}
+	if cfg.GitMirrors == nil {
+		cfg.GitMirrors = gitConfig.Mirrors
+	}
 }
 
 // SaveGitConfig saves Git configuration to the store directory
@@ -96,8 +355,17 @@ func (cfg *Config) SaveGitConfig() error {
 	gitConfigPath := filepath.Join(cfg.StoreDir, ".git-config")
 
 	gitConfig := GitConfig{
-		URL:      cfg.GitURL,
-		AutoSync: cfg.GitAutoSync,
+		URL:        cfg.GitURL,
+		AutoSync:   cfg.GitAutoSync,
+		SignKey:    cfg.GitSignKey,
+		SignStrict: cfg.GitSignStrict,
+
+		TrustedKeys: cfg.GitTrustedKeys,
+		VerifyPull:  cfg.GitVerifyPull,
+
+		CommitTemplate: cfg.GitCommitTemplate,
+
+		Mirrors: cfg.GitMirrors,
 	}
 
 	data, err := json.MarshalIndent(gitConfig, "", "  ")
@@ -108,6 +376,24 @@ func (cfg *Config) SaveGitConfig() error {
 	return os.WriteFile(gitConfigPath, data, 0600)
 }
 
+// loadTemplates adds the templates from .templates.json in the store
+// directory, which override built-ins of the same name
+func (cfg *Config) loadTemplates() {
+	data, err := os.ReadFile(filepath.Join(cfg.StoreDir, ".templates.json"))
+	if err != nil {
+		return // File doesn't exist or can't be read
+	}
+
+	var templates map[string]string
+	if err := json.Unmarshal(data, &templates); err != nil {
+		return // Invalid JSON
+	}
+
+	for name, body := range templates {
+		cfg.Templates[name] = body
+	}
+}
+
 func getEnvDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
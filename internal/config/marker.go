@@ -0,0 +1,86 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// markerFileName is the file that selects a store for a directory tree. The
+// default store is a directory of the same name, which is never a marker.
+const markerFileName = ".chowkidaar"
+
+// findMarkerStore looks for a .chowkidaar file in the working directory and
+// its parents and returns the store it names and the file's path, or empty
+// strings if there is none
+func findMarkerStore() (string, string, error) {
+	dir, err := os.Getwd()
+	if err != nil {
+		return "", "", nil
+	}
+
+	for {
+		path := filepath.Join(dir, markerFileName)
+		if info, err := os.Stat(path); err == nil && info.Mode().IsRegular() {
+			storeDir, err := readMarker(path)
+			return storeDir, path, err
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", "", nil
+		}
+		dir = parent
+	}
+}
+
+// readMarker parses a marker file of key=value lines; blank lines and lines
+// starting with # are ignored. The only key is store, a path that is relative
+// to the marker's directory unless absolute or starting with ~/.
+func readMarker(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer file.Close()
+
+	storeDir := ""
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			return "", fmt.Errorf("%s line %d: expected key=value", path, lineNumber)
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		switch key {
+		case "store":
+			storeDir = value
+		default:
+			return "", fmt.Errorf("%s line %d: unknown key '%s'", path, lineNumber, key)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if storeDir == "" {
+		return "", fmt.Errorf("%s: no store=... line", path)
+	}
+
+	if rest, ok := strings.CutPrefix(storeDir, "~/"); ok {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		storeDir = filepath.Join(homeDir, rest)
+	} else if !filepath.IsAbs(storeDir) {
+		storeDir = filepath.Join(filepath.Dir(path), storeDir)
+	}
+	return storeDir, nil
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindMarkerStore(t *testing.T) {
+	root := t.TempDir()
+	project := filepath.Join(root, "work", "project", "src")
+	if err := os.MkdirAll(project, 0700); err != nil {
+		t.Fatal(err)
+	}
+	marker := filepath.Join(root, "work", ".chowkidaar")
+	if err := os.WriteFile(marker, []byte("# work passwords\nstore = ../stores/work\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// A directory of that name, like the default store, is not a marker
+	if err := os.Mkdir(filepath.Join(root, "work", "project", ".chowkidaar"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Chdir(project)
+
+	storeDir, path, err := findMarkerStore()
+	if err != nil {
+		t.Fatalf("findMarkerStore: %v", err)
+	}
+	if want := filepath.Join(root, "stores", "work"); storeDir != want || path != marker {
+		t.Errorf("findMarkerStore = %q, %q, want %q, %q", storeDir, path, want, marker)
+	}
+}
+
+func TestReadMarkerErrors(t *testing.T) {
+	tests := []struct {
+		content string
+		wantErr string
+	}{
+		{content: "store\n", wantErr: "expected key=value"},
+		{content: "profile=work\n", wantErr: "unknown key 'profile'"},
+		{content: "# nothing\n", wantErr: "no store="},
+	}
+
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), ".chowkidaar")
+		if err := os.WriteFile(path, []byte(tt.content), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readMarker(path); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("readMarker(%q) error = %v, want %q", tt.content, err, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,135 @@
+package crypto
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"chowkidaar/internal/termwidth"
+)
+
+// A banner template is a text file drawn in place of the default password
+// box, centered on the screen as a block. These placeholders are replaced:
+//
+//	{{title}}     the banner title (PASSWORD_STORE_BANNER_TITLE)
+//	{{subtitle}}  the banner subtitle
+//	{{prompt}}    the master password prompt
+//	{{input}}     the password field; exactly one line must contain it
+//
+// The {{input}} placeholder is widened with spaces until its line is as wide
+// as the widest line of the banner, and the asterisks typed into the field
+// are centered in that space. Text before and after it on the same line,
+// such as box borders, is kept. Any other {{...}} is an error.
+const inputPlaceholder = "{{input}}"
+
+var placeholderPattern = regexp.MustCompile(`\{\{[^}]*\}\}`)
+
+// bannerLayout is a rendered password banner: its rows, the row holding the
+// password field, and the columns of the field within that row
+type bannerLayout struct {
+	lines      []string
+	inputLine  int // Index into lines
+	fieldStart int // Columns before the field
+	fieldWidth int
+}
+
+// SetBannerTemplate draws the password banner from the template file at
+// path, see inputPlaceholder for the format. An empty path, or a template
+// that cannot be read or does not fit the terminal, gives the default box.
+func (c *Crypto) SetBannerTemplate(path string) {
+	c.bannerTemplate = path
+}
+
+// bannerLayout renders the banner for a terminal of the given size, from the
+// configured template if it renders, else the default box
+func (c *Crypto) bannerLayout(prompt string, width, height int) bannerLayout {
+	title, subtitle := c.bannerText()
+	if c.bannerTemplate != "" {
+		if data, err := os.ReadFile(c.bannerTemplate); err == nil {
+			layout, err := renderBannerTemplate(string(data), title, subtitle, prompt)
+			if err == nil && termwidth.String(layout.lines[0]) <= width && len(layout.lines) < height {
+				return layout
+			}
+		}
+	}
+
+	// The box grows for whichever of the prompt and titles is widest
+	widest := prompt
+	for _, text := range []string{title, subtitle} {
+		if termwidth.String(text) > termwidth.String(widest) {
+			widest = text
+		}
+	}
+	boxWidth := bannerWidth(widest, width)
+	return bannerLayout{
+		lines:      bannerLines(title, subtitle, prompt, boxWidth),
+		inputLine:  9,
+		fieldStart: 1,
+		fieldWidth: boxWidth - 2,
+	}
+}
+
+// renderBannerTemplate fills in a banner template. Every returned line is
+// padded to the width of the widest one, so the block centers as a whole.
+func renderBannerTemplate(template, title, subtitle, prompt string) (bannerLayout, error) {
+	values := map[string]string{
+		"{{title}}":    title,
+		"{{subtitle}}": subtitle,
+		"{{prompt}}":   prompt,
+	}
+
+	rows := strings.Split(strings.TrimRight(strings.ReplaceAll(template, "\r\n", "\n"), "\n"), "\n")
+	layout := bannerLayout{inputLine: -1}
+	var before, after string
+	for i, row := range rows {
+		var err error
+		row = placeholderPattern.ReplaceAllStringFunc(row, func(placeholder string) string {
+			if placeholder == inputPlaceholder {
+				return placeholder
+			}
+			value, ok := values[placeholder]
+			if !ok && err == nil {
+				err = fmt.Errorf("unknown placeholder %s on line %d", placeholder, i+1)
+			}
+			return value
+		})
+		if err != nil {
+			return bannerLayout{}, err
+		}
+
+		if strings.Contains(row, inputPlaceholder) {
+			if layout.inputLine >= 0 {
+				return bannerLayout{}, fmt.Errorf("%s appears more than once", inputPlaceholder)
+			}
+			layout.inputLine = i
+			before, after, _ = strings.Cut(row, inputPlaceholder)
+			if strings.Contains(after, inputPlaceholder) {
+				return bannerLayout{}, fmt.Errorf("%s appears more than once", inputPlaceholder)
+			}
+			row = before + after
+		}
+		layout.lines = append(layout.lines, row)
+	}
+	if layout.inputLine < 0 {
+		return bannerLayout{}, fmt.Errorf("template has no %s line", inputPlaceholder)
+	}
+
+	width := 0
+	for _, line := range layout.lines {
+		width = max(width, termwidth.String(line))
+	}
+	layout.fieldStart = termwidth.String(before)
+	layout.fieldWidth = width - layout.fieldStart - termwidth.String(after)
+	if layout.fieldWidth < 1 {
+		// Nothing else is wider, so give the field a usable size
+		layout.fieldWidth = 20
+		width = layout.fieldStart + layout.fieldWidth + termwidth.String(after)
+	}
+	layout.lines[layout.inputLine] = before + strings.Repeat(" ", layout.fieldWidth) + after
+
+	for i, line := range layout.lines {
+		layout.lines[i] = line + strings.Repeat(" ", width-termwidth.String(line))
+	}
+	return layout, nil
+}
@@ -0,0 +1,37 @@
+package crypto
+
+import (
+	"testing"
+
+	"chowkidaar/internal/termwidth"
+)
+
+func TestBannerRowsEqualWidth(t *testing.T) {
+	prompts := []string{
+		"Enter master password: ",
+		"请输入主密码：",
+		"マスターパスワードを入力してください",
+		"Contraseña maestra 🔒",
+		// Wider than the box: the box grows, then the prompt is cut
+		"请输入您的主密码以解锁密码库中保存的所有条目和历史版本以及共享副本然后继续操作",
+	}
+
+	for _, prompt := range prompts {
+		for _, terminalWidth := range []int{80, 50} {
+			boxWidth := bannerWidth(prompt, terminalWidth)
+			for i, line := range bannerLines("保险库", "Password Manager", prompt, boxWidth) {
+				if got := termwidth.String(line); got != boxWidth {
+					t.Errorf("prompt %q, terminal %d: row %d is %d columns, want %d: %q", prompt, terminalWidth, i, got, boxWidth, line)
+				}
+			}
+		}
+	}
+}
+
+func TestCenterTextTruncatesWideCharacters(t *testing.T) {
+	// Each ideograph is two columns, so an odd width leaves one column of padding
+	got := centerText("密码密码", 7)
+	if got != "密码密 " {
+		t.Errorf("centerText = %q, want %q", got, "密码密 ")
+	}
+}
@@ -1,9 +1,11 @@
 package crypto
 
 import (
-	"crypto/aes"
-	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -12,9 +14,9 @@ import (
 	"time"
 
 	"chowkidaar/internal/cache"
+	"chowkidaar/internal/termwidth"
 
 	"github.com/tyler-smith/go-bip39"
-	"golang.org/x/crypto/argon2"
 	"golang.org/x/term"
 )
 
@@ -34,27 +36,32 @@ const (
 	keyFileSize = 32 // 256 bits
 )
 
-// EncryptedData represents the structure of encrypted password data
-type EncryptedData struct {
-	Salt       []byte
-	Nonce      []byte
-	Ciphertext []byte
-}
-
 // Crypto handles password-based encryption using Argon2id + AES-256-GCM
 type Crypto struct {
 	storeDir      string
 	passwordCache *cache.PasswordCache
+	kdf           kdfParams // KDF used for newly encrypted blobs
+	noKeyFile     bool      // Keys come from the master password alone
+	keyFileRef    string    // Fingerprint of the keyfile the store was first written with
+	skipCache     bool      // Set by PromptMasterPasswordNoCache
+
+	bannerTitle    string // Title lines of the password banner, see SetBanner
+	bannerSubtitle string
+	bannerTemplate string // Banner template file, see SetBannerTemplate
+	hideAsterisks  bool   // Show nothing while the master password is typed
 }
 
 // New creates a new Crypto instance
 func New(storeDir string) *Crypto {
 	// Default cache timeout of 5 minutes
 	passwordCache := cache.NewPasswordCache(storeDir, 5*time.Minute)
-	return &Crypto{
+	c := &Crypto{
 		storeDir:      storeDir,
 		passwordCache: passwordCache,
 	}
+	// A new store has no KDF choice yet, so the default is fine
+	c.loadStoreConfig()
+	return c
 }
 
 // NewFromStore creates a Crypto handler for an existing store
@@ -66,52 +73,116 @@ func NewFromStore(storeDir string) (*Crypto, error) {
 
 	// Default cache timeout of 5 minutes
 	passwordCache := cache.NewPasswordCache(storeDir, 5*time.Minute)
-	return &Crypto{
+	c := &Crypto{
 		storeDir:      storeDir,
 		passwordCache: passwordCache,
-	}, nil
+	}
+	if err := c.loadStoreConfig(); err != nil {
+		return nil, err
+	}
+	return c, nil
 }
 
-// Encrypt encrypts data using a master password with Argon2id + AES-256-GCM
-func (c *Crypto) Encrypt(data []byte, masterPassword string) ([]byte, error) {
-	// Generate random salt
-	salt := make([]byte, saltSize)
-	if _, err := rand.Read(salt); err != nil {
-		return nil, fmt.Errorf("failed to generate salt: %w", err)
-	}
+// ErrAuthFailed means a blob did not decrypt: the master password or secret
+// is wrong, or the blob was damaged
+var ErrAuthFailed = errors.New("authentication failed")
+
+// ErrKeyFileChanged means a blob did not decrypt and the keyfile is not the
+// one the store was first written with, e.g. after init with a mistyped
+// recovery phrase
+var ErrKeyFileChanged = errors.New("keyfile does not match this store")
 
+// ErrKeyFileMissing means the store uses a keyfile but this device has none
+var ErrKeyFileMissing = errors.New("keyfile not found")
+
+// Encrypt encrypts data using a master password with the store's KDF + AES-256-GCM
+func (c *Crypto) Encrypt(data []byte, masterPassword string) ([]byte, error) {
 	// Get combined key (password + keyfile)
 	combinedKey, err := c.getCombinedKey(masterPassword)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get combined key: %w", err)
 	}
 
-	// Derive key using Argon2id
-	key := argon2.IDKey(combinedKey, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	encrypted, err := encryptWithKeyMaterial(data, combinedKey, c.kdf)
+	if err == nil {
+		c.recordKeyFile()
+	}
+	return encrypted, err
+}
+
+// recordKeyFile remembers the fingerprint of the keyfile in .crypto.json the
+// first time something is encrypted with it, so a later decryption failure
+// can tell a replaced keyfile from a wrong master password. Failing to
+// record it only loses that hint.
+func (c *Crypto) recordKeyFile() {
+	if c.noKeyFile || c.keyFileRef != "" {
+		return
+	}
+	if fingerprint, err := c.KeyFileFingerprint(); err == nil {
+		c.keyFileRef = fingerprint
+		c.saveStoreConfig()
+	}
+}
+
+// classifyDecryptError turns a failed authentication into ErrKeyFileChanged
+// when the keyfile differs from the recorded one, with advice on fixing it
+func (c *Crypto) classifyDecryptError(err error) error {
+	if !errors.Is(err, ErrAuthFailed) || c.noKeyFile || c.keyFileRef == "" {
+		return err
+	}
+	fingerprint, fpErr := c.KeyFileFingerprint()
+	if fpErr != nil || fingerprint == c.keyFileRef {
+		return err
+	}
+	return fmt.Errorf("%w: this device's keyfile has fingerprint %s, but the store was written with %s. "+
+		"It was probably recreated from a wrong recovery phrase; copy %s from a device that can read the store, "+
+		"or move it aside and run 'chowkidaar init' again with the right phrase", ErrKeyFileChanged, fingerprint, c.keyFileRef, keyFileName)
+}
+
+// EncryptWithSecret encrypts data using only a shared secret (no keyfile),
+// so that anyone who knows the secret can decrypt it
+func (c *Crypto) EncryptWithSecret(data []byte, secret string) ([]byte, error) {
+	return encryptWithKeyMaterial(data, []byte(secret), c.kdf)
+}
 
-	// Create AES cipher
-	block, err := aes.NewCipher(key)
+// encryptWithKeyMaterial derives a key from the given material and encrypts data.
+// The result is header|salt|nonce|ciphertext, with the header authenticated
+// by the cipher, see envelope.go.
+func encryptWithKeyMaterial(data, keyMaterial []byte, params kdfParams) ([]byte, error) {
+	// Generate random salt
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	// Derive key using the store's KDF
+	key, err := params.deriveKey(keyMaterial, salt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+		return nil, fmt.Errorf("failed to derive key: %w", err)
 	}
 
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+	aead, err := newAEAD(defaultAEAD, key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	// Generate random nonce
-	nonce := make([]byte, nonceSize)
+	nonce := make([]byte, aead.NonceSize())
 	if _, err := rand.Read(nonce); err != nil {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	// Encrypt data
-	ciphertext := gcm.Seal(nil, nonce, data, nil)
+	// Compress large plaintexts, then encrypt
+	plaintext, flags, err := compressPlaintext(data)
+	if err != nil {
+		return nil, err
+	}
+	header := encodeHeader(params, defaultAEAD, flags)
+	ciphertext := aead.Seal(nil, nonce, plaintext, header)
 
-	// Combine salt, nonce, and ciphertext
-	result := make([]byte, 0, saltSize+nonceSize+len(ciphertext))
+	// Combine header, salt, nonce, and ciphertext
+	result := make([]byte, 0, len(header)+len(salt)+len(nonce)+len(ciphertext))
+	result = append(result, header...)
 	result = append(result, salt...)
 	result = append(result, nonce...)
 	result = append(result, ciphertext...)
@@ -121,45 +192,54 @@ func (c *Crypto) Encrypt(data []byte, masterPassword string) ([]byte, error) {
 
 // Decrypt decrypts data using a master password
 func (c *Crypto) Decrypt(encryptedData []byte, masterPassword string) ([]byte, error) {
-	if len(encryptedData) < saltSize+nonceSize {
-		return nil, fmt.Errorf("encrypted data too short")
-	}
-
-	// Extract salt, nonce, and ciphertext
-	salt := encryptedData[:saltSize]
-	nonce := encryptedData[saltSize : saltSize+nonceSize]
-	ciphertext := encryptedData[saltSize+nonceSize:]
-
 	// Get combined key (password + keyfile)
 	combinedKey, err := c.getCombinedKey(masterPassword)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get combined key: %w", err)
 	}
 
-	// Derive key using Argon2id with the same salt
-	key := argon2.IDKey(combinedKey, salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+	plaintext, err := decryptWithKeyMaterial(encryptedData, combinedKey)
+	if err != nil {
+		return nil, c.classifyDecryptError(err)
+	}
+	return plaintext, nil
+}
+
+// DecryptWithSecret decrypts data that was encrypted with EncryptWithSecret
+func (c *Crypto) DecryptWithSecret(encryptedData []byte, secret string) ([]byte, error) {
+	return decryptWithKeyMaterial(encryptedData, []byte(secret))
+}
 
-	// Create AES cipher
-	block, err := aes.NewCipher(key)
+// decryptWithKeyMaterial derives a key from the given material and decrypts
+// data, using the KDF and cipher recorded in the blob's header
+func decryptWithKeyMaterial(encryptedData, keyMaterial []byte) ([]byte, error) {
+	data, err := parseEncryptedData(encryptedData)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+		return nil, err
 	}
 
-	// Create GCM mode
-	gcm, err := cipher.NewGCM(block)
+	// Derive key with the same KDF and salt
+	key, err := data.KDF.deriveKey(keyMaterial, data.Salt)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, fmt.Errorf("failed to derive key: %w", err)
 	}
 
-	// Decrypt data
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	aead, err := newAEAD(data.AEAD, key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decrypt data (wrong password?): %w", err)
+		return nil, err
 	}
 
-	return plaintext, nil
+	plaintext, err := aead.Open(nil, data.Nonce, data.Ciphertext, data.Header)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt data (wrong password?): %w", ErrAuthFailed)
+	}
+
+	return decodePlaintext(plaintext, data.Flags)
 }
 
+// legacyKDFParams are the fixed parameters of blobs written before headers existed
+var legacyKDFParams = kdfParams{id: kdfIDArgon2id, p1: argon2Time, p2: argon2Memory, p3: argon2Threads}
+
 // PromptMasterPassword securely prompts for the master password with caching
 func (c *Crypto) PromptMasterPassword(prompt string) (string, error) {
 	// Check if we have a cached password first
@@ -168,6 +248,45 @@ func (c *Crypto) PromptMasterPassword(prompt string) (string, error) {
 		return cachedPassword, nil
 	}
 
+	return c.readMasterPassword(prompt)
+}
+
+// PromptMasterPasswordNoCache always prompts, ignoring a cached password, and
+// keeps the password out of the cache for the rest of this process
+func (c *Crypto) PromptMasterPasswordNoCache(prompt string) (string, error) {
+	c.DisableCache()
+	return c.readMasterPassword(prompt)
+}
+
+// DisableCache keeps the master password out of the cache for the rest of
+// this process, for passwords obtained without a prompt
+func (c *Crypto) DisableCache() {
+	c.skipCache = true
+}
+
+// SetBanner changes the two title lines of the password banner. An empty
+// title keeps the default.
+func (c *Crypto) SetBanner(title, subtitle string) {
+	c.bannerTitle, c.bannerSubtitle = title, subtitle
+}
+
+// SetHideAsterisks turns off the asterisks shown for each typed character
+// of the master password, which reveal its length to onlookers. Nothing is
+// echoed then, as in sudo and ssh prompts.
+func (c *Crypto) SetHideAsterisks(hide bool) {
+	c.hideAsterisks = hide
+}
+
+// bannerText returns the title lines of the password banner
+func (c *Crypto) bannerText() (string, string) {
+	if c.bannerTitle == "" {
+		return "CHOWKIDAAR", "Password Manager"
+	}
+	return c.bannerTitle, c.bannerSubtitle
+}
+
+// readMasterPassword shows the banner and reads the master password
+func (c *Crypto) readMasterPassword(prompt string) (string, error) {
 	// Display full-screen banner
 	leftPad, inputRow, boxWidth := c.displayPasswordBanner(prompt)
 
@@ -188,7 +307,8 @@ func (c *Crypto) PromptMasterPassword(prompt string) (string, error) {
 }
 
 // readPasswordWithFeedback reads password from stdin with asterisk visual feedback
-// Asterisks are center-aligned within the box
+// Asterisks are center-aligned within the box, unless SetHideAsterisks
+// turned them off
 func (c *Crypto) readPasswordWithFeedback(leftPad, row, boxWidth int) (string, error) {
 	// Set terminal to raw mode to read individual characters
 	oldState, err := term.MakeRaw(int(syscall.Stdin))
@@ -202,9 +322,13 @@ func (c *Crypto) readPasswordWithFeedback(leftPad, row, boxWidth int) (string, e
 
 	// Helper function to redraw centered asterisks
 	redrawPassword := func() {
+		if c.hideAsterisks {
+			return
+		}
+
 		// Clear the input line
-		fmt.Printf("\033[%d;%dH", row, leftPad+2)
-		fmt.Print(strings.Repeat(" ", boxWidth-2))
+		fmt.Fprintf(os.Stderr, "\033[%d;%dH", row, leftPad+2)
+		fmt.Fprint(os.Stderr, strings.Repeat(" ", boxWidth-2))
 
 		// Calculate centered position for asterisks
 		asterisks := strings.Repeat("*", len(password))
@@ -214,8 +338,14 @@ func (c *Crypto) readPasswordWithFeedback(leftPad, row, boxWidth int) (string, e
 		}
 
 		// Position cursor and print centered asterisks
-		fmt.Printf("\033[%d;%dH", row, leftPad+2+padding)
-		fmt.Print(asterisks)
+		fmt.Fprintf(os.Stderr, "\033[%d;%dH", row, leftPad+2+padding)
+		fmt.Fprint(os.Stderr, asterisks)
+	}
+
+	// Without feedback, park the cursor in the middle of the field so it is
+	// still clear where the password goes
+	if c.hideAsterisks {
+		fmt.Fprintf(os.Stderr, "\033[%d;%dH", row, leftPad+2+(boxWidth-2)/2)
 	}
 
 	for {
@@ -232,7 +362,7 @@ func (c *Crypto) readPasswordWithFeedback(leftPad, row, boxWidth int) (string, e
 		// Handle special keys
 		switch char {
 		case 3: // Ctrl+C
-			fmt.Println()
+			fmt.Fprintln(os.Stderr)
 			return "", fmt.Errorf("interrupted")
 		case 13, 10: // Enter (CR or LF)
 			return string(password), nil
@@ -252,10 +382,12 @@ func (c *Crypto) readPasswordWithFeedback(leftPad, row, boxWidth int) (string, e
 }
 
 // displayPasswordBanner shows a full-screen banner for password entry
-// Returns (leftPadding, inputRow, boxWidth) for cursor positioning
+// Returns (leftPadding, inputRow, boxWidth) for cursor positioning: the
+// password field starts at column leftPadding+2 of inputRow and is
+// boxWidth-2 columns wide, as inside the default box
 func (c *Crypto) displayPasswordBanner(prompt string) (int, int, int) {
 	// Clear screen
-	fmt.Print("\033[2J\033[H")
+	fmt.Fprint(os.Stderr, "\033[2J\033[H")
 
 	// Get terminal size
 	width := 80  // default width
@@ -265,72 +397,97 @@ func (c *Crypto) displayPasswordBanner(prompt string) (int, int, int) {
 		height = h
 	}
 
+	layout := c.bannerLayout(prompt, width, height)
+
 	// Calculate vertical centering
-	topPadding := (height - 13) / 2
+	topPadding := (height - len(layout.lines) - 1) / 2
 	if topPadding < 0 {
 		topPadding = 0
 	}
 
 	// Add top padding
 	for i := 0; i < topPadding; i++ {
-		fmt.Println()
-	}
-
-	// Calculate box width (max 60 chars or terminal width - 20)
-	boxWidth := 60
-	if width-20 < boxWidth {
-		boxWidth = width - 20
-	}
-	if boxWidth < 40 {
-		boxWidth = 40
+		fmt.Fprintln(os.Stderr)
 	}
 
 	// Calculate left padding for horizontal centering
-	leftPadding := (width - boxWidth) / 2
+	leftPadding := (width - termwidth.String(layout.lines[0])) / 2
 	if leftPadding < 0 {
 		leftPadding = 0
 	}
 	indent := strings.Repeat(" ", leftPadding)
 
-	// Clean, professional banner with input line inside
-	fmt.Println(indent + "┌" + strings.Repeat("─", boxWidth-2) + "┐")
-	fmt.Println(indent + "│" + strings.Repeat(" ", boxWidth-2) + "│")
-	fmt.Println(indent + "│" + centerText("CHOWKIDAAR", boxWidth-2) + "│")
-	fmt.Println(indent + "│" + centerText("Password Manager", boxWidth-2) + "│")
-	fmt.Println(indent + "│" + strings.Repeat(" ", boxWidth-2) + "│")
-	fmt.Println(indent + "├" + strings.Repeat("─", boxWidth-2) + "┤")
-	fmt.Println(indent + "│" + strings.Repeat(" ", boxWidth-2) + "│")
-	fmt.Println(indent + "│" + centerText(prompt, boxWidth-2) + "│")
-	fmt.Println(indent + "│" + strings.Repeat(" ", boxWidth-2) + "│")
+	for _, line := range layout.lines {
+		fmt.Fprintln(os.Stderr, indent+line)
+	}
+
+	// Row where input will appear, inside the box
+	inputRow := topPadding + layout.inputLine + 1
+
+	return leftPadding + layout.fieldStart - 1, inputRow, layout.fieldWidth + 2
+}
+
+// bannerWidth picks the width of the banner box for a terminal this wide:
+// 60 columns, or terminal width - 20, but at least 40 and wide enough for
+// the prompt where the terminal allows
+func bannerWidth(prompt string, terminalWidth int) int {
+	boxWidth := 60
+	if terminalWidth-20 < boxWidth {
+		boxWidth = terminalWidth - 20
+	}
+	if boxWidth < 40 {
+		boxWidth = 40
+	}
+	if needed := termwidth.String(prompt) + 4; needed > boxWidth {
+		boxWidth = min(needed, max(terminalWidth, 40))
+	}
+	return boxWidth
+}
 
-	// Input line inside the box
-	inputRow := topPadding + 10 // Row where input will appear
-	fmt.Println(indent + "│" + strings.Repeat(" ", boxWidth-2) + "│")
-	fmt.Println(indent + "│" + strings.Repeat(" ", boxWidth-2) + "│")
-	fmt.Println(indent + "└" + strings.Repeat("─", boxWidth-2) + "┘")
+// bannerLines returns the rows of the password banner box, each boxWidth
+// columns wide whatever characters the title and prompt contain
+func bannerLines(title, subtitle, prompt string, boxWidth int) []string {
+	inner := boxWidth - 2
+	blank := "│" + strings.Repeat(" ", inner) + "│"
 
-	return leftPadding, inputRow, boxWidth
+	// Clean, professional banner with input line inside
+	return []string{
+		"┌" + strings.Repeat("─", inner) + "┐",
+		blank,
+		"│" + centerText(title, inner) + "│",
+		"│" + centerText(subtitle, inner) + "│",
+		blank,
+		"├" + strings.Repeat("─", inner) + "┤",
+		blank,
+		"│" + centerText(prompt, inner) + "│",
+		blank,
+		blank, // Input line
+		blank,
+		"└" + strings.Repeat("─", inner) + "┘",
+	}
 }
 
 // clearPasswordBanner clears the password banner from screen
 func (c *Crypto) clearPasswordBanner() {
 	// Clear screen and return to normal
-	fmt.Print("\033[2J\033[H")
+	fmt.Fprint(os.Stderr, "\033[2J\033[H")
 }
 
-// centerText centers text within a given width
+// centerText centers text within a given number of terminal columns,
+// truncating text that does not fit
 func centerText(text string, width int) string {
-	// Count actual character width (accounting for emojis and Unicode)
-	textLen := len([]rune(text))
-	if textLen >= width {
-		return text[:width]
-	}
-	padding := (width - textLen) / 2
-	return strings.Repeat(" ", padding) + text + strings.Repeat(" ", width-textLen-padding)
+	text = termwidth.Truncate(text, width)
+	textWidth := termwidth.String(text)
+	padding := (width - textWidth) / 2
+	return strings.Repeat(" ", padding) + text + strings.Repeat(" ", width-textWidth-padding)
 }
 
-// CachePassword caches a validated master password
+// CachePassword caches a validated master password, unless it was read
+// with PromptMasterPasswordNoCache
 func (c *Crypto) CachePassword(password string) error {
+	if c.skipCache {
+		return nil
+	}
 	return c.passwordCache.Set(password)
 }
 
@@ -383,6 +540,18 @@ func (c *Crypto) HasKeyFile() bool {
 	return err == nil
 }
 
+// KeyFileFingerprint returns a short SHA-256 fingerprint of the keyfile, so
+// devices can confirm they hold the same keyfile without revealing it
+func (c *Crypto) KeyFileFingerprint() (string, error) {
+	keyFileData, err := c.readKeyFile()
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(keyFileData)
+	return hex.EncodeToString(sum[:8]), nil
+}
+
 // HasEncryptedPasswords checks if any .enc files exist (indicating initialized store)
 func (c *Crypto) HasEncryptedPasswords() (bool, error) {
 	found := false
@@ -391,7 +560,6 @@ func (c *Crypto) HasEncryptedPasswords() (bool, error) {
 			return err
 		}
 		if !info.IsDir() && strings.HasSuffix(info.Name(), ".enc") {
-			fmt.Println(info.Name())
 			found = true
 			return filepath.SkipDir // Stop walking once we find one
 		}
@@ -405,14 +573,100 @@ func (c *Crypto) HasEncryptedPasswords() (bool, error) {
 	return found, nil
 }
 
-// getCombinedKey combines the master password with the keyfile
-func (c *Crypto) getCombinedKey(masterPassword string) ([]byte, error) {
-	// Read keyfile
+// StoreState describes how far a store has been set up
+type StoreState int
+
+const (
+	// StateUninitialized has neither a keyfile nor passwords
+	StateUninitialized StoreState = iota
+	// StateKeyFileOnly has a keyfile but no passwords yet: a new store, or
+	// an init interrupted before the recovery phrase was written down
+	StateKeyFileOnly
+	// StateMissingKeyFile has passwords but no keyfile: a fresh clone or a
+	// lost keyfile, recovered from the recovery phrase
+	StateMissingKeyFile
+	// StateEmpty is a password-only store without passwords yet
+	StateEmpty
+	// StateHasPasswords has passwords and everything needed to read them
+	StateHasPasswords
+)
+
+// StoreState reports how far the store has been set up, so init can tell a
+// new store from a half-finished or cloned one
+func (c *Crypto) StoreState() (StoreState, error) {
+	hasPasswords, err := c.HasEncryptedPasswords()
+	if err != nil {
+		return StateUninitialized, err
+	}
+
+	switch {
+	case !c.UsesKeyFile() && hasPasswords:
+		return StateHasPasswords, nil
+	case !c.UsesKeyFile():
+		return StateEmpty, nil
+	case hasPasswords && c.HasKeyFile():
+		return StateHasPasswords, nil
+	case hasPasswords:
+		return StateMissingKeyFile, nil
+	case c.HasKeyFile():
+		return StateKeyFileOnly, nil
+	default:
+		return StateUninitialized, nil
+	}
+}
+
+// NameKey derives the key used to hash entry names when the store hides
+// its structure. It depends only on the keyfile, so names can be resolved
+// without the master password.
+func (c *Crypto) NameKey() ([]byte, error) {
+	keyFileData, err := c.readKeyFile()
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, keyFileData)
+	mac.Write([]byte("chowkidaar entry names"))
+	return mac.Sum(nil), nil
+}
+
+// EncryptWithKeyFile encrypts data using only the keyfile. It protects
+// metadata that must be readable without the master password.
+func (c *Crypto) EncryptWithKeyFile(data []byte) ([]byte, error) {
+	keyFileData, err := c.readKeyFile()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := encryptWithKeyMaterial(data, keyFileData, c.kdf)
+	if err == nil {
+		c.recordKeyFile()
+	}
+	return encrypted, err
+}
+
+// DecryptWithKeyFile decrypts data produced by EncryptWithKeyFile
+func (c *Crypto) DecryptWithKeyFile(encryptedData []byte) ([]byte, error) {
+	keyFileData, err := c.readKeyFile()
+	if err != nil {
+		return nil, err
+	}
+	data, err := decryptWithKeyMaterial(encryptedData, keyFileData)
+	if err != nil {
+		return nil, c.classifyDecryptError(err)
+	}
+	return data, nil
+}
+
+// readKeyFile reads and checks the store's keyfile
+func (c *Crypto) readKeyFile() ([]byte, error) {
+	if c.noKeyFile {
+		return nil, fmt.Errorf("this store does not use a keyfile")
+	}
+
 	keyFilePath := filepath.Join(c.storeDir, keyFileName)
 	keyFileData, err := os.ReadFile(keyFilePath)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("keyfile not found. Run 'chowkidaar init' first")
+			return nil, fmt.Errorf("%w. Run 'chowkidaar init' first", ErrKeyFileMissing)
 		}
 		return nil, fmt.Errorf("failed to read keyfile: %w", err)
 	}
@@ -421,6 +675,21 @@ func (c *Crypto) getCombinedKey(masterPassword string) ([]byte, error) {
 		return nil, fmt.Errorf("invalid keyfile size")
 	}
 
+	return keyFileData, nil
+}
+
+// getCombinedKey combines the master password with the keyfile, or returns
+// the master password alone in stores without a keyfile
+func (c *Crypto) getCombinedKey(masterPassword string) ([]byte, error) {
+	if c.noKeyFile {
+		return []byte(masterPassword), nil
+	}
+
+	keyFileData, err := c.readKeyFile()
+	if err != nil {
+		return nil, err
+	}
+
 	// Combine password and keyfile
 	combined := make([]byte, 0, len(masterPassword)+keyFileSize)
 	combined = append(combined, []byte(masterPassword)...)
@@ -434,6 +703,12 @@ func (c *Crypto) ClearPasswordCache() {
 	c.passwordCache.Clear()
 }
 
+// RefreshCache extends a valid cached password to a full timeout, see
+// cache.PasswordCache.Refresh
+func (c *Crypto) RefreshCache() bool {
+	return c.passwordCache.Refresh()
+}
+
 // SetCacheTimeout sets the cache timeout duration
 func (c *Crypto) SetCacheTimeout(timeout time.Duration) {
 	c.passwordCache.SetTimeout(timeout)
@@ -444,6 +719,11 @@ func (c *Crypto) GetCacheRemainingTime() time.Duration {
 	return c.passwordCache.GetRemainingTime()
 }
 
+// CacheDiagnostics inspects the password cache without changing it
+func (c *Crypto) CacheDiagnostics() cache.Diagnostics {
+	return c.passwordCache.DiagnosticInfo()
+}
+
 // IsCacheValid checks if the password cache is valid and not expired
 func (c *Crypto) IsCacheValid() bool {
 	_, found := c.passwordCache.Get()
@@ -0,0 +1,485 @@
+package crypto
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"chowkidaar/internal/termwidth"
+)
+
+var testKeyMaterial = []byte("master password and keyfile")
+
+// legacyEncrypt builds a blob in the format used before headers existed:
+// salt|nonce|ciphertext with fixed Argon2id parameters and no associated data
+func legacyEncrypt(t *testing.T, data, keyMaterial []byte) []byte {
+	t.Helper()
+
+	salt := make([]byte, saltSize)
+	nonce := make([]byte, nonceSize)
+	rand.Read(salt)
+	rand.Read(nonce)
+
+	key, err := legacyKDFParams.deriveKey(keyMaterial, salt)
+	if err != nil {
+		t.Fatalf("deriveKey: %v", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	blob := append(salt, nonce...)
+	return append(blob, gcm.Seal(nil, nonce, data, nil)...)
+}
+
+func TestKDFRoundTrip(t *testing.T) {
+	for _, kdf := range []string{KDFArgon2id, KDFScrypt} {
+		t.Run(kdf, func(t *testing.T) {
+			params, err := defaultKDFParams(kdf)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			blob, err := encryptWithKeyMaterial([]byte("hunter2"), testKeyMaterial, params)
+			if err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+			data, err := parseEncryptedData(blob)
+			if err != nil || data.Version != headerVersion || data.AEAD != defaultAEAD || data.KDF != params {
+				t.Fatalf("parseEncryptedData = %+v, %v, want version %d with %+v", data, err, headerVersion, params)
+			}
+
+			plaintext, err := decryptWithKeyMaterial(blob, testKeyMaterial)
+			if err != nil || string(plaintext) != "hunter2" {
+				t.Fatalf("decrypt = %q, %v, want hunter2", plaintext, err)
+			}
+
+			if _, err := decryptWithKeyMaterial(blob, []byte("wrong")); err == nil {
+				t.Error("decrypt with wrong key material succeeded")
+			}
+		})
+	}
+}
+
+func TestDecryptLegacyBlob(t *testing.T) {
+	blob := legacyEncrypt(t, []byte("hunter2"), testKeyMaterial)
+	if data, err := parseEncryptedData(blob); err != nil || data.Version != 0 {
+		t.Skip("random salt starts with the header magic")
+	}
+
+	plaintext, err := decryptWithKeyMaterial(blob, testKeyMaterial)
+	if err != nil || string(plaintext) != "hunter2" {
+		t.Fatalf("decrypt = %q, %v, want hunter2", plaintext, err)
+	}
+
+	c := &Crypto{}
+	if c.HasHeader(blob) {
+		t.Error("HasHeader on a legacy blob = true")
+	}
+	current, err := encryptWithKeyMaterial([]byte("hunter2"), testKeyMaterial, legacyKDFParams)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	if !c.HasHeader(current) {
+		t.Error("HasHeader on a current blob = false")
+	}
+}
+
+// version1Encrypt builds a blob with the version 1 header, which had no
+// cipher id and always used AES-256-GCM
+func version1Encrypt(t *testing.T, data, keyMaterial []byte, params kdfParams) []byte {
+	t.Helper()
+
+	header := append([]byte(headerMagic), 1, params.id)
+	header = binary.BigEndian.AppendUint32(header, params.p1)
+	header = binary.BigEndian.AppendUint32(header, params.p2)
+	header = binary.BigEndian.AppendUint32(header, params.p3)
+
+	salt := make([]byte, saltSize)
+	nonce := make([]byte, nonceSize)
+	rand.Read(salt)
+	rand.Read(nonce)
+
+	key, err := params.deriveKey(keyMaterial, salt)
+	if err != nil {
+		t.Fatalf("deriveKey: %v", err)
+	}
+	aead, err := newAEAD(aeadIDAES256GCM, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(append(append(header, salt...), nonce...), aead.Seal(nil, nonce, data, header)...)
+}
+
+func TestDecryptVersion1Blob(t *testing.T) {
+	params, _ := defaultKDFParams(KDFScrypt)
+	blob := version1Encrypt(t, []byte("hunter2"), testKeyMaterial, params)
+
+	data, err := parseEncryptedData(blob)
+	if err != nil || data.Version != 1 || data.AEAD != aeadIDAES256GCM || data.KDF != params {
+		t.Fatalf("parseEncryptedData = %+v, %v, want version 1 with %+v", data, err, params)
+	}
+
+	plaintext, err := decryptWithKeyMaterial(blob, testKeyMaterial)
+	if err != nil || string(plaintext) != "hunter2" {
+		t.Fatalf("decrypt = %q, %v, want hunter2", plaintext, err)
+	}
+
+	// Version 1 blobs are rewritten by reencrypt
+	c := New(t.TempDir())
+	c.kdf = params
+	if c.IsCurrent(blob) {
+		t.Error("IsCurrent reported a version 1 blob as current")
+	}
+}
+
+func TestCompression(t *testing.T) {
+	params, _ := defaultKDFParams(KDFScrypt)
+	random := make([]byte, 4096)
+	rand.Read(random)
+
+	tests := []struct {
+		name      string
+		plaintext []byte
+		flags     byte
+	}{
+		{name: "compressible", plaintext: []byte(strings.Repeat("password: hunter2\n", 200)), flags: flagGzip},
+		{name: "incompressible", plaintext: random, flags: 0},
+		{name: "tiny", plaintext: []byte("hunter2"), flags: 0},
+		{name: "below threshold", plaintext: []byte(strings.Repeat("a", compressThreshold-1)), flags: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			blob, err := encryptWithKeyMaterial(tt.plaintext, testKeyMaterial, params)
+			if err != nil {
+				t.Fatalf("encrypt: %v", err)
+			}
+			data, err := parseEncryptedData(blob)
+			if err != nil || data.Flags != tt.flags {
+				t.Fatalf("parseEncryptedData = %+v, %v, want flags %#x", data, err, tt.flags)
+			}
+
+			// Without compression the blob is the plaintext plus fixed overhead
+			overhead := headerSize + saltSize + nonceSize + 16
+			if tt.flags == 0 && len(blob) != len(tt.plaintext)+overhead {
+				t.Errorf("blob is %d bytes, want %d", len(blob), len(tt.plaintext)+overhead)
+			}
+			if tt.flags == flagGzip && len(blob) >= len(tt.plaintext) {
+				t.Errorf("compressed blob is %d bytes, plaintext %d", len(blob), len(tt.plaintext))
+			}
+
+			plaintext, err := decryptWithKeyMaterial(blob, testKeyMaterial)
+			if err != nil || string(plaintext) != string(tt.plaintext) {
+				t.Fatalf("decrypt = %d bytes, %v, want the %d byte plaintext", len(plaintext), err, len(tt.plaintext))
+			}
+		})
+	}
+}
+
+func TestParseEncryptedDataErrors(t *testing.T) {
+	params, _ := defaultKDFParams(KDFArgon2id)
+	unknownCipher := append(encodeHeader(params, 0xff, 0), make([]byte, saltSize+nonceSize+16)...)
+	if _, err := parseEncryptedData(unknownCipher); err == nil {
+		t.Error("parseEncryptedData accepted an unknown cipher id")
+	}
+
+	short := append(encodeHeader(params, defaultAEAD, 0), make([]byte, saltSize)...)
+	if _, err := parseEncryptedData(short); err == nil {
+		t.Error("parseEncryptedData accepted a blob without nonce")
+	}
+	if _, err := decryptWithKeyMaterial(short, testKeyMaterial); err == nil {
+		t.Error("decrypt of a truncated blob succeeded")
+	}
+}
+
+func TestIsCurrent(t *testing.T) {
+	c := New(t.TempDir())
+	argon2Params, _ := defaultKDFParams(KDFArgon2id)
+	scryptParams, _ := defaultKDFParams(KDFScrypt)
+	weaker := argon2Params
+	weaker.p2 /= 2
+
+	tests := []struct {
+		name    string
+		params  *kdfParams // nil for a legacy blob
+		current bool
+	}{
+		{name: "current", params: &argon2Params, current: true},
+		{name: "other kdf", params: &scryptParams},
+		{name: "older parameters", params: &weaker},
+		{name: "legacy", params: nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var blob []byte
+			if tt.params == nil {
+				blob = legacyEncrypt(t, []byte("hunter2"), testKeyMaterial)
+			} else {
+				var err error
+				if blob, err = encryptWithKeyMaterial([]byte("hunter2"), testKeyMaterial, *tt.params); err != nil {
+					t.Fatalf("encrypt: %v", err)
+				}
+			}
+			if got := c.IsCurrent(blob); got != tt.current {
+				t.Errorf("IsCurrent = %v, want %v", got, tt.current)
+			}
+		})
+	}
+}
+
+func TestTamperedHeaderFails(t *testing.T) {
+	params, _ := defaultKDFParams(KDFScrypt)
+	blob, err := encryptWithKeyMaterial([]byte("hunter2"), testKeyMaterial, params)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		offset int
+		value  byte
+	}{
+		{name: "kdf id", offset: len(headerMagic) + 1, value: kdfIDArgon2id},
+		{name: "cipher id", offset: len(headerMagic) + 2, value: aeadIDAES256GCM + 1},
+		{name: "flags", offset: len(headerMagic) + 3, value: flagGzip},
+		{name: "old version", offset: len(headerMagic), value: 1},
+		{name: "parameter", offset: headerSize - 1, value: 2},
+		{name: "version", offset: len(headerMagic), value: headerVersion + 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tampered := append([]byte(nil), blob...)
+			tampered[tt.offset] = tt.value
+
+			if _, err := decryptWithKeyMaterial(tampered, testKeyMaterial); err == nil {
+				t.Fatal("decrypt of tampered blob succeeded")
+			}
+		})
+	}
+}
+
+func TestCraftedKDFParametersFailQuickly(t *testing.T) {
+	tests := []struct {
+		name   string
+		params kdfParams
+	}{
+		{name: "scrypt N", params: kdfParams{id: kdfIDScrypt, p1: 1 << 22, p2: 8, p3: 1}},
+		{name: "scrypt r", params: kdfParams{id: kdfIDScrypt, p1: 1 << 15, p2: 1 << 20, p3: 1}},
+		{name: "scrypt memory", params: kdfParams{id: kdfIDScrypt, p1: 1 << 20, p2: 32, p3: 1}},
+		{name: "scrypt p", params: kdfParams{id: kdfIDScrypt, p1: 1 << 15, p2: 8, p3: 1 << 20}},
+		{name: "argon2id memory", params: kdfParams{id: kdfIDArgon2id, p1: 100, p2: 4 * 1024 * 1024, p3: 4}},
+		{name: "argon2id time", params: kdfParams{id: kdfIDArgon2id, p1: 1000, p2: 64 * 1024, p3: 4}},
+	}
+
+	params, _ := defaultKDFParams(KDFScrypt)
+	blob, err := encryptWithKeyMaterial([]byte("hunter2"), testKeyMaterial, params)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			crafted := append([]byte(nil), blob...)
+			crafted[len(headerMagic)+1] = tt.params.id
+			fields := crafted[headerSize-12 : headerSize]
+			binary.BigEndian.PutUint32(fields[0:4], tt.params.p1)
+			binary.BigEndian.PutUint32(fields[4:8], tt.params.p2)
+			binary.BigEndian.PutUint32(fields[8:12], tt.params.p3)
+
+			start := time.Now()
+			if _, err := decryptWithKeyMaterial(crafted, testKeyMaterial); err == nil {
+				t.Fatal("decrypt with crafted parameters succeeded")
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("decrypt took %s before failing", elapsed)
+			}
+		})
+	}
+}
+
+func TestPasswordOnlyStore(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir)
+	if err := c.DisableKeyFile(); err != nil {
+		t.Fatalf("DisableKeyFile: %v", err)
+	}
+
+	blob, err := c.Encrypt([]byte("hunter2"), "master")
+	if err != nil {
+		t.Fatalf("Encrypt without keyfile: %v", err)
+	}
+
+	// The choice is recorded in the store
+	reopened, err := NewFromStore(dir)
+	if err != nil {
+		t.Fatalf("NewFromStore: %v", err)
+	}
+	if reopened.UsesKeyFile() {
+		t.Fatal("UsesKeyFile = true after DisableKeyFile")
+	}
+
+	plaintext, err := reopened.Decrypt(blob, "master")
+	if err != nil || string(plaintext) != "hunter2" {
+		t.Fatalf("Decrypt = %q, %v, want hunter2", plaintext, err)
+	}
+	if _, err := reopened.Decrypt(blob, "wrong"); err == nil {
+		t.Error("Decrypt with wrong password succeeded")
+	}
+	if _, err := reopened.NameKey(); err == nil {
+		t.Error("NameKey succeeded without a keyfile")
+	}
+}
+
+func TestSkipCacheKeepsPasswordOut(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	c := New(t.TempDir())
+
+	// As after PromptMasterPasswordNoCache
+	c.skipCache = true
+	if err := c.CachePassword("hunter2"); err != nil {
+		t.Fatalf("CachePassword: %v", err)
+	}
+	if c.IsCacheValid() {
+		t.Fatal("password was cached despite --no-cache")
+	}
+}
+
+func TestStoreState(t *testing.T) {
+	tests := []struct {
+		name      string
+		keyFile   bool
+		passwords bool
+		noKeyFile bool
+		want      StoreState
+	}{
+		{name: "new", want: StateUninitialized},
+		{name: "interrupted init", keyFile: true, want: StateKeyFileOnly},
+		{name: "clone", passwords: true, want: StateMissingKeyFile},
+		{name: "ready", keyFile: true, passwords: true, want: StateHasPasswords},
+		{name: "password-only, empty", noKeyFile: true, want: StateEmpty},
+		{name: "password-only", noKeyFile: true, passwords: true, want: StateHasPasswords},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			c := New(dir)
+			if tt.keyFile {
+				mnemonic, err := c.GenerateMnemonic()
+				if err != nil {
+					t.Fatalf("GenerateMnemonic: %v", err)
+				}
+				if err := c.CreateKeyFileFromMnemonic(mnemonic); err != nil {
+					t.Fatalf("CreateKeyFileFromMnemonic: %v", err)
+				}
+			}
+			if tt.noKeyFile {
+				if err := c.DisableKeyFile(); err != nil {
+					t.Fatalf("DisableKeyFile: %v", err)
+				}
+			}
+			if tt.passwords {
+				if err := os.MkdirAll(filepath.Join(dir, "Email"), 0700); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.WriteFile(filepath.Join(dir, "Email", "gmail.enc"), []byte("blob"), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			got, err := c.StoreState()
+			if err != nil || got != tt.want {
+				t.Errorf("StoreState = %v, %v, want %v", got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeyFileChanged(t *testing.T) {
+	dir := t.TempDir()
+	c := New(dir)
+	newKeyFile := func() {
+		t.Helper()
+		mnemonic, err := c.GenerateMnemonic()
+		if err != nil {
+			t.Fatalf("GenerateMnemonic: %v", err)
+		}
+		if err := c.CreateKeyFileFromMnemonic(mnemonic); err != nil {
+			t.Fatalf("CreateKeyFileFromMnemonic: %v", err)
+		}
+	}
+	newKeyFile()
+
+	blob, err := c.Encrypt([]byte("hunter2"), "master")
+	if err != nil {
+		t.Fatalf("Encrypt: %v", err)
+	}
+	if _, err := c.Decrypt(blob, "wrong"); !errors.Is(err, ErrAuthFailed) || errors.Is(err, ErrKeyFileChanged) {
+		t.Errorf("Decrypt with a wrong password = %v, want ErrAuthFailed only", err)
+	}
+
+	// A keyfile from another recovery phrase, as read by a fresh handler
+	newKeyFile()
+	c, err = NewFromStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decrypt(blob, "master"); !errors.Is(err, ErrKeyFileChanged) {
+		t.Errorf("Decrypt with a replaced keyfile = %v, want ErrKeyFileChanged", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, keyFileName)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.Decrypt(blob, "master"); !errors.Is(err, ErrKeyFileMissing) {
+		t.Errorf("Decrypt without a keyfile = %v, want ErrKeyFileMissing", err)
+	}
+}
+
+func TestRenderBannerTemplate(t *testing.T) {
+	template := "+------------------+\n| {{title}}\n|{{input}}|\n+------------------+\n"
+	layout, err := renderBannerTemplate(template, "保险库", "", "Enter master password: ")
+	if err != nil {
+		t.Fatalf("renderBannerTemplate: %v", err)
+	}
+
+	if layout.inputLine != 2 || layout.fieldStart != 1 || layout.fieldWidth != 18 {
+		t.Errorf("field = line %d, start %d, width %d, want line 2, start 1, width 18",
+			layout.inputLine, layout.fieldStart, layout.fieldWidth)
+	}
+	if got, want := layout.lines[2], "|"+strings.Repeat(" ", 18)+"|"; got != want {
+		t.Errorf("input line = %q, want %q", got, want)
+	}
+	for i, line := range layout.lines {
+		if termwidth.String(line) != 20 {
+			t.Errorf("line %d %q is %d columns wide, want 20", i, line, termwidth.String(line))
+		}
+	}
+
+	for _, bad := range []string{
+		"no field here\n",
+		"{{input}}\n{{input}}\n",
+		"{{input}} {{input}}\n",
+		"{{input}}\n{{user}}\n",
+	} {
+		if _, err := renderBannerTemplate(bad, "title", "", "prompt"); err == nil {
+			t.Errorf("renderBannerTemplate(%q) succeeded, want an error", bad)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package crypto
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Blobs start with a header recording how they were encrypted, so that new
+// KDFs and ciphers can be added without breaking existing files:
+//
+//	version 3: magic | 3 | kdf id | aead id | flags | three big-endian uint32 KDF parameters
+//	version 2: magic | 2 | kdf id | aead id | three big-endian uint32 KDF parameters
+//	version 1: magic | 1 | kdf id | three big-endian uint32 KDF parameters
+//
+// The header is followed by salt|nonce|ciphertext and authenticated as the
+// cipher's additional data. The flags record how the plaintext was encoded
+// before encryption, see flagGzip. Version 1 blobs always use AES-256-GCM. Blobs
+// from before headers existed are a bare salt|nonce|ciphertext, see
+// legacyKDFParams.
+const (
+	headerMagic   = "CKDR"
+	headerVersion = 3
+	headerSize    = len(headerMagic) + 4 + 12
+	headerSizeV2  = len(headerMagic) + 3 + 12
+	headerSizeV1  = len(headerMagic) + 2 + 12
+
+	// flagGzip marks a gzip-compressed plaintext
+	flagGzip byte = 1 << 0
+
+	// compressThreshold is the plaintext size from which compression is
+	// tried. Below it the gzip framing would cost more than it saves, and a
+	// compressed plaintext is only kept when it is actually smaller.
+	compressThreshold = 1024
+
+	// maxPlaintextSize bounds decompression of a blob
+	maxPlaintextSize = 64 << 20
+
+	aeadIDAES256GCM byte = 1
+
+	// defaultAEAD is the cipher of newly encrypted blobs
+	defaultAEAD = aeadIDAES256GCM
+)
+
+// EncryptedData is an encrypted blob split into its parts
+type EncryptedData struct {
+	Version    byte      // Header version, 0 for a blob without header
+	KDF        kdfParams // How the key was derived from the key material
+	AEAD       byte      // Cipher id, e.g. aeadIDAES256GCM
+	Flags      byte      // Plaintext encoding, e.g. flagGzip; 0 before version 3
+	Header     []byte    // Authenticated as additional data; nil without header
+	Salt       []byte
+	Nonce      []byte
+	Ciphertext []byte
+}
+
+// encodeHeader encodes the current header for a KDF, cipher and flags
+func encodeHeader(params kdfParams, aead, flags byte) []byte {
+	header := make([]byte, 0, headerSize)
+	header = append(header, headerMagic...)
+	header = append(header, headerVersion, params.id, aead, flags)
+	header = binary.BigEndian.AppendUint32(header, params.p1)
+	header = binary.BigEndian.AppendUint32(header, params.p2)
+	header = binary.BigEndian.AppendUint32(header, params.p3)
+	return header
+}
+
+// parseEncryptedData splits a blob into its header fields, salt, nonce and
+// ciphertext. A blob without a known header version is read as one written
+// before headers existed; a headerless blob whose random salt happens to
+// start with the magic and a version is too unlikely (about 1 in 2^39) to
+// justify a second KDF run on every wrong password.
+func parseEncryptedData(blob []byte) (*EncryptedData, error) {
+	data := &EncryptedData{KDF: legacyKDFParams, AEAD: aeadIDAES256GCM}
+
+	if bytes.HasPrefix(blob, []byte(headerMagic)) && len(blob) > len(headerMagic) {
+		fields := blob[len(headerMagic)+1:]
+		switch blob[len(headerMagic)] {
+		case 3:
+			if len(blob) >= headerSize {
+				data.Version, data.KDF.id, data.AEAD, data.Flags = 3, fields[0], fields[1], fields[2]
+				data.Header = blob[:headerSize]
+				fields = fields[3:]
+			}
+		case 2:
+			if len(blob) >= headerSizeV2 {
+				data.Version, data.KDF.id, data.AEAD = 2, fields[0], fields[1]
+				data.Header = blob[:headerSizeV2]
+				fields = fields[2:]
+			}
+		case 1:
+			if len(blob) >= headerSizeV1 {
+				data.Version, data.KDF.id = 1, fields[0]
+				data.Header = blob[:headerSizeV1]
+				fields = fields[1:]
+			}
+		}
+		if data.Header != nil {
+			data.KDF.p1 = binary.BigEndian.Uint32(fields[0:4])
+			data.KDF.p2 = binary.BigEndian.Uint32(fields[4:8])
+			data.KDF.p3 = binary.BigEndian.Uint32(fields[8:12])
+		}
+	}
+
+	nonceLen, err := aeadNonceSize(data.AEAD)
+	if err != nil {
+		return nil, err
+	}
+	body := blob[len(data.Header):]
+	if len(body) < saltSize+nonceLen {
+		return nil, fmt.Errorf("encrypted data too short")
+	}
+
+	data.Salt = body[:saltSize]
+	data.Nonce = body[saltSize : saltSize+nonceLen]
+	data.Ciphertext = body[saltSize+nonceLen:]
+	return data, nil
+}
+
+// compressPlaintext gzips a plaintext of at least compressThreshold bytes
+// and returns it with flagGzip, or returns it unchanged with no flags when
+// it is smaller or does not compress
+func compressPlaintext(plaintext []byte) ([]byte, byte, error) {
+	if len(plaintext) < compressThreshold {
+		return plaintext, 0, nil
+	}
+
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	if _, err := writer.Write(plaintext); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress data: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, 0, fmt.Errorf("failed to compress data: %w", err)
+	}
+	if buf.Len() >= len(plaintext) {
+		return plaintext, 0, nil
+	}
+	return buf.Bytes(), flagGzip, nil
+}
+
+// decodePlaintext undoes the encoding recorded in a blob's flags
+func decodePlaintext(plaintext []byte, flags byte) ([]byte, error) {
+	if flags&^flagGzip != 0 {
+		return nil, fmt.Errorf("unknown header flags %#x", flags)
+	}
+	if flags&flagGzip == 0 {
+		return plaintext, nil
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(plaintext))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	defer reader.Close()
+	decoded, err := io.ReadAll(io.LimitReader(reader, maxPlaintextSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress data: %w", err)
+	}
+	if len(decoded) > maxPlaintextSize {
+		return nil, fmt.Errorf("decompressed data exceeds %d bytes", maxPlaintextSize)
+	}
+	return decoded, nil
+}
+
+// aeadNonceSize returns the nonce size of a cipher
+func aeadNonceSize(id byte) (int, error) {
+	switch id {
+	case aeadIDAES256GCM:
+		return nonceSize, nil
+	default:
+		return 0, fmt.Errorf("unknown cipher id %d", id)
+	}
+}
+
+// newAEAD creates the cipher with the given id for a derived key
+func newAEAD(id byte, key []byte) (cipher.AEAD, error) {
+	switch id {
+	case aeadIDAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create AES cipher: %w", err)
+		}
+		gcm, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create GCM: %w", err)
+		}
+		return gcm, nil
+	default:
+		return nil, fmt.Errorf("unknown cipher id %d", id)
+	}
+}
@@ -0,0 +1,178 @@
+package crypto
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// KDF names accepted by SetKDF
+const (
+	KDFArgon2id = "argon2id"
+	KDFScrypt   = "scrypt"
+)
+
+const (
+	// scrypt parameters (interactive-login strength, 32 MB of memory)
+	scryptN = 1 << 15
+	scryptR = 8
+	scryptP = 1
+
+	kdfIDArgon2id byte = 1
+	kdfIDScrypt   byte = 2
+
+	// Limits on the parameters in a header, which may come from a Git remote
+	// or a shared copy: a crafted one must not make decryption allocate
+	// gigabytes or run for minutes. They leave room above the defaults.
+	maxArgon2Time    = 10
+	maxArgon2Memory  = 1024 * 1024 // KiB (1 GiB)
+	maxArgon2Threads = 255
+	maxScryptN       = 1 << 20
+	maxScryptR       = 32
+	maxScryptP       = 16
+	maxScryptMemory  = 1 << 30 // Bytes scrypt allocates, 128*N*r
+
+	// cryptoConfigFile records the KDF chosen for new blobs and whether the
+	// store uses a keyfile, both set at init
+	cryptoConfigFile = ".crypto.json"
+)
+
+// kdfParams describes how the key of a blob was derived
+type kdfParams struct {
+	id byte
+	// argon2id: time, memory (KiB), threads; scrypt: N, r, p
+	p1, p2, p3 uint32
+}
+
+// storeCryptoConfig is the content of .crypto.json
+type storeCryptoConfig struct {
+	KDF       string `json:"kdf"`
+	NoKeyFile bool   `json:"no_keyfile,omitempty"`
+
+	// Fingerprint of the keyfile, recorded on the first encryption
+	KeyFileFingerprint string `json:"keyfile_fingerprint,omitempty"`
+}
+
+// defaultKDFParams returns the current parameters for a named KDF
+func defaultKDFParams(name string) (kdfParams, error) {
+	switch name {
+	case KDFArgon2id, "":
+		return kdfParams{id: kdfIDArgon2id, p1: argon2Time, p2: argon2Memory, p3: argon2Threads}, nil
+	case KDFScrypt:
+		return kdfParams{id: kdfIDScrypt, p1: scryptN, p2: scryptR, p3: scryptP}, nil
+	default:
+		return kdfParams{}, fmt.Errorf("unknown KDF '%s' (use %s or %s)", name, KDFArgon2id, KDFScrypt)
+	}
+}
+
+// deriveKey derives the encryption key from key material and salt
+func (p kdfParams) deriveKey(keyMaterial, salt []byte) ([]byte, error) {
+	switch p.id {
+	case kdfIDArgon2id:
+		// Reject parameters that would exhaust memory or hang (e.g. a tampered header)
+		if p.p1 == 0 || p.p1 > maxArgon2Time || p.p2 == 0 || p.p2 > maxArgon2Memory || p.p3 == 0 || p.p3 > maxArgon2Threads {
+			return nil, fmt.Errorf("invalid argon2id parameters")
+		}
+		return argon2.IDKey(keyMaterial, salt, p.p1, p.p2, uint8(p.p3), argon2KeyLen), nil
+	case kdfIDScrypt:
+		if p.p1 < 2 || p.p1 > maxScryptN || p.p1&(p.p1-1) != 0 || p.p2 == 0 || p.p2 > maxScryptR || p.p3 == 0 || p.p3 > maxScryptP ||
+			128*uint64(p.p1)*uint64(p.p2) > maxScryptMemory {
+			return nil, fmt.Errorf("invalid scrypt parameters")
+		}
+		return scrypt.Key(keyMaterial, salt, int(p.p1), int(p.p2), int(p.p3), argon2KeyLen)
+	default:
+		return nil, fmt.Errorf("unknown KDF id %d", p.id)
+	}
+}
+
+// loadStoreConfig reads the KDF chosen for this store, defaulting to
+// Argon2id, and whether it uses a keyfile
+func (c *Crypto) loadStoreConfig() error {
+	c.kdf, _ = defaultKDFParams(KDFArgon2id)
+
+	data, err := os.ReadFile(filepath.Join(c.storeDir, cryptoConfigFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read %s: %w", cryptoConfigFile, err)
+	}
+
+	var config storeCryptoConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", cryptoConfigFile, err)
+	}
+
+	params, err := defaultKDFParams(config.KDF)
+	if err != nil {
+		return err
+	}
+	c.kdf = params
+	c.noKeyFile = config.NoKeyFile
+	c.keyFileRef = config.KeyFileFingerprint
+	return nil
+}
+
+// saveStoreConfig writes the store's crypto settings to .crypto.json
+func (c *Crypto) saveStoreConfig() error {
+	data, err := json.MarshalIndent(storeCryptoConfig{KDF: c.KDF(), NoKeyFile: c.noKeyFile, KeyFileFingerprint: c.keyFileRef}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(c.storeDir, cryptoConfigFile), data, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", cryptoConfigFile, err)
+	}
+	return nil
+}
+
+// SetKDF selects the KDF used for newly encrypted blobs and records it in
+// the store. Existing blobs keep decrypting with the KDF in their header.
+func (c *Crypto) SetKDF(name string) error {
+	params, err := defaultKDFParams(name)
+	if err != nil {
+		return err
+	}
+
+	c.kdf = params
+	return c.saveStoreConfig()
+}
+
+// DisableKeyFile makes the store derive keys from the master password
+// alone and records the choice in the store. It must be set before
+// anything is encrypted.
+func (c *Crypto) DisableKeyFile() error {
+	c.noKeyFile = true
+	return c.saveStoreConfig()
+}
+
+// UsesKeyFile reports whether keys combine the master password with the keyfile
+func (c *Crypto) UsesKeyFile() bool {
+	return !c.noKeyFile
+}
+
+// IsCurrent reports whether a blob was encrypted with the current header
+// version, cipher and the store's KDF and parameters. Blobs from before
+// headers existed never are.
+func (c *Crypto) IsCurrent(encryptedData []byte) bool {
+	data, err := parseEncryptedData(encryptedData)
+	return err == nil && data.Version == headerVersion && data.AEAD == defaultAEAD && data.KDF == c.kdf
+}
+
+// HasHeader reports whether a blob starts with a header, i.e. was not
+// written before headers existed
+func (c *Crypto) HasHeader(encryptedData []byte) bool {
+	data, err := parseEncryptedData(encryptedData)
+	return err == nil && data.Version != 0
+}
+
+// KDF returns the name of the KDF used for newly encrypted blobs
+func (c *Crypto) KDF() string {
+	if c.kdf.id == kdfIDScrypt {
+		return KDFScrypt
+	}
+	return KDFArgon2id
+}
@@ -2,21 +2,57 @@ package gitsync
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"net/url"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	gogit "github.com/go-git/go-git/v5"
 	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/format/index"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
+	"github.com/kevinburke/ssh_config"
 	"golang.org/x/term"
 )
 
+// lastSyncFile records the time of the last successful push or pull. It
+// lives in the git-ignored .cache directory, so each device tracks its own.
+const lastSyncFile = ".cache/last-sync"
+
+// gitignoreContent is written to the store's .gitignore so that local-only
+// files never leave the device
+const gitignoreContent = `# Chowkidaar configuration and cache files
+.cache/
+.keyfile
+.git-config
+.backups/
+.history/
+
+# System files
+.DS_Store
+*.tmp
+*.swp
+*~
+
+# Only backup encrypted password files (*.enc)
+# Everything else should be ignored by default
+`
+
 // NetrcEntry represents a single entry in .netrc file
 type NetrcEntry struct {
 	Machine  string
@@ -28,22 +64,74 @@ type NetrcEntry struct {
 type GitSync struct {
 	storeDir   string
 	repository *gogit.Repository
-	remoteURL  string
+	remoteURL  string      // Effective remote URL, the repository's origin when it has one
 	auth       interface{} // Will hold either *http.BasicAuth or *ssh.PublicKeys
+
+	signKeySpec string       // Key file path or gpg key ID used to sign commits
+	signStrict  bool         // Fail instead of committing unsigned when the key is unavailable
+	signer      gogit.Signer // Loaded lazily on the first commit
+
+	verifyKeys openpgp.EntityList // Keys pulled commits must be signed with, see SetVerifyKeys
+	mirrors    []string           // Backup remote URLs pushed to by PushAll, see SetMirrors
+
+	configuredURL string        // URL passed in from the chowkidaar configuration
+	timeout       time.Duration // Limit for each network operation (0 for none)
+	shallow       bool          // Clone only the latest commit, see SetShallow
+
+	allowPlaintext bool // Commit files that are not encrypted, see assertNoPlaintext
+}
+
+// SetShallow makes a clone fetch only the latest commit instead of the whole
+// history. Pull and push keep working; the history before the clone is only
+// missing locally and can be fetched later with 'git fetch --unshallow'.
+func (gs *GitSync) SetShallow(shallow bool) {
+	gs.shallow = shallow
+}
+
+// DefaultTimeout bounds clone, push and pull unless configured otherwise
+const DefaultTimeout = 30 * time.Second
+
+// SetSigningKey enables OpenPGP signing of commits. keySpec is either the
+// path of a private key file or a key ID understood by gpg, in which case
+// gpg signs each commit itself so the key never leaves gpg-agent. With
+// strict set, commits fail when they cannot be signed instead of falling
+// back to unsigned commits.
+func (gs *GitSync) SetSigningKey(keySpec string, strict bool) {
+	gs.signKeySpec = keySpec
+	gs.signStrict = strict
+	gs.signer = nil
+}
+
+// SetVerifyKeys makes Pull and ResetToRemote check that every incoming
+// commit is signed by one of keys before it reaches the working tree. An
+// empty list turns the check off.
+func (gs *GitSync) SetVerifyKeys(keys openpgp.EntityList) {
+	gs.verifyKeys = keys
+}
+
+// SetMirrors sets the URLs of backup remotes that PushAll pushes to after
+// origin. They are kept as the remotes mirror1, mirror2, ... of the
+// repository. Pull only ever uses origin.
+func (gs *GitSync) SetMirrors(urls []string) {
+	gs.mirrors = urls
 }
 
+// mirrorPrefix names the remotes created for mirrors
+const mirrorPrefix = "mirror"
+
 // NewGitSync creates a new GitSync instance
 func NewGitSync(storeDir, remoteURL string) *GitSync {
 	gs := &GitSync{
-		storeDir:  storeDir,
-		remoteURL: remoteURL,
+		storeDir:      storeDir,
+		remoteURL:     remoteURL,
+		configuredURL: remoteURL,
+		timeout:       DefaultTimeout,
 	}
 
 	// Try to open existing Git repository
 	if repo, err := gogit.PlainOpen(storeDir); err == nil {
 		gs.repository = repo
-		// Ensure .gitignore is up to date
-		gs.ensureGitignore()
+		gs.reconcileRemoteURL()
 	}
 
 	return gs
@@ -51,6 +139,11 @@ func NewGitSync(storeDir, remoteURL string) *GitSync {
 
 // InitializeWithRemote initializes or clones a password store with Git support
 func (gs *GitSync) InitializeWithRemote() error {
+	// The URL given to init replaces whatever origin an existing repository has
+	if gs.configuredURL != "" {
+		gs.remoteURL = gs.configuredURL
+	}
+
 	// Check if the directory already exists and has content
 	if _, err := os.Stat(gs.storeDir); err == nil {
 		// Directory exists, check if it's already a Git repository
@@ -79,7 +172,7 @@ func (gs *GitSync) InitializeWithRemote() error {
 
 // cloneFromRemote clones the password store from a remote Git repository
 func (gs *GitSync) cloneFromRemote() error {
-	fmt.Printf("Cloning password store from %s...\n", gs.remoteURL)
+	fmt.Fprintf(os.Stderr, "Cloning password store from %s...\n", gs.remoteURL)
 
 	// Create parent directory if it doesn't exist
 	if err := os.MkdirAll(filepath.Dir(gs.storeDir), 0700); err != nil {
@@ -96,35 +189,43 @@ func (gs *GitSync) cloneFromRemote() error {
 		URL:      gs.remoteURL,
 		Progress: os.Stdout,
 	}
+	if gs.shallow {
+		cloneOptions.Depth = 1
+	}
 
 	// Add authentication if available
 	if gs.auth != nil {
 		cloneOptions.Auth = gs.auth.(transport.AuthMethod)
 	}
 
-	repo, err := gogit.PlainClone(gs.storeDir, false, cloneOptions)
+	ctx, cancel := gs.networkContext()
+	defer cancel()
+
+	repo, err := gogit.PlainCloneContext(ctx, gs.storeDir, false, cloneOptions)
+	err = gs.checkTimeout(ctx, "clone", err)
 
 	if err != nil {
 		// If clone fails, check if it's because the repo is empty
 		if strings.Contains(err.Error(), "remote repository is empty") {
-			fmt.Println("Remote repository is empty, initializing new password store...")
+			fmt.Fprintln(os.Stderr, "Remote repository is empty, initializing new password store...")
 			return gs.initLocalRepository()
 		}
 		return fmt.Errorf("failed to clone repository: %w", err)
 	}
 
 	gs.repository = repo
-	fmt.Println("Password store cloned successfully!")
+	fmt.Fprintln(os.Stderr, "Password store cloned successfully!")
+	gs.recordSync()
 
 	// Ensure .gitignore is up to date after cloning
 	if err := gs.ensureGitignore(); err != nil {
-		fmt.Printf("Warning: failed to update .gitignore: %v\n", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to update .gitignore: %v\n", err)
 	}
 
 	// Count existing passwords
 	count, err := gs.countPasswordFiles()
 	if err == nil && count > 0 {
-		fmt.Printf("Found %d existing passwords in the store.\n", count)
+		fmt.Fprintf(os.Stderr, "Found %d existing passwords in the store.\n", count)
 	}
 
 	return nil
@@ -132,7 +233,7 @@ func (gs *GitSync) cloneFromRemote() error {
 
 // initLocalRepository initializes a new local Git repository
 func (gs *GitSync) initLocalRepository() error {
-	fmt.Println("Initializing new password store with Git support...")
+	fmt.Fprintln(os.Stderr, "Initializing new password store with Git support...")
 
 	// Create store directory
 	if err := os.MkdirAll(gs.storeDir, 0700); err != nil {
@@ -154,32 +255,12 @@ func (gs *GitSync) initLocalRepository() error {
 		}
 	}
 
-	// Create initial .gitignore
-	gitignorePath := filepath.Join(gs.storeDir, ".gitignore")
-	gitignoreContent := `# Chowkidaar configuration and cache files
-.cache/
-.keyfile
-.git-config
-
-# System files
-.DS_Store
-*.tmp
-*.swp
-*~
-
-# Only backup encrypted password files (*.enc)
-# Everything else should be ignored by default
-`
-	if err := os.WriteFile(gitignorePath, []byte(gitignoreContent), 0644); err != nil {
-		return fmt.Errorf("failed to create .gitignore: %w", err)
-	}
-
-	// Create initial commit
+	// Create initial commit (which writes the .gitignore)
 	if err := gs.commitChanges("Initialize password store"); err != nil {
 		return fmt.Errorf("failed to create initial commit: %w", err)
 	}
 
-	fmt.Println("Password store initialized successfully!")
+	fmt.Fprintln(os.Stderr, "Password store initialized successfully!")
 	return nil
 }
 
@@ -213,16 +294,54 @@ func (gs *GitSync) configureRemote() error {
 		return fmt.Errorf("failed to configure remote: %w", err)
 	}
 
+	return gs.configureMirrors()
+}
+
+// configureMirrors makes the remotes mirror1, mirror2, ... match the
+// configured mirror URLs, removing mirror remotes no longer configured
+func (gs *GitSync) configureMirrors() error {
+	remotes, err := gs.repository.Remotes()
+	if err != nil {
+		return fmt.Errorf("failed to get remotes: %w", err)
+	}
+	for _, remote := range remotes {
+		name := remote.Config().Name
+		index, err := strconv.Atoi(strings.TrimPrefix(name, mirrorPrefix))
+		if !strings.HasPrefix(name, mirrorPrefix) || err != nil {
+			continue
+		}
+		if index >= 1 && index <= len(gs.mirrors) && slices.Equal(remote.Config().URLs, []string{gs.mirrors[index-1]}) {
+			continue
+		}
+		if err := gs.repository.DeleteRemote(name); err != nil {
+			return fmt.Errorf("failed to remove remote %s: %w", name, err)
+		}
+	}
+
+	for i, mirrorURL := range gs.mirrors {
+		name := mirrorRemoteName(i)
+		if _, err := gs.repository.Remote(name); err == nil {
+			continue
+		}
+		if _, err := gs.repository.CreateRemote(&config.RemoteConfig{Name: name, URLs: []string{mirrorURL}}); err != nil {
+			return fmt.Errorf("failed to configure remote %s: %w", name, err)
+		}
+	}
 	return nil
 }
 
+// mirrorRemoteName returns the remote name of the i-th mirror, from 0
+func mirrorRemoteName(i int) string {
+	return mirrorPrefix + strconv.Itoa(i+1)
+}
+
 // Push pushes changes to the remote repository
 func (gs *GitSync) Push() error {
 	if gs.repository == nil {
 		return fmt.Errorf("Git repository not initialized")
 	}
 
-	fmt.Println("Pushing changes to remote repository...")
+	fmt.Fprintln(os.Stderr, "Pushing changes to remote repository...")
 
 	// Setup authentication if not already done
 	if gs.auth == nil {
@@ -231,8 +350,17 @@ func (gs *GitSync) Push() error {
 		}
 	}
 
+	ctx, cancel := gs.networkContext()
+	defer cancel()
+
+	local, remoteBranch, err := gs.trackRemoteBranch(ctx)
+	if err != nil {
+		return err
+	}
+
 	pushOptions := &gogit.PushOptions{
 		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{config.RefSpec(plumbing.NewBranchReferenceName(local) + ":" + plumbing.NewBranchReferenceName(remoteBranch))},
 		Progress:   os.Stdout,
 	}
 
@@ -241,145 +369,1085 @@ func (gs *GitSync) Push() error {
 		pushOptions.Auth = gs.auth.(transport.AuthMethod)
 	}
 
-	err := gs.repository.Push(pushOptions)
+	err = gs.checkTimeout(ctx, "push", gs.repository.PushContext(ctx, pushOptions))
+
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("failed to push changes: %w", err)
+	}
+
+	if err == gogit.NoErrAlreadyUpToDate {
+		fmt.Fprintln(os.Stderr, "Already up to date.")
+	} else {
+		fmt.Fprintln(os.Stderr, "Changes pushed successfully!")
+	}
+
+	gs.recordSync()
+	return nil
+}
+
+// PushResult is the outcome of pushing to one remote in PushAll
+type PushResult struct {
+	Remote string // Remote name, origin or mirrorN
+	URL    string
+	Err    error // nil when the push succeeded or there was nothing to push
+}
+
+// PushAll pushes to origin and then to every mirror, each with its own
+// authentication. A failing remote does not stop the others; the results
+// report each one. Mirrors get the branch that origin tracks, so they
+// stay copies of origin.
+func (gs *GitSync) PushAll() ([]PushResult, error) {
+	if gs.repository == nil {
+		return nil, fmt.Errorf("Git repository not initialized")
+	}
+	if err := gs.configureMirrors(); err != nil {
+		return nil, err
+	}
+
+	results := []PushResult{{Remote: "origin", URL: gs.remoteURL, Err: gs.Push()}}
+	if len(gs.mirrors) == 0 {
+		return results, nil
+	}
+
+	ctx, cancel := gs.networkContext()
+	local, remoteBranch, err := gs.trackRemoteBranch(ctx)
+	cancel()
+	for i, mirrorURL := range gs.mirrors {
+		result := PushResult{Remote: mirrorRemoteName(i), URL: mirrorURL}
+		if err != nil {
+			result.Err = err
+		} else {
+			result.Err = gs.pushMirror(result.Remote, mirrorURL, local, remoteBranch)
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
+
+// pushMirror pushes a local branch to a mirror remote. The mirror gets its
+// own authentication, found the same way as for origin but for its URL.
+func (gs *GitSync) pushMirror(name, mirrorURL, local, remoteBranch string) error {
+	fmt.Fprintf(os.Stderr, "Pushing changes to %s...\n", name)
+
+	mirror := &GitSync{storeDir: gs.storeDir, repository: gs.repository, remoteURL: mirrorURL, timeout: gs.timeout}
+	if err := mirror.setupAuthentication(); err != nil {
+		return fmt.Errorf("failed to setup authentication: %w", err)
+	}
+
+	ctx, cancel := gs.networkContext()
+	defer cancel()
+
+	pushOptions := &gogit.PushOptions{
+		RemoteName: name,
+		RefSpecs:   []config.RefSpec{config.RefSpec(plumbing.NewBranchReferenceName(local) + ":" + plumbing.NewBranchReferenceName(remoteBranch))},
+		Progress:   os.Stdout,
+	}
+	if mirror.auth != nil {
+		pushOptions.Auth = mirror.auth.(transport.AuthMethod)
+	}
+
+	err := gs.checkTimeout(ctx, "push to "+name, gs.repository.PushContext(ctx, pushOptions))
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("failed to push changes: %w", err)
+	}
+	return nil
+}
+
+// Pull pulls changes from the remote repository
+func (gs *GitSync) Pull() error {
+	if gs.repository == nil {
+		return fmt.Errorf("Git repository not initialized")
+	}
+
+	// Get the working tree
+	worktree, err := gs.repository.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	fmt.Fprintln(os.Stderr, "Pulling changes from remote repository...")
+
+	// Setup authentication if not already done
+	if gs.auth == nil {
+		if err := gs.setupAuthentication(); err != nil {
+			return fmt.Errorf("failed to setup authentication: %w", err)
+		}
+	}
+
+	ctx, cancel := gs.networkContext()
+	defer cancel()
+
+	_, remoteBranch, err := gs.trackRemoteBranch(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = gs.pullFastForward(ctx, worktree, remoteBranch)
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("failed to pull changes: %w", err)
+	}
+
+	if err == gogit.NoErrAlreadyUpToDate {
+		fmt.Fprintln(os.Stderr, "Already up to date.")
+	} else {
+		fmt.Fprintln(os.Stderr, "Changes pulled successfully!")
+	}
+
+	gs.recordSync()
+	return nil
+}
+
+// ResetToRemote fetches from origin and hard-resets the current branch and
+// working tree to origin's copy of it, discarding local commits and
+// uncommitted changes to tracked files. Untracked and ignored files, such as
+// the keyfile, are left alone.
+func (gs *GitSync) ResetToRemote() error {
+	if gs.repository == nil {
+		return fmt.Errorf("Git repository not initialized")
+	}
+
+	worktree, err := gs.repository.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	fmt.Fprintln(os.Stderr, "Fetching changes from remote repository...")
+
+	if gs.auth == nil {
+		if err := gs.setupAuthentication(); err != nil {
+			return fmt.Errorf("failed to setup authentication: %w", err)
+		}
+	}
+
+	fetchOptions := &gogit.FetchOptions{
+		RemoteName: "origin",
+		Progress:   os.Stdout,
+		Force:      true,
+	}
+	if gs.auth != nil {
+		fetchOptions.Auth = gs.auth.(transport.AuthMethod)
+	}
+
+	ctx, cancel := gs.networkContext()
+	defer cancel()
+
+	err = gs.checkTimeout(ctx, "fetch", gs.repository.FetchContext(ctx, fetchOptions))
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return fmt.Errorf("failed to fetch changes: %w", err)
+	}
+
+	_, branch, err := gs.trackRemoteBranch(ctx)
+	if err != nil {
+		return err
+	}
+
+	remoteRef, err := gs.repository.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return fmt.Errorf("remote has no branch %s: %w", branch, err)
+	}
+
+	if len(gs.verifyKeys) > 0 {
+		incoming, _, err := gs.incomingCommits(remoteRef.Hash())
+		if err != nil {
+			return err
+		}
+		if err := gs.verifyCommits(incoming, gs.verifyKeys); err != nil {
+			return err
+		}
+	}
+
+	// A hard reset of the whole worktree would also delete untracked and
+	// ignored files, including the keyfile, so only tracked paths are reset
+	files, err := gs.trackedPaths(remoteRef.Hash())
+	if err != nil {
+		return err
+	}
+	resetOptions := &gogit.ResetOptions{Commit: remoteRef.Hash(), Mode: gogit.HardReset, Files: files}
+	if len(files) == 0 {
+		resetOptions.Mode = gogit.MixedReset
+	}
+	if err := worktree.Reset(resetOptions); err != nil {
+		return fmt.Errorf("failed to reset to origin/%s: %w", branch, err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Store reset to origin/%s (%s)\n", branch, remoteRef.Hash().String()[:8])
+	gs.recordSync()
+	return nil
+}
+
+// pullFastForward fetches origin's branch and fast-forwards to it, with
+// verify keys set only after every new commit passed VerifyCommits. Unlike
+// PullContext it does not fetch again before merging, so commits pushed
+// meanwhile are never merged unchecked, and it only updates tracked paths:
+// go-git's pull resets the whole worktree, which deletes untracked and
+// ignored files such as the keyfile, .git-config and .cache/.
+func (gs *GitSync) pullFastForward(ctx context.Context, worktree *gogit.Worktree, remoteBranch string) error {
+	fetchOptions := &gogit.FetchOptions{
+		RemoteName: "origin",
+		Progress:   os.Stdout,
+	}
+	if gs.auth != nil {
+		fetchOptions.Auth = gs.auth.(transport.AuthMethod)
+	}
+
+	err := gs.checkTimeout(ctx, "fetch", gs.repository.FetchContext(ctx, fetchOptions))
+	if err != nil && err != gogit.NoErrAlreadyUpToDate {
+		return err
+	}
+
+	remoteRef, err := gs.repository.Reference(plumbing.NewRemoteReferenceName("origin", remoteBranch), true)
+	if err != nil {
+		return fmt.Errorf("remote has no branch %s: %w", remoteBranch, err)
+	}
+	incoming, fastForward, err := gs.incomingCommits(remoteRef.Hash())
+	if err != nil {
+		return err
+	}
+	if len(incoming) == 0 {
+		return gogit.NoErrAlreadyUpToDate
+	}
+	if !fastForward {
+		return gogit.ErrNonFastForwardUpdate
+	}
+	if len(gs.verifyKeys) > 0 {
+		if err := gs.verifyCommits(incoming, gs.verifyKeys); err != nil {
+			return err
+		}
+	}
+
+	// Local changes to tracked files still stop the merge with
+	// ErrUnstagedChanges, as they would in git
+	files, err := gs.trackedPaths(remoteRef.Hash())
+	if err != nil {
+		return err
+	}
+	resetOptions := &gogit.ResetOptions{Mode: gogit.MergeReset, Commit: remoteRef.Hash(), Files: files}
+	if len(files) == 0 {
+		resetOptions.Mode = gogit.MixedReset
+	}
+	return worktree.Reset(resetOptions)
+}
+
+// ErrUntrustedCommit is returned for an incoming commit that is unsigned or
+// not signed by any of the trusted keys
+var ErrUntrustedCommit = errors.New("commit is not signed by a trusted key")
+
+// LoadTrustedKeys reads the public keys that pulled commits are verified
+// against from an armored or binary key ring file
+func LoadTrustedKeys(path string) (openpgp.EntityList, error) {
+	keyData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read trusted keys: %w", err)
+	}
+
+	keys, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyData))
+	if err != nil {
+		if keys, err = openpgp.ReadKeyRing(bytes.NewReader(keyData)); err != nil {
+			return nil, fmt.Errorf("failed to parse trusted keys: %w", err)
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%s contains no keys", path)
+	}
+	return keys, nil
+}
+
+// VerifyCommits checks that every commit on origin's branch that is not in
+// HEAD yet is signed by one of keys. It only reads the repository, so it is
+// meant to run after a fetch and before the merge.
+func (gs *GitSync) VerifyCommits(keys openpgp.EntityList) error {
+	if gs.repository == nil {
+		return fmt.Errorf("Git repository not initialized")
+	}
+
+	_, branch, err := gs.upstreamBranch()
+	if err != nil {
+		return err
+	}
+	remoteRef, err := gs.repository.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return fmt.Errorf("no remote tracking branch for %s: %w", branch, err)
+	}
+
+	incoming, _, err := gs.incomingCommits(remoteRef.Hash())
+	if err != nil {
+		return err
+	}
+	return gs.verifyCommits(incoming, keys)
+}
+
+// incomingCommits returns the commits reachable from to but not from HEAD,
+// oldest first, and whether HEAD can be fast-forwarded to it
+func (gs *GitSync) incomingCommits(to plumbing.Hash) ([]*object.Commit, bool, error) {
+	local := make(map[plumbing.Hash]bool)
+	head, err := gs.repository.Head()
+	if err == nil {
+		if local, err = gs.ancestors(head.Hash()); err != nil {
+			return nil, false, err
+		}
+	} else if err != plumbing.ErrReferenceNotFound {
+		return nil, false, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	remote, err := gs.ancestors(to)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var incoming []*object.Commit
+	for hash := range remote {
+		if local[hash] {
+			continue
+		}
+		commit, err := gs.repository.CommitObject(hash)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read commit history: %w", err)
+		}
+		incoming = append(incoming, commit)
+	}
+	slices.SortFunc(incoming, func(a, b *object.Commit) int {
+		return a.Committer.When.Compare(b.Committer.When)
+	})
+
+	fastForward := head == nil || remote[head.Hash()]
+	return incoming, fastForward, nil
+}
+
+// verifyCommits checks the signature of each commit against keys and stops
+// at the first one that fails
+func (gs *GitSync) verifyCommits(commits []*object.Commit, keys openpgp.EntityList) error {
+	for _, commit := range commits {
+		subject, _, _ := strings.Cut(strings.TrimSpace(commit.Message), "\n")
+		if commit.PGPSignature == "" {
+			return fmt.Errorf("%w: %s (%s) is unsigned", ErrUntrustedCommit, commit.Hash.String()[:8], subject)
+		}
+
+		signed := &plumbing.MemoryObject{}
+		if err := commit.EncodeWithoutSignature(signed); err != nil {
+			return fmt.Errorf("failed to encode commit %s: %w", commit.Hash, err)
+		}
+		reader, err := signed.Reader()
+		if err != nil {
+			return fmt.Errorf("failed to encode commit %s: %w", commit.Hash, err)
+		}
+		if _, err := openpgp.CheckArmoredDetachedSignature(keys, reader, strings.NewReader(commit.PGPSignature), nil); err != nil {
+			return fmt.Errorf("%w: %s (%s): %v", ErrUntrustedCommit, commit.Hash.String()[:8], subject, err)
+		}
+	}
+	return nil
+}
+
+// trackedPaths returns the paths in the index and in the given commit's tree
+func (gs *GitSync) trackedPaths(commitHash plumbing.Hash) ([]string, error) {
+	paths := make(map[string]bool)
+
+	idx, err := gs.repository.Storer.Index()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+	for _, entry := range idx.Entries {
+		paths[entry.Name] = true
+	}
+
+	commit, err := gs.repository.CommitObject(commitHash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit %s: %w", commitHash, err)
+	}
+	tree, err := commit.Tree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tree of %s: %w", commitHash, err)
+	}
+	files := tree.Files()
+	defer files.Close()
+	for {
+		file, err := files.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read tree of %s: %w", commitHash, err)
+		}
+		paths[file.Name] = true
+	}
+
+	result := make([]string, 0, len(paths))
+	for path := range paths {
+		result = append(result, path)
+	}
+	return result, nil
+}
+
+// currentBranch returns the branch checked out in the store, which may not
+// have any commits yet
+func (gs *GitSync) currentBranch() (string, error) {
+	head, err := gs.repository.Storer.Reference(plumbing.HEAD)
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	if head.Type() != plumbing.SymbolicReference || !head.Target().IsBranch() {
+		return "", fmt.Errorf("HEAD is detached, check out a branch in %s first", gs.storeDir)
+	}
+	return head.Target().Short(), nil
+}
+
+// upstreamBranch returns the current branch and the branch of origin it
+// tracks, as recorded by a clone or trackRemoteBranch. Without a record the
+// remote branch is assumed to have the same name.
+func (gs *GitSync) upstreamBranch() (string, string, error) {
+	local, err := gs.currentBranch()
+	if err != nil {
+		return "", "", err
+	}
+
+	cfg, err := gs.repository.Config()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read repository config: %w", err)
+	}
+	if branch, ok := cfg.Branches[local]; ok && branch.Remote == "origin" && branch.Merge.IsBranch() {
+		return local, branch.Merge.Short(), nil
+	}
+	return local, local, nil
+}
+
+// trackRemoteBranch returns the current branch and the branch of origin to
+// push to and pull from. When none is recorded it asks the remote and picks
+// the branch of the same name, else the remote's default branch, and records
+// the choice like git's upstream setting. An empty remote gets the current
+// branch's name.
+func (gs *GitSync) trackRemoteBranch(ctx context.Context) (string, string, error) {
+	local, err := gs.currentBranch()
+	if err != nil {
+		return "", "", err
+	}
+
+	cfg, err := gs.repository.Config()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read repository config: %w", err)
+	}
+	if branch, ok := cfg.Branches[local]; ok && branch.Remote == "origin" && branch.Merge.IsBranch() {
+		return local, branch.Merge.Short(), nil
+	}
+
+	remote, err := gs.repository.Remote("origin")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to read remote origin: %w", err)
+	}
+	listOptions := &gogit.ListOptions{}
+	if gs.auth != nil {
+		listOptions.Auth = gs.auth.(transport.AuthMethod)
+	}
+	refs, err := remote.ListContext(ctx, listOptions)
+	if errors.Is(err, transport.ErrEmptyRemoteRepository) {
+		return local, local, nil
+	}
+	if err := gs.checkTimeout(ctx, "list remote branches", err); err != nil {
+		return "", "", fmt.Errorf("failed to list remote branches: %w", err)
+	}
+
+	remoteBranch, defaultBranch := "", ""
+	for _, ref := range refs {
+		if ref.Name() == plumbing.NewBranchReferenceName(local) {
+			remoteBranch = local
+		}
+		if ref.Name() == plumbing.HEAD && ref.Type() == plumbing.SymbolicReference && ref.Target().IsBranch() {
+			defaultBranch = ref.Target().Short()
+		}
+	}
+	if remoteBranch == "" {
+		remoteBranch = defaultBranch
+	}
+	if remoteBranch == "" {
+		return local, local, nil
+	}
+
+	cfg.Branches[local] = &config.Branch{Name: local, Remote: "origin", Merge: plumbing.NewBranchReferenceName(remoteBranch)}
+	if err := gs.repository.SetConfig(cfg); err != nil {
+		return "", "", fmt.Errorf("failed to record upstream branch: %w", err)
+	}
+	return local, remoteBranch, nil
+}
+
+// CommitChanges commits changes to the repository
+func (gs *GitSync) commitChanges(message string) error {
+	if gs.repository == nil {
+		return fmt.Errorf("Git repository not initialized")
+	}
+
+	// Keep local files such as the keyfile out of every commit
+	if err := gs.ensureGitignore(); err != nil {
+		return fmt.Errorf("failed to update .gitignore: %w", err)
+	}
+
+	// Get the working tree
+	worktree, err := gs.repository.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	// Refuse to stage anything that could be a secret in the clear
+	if !gs.allowPlaintext {
+		pending, err := worktree.Status()
+		if err != nil {
+			return fmt.Errorf("failed to get status: %w", err)
+		}
+		var paths []string
+		for path, fileStatus := range pending {
+			if fileStatus.Worktree != gogit.Deleted && fileStatus.Staging != gogit.Deleted {
+				paths = append(paths, path)
+			}
+		}
+		if err := assertNoPlaintext(paths); err != nil {
+			return err
+		}
+	}
+
+	// Add all changes
+	_, err = worktree.Add(".")
+	if err != nil {
+		return fmt.Errorf("failed to add changes: %w", err)
+	}
+
+	// Check if there are any changes to commit
+	status, err := worktree.Status()
+	if err != nil {
+		return fmt.Errorf("failed to get status: %w", err)
+	}
+
+	if len(status) == 0 {
+		// No changes to commit
+		return nil
+	}
+
+	signer, err := gs.commitSigner()
+	if err != nil {
+		if gs.signStrict {
+			return fmt.Errorf("failed to load commit signing key: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: creating unsigned commit, failed to load signing key: %v\n", err)
+	}
+
+	// Commit changes
+	commit, err := worktree.Commit(message, &gogit.CommitOptions{
+		// Author: &object.Signature{
+		// 	//Name:  "pwd-mngr",
+		// 	//Email: "pwd-mngr@localhost",
+		// 	When: time.Now(),
+		// },
+		Signer: signer,
+	})
+	if err != nil && signer != nil && !gs.signStrict {
+		fmt.Fprintf(os.Stderr, "Warning: creating unsigned commit, failed to sign: %v\n", err)
+		commit, err = worktree.Commit(message, &gogit.CommitOptions{})
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to commit changes: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Changes committed: %s\n", commit.String()[:8])
+	return nil
+}
+
+// plaintextAllowlist names the files, by base name, that are committed
+// although they are not encrypted: they hold no secrets
+var plaintextAllowlist = map[string]bool{
+	".gitignore":      true,
+	".desc":           true, // Folder descriptions
+	".crypto.json":    true, // Key derivation parameters
+	".names.idx":      true, // Encrypted name index of stores that hide names
+	".gpg-id":         true, // Left by pass in migrated stores
+	".store-version":  true, // On-disk format version
+	".templates.json": true, // Entry templates for insert --template
+}
+
+// SetAllowPlaintext lets commits include files that are neither encrypted
+// entries nor on the allowlist, for a file added deliberately
+func (gs *GitSync) SetAllowPlaintext(allow bool) {
+	gs.allowPlaintext = allow
+}
+
+// assertNoPlaintext fails when any of paths, relative to the store, is not
+// an encrypted entry (.enc or .gpg) or an allowlisted file. Such a file, e.g.
+// an editor backup, may hold a secret in the clear and must not reach the
+// remote.
+func assertNoPlaintext(paths []string) error {
+	var offending []string
+	for _, path := range paths {
+		name := filepath.Base(path)
+		if strings.HasSuffix(name, ".enc") || strings.HasSuffix(name, ".gpg") || plaintextAllowlist[name] {
+			continue
+		}
+		offending = append(offending, filepath.ToSlash(path))
+	}
+	if len(offending) == 0 {
+		return nil
+	}
+
+	slices.Sort(offending)
+	return fmt.Errorf("%w: %s (remove it, add it to .gitignore or pass --allow-plaintext)", ErrPlaintext, strings.Join(offending, ", "))
+}
+
+// ErrPlaintext is returned for a commit that would include unencrypted files
+var ErrPlaintext = errors.New("refusing to commit unencrypted files")
+
+// CommitAndPushChanges commits changes and pushes them to remote
+func (gs *GitSync) CommitAndPushChanges(message string) error {
+	// Commit changes
+	if err := gs.commitChanges(message); err != nil {
+		return err
+	}
+
+	// Push to remote if configured
+	if gs.remoteURL != "" {
+		return gs.Push()
+	}
+
+	return nil
+}
+
+// Commit commits changes locally without pushing to remote
+func (gs *GitSync) Commit(message string) error {
+	return gs.commitChanges(message)
+}
+
+// MoveFile renames a file in the store like git mv: it is moved on disk and
+// the index records old as removed and new as added, so the next commit shows
+// a rename that git log --follow tracks. Paths are absolute or relative to
+// the store. A file git does not track yet is simply renamed.
+func (gs *GitSync) MoveFile(oldPath, newPath string) error {
+	if gs.repository == nil {
+		return os.Rename(gs.storePath(oldPath), gs.storePath(newPath))
+	}
+
+	oldRel, err := filepath.Rel(gs.storeDir, gs.storePath(oldPath))
+	if err != nil {
+		return err
+	}
+	newRel, err := filepath.Rel(gs.storeDir, gs.storePath(newPath))
+	if err != nil {
+		return err
+	}
+
+	worktree, err := gs.repository.Worktree()
+	if err != nil {
+		return fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	_, err = worktree.Move(filepath.ToSlash(oldRel), filepath.ToSlash(newRel))
+	if errors.Is(err, index.ErrEntryNotFound) {
+		return os.Rename(gs.storePath(oldPath), gs.storePath(newPath))
+	}
+	if err != nil {
+		return fmt.Errorf("failed to move %s to %s: %w", oldRel, newRel, err)
+	}
+	return nil
+}
+
+// storePath returns path as an absolute path inside the store
+func (gs *GitSync) storePath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(gs.storeDir, path)
+}
+
+// Status returns the Git status of the repository
+func (gs *GitSync) Status() (gogit.Status, error) {
+	if gs.repository == nil {
+		return nil, fmt.Errorf("Git repository not initialized")
+	}
+
+	worktree, err := gs.repository.Worktree()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get worktree: %w", err)
+	}
+
+	return worktree.Status()
+}
+
+// AheadBehind counts the local commits missing from origin and the origin
+// commits missing locally, as of the last fetch. It does not contact the remote.
+func (gs *GitSync) AheadBehind() (int, int, error) {
+	if gs.repository == nil {
+		return 0, 0, fmt.Errorf("Git repository not initialized")
+	}
+
+	head, err := gs.repository.Head()
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to read HEAD: %w", err)
+	}
+
+	_, branch, err := gs.upstreamBranch()
+	if err != nil {
+		return 0, 0, err
+	}
+	remoteRef, err := gs.repository.Reference(plumbing.NewRemoteReferenceName("origin", branch), true)
+	if err != nil {
+		return 0, 0, fmt.Errorf("no remote tracking branch for %s: %w", branch, err)
+	}
+
+	local, err := gs.ancestors(head.Hash())
+	if err != nil {
+		return 0, 0, err
+	}
+	remote, err := gs.ancestors(remoteRef.Hash())
+	if err != nil {
+		return 0, 0, err
+	}
+
+	ahead, behind := 0, 0
+	for hash := range local {
+		if !remote[hash] {
+			ahead++
+		}
+	}
+	for hash := range remote {
+		if !local[hash] {
+			behind++
+		}
+	}
+	return ahead, behind, nil
+}
+
+// HeadCommit returns the hash of the commit checked out in the store
+func (gs *GitSync) HeadCommit() (string, error) {
+	if gs.repository == nil {
+		return "", fmt.Errorf("Git repository not initialized")
+	}
+
+	head, err := gs.repository.Head()
+	if err != nil {
+		return "", fmt.Errorf("failed to read HEAD: %w", err)
+	}
+	return head.Hash().String(), nil
+}
+
+// Changes lists the entries, by file name without .enc, that differ
+// between two commits. Files that are not entries, such as history
+// versions and the name index, are left out.
+type Changes struct {
+	Added    []string
+	Modified []string
+	Removed  []string
+}
+
+// Count returns the number of changed entries
+func (c Changes) Count() int {
+	return len(c.Added) + len(c.Modified) + len(c.Removed)
+}
+
+// ChangesBetween compares the trees of two commits, e.g. HeadCommit before
+// and after a pull
+func (gs *GitSync) ChangesBetween(from, to string) (Changes, error) {
+	var changes Changes
+	if gs.repository == nil {
+		return changes, fmt.Errorf("Git repository not initialized")
+	}
+
+	trees := make([]*object.Tree, 2)
+	for i, hash := range []string{from, to} {
+		commit, err := gs.repository.CommitObject(plumbing.NewHash(hash))
+		if err != nil {
+			return changes, fmt.Errorf("failed to read commit %s: %w", hash, err)
+		}
+		if trees[i], err = commit.Tree(); err != nil {
+			return changes, fmt.Errorf("failed to read tree of %s: %w", hash, err)
+		}
+	}
+
+	diff, err := object.DiffTree(trees[0], trees[1])
+	if err != nil {
+		return changes, fmt.Errorf("failed to compare %s and %s: %w", from, to, err)
+	}
+	for _, change := range diff {
+		switch {
+		case change.From.Name == "":
+			if name, ok := entryName(change.To.Name); ok {
+				changes.Added = append(changes.Added, name)
+			}
+		case change.To.Name == "":
+			if name, ok := entryName(change.From.Name); ok {
+				changes.Removed = append(changes.Removed, name)
+			}
+		default:
+			if name, ok := entryName(change.To.Name); ok {
+				changes.Modified = append(changes.Modified, name)
+			}
+		}
+	}
+	return changes, nil
+}
+
+// entryName returns the entry name of a path in the repository, or false
+// if the path is not an entry
+func entryName(path string) (string, bool) {
+	if !strings.HasSuffix(path, ".enc") {
+		return "", false
+	}
+	for _, part := range strings.Split(path, "/") {
+		if strings.HasPrefix(part, ".") {
+			return "", false
+		}
+	}
+	return strings.TrimSuffix(path, ".enc"), true
+}
+
+// ancestors returns the set of commits reachable from the given commit
+func (gs *GitSync) ancestors(from plumbing.Hash) (map[plumbing.Hash]bool, error) {
+	// The parents of a shallow clone's oldest commits were never fetched
+	boundary := make(map[plumbing.Hash]bool)
+	shallow, err := gs.repository.Storer.Shallow()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read shallow commits: %w", err)
+	}
+	for _, hash := range shallow {
+		boundary[hash] = true
+	}
+
+	seen := make(map[plumbing.Hash]bool)
+	pending := []plumbing.Hash{from}
+	for len(pending) > 0 {
+		hash := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		if seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		if boundary[hash] {
+			continue
+		}
 
-	if err != nil && err != gogit.NoErrAlreadyUpToDate {
-		return fmt.Errorf("failed to push changes: %w", err)
+		commit, err := gs.repository.CommitObject(hash)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit history: %w", err)
+		}
+		pending = append(pending, commit.ParentHashes...)
 	}
+	return seen, nil
+}
 
-	if err == gogit.NoErrAlreadyUpToDate {
-		fmt.Println("Already up to date.")
-	} else {
-		fmt.Println("Changes pushed successfully!")
-	}
+// LogFilter selects the commits returned by Log
+type LogFilter struct {
+	Path    string    // Only commits that changed this file, relative to the store root
+	Since   time.Time // Only commits made at or after this time (zero for no limit)
+	Until   time.Time // Only commits made at or before this time (zero for no limit)
+	Limit   int       // Only the newest this many matching commits (0 for all)
+	Reverse bool      // Oldest first instead of newest first
+}
 
-	return nil
+// LogEntry is the metadata of one commit in the store's history
+type LogEntry struct {
+	Hash    string    `json:"hash"`
+	Author  string    `json:"author"`
+	Email   string    `json:"email"`
+	Date    time.Time `json:"date"`
+	Message string    `json:"message"`
 }
 
-// Pull pulls changes from the remote repository
-func (gs *GitSync) Pull() error {
+// Log returns the commits reachable from HEAD that match filter, newest
+// first unless filter.Reverse is set. In a shallow clone it stops at the
+// oldest fetched commit.
+func (gs *GitSync) Log(filter LogFilter) ([]LogEntry, error) {
 	if gs.repository == nil {
-		return fmt.Errorf("Git repository not initialized")
+		return nil, fmt.Errorf("Git repository not initialized")
 	}
 
-	// Get the working tree
-	worktree, err := gs.repository.Worktree()
+	head, err := gs.repository.Head()
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		return nil, fmt.Errorf("failed to read HEAD: %w", err)
 	}
 
-	fmt.Println("Pulling changes from remote repository...")
-
-	// Setup authentication if not already done
-	if gs.auth == nil {
-		if err := gs.setupAuthentication(); err != nil {
-			return fmt.Errorf("failed to setup authentication: %w", err)
-		}
+	logOptions := &gogit.LogOptions{From: head.Hash(), Order: gogit.LogOrderCommitterTime}
+	if filter.Path != "" {
+		path := filepath.ToSlash(filter.Path)
+		logOptions.FileName = &path
 	}
-
-	pullOptions := &gogit.PullOptions{
-		RemoteName: "origin",
-		Progress:   os.Stdout,
+	if !filter.Since.IsZero() {
+		logOptions.Since = &filter.Since
+	}
+	if !filter.Until.IsZero() {
+		logOptions.Until = &filter.Until
 	}
 
-	// Add authentication if available
-	if gs.auth != nil {
-		pullOptions.Auth = gs.auth.(transport.AuthMethod)
+	commits, err := gs.repository.Log(logOptions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read commit history: %w", err)
 	}
+	defer commits.Close()
 
-	err = worktree.Pull(pullOptions)
+	var entries []LogEntry
+	for filter.Limit <= 0 || len(entries) < filter.Limit {
+		commit, err := commits.Next()
+		if err == io.EOF {
+			break
+		}
+		// The parents of a shallow clone's oldest commits were never fetched
+		if errors.Is(err, plumbing.ErrObjectNotFound) && gs.IsShallow() {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read commit history: %w", err)
+		}
 
-	if err != nil && err != gogit.NoErrAlreadyUpToDate {
-		return fmt.Errorf("failed to pull changes: %w", err)
+		entries = append(entries, LogEntry{
+			Hash:    commit.Hash.String(),
+			Author:  commit.Author.Name,
+			Email:   commit.Author.Email,
+			Date:    commit.Author.When,
+			Message: strings.TrimSpace(commit.Message),
+		})
 	}
 
-	if err == gogit.NoErrAlreadyUpToDate {
-		fmt.Println("Already up to date.")
-	} else {
-		fmt.Println("Changes pulled successfully!")
+	if filter.Reverse {
+		slices.Reverse(entries)
 	}
-
-	return nil
+	return entries, nil
 }
 
-// CommitChanges commits changes to the repository
-func (gs *GitSync) commitChanges(message string) error {
+// IsShallow reports whether the store was cloned without its full history,
+// see SetShallow
+func (gs *GitSync) IsShallow() bool {
 	if gs.repository == nil {
-		return fmt.Errorf("Git repository not initialized")
+		return false
 	}
+	shallow, err := gs.repository.Storer.Shallow()
+	return err == nil && len(shallow) > 0
+}
 
-	// Get the working tree
-	worktree, err := gs.repository.Worktree()
+// LastSyncTime returns when the store last pushed to or pulled from its
+// remote successfully. It returns the zero time if no sync was recorded.
+func (gs *GitSync) LastSyncTime() (time.Time, error) {
+	data, err := os.ReadFile(filepath.Join(gs.storeDir, lastSyncFile))
 	if err != nil {
-		return fmt.Errorf("failed to get worktree: %w", err)
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+		return time.Time{}, fmt.Errorf("failed to read last sync time: %w", err)
 	}
 
-	// Add all changes
-	_, err = worktree.Add(".")
+	lastSync, err := time.Parse(time.RFC3339Nano, strings.TrimSpace(string(data)))
 	if err != nil {
-		return fmt.Errorf("failed to add changes: %w", err)
+		return time.Time{}, fmt.Errorf("failed to parse last sync time: %w", err)
 	}
+	return lastSync, nil
+}
 
-	// Check if there are any changes to commit
-	status, err := worktree.Status()
+// recordSync stores the time of a successful sync, to the nanosecond so that
+// entries written by the sync are not newer than it. It is best-effort: the
+// sync itself already succeeded, so a failure only prints a warning.
+func (gs *GitSync) recordSync() {
+	path := filepath.Join(gs.storeDir, lastSyncFile)
+	err := os.MkdirAll(filepath.Dir(path), 0700)
+	if err == nil {
+		err = os.WriteFile(path, []byte(time.Now().UTC().Format(time.RFC3339Nano)+"\n"), 0600)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to get status: %w", err)
+		fmt.Fprintf(os.Stderr, "Warning: failed to record sync time: %v\n", err)
 	}
+}
 
-	if len(status) == 0 {
-		// No changes to commit
-		return nil
+// reconcileRemoteURL makes the repository's origin the effective remote URL,
+// since that is what push and pull use
+func (gs *GitSync) reconcileRemoteURL() {
+	remote, err := gs.repository.Remote("origin")
+	if err != nil || len(remote.Config().URLs) == 0 {
+		return
 	}
+	gs.remoteURL = remote.Config().URLs[0]
+}
 
-	// Commit changes
-	commit, err := worktree.Commit(message, &gogit.CommitOptions{
-		// Author: &object.Signature{
-		// 	//Name:  "pwd-mngr",
-		// 	//Email: "pwd-mngr@localhost",
-		// 	When: time.Now(),
-		// },
-	})
+// RemoteURLDrift returns the configured remote URL and reports whether it
+// differs from the repository's origin, which is used instead
+func (gs *GitSync) RemoteURLDrift() (string, bool) {
+	return gs.configuredURL, gs.configuredURL != "" && gs.configuredURL != gs.remoteURL
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to commit changes: %w", err)
-	}
+// SetTimeout limits how long a single clone, push or pull may take.
+// A zero timeout waits indefinitely.
+func (gs *GitSync) SetTimeout(timeout time.Duration) {
+	gs.timeout = timeout
+}
 
-	fmt.Printf("Changes committed: %s\n", commit.String()[:8])
-	return nil
+// networkContext returns the context for one network operation
+func (gs *GitSync) networkContext() (context.Context, context.CancelFunc) {
+	if gs.timeout <= 0 {
+		return context.WithCancel(context.Background())
+	}
+	return context.WithTimeout(context.Background(), gs.timeout)
 }
 
-// CommitAndPushChanges commits changes and pushes them to remote
-func (gs *GitSync) CommitAndPushChanges(message string) error {
-	// Commit changes
-	if err := gs.commitChanges(message); err != nil {
+// checkTimeout replaces err with a clear message when ctx ran out of time.
+// Failures are marked with ErrRemote; NoErrAlreadyUpToDate is passed on as is.
+func (gs *GitSync) checkTimeout(ctx context.Context, operation string, err error) error {
+	if err == nil || err == gogit.NoErrAlreadyUpToDate {
 		return err
 	}
-
-	// Push to remote if configured
-	if gs.remoteURL != "" {
-		return gs.Push()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%s timed out after %s (use --timeout or PASSWORD_STORE_GIT_TIMEOUT to allow longer)", operation, gs.timeout)
 	}
+	return &remoteError{err: err}
+}
 
-	return nil
+// ErrRemote matches errors of operations that talk to a Git remote: clone,
+// fetch, pull and push, whether the network, the remote or authentication
+// failed
+var ErrRemote = errors.New("remote operation failed")
+
+// remoteError marks a failure with ErrRemote without changing its message
+type remoteError struct {
+	err error
 }
 
-// Commit commits changes locally without pushing to remote
-func (gs *GitSync) Commit(message string) error {
-	return gs.commitChanges(message)
+func (e *remoteError) Error() string {
+	return e.err.Error()
 }
 
-// Status returns the Git status of the repository
-func (gs *GitSync) Status() (gogit.Status, error) {
+func (e *remoteError) Unwrap() error {
+	return e.err
+}
+
+func (e *remoteError) Is(target error) bool {
+	return target == ErrRemote
+}
+
+// SetRemoteURL points origin at a new URL. Credentials set up for the old
+// remote are dropped.
+func (gs *GitSync) SetRemoteURL(remoteURL string) error {
 	if gs.repository == nil {
-		return nil, fmt.Errorf("Git repository not initialized")
+		return fmt.Errorf("Git repository not initialized")
 	}
 
-	worktree, err := gs.repository.Worktree()
+	if err := ValidateRemoteURL(remoteURL); err != nil {
+		return err
+	}
+
+	gs.remoteURL = remoteURL
+	gs.configuredURL = remoteURL
+	gs.auth = nil
+	return gs.configureRemote()
+}
+
+// ValidateRemoteURL checks that a remote URL uses a scheme chowkidaar can
+// authenticate against: https, ssh:// or the scp-like git@host:path form
+func ValidateRemoteURL(remoteURL string) error {
+	if strings.HasPrefix(remoteURL, "git@") && strings.Contains(remoteURL, ":") {
+		return nil
+	}
+
+	parsedURL, err := url.Parse(remoteURL)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get worktree: %w", err)
+		return fmt.Errorf("invalid remote URL: %w", err)
 	}
 
-	return worktree.Status()
+	switch parsedURL.Scheme {
+	case "https", "ssh":
+		if parsedURL.Host == "" {
+			return fmt.Errorf("invalid remote URL '%s': missing host", remoteURL)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unsupported remote URL '%s': use https://, ssh:// or git@host:path", remoteURL)
+	}
 }
 
 // IsGitEnabled checks if Git support is enabled for this store
@@ -387,7 +1455,8 @@ func (gs *GitSync) IsGitEnabled() bool {
 	return gs.repository != nil
 }
 
-// GetRemoteURL returns the configured remote URL
+// GetRemoteURL returns the effective remote URL, which is the repository's
+// origin when it has one
 func (gs *GitSync) GetRemoteURL() string {
 	return gs.remoteURL
 }
@@ -399,6 +1468,10 @@ func (gs *GitSync) countPasswordFiles() (int, error) {
 		if err != nil {
 			return err
 		}
+		// Skip .git, .history, .shared and other hidden directories
+		if info.IsDir() && path != gs.storeDir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
 		if !info.IsDir() && strings.HasSuffix(info.Name(), ".enc") {
 			count++
 		}
@@ -426,10 +1499,33 @@ func (gs *GitSync) setupAuthentication() error {
 	return nil
 }
 
-// setupSSHAuthentication sets up SSH key authentication
+// setupSSHAuthentication sets up SSH key authentication. A Host entry in
+// ~/.ssh/config matching the URL's host, such as an alias like github-work
+// for a second account, picks the user and key as it would for ssh; without
+// one, the SSH agent and then the default keys are tried. The connection
+// goes to the entry's HostName and Port, which go-git reads from the same
+// file.
 func (gs *GitSync) setupSSHAuthentication() error {
+	user, alias := sshUserHost(gs.remoteURL)
+
+	if host, ok := lookupSSHHost(alias); ok {
+		if user == "" {
+			user = host.User
+		}
+		for _, keyPath := range host.IdentityFiles {
+			if sshAuth, err := loadSSHKey(sshUserOrDefault(user), keyPath); err == nil {
+				gs.auth = sshAuth
+				return nil
+			}
+		}
+		if len(host.IdentityFiles) > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: no usable IdentityFile for %s in ~/.ssh/config, trying the default keys\n", alias)
+		}
+	}
+	user = sshUserOrDefault(user)
+
 	// Try to use SSH agent first
-	sshAuth, err := ssh.NewSSHAgentAuth("git")
+	sshAuth, err := ssh.NewSSHAgentAuth(user)
 	if err == nil {
 		gs.auth = sshAuth
 		return nil
@@ -449,30 +1545,110 @@ func (gs *GitSync) setupSSHAuthentication() error {
 	}
 
 	for _, keyPath := range keyPaths {
-		if _, err := os.Stat(keyPath); err == nil {
-			sshAuth, err := ssh.NewPublicKeysFromFile("git", keyPath, "")
-			if err == nil {
-				gs.auth = sshAuth
-				return nil
-			}
-			// If key requires passphrase, prompt for it
-			fmt.Printf("SSH key %s requires a passphrase: ", keyPath)
-			passphrase, err := term.ReadPassword(int(syscall.Stdin))
-			fmt.Println()
-			if err != nil {
-				continue
-			}
-			sshAuth, err = ssh.NewPublicKeysFromFile("git", keyPath, string(passphrase))
-			if err == nil {
-				gs.auth = sshAuth
-				return nil
-			}
+		if sshAuth, err := loadSSHKey(user, keyPath); err == nil {
+			gs.auth = sshAuth
+			return nil
 		}
 	}
 
 	return fmt.Errorf("no valid SSH authentication method found")
 }
 
+// loadSSHKey loads a private key file, prompting for its passphrase if it
+// has one
+func loadSSHKey(user, keyPath string) (*ssh.PublicKeys, error) {
+	if _, err := os.Stat(keyPath); err != nil {
+		return nil, err
+	}
+	sshAuth, err := ssh.NewPublicKeysFromFile(user, keyPath, "")
+	if err == nil {
+		return sshAuth, nil
+	}
+
+	// If key requires passphrase, prompt for it
+	fmt.Fprintf(os.Stderr, "SSH key %s requires a passphrase: ", keyPath)
+	passphrase, err := term.ReadPassword(int(syscall.Stdin))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewPublicKeysFromFile(user, keyPath, string(passphrase))
+}
+
+// sshHost is what ~/.ssh/config says about a host
+type sshHost struct {
+	HostName      string // Real host name, empty when not set
+	User          string
+	IdentityFiles []string // Key files with ~ expanded, in order
+}
+
+// lookupSSHHost reads the entry for a host or alias from ~/.ssh/config. It
+// reports false when the file is missing or nothing in it applies.
+func lookupSSHHost(alias string) (sshHost, bool) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil || alias == "" {
+		return sshHost{}, false
+	}
+	return readSSHHost(filepath.Join(homeDir, ".ssh", "config"), homeDir, alias)
+}
+
+// readSSHHost reads the entry for a host or alias from an ssh_config file,
+// expanding ~ and %d in IdentityFile to homeDir
+func readSSHHost(path, homeDir, alias string) (sshHost, bool) {
+	file, err := os.Open(path)
+	if err != nil {
+		return sshHost{}, false
+	}
+	defer file.Close()
+
+	sshConfig, err := ssh_config.Decode(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: ignoring %s: %v\n", path, err)
+		return sshHost{}, false
+	}
+
+	var host sshHost
+	host.HostName, _ = sshConfig.Get(alias, "HostName")
+	host.User, _ = sshConfig.Get(alias, "User")
+	identityFiles, _ := sshConfig.GetAll(alias, "IdentityFile")
+	for _, identityFile := range identityFiles {
+		switch {
+		case identityFile == "~":
+			identityFile = homeDir
+		case strings.HasPrefix(identityFile, "~/"):
+			identityFile = filepath.Join(homeDir, identityFile[2:])
+		}
+		host.IdentityFiles = append(host.IdentityFiles, strings.ReplaceAll(identityFile, "%d", homeDir))
+	}
+	return host, host.HostName != "" || host.User != "" || len(host.IdentityFiles) > 0
+}
+
+// sshUserHost returns the user, empty if none is given, and the host or
+// alias of an SSH remote URL, either ssh://user@host/path or user@host:path
+func sshUserHost(remoteURL string) (string, string) {
+	if strings.HasPrefix(remoteURL, "ssh://") {
+		parsedURL, err := url.Parse(remoteURL)
+		if err != nil {
+			return "", ""
+		}
+		return parsedURL.User.Username(), parsedURL.Hostname()
+	}
+
+	hostPart, _, _ := strings.Cut(remoteURL, ":")
+	if user, host, ok := strings.Cut(hostPart, "@"); ok {
+		return user, host
+	}
+	return "", hostPart
+}
+
+// sshUserOrDefault returns user, or git, which the big hosts expect
+func sshUserOrDefault(user string) string {
+	if user == "" {
+		return "git"
+	}
+	return user
+}
+
 // setupHTTPSAuthentication sets up HTTPS authentication
 func (gs *GitSync) setupHTTPSAuthentication() error {
 	// First, try to read from .netrc file
@@ -481,7 +1657,7 @@ func (gs *GitSync) setupHTTPSAuthentication() error {
 			Username: username,
 			Password: password,
 		}
-		fmt.Printf("Using credentials from .netrc file for authentication\n")
+		fmt.Fprintf(os.Stderr, "Using credentials from .netrc file for authentication\n")
 		return nil
 	}
 
@@ -506,14 +1682,24 @@ func (gs *GitSync) setupHTTPSAuthentication() error {
 		return nil
 	}
 
+	// Ask the user's configured git credential helper
+	if username, password, err := gs.readCredentialHelper(); err == nil && username != "" && password != "" {
+		gs.auth = &http.BasicAuth{
+			Username: username,
+			Password: password,
+		}
+		fmt.Fprintf(os.Stderr, "Using credentials from git credential helper for authentication\n")
+		return nil
+	}
+
 	// Prompt for credentials
-	fmt.Print("Git username: ")
+	fmt.Fprint(os.Stderr, "Git username: ")
 	var username string
 	fmt.Scanln(&username)
 
-	fmt.Print("Git password/token: ")
+	fmt.Fprint(os.Stderr, "Git password/token: ")
 	password, err := term.ReadPassword(int(syscall.Stdin))
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 	if err != nil {
 		return fmt.Errorf("failed to read password: %w", err)
 	}
@@ -559,6 +1745,65 @@ func (gs *GitSync) readNetrcCredentials() (string, string, error) {
 	return "", "", fmt.Errorf("no matching entry found in .netrc for %s", hostname)
 }
 
+// readCredentialHelper obtains credentials for the remote URL through
+// `git credential fill`, which consults whatever helper the user configured
+// (git-credential-manager, osxkeychain, libsecret, ...)
+func (gs *GitSync) readCredentialHelper() (string, string, error) {
+	if gs.remoteURL == "" {
+		return "", "", fmt.Errorf("no remote URL configured")
+	}
+
+	gitPath, err := exec.LookPath("git")
+	if err != nil {
+		return "", "", fmt.Errorf("git not found in PATH: %w", err)
+	}
+
+	parsedURL, err := url.Parse(gs.remoteURL)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to parse remote URL: %w", err)
+	}
+	if parsedURL.Host == "" {
+		return "", "", fmt.Errorf("could not extract hostname from URL: %s", gs.remoteURL)
+	}
+
+	var input strings.Builder
+	fmt.Fprintf(&input, "protocol=%s\n", parsedURL.Scheme)
+	fmt.Fprintf(&input, "host=%s\n", parsedURL.Host)
+	if path := strings.TrimPrefix(parsedURL.Path, "/"); path != "" {
+		fmt.Fprintf(&input, "path=%s\n", path)
+	}
+	input.WriteString("\n")
+
+	cmd := exec.Command(gitPath, "credential", "fill")
+	cmd.Stdin = strings.NewReader(input.String())
+	// Never let git fall back to its own terminal prompt; we prompt ourselves
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	output, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("git credential fill failed: %w", err)
+	}
+
+	var username, password string
+	scanner := bufio.NewScanner(strings.NewReader(string(output)))
+	for scanner.Scan() {
+		key, value, found := strings.Cut(scanner.Text(), "=")
+		if !found {
+			continue
+		}
+		switch key {
+		case "username":
+			username = value
+		case "password":
+			password = value
+		}
+	}
+
+	if username == "" || password == "" {
+		return "", "", fmt.Errorf("credential helper returned no credentials for %s", parsedURL.Host)
+	}
+	return username, password, nil
+}
+
 // parseNetrcFile parses the .netrc file and returns all entries
 func (gs *GitSync) parseNetrcFile() ([]NetrcEntry, error) {
 	homeDir, err := os.UserHomeDir()
@@ -647,6 +1892,97 @@ func (gs *GitSync) parseNetrcFile() ([]NetrcEntry, error) {
 	return entries, nil
 }
 
+// commitSigner returns the signer used for commits, or nil if signing is
+// not configured
+func (gs *GitSync) commitSigner() (gogit.Signer, error) {
+	if gs.signKeySpec == "" || gs.signer != nil {
+		return gs.signer, nil
+	}
+
+	keyData, err := os.ReadFile(gs.signKeySpec)
+	if os.IsNotExist(err) {
+		// Not a file, let gpg sign with the key from its keyring
+		if err := exec.Command("gpg", "--list-secret-keys", gs.signKeySpec).Run(); err != nil {
+			return nil, fmt.Errorf("no secret key for %s in gpg keyring", gs.signKeySpec)
+		}
+		gs.signer = gpgSigner{keyID: gs.signKeySpec}
+		return gs.signer, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyData))
+	if err != nil {
+		if entities, err = openpgp.ReadKeyRing(bytes.NewReader(keyData)); err != nil {
+			return nil, fmt.Errorf("failed to parse signing key: %w", err)
+		}
+	}
+
+	var entity *openpgp.Entity
+	for _, candidate := range entities {
+		if candidate.PrivateKey != nil {
+			entity = candidate
+			break
+		}
+	}
+	if entity == nil {
+		return nil, fmt.Errorf("%s does not contain a private key", gs.signKeySpec)
+	}
+
+	if entity.PrivateKey.Encrypted {
+		passphrase := os.Getenv("PASSWORD_STORE_GIT_SIGN_PASSPHRASE")
+		if passphrase == "" {
+			if !term.IsTerminal(int(syscall.Stdin)) {
+				return nil, fmt.Errorf("signing key is encrypted and no passphrase is available")
+			}
+			fmt.Fprint(os.Stderr, "Signing key passphrase: ")
+			input, err := term.ReadPassword(int(syscall.Stdin))
+			fmt.Fprintln(os.Stderr)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read passphrase: %w", err)
+			}
+			passphrase = string(input)
+		}
+		if err := entity.DecryptPrivateKeys([]byte(passphrase)); err != nil {
+			return nil, fmt.Errorf("failed to decrypt signing key: %w", err)
+		}
+	}
+
+	gs.signer = entitySigner{entity: entity}
+	return gs.signer, nil
+}
+
+// gpgSigner signs commits by running gpg, so gpg-agent and pinentry handle
+// the private key
+type gpgSigner struct {
+	keyID string
+}
+
+func (g gpgSigner) Sign(message io.Reader) ([]byte, error) {
+	cmd := exec.Command("gpg", "--detach-sign", "--armor", "--local-user", g.keyID)
+	cmd.Stdin = message
+	cmd.Stderr = os.Stderr
+	signature, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("gpg failed to sign: %w", err)
+	}
+	return signature, nil
+}
+
+// entitySigner signs commits with a key loaded from a key file
+type entitySigner struct {
+	entity *openpgp.Entity
+}
+
+func (e entitySigner) Sign(message io.Reader) ([]byte, error) {
+	var signature bytes.Buffer
+	if err := openpgp.ArmoredDetachSign(&signature, e.entity, message, nil); err != nil {
+		return nil, err
+	}
+	return signature.Bytes(), nil
+}
+
 // ensureGitignore creates or updates the .gitignore file to exclude config files
 func (gs *GitSync) ensureGitignore() error {
 	if gs.repository == nil {
@@ -654,20 +1990,6 @@ func (gs *GitSync) ensureGitignore() error {
 	}
 
 	gitignorePath := filepath.Join(gs.storeDir, ".gitignore")
-	gitignoreContent := `# Chowkidaar configuration and cache files
-.cache/
-.keyfile
-.git-config
-
-# System files
-.DS_Store
-*.tmp
-*.swp
-*~
-
-# Only backup encrypted password files (*.enc)
-# Everything else should be ignored by default
-`
 
 	// Write the .gitignore file
 	if err := os.WriteFile(gitignorePath, []byte(gitignoreContent), 0644); err != nil {
@@ -689,7 +2011,7 @@ func (gs *GitSync) removeTrackedConfigFiles() error {
 		return err
 	}
 
-	configFiles := []string{".cache", ".keyfile", ".git-config"}
+	configFiles := []string{".cache", ".keyfile", ".git-config", ".backups", ".history"}
 
 	for _, configFile := range configFiles {
 		configPath := filepath.Join(gs.storeDir, configFile)
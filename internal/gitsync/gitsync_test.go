@@ -0,0 +1,555 @@
+package gitsync
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	gogit "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// newMainRemote creates a bare repository whose default branch is main,
+// holding one commit with the given file
+func newMainRemote(t *testing.T, file string) string {
+	t.Helper()
+	root := t.TempDir()
+	main := plumbing.NewBranchReferenceName("main")
+
+	remoteDir := filepath.Join(root, "remote.git")
+	if _, err := gogit.PlainInitWithOptions(remoteDir, &gogit.PlainInitOptions{Bare: true, InitOptions: gogit.InitOptions{DefaultBranch: main}}); err != nil {
+		t.Fatal(err)
+	}
+
+	seedDir := filepath.Join(root, "seed")
+	seed, err := gogit.PlainInitWithOptions(seedDir, &gogit.PlainInitOptions{InitOptions: gogit.InitOptions{DefaultBranch: main}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(seedDir, file), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := seed.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := worktree.Add(file); err != nil {
+		t.Fatal(err)
+	}
+	author := &object.Signature{Name: "Test", Email: "test@example.com", When: time.Now()}
+	if _, err := worktree.Commit("Add "+file, &gogit.CommitOptions{Author: author}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := seed.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{remoteDir}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := seed.Push(&gogit.PushOptions{RefSpecs: []config.RefSpec{"refs/heads/main:refs/heads/main"}}); err != nil {
+		t.Fatal(err)
+	}
+	return remoteDir
+}
+
+// setGitIdentity gives commits made by the test an author
+func setGitIdentity(t *testing.T) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = Test\n\temail = test@example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSyncWithNonMasterBranch(t *testing.T) {
+	setGitIdentity(t)
+	remoteDir := newMainRemote(t, "first.enc")
+
+	storeDir := filepath.Join(t.TempDir(), "store")
+	gs := NewGitSync(storeDir, remoteDir)
+	if err := gs.InitializeWithRemote(); err != nil {
+		t.Fatalf("InitializeWithRemote: %v", err)
+	}
+
+	// Check the clone out on a local branch named master, with no upstream
+	// recorded, as in a store initialized before the remote existed
+	head, err := gs.repository.Head()
+	if err != nil {
+		t.Fatal(err)
+	}
+	master := plumbing.NewBranchReferenceName("master")
+	if err := gs.repository.Storer.SetReference(plumbing.NewHashReference(master, head.Hash())); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.repository.Storer.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, master)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.repository.DeleteBranch("main"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(storeDir, "second.enc"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.CommitAndPushChanges("Add second"); err != nil {
+		t.Fatalf("CommitAndPushChanges: %v", err)
+	}
+
+	remote, err := gogit.PlainOpen(remoteDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := remote.Reference(master, false); err == nil {
+		t.Error("push created a master branch on the remote instead of updating main")
+	}
+	remoteMain, err := remote.Reference(plumbing.NewBranchReferenceName("main"), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if localHead, _ := gs.HeadCommit(); remoteMain.Hash().String() != localHead {
+		t.Errorf("remote main = %s, want local head %s", remoteMain.Hash(), localHead)
+	}
+
+	if ahead, behind, err := gs.AheadBehind(); err != nil || ahead != 0 || behind != 0 {
+		t.Errorf("AheadBehind = %d, %d, %v, want 0, 0, nil", ahead, behind, err)
+	}
+
+	// A second device pulls the change from main
+	otherDir := filepath.Join(t.TempDir(), "other")
+	other := NewGitSync(otherDir, remoteDir)
+	if err := other.InitializeWithRemote(); err != nil {
+		t.Fatalf("InitializeWithRemote: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(otherDir, "third.enc"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.CommitAndPushChanges("Add third"); err != nil {
+		t.Fatalf("CommitAndPushChanges: %v", err)
+	}
+
+	if err := gs.Pull(); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "third.enc")); err != nil {
+		t.Errorf("pulled change missing: %v", err)
+	}
+}
+
+func TestPushAll(t *testing.T) {
+	setGitIdentity(t)
+	remoteDir := newMainRemote(t, "first.enc")
+
+	mirrorDir := filepath.Join(t.TempDir(), "mirror.git")
+	if _, err := gogit.PlainInit(mirrorDir, true); err != nil {
+		t.Fatal(err)
+	}
+	missingDir := filepath.Join(t.TempDir(), "missing.git")
+
+	storeDir := filepath.Join(t.TempDir(), "store")
+	gs := NewGitSync(storeDir, remoteDir)
+	gs.SetMirrors([]string{mirrorDir, missingDir})
+	if err := gs.InitializeWithRemote(); err != nil {
+		t.Fatalf("InitializeWithRemote: %v", err)
+	}
+
+	results, err := gs.PushAll()
+	if err != nil {
+		t.Fatalf("PushAll: %v", err)
+	}
+	if len(results) != 3 {
+		t.Fatalf("PushAll returned %d results, want 3", len(results))
+	}
+	for i, want := range []struct {
+		remote string
+		failed bool
+	}{{"origin", false}, {"mirror1", false}, {"mirror2", true}} {
+		if results[i].Remote != want.remote || (results[i].Err != nil) != want.failed {
+			t.Errorf("result %d = %s, %v, want %s failed=%v", i, results[i].Remote, results[i].Err, want.remote, want.failed)
+		}
+	}
+	if !errors.Is(results[2].Err, ErrRemote) {
+		t.Errorf("failed push = %v, want it to match ErrRemote", results[2].Err)
+	}
+
+	mirror, err := gogit.PlainOpen(mirrorDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mirrorMain, err := mirror.Reference(plumbing.NewBranchReferenceName("main"), false)
+	if err != nil {
+		t.Fatalf("mirror has no main branch: %v", err)
+	}
+	if head, _ := gs.HeadCommit(); mirrorMain.Hash().String() != head {
+		t.Errorf("mirror main = %s, want local head %s", mirrorMain.Hash(), head)
+	}
+
+	// Dropping a mirror removes its remote
+	gs.SetMirrors([]string{mirrorDir})
+	if _, err := gs.PushAll(); err != nil {
+		t.Fatalf("PushAll: %v", err)
+	}
+	if _, err := gs.repository.Remote("mirror2"); err == nil {
+		t.Error("remote mirror2 kept after it was dropped from the mirrors")
+	}
+}
+
+func TestReadSSHHost(t *testing.T) {
+	home := t.TempDir()
+	path := filepath.Join(home, "config")
+	sshConfig := `Host github-work
+  HostName github.com
+  User git
+  IdentityFile ~/.ssh/id_work
+  IdentityFile %d/.ssh/id_work_old
+
+Host gitea
+  HostName gitea.example.com
+`
+	if err := os.WriteFile(path, []byte(sshConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	host, ok := readSSHHost(path, home, "github-work")
+	want := []string{filepath.Join(home, ".ssh", "id_work"), filepath.Join(home, ".ssh", "id_work_old")}
+	if !ok || host.HostName != "github.com" || host.User != "git" || strings.Join(host.IdentityFiles, ",") != strings.Join(want, ",") {
+		t.Errorf("readSSHHost(github-work) = %+v, %v, want github.com as git with %v", host, ok, want)
+	}
+	if host, ok := readSSHHost(path, home, "gitea"); !ok || host.HostName != "gitea.example.com" || len(host.IdentityFiles) != 0 {
+		t.Errorf("readSSHHost(gitea) = %+v, %v, want gitea.example.com without keys", host, ok)
+	}
+	if host, ok := readSSHHost(path, home, "github.com"); ok {
+		t.Errorf("readSSHHost(github.com) = %+v, want no match", host)
+	}
+	if _, ok := readSSHHost(filepath.Join(home, "missing"), home, "github-work"); ok {
+		t.Error("readSSHHost of a missing file matched")
+	}
+
+	tests := []struct {
+		url, user, host string
+	}{
+		{url: "git@github-work:org/repo.git", user: "git", host: "github-work"},
+		{url: "ssh://deploy@gitea:2222/me/pass.git", user: "deploy", host: "gitea"},
+		{url: "ssh://gitea/me/pass.git", user: "", host: "gitea"},
+	}
+	for _, tt := range tests {
+		if user, host := sshUserHost(tt.url); user != tt.user || host != tt.host {
+			t.Errorf("sshUserHost(%q) = %q, %q, want %q, %q", tt.url, user, host, tt.user, tt.host)
+		}
+	}
+}
+
+func TestLogFilter(t *testing.T) {
+	storeDir := t.TempDir()
+	repo, err := gogit.PlainInit(storeDir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	worktree, err := repo.Worktree()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// One commit a day: gmail, bank, gmail again, then bank
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	files := []string{"Email/gmail.enc", "bank.enc", "Email/gmail.enc", "bank.enc"}
+	for i, file := range files {
+		path := filepath.Join(storeDir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte{byte(i)}, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := worktree.Add(file); err != nil {
+			t.Fatal(err)
+		}
+		when := start.AddDate(0, 0, i)
+		author := &object.Signature{Name: "Test", Email: "test@example.com", When: when}
+		if _, err := worktree.Commit(fmt.Sprintf("Commit %d", i), &gogit.CommitOptions{Author: author, Committer: author}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gs := NewGitSync(storeDir, "")
+	tests := []struct {
+		name   string
+		filter LogFilter
+		want   []string
+	}{
+		{name: "all", filter: LogFilter{}, want: []string{"Commit 3", "Commit 2", "Commit 1", "Commit 0"}},
+		{name: "path", filter: LogFilter{Path: "Email/gmail.enc"}, want: []string{"Commit 2", "Commit 0"}},
+		{name: "reverse", filter: LogFilter{Path: "bank.enc", Reverse: true}, want: []string{"Commit 1", "Commit 3"}},
+		{name: "limit", filter: LogFilter{Limit: 2, Reverse: true}, want: []string{"Commit 2", "Commit 3"}},
+		{name: "range", filter: LogFilter{Since: start.AddDate(0, 0, 1), Until: start.AddDate(0, 0, 2)}, want: []string{"Commit 2", "Commit 1"}},
+	}
+
+	for _, tt := range tests {
+		entries, err := gs.Log(tt.filter)
+		if err != nil {
+			t.Fatalf("%s: Log: %v", tt.name, err)
+		}
+		var got []string
+		for _, entry := range entries {
+			got = append(got, entry.Message)
+		}
+		if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+			t.Errorf("%s: Log = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPullVerify(t *testing.T) {
+	setGitIdentity(t)
+	remoteDir := newMainRemote(t, "first.enc")
+
+	storeDir := filepath.Join(t.TempDir(), "store")
+	gs := NewGitSync(storeDir, remoteDir)
+	if err := gs.InitializeWithRemote(); err != nil {
+		t.Fatalf("InitializeWithRemote: %v", err)
+	}
+
+	trusted, err := openpgp.NewEntity("Trusted", "", "trusted@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gs.SetVerifyKeys(openpgp.EntityList{trusted})
+
+	otherDir := filepath.Join(t.TempDir(), "other")
+	other := NewGitSync(otherDir, remoteDir)
+	if err := other.InitializeWithRemote(); err != nil {
+		t.Fatalf("InitializeWithRemote: %v", err)
+	}
+	pushFile := func(name string, signer gogit.Signer) {
+		t.Helper()
+		other.signer = signer
+		if err := os.WriteFile(filepath.Join(otherDir, name), []byte("secret"), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := other.CommitAndPushChanges("Add " + name); err != nil {
+			t.Fatalf("CommitAndPushChanges: %v", err)
+		}
+	}
+
+	pushFile("signed.enc", entitySigner{entity: trusted})
+	if err := gs.Pull(); err != nil {
+		t.Fatalf("Pull of a signed commit: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "signed.enc")); err != nil {
+		t.Errorf("signed change missing: %v", err)
+	}
+
+	untrusted, err := openpgp.NewEntity("Mallory", "", "mallory@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		file   string
+		signer gogit.Signer
+	}{
+		{file: "untrusted.enc", signer: entitySigner{entity: untrusted}},
+		{file: "unsigned.enc"},
+	} {
+		before, _ := gs.HeadCommit()
+		pushFile(tt.file, tt.signer)
+		if err := gs.Pull(); !errors.Is(err, ErrUntrustedCommit) {
+			t.Errorf("Pull of %s = %v, want ErrUntrustedCommit", tt.file, err)
+		}
+		if err := gs.VerifyCommits(openpgp.EntityList{trusted}); !errors.Is(err, ErrUntrustedCommit) {
+			t.Errorf("VerifyCommits after %s = %v, want ErrUntrustedCommit", tt.file, err)
+		}
+		if after, _ := gs.HeadCommit(); after != before {
+			t.Errorf("HEAD moved to %s after a rejected pull", after)
+		}
+		if _, err := os.Stat(filepath.Join(storeDir, tt.file)); !os.IsNotExist(err) {
+			t.Errorf("%s was merged", tt.file)
+		}
+	}
+}
+
+func TestCommitRefusesPlaintext(t *testing.T) {
+	setGitIdentity(t)
+	storeDir := t.TempDir()
+	if _, err := gogit.PlainInit(storeDir, false); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"bank.enc": "x", "Email/.desc": "Mail accounts\n", "bank.txt~": "hunter2", "notes.txt": "hunter2"} {
+		path := filepath.Join(storeDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// bank.txt~ is ignored by .gitignore; notes.txt is not
+	gs := NewGitSync(storeDir, "")
+	err := gs.Commit("Add passwords")
+	if !errors.Is(err, ErrPlaintext) || !strings.Contains(err.Error(), "notes.txt") || strings.Contains(err.Error(), "bank") {
+		t.Fatalf("Commit with a plaintext file = %v, want ErrPlaintext naming notes.txt only", err)
+	}
+	if _, err := gs.HeadCommit(); err == nil {
+		t.Error("a commit was created despite the plaintext file")
+	}
+
+	gs.SetAllowPlaintext(true)
+	if err := gs.Commit("Add passwords"); err != nil {
+		t.Fatalf("Commit with --allow-plaintext: %v", err)
+	}
+	status, err := gs.Status()
+	if err != nil || !status.IsClean() {
+		t.Errorf("status after commit = %v, %v, want clean", status, err)
+	}
+}
+
+func TestCommitAllowsTemplates(t *testing.T) {
+	setGitIdentity(t)
+	storeDir := t.TempDir()
+	if _, err := gogit.PlainInit(storeDir, false); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"bank.enc": "x", ".templates.json": `{"server": "user: \nhost: \n"}`} {
+		if err := os.WriteFile(filepath.Join(storeDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gs := NewGitSync(storeDir, "")
+	if err := gs.Commit("Add templates"); err != nil {
+		t.Fatalf("Commit with .templates.json: %v", err)
+	}
+	status, err := gs.Status()
+	if err != nil || !status.IsClean() {
+		t.Errorf("status after commit = %v, %v, want clean", status, err)
+	}
+}
+
+func TestChangesBetween(t *testing.T) {
+	setGitIdentity(t)
+	storeDir := t.TempDir()
+	if _, err := gogit.PlainInit(storeDir, false); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		path := filepath.Join(storeDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gs := NewGitSync(storeDir, "")
+	write("bank.enc", "x")
+	write("Email/gmail.enc", "x")
+	if err := gs.Commit("Add passwords"); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	before, err := gs.HeadCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// History versions are not entries
+	write("Email/gmail.enc", "y")
+	write(".history/Email/gmail/1.enc", "x")
+	write("Work/vpn.enc", "x")
+	if err := os.Remove(filepath.Join(storeDir, "bank.enc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := gs.Commit("Change passwords"); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	after, err := gs.HeadCommit()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes, err := gs.ChangesBetween(before, after)
+	if err != nil {
+		t.Fatalf("ChangesBetween: %v", err)
+	}
+	want := Changes{Added: []string{"Work/vpn"}, Modified: []string{"Email/gmail"}, Removed: []string{"bank"}}
+	if fmt.Sprint(changes) != fmt.Sprint(want) || changes.Count() != 3 {
+		t.Errorf("ChangesBetween = %+v, want %+v", changes, want)
+	}
+}
+
+func TestPullKeepsUntrackedFiles(t *testing.T) {
+	setGitIdentity(t)
+	remoteDir := newMainRemote(t, "first.enc")
+
+	storeDir := filepath.Join(t.TempDir(), "store")
+	gs := NewGitSync(storeDir, remoteDir)
+	if err := gs.InitializeWithRemote(); err != nil {
+		t.Fatalf("InitializeWithRemote: %v", err)
+	}
+	local := []string{".keyfile", ".git-config", ".cache/password.cache", "draft.txt"}
+	for _, name := range local {
+		path := filepath.Join(storeDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("local"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	otherDir := filepath.Join(t.TempDir(), "other")
+	other := NewGitSync(otherDir, remoteDir)
+	if err := other.InitializeWithRemote(); err != nil {
+		t.Fatalf("InitializeWithRemote: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(otherDir, "second.enc"), []byte("secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := other.CommitAndPushChanges("Add second.enc"); err != nil {
+		t.Fatalf("CommitAndPushChanges: %v", err)
+	}
+
+	if err := gs.Pull(); err != nil {
+		t.Fatalf("Pull: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(storeDir, "second.enc")); err != nil {
+		t.Errorf("pulled file missing: %v", err)
+	}
+	for _, name := range local {
+		if data, err := os.ReadFile(filepath.Join(storeDir, filepath.FromSlash(name))); err != nil || string(data) != "local" {
+			t.Errorf("%s after Pull = %q, %v, want it kept", name, data, err)
+		}
+	}
+}
+
+func TestLastSyncTime(t *testing.T) {
+	storeDir := t.TempDir()
+	gs := NewGitSync(storeDir, "")
+	if lastSync, err := gs.LastSyncTime(); err != nil || !lastSync.IsZero() {
+		t.Fatalf("LastSyncTime before any sync = %v, %v, want zero", lastSync, err)
+	}
+
+	// An entry written by the sync must not count as modified after it
+	entry := filepath.Join(storeDir, "gmail.enc")
+	if err := os.WriteFile(entry, []byte("x"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	gs.recordSync()
+	info, err := os.Stat(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lastSync, err := gs.LastSyncTime()
+	if err != nil {
+		t.Fatalf("LastSyncTime: %v", err)
+	}
+	if info.ModTime().After(lastSync) {
+		t.Errorf("entry written at %v is newer than the last sync at %v", info.ModTime(), lastSync)
+	}
+}
@@ -0,0 +1,118 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Supported hook events
+const (
+	PostInsert = "post_insert"
+	PostRemove = "post_remove"
+	PostSync   = "post_sync"
+)
+
+// DefaultTimeout is how long a hook may run before it is killed
+const DefaultTimeout = 30 * time.Second
+
+// Run executes the command configured for event, if any, passing args
+// (entry names, never secrets) as extra arguments. A failing hook only
+// prints a warning; it never fails the operation that triggered it.
+func Run(hooks map[string]string, event string, args ...string) {
+	command := strings.TrimSpace(hooks[event])
+	if command == "" {
+		return
+	}
+
+	if err := run(command, DefaultTimeout, args...); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s hook failed: %v\n", event, err)
+	}
+}
+
+// run executes a hook command with a timeout
+func run(command string, timeout time.Duration, args ...string) error {
+	fields, err := splitCommand(command)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, fields[0], append(fields[1:], args...)...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// splitCommand splits a hook command into words the way a shell does for a
+// simple command: single quotes keep everything literally, double quotes
+// keep everything but \" and \\, and a backslash elsewhere escapes the next
+// character. There is no expansion of variables, globs or ~.
+func splitCommand(command string) ([]string, error) {
+	var words []string
+	var word strings.Builder
+	inWord := false
+	var quote rune
+
+	runes := []rune(command)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+			} else {
+				word.WriteRune(r)
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+			} else if r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				word.WriteRune(runes[i])
+			} else {
+				word.WriteRune(r)
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == '\\':
+			if i+1 == len(runes) {
+				return nil, fmt.Errorf("hook command ends with a backslash")
+			}
+			i++
+			word.WriteRune(runes[i])
+			inWord = true
+		case unicode.IsSpace(r):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			word.WriteRune(r)
+			inWord = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("hook command has an unterminated %c quote", quote)
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	if len(words) == 0 {
+		return nil, fmt.Errorf("hook command is empty")
+	}
+	return words, nil
+}
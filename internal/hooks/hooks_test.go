@@ -0,0 +1,107 @@
+package hooks
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		want    []string
+	}{
+		{command: "notify-backup", want: []string{"notify-backup"}},
+		{command: "  git -C  dir push ", want: []string{"git", "-C", "dir", "push"}},
+		{command: `"/home/me/My Scripts/backup" --tag 'a b'`, want: []string{"/home/me/My Scripts/backup", "--tag", "a b"}},
+		{command: `/home/me/My\ Scripts/backup`, want: []string{"/home/me/My Scripts/backup"}},
+		{command: `echo "say \"hi\" \\ \n" 'it\s'`, want: []string{"echo", `say "hi" \ \n`, `it\s`}},
+		{command: `echo "" x''y`, want: []string{"echo", "", "xy"}},
+	}
+	for _, tt := range tests {
+		got, err := splitCommand(tt.command)
+		if err != nil || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitCommand(%q) = %q, %v, want %q", tt.command, got, err, tt.want)
+		}
+	}
+
+	for _, command := range []string{"", "   ", `echo "open`, "echo 'open", `echo \`} {
+		if got, err := splitCommand(command); err == nil {
+			t.Errorf("splitCommand(%q) = %q, want an error", command, got)
+		}
+	}
+}
+
+func TestRunPassesArguments(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+
+	dir := filepath.Join(t.TempDir(), "my hooks")
+	if err := os.Mkdir(dir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	argsFile := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "record args")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nfor arg in \"$@\"; do echo \"$arg\" >> \"$(dirname \"$0\")/args\"; done\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(`"`+script+`" 'first arg'`, time.Minute, "Email/gmail", "Work/vpn"); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "first arg\nEmail/gmail\nWork/vpn\n"; string(args) != want {
+		t.Errorf("hook was run with %q, want %q", args, want)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+
+	start := time.Now()
+	err := run("sleep 10", 100*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("run = %v, want a timeout", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("hook was killed after %s", elapsed)
+	}
+}
+
+func TestRunFailureOnlyWarns(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses false")
+	}
+
+	stderr := os.Stderr
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stderr = writer
+	defer func() { os.Stderr = stderr }()
+
+	Run(map[string]string{PostInsert: "false"}, PostInsert, "Email/gmail")
+	Run(map[string]string{PostInsert: "'unterminated"}, PostInsert, "Email/gmail")
+	Run(map[string]string{}, PostInsert, "Email/gmail")
+
+	writer.Close()
+	output, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(output), "Warning: post_insert hook failed"); got != 2 {
+		t.Errorf("got %d warnings, want 2:\n%s", got, output)
+	}
+}
@@ -1,12 +1,18 @@
 package list
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/term"
 )
 
 // ListOptions holds configuration for list display
@@ -17,13 +23,25 @@ type ListOptions struct {
 	ShowDetails  bool
 	MaxDepth     int
 	SearchFilter string
+	ShowAge      bool          // Show relative age of each password
+	DirsOnly     bool          // Only display directories (with password counts)
+	PathsOnly    bool          // Only print the full name of each password, quoted for the shell
+	OlderThan    time.Duration // Only show passwords last modified before this age (0 for all)
+	Since        time.Time     // Only show passwords modified after this time (zero for all)
+
+	// Descriptions maps folder paths relative to the store root, with
+	// slashes, to a description shown after the folder name (nil for none)
+	Descriptions map[string]string
 }
 
-// DefaultOptions returns sensible default list options
+// DefaultOptions returns sensible default list options.
+// Colors and icons are turned off when stdout is not a terminal,
+// and colors also honor the NO_COLOR convention (https://no-color.org).
 func DefaultOptions() *ListOptions {
+	isTTY := IsTerminal(os.Stdout)
 	return &ListOptions{
-		ShowIcons:   true,
-		ShowColors:  true,
+		ShowIcons:   isTTY,
+		ShowColors:  isTTY && os.Getenv("NO_COLOR") == "",
 		Flat:        false,
 		ShowDetails: false,
 		MaxDepth:    -1, // No limit
@@ -39,12 +57,16 @@ type Entry struct {
 	ModTime     time.Time
 	Children    []*Entry
 	Depth       int
+	Description string // Folder description, see ListOptions.Descriptions
+
+	agedBeyondDepth bool // A password below the depth limit passes the age filters
 }
 
 // ListBuilder builds and displays password store listings
 type ListBuilder struct {
 	baseDir string
 	options *ListOptions
+	files   map[string]string // Entry name -> file path, for stores that hide names
 }
 
 // NewListBuilder creates a new list builder
@@ -58,22 +80,24 @@ func NewListBuilder(baseDir string, options *ListOptions) *ListBuilder {
 	}
 }
 
+// NewIndexedListBuilder creates a list builder for a store that hides its
+// entry names. files maps each entry name to its file relative to baseDir.
+func NewIndexedListBuilder(baseDir string, files map[string]string, options *ListOptions) *ListBuilder {
+	lb := NewListBuilder(baseDir, options)
+	lb.files = files
+	return lb
+}
+
 // Generate creates the entry tree and displays it
 func (lb *ListBuilder) Generate(subfolder string) error {
-	searchDir := lb.baseDir
-	if subfolder != "" {
-		searchDir = filepath.Join(lb.baseDir, subfolder)
-	}
-
-	// Check if directory exists
-	if _, err := os.Stat(searchDir); os.IsNotExist(err) {
-		return fmt.Errorf("directory does not exist: %s", searchDir)
+	if lb.options.PathsOnly {
+		return lb.WritePaths(subfolder, os.Stdout)
 	}
 
 	// Build entry tree
-	root, err := lb.buildTree(searchDir, "", 0)
+	root, err := lb.loadTree(subfolder)
 	if err != nil {
-		return fmt.Errorf("failed to build directory tree: %w", err)
+		return err
 	}
 
 	// Check if we have any entries
@@ -107,11 +131,111 @@ func (lb *ListBuilder) Generate(subfolder string) error {
 
 	// Display the tree
 	if lb.options.Flat {
-		return lb.displayFlat(root)
+		return lb.displayFlat(root, os.Stdout)
 	}
 	return lb.displayTree(root)
 }
 
+// EntryNames returns the names of all passwords under subfolder (relative to the
+// store root, without the .enc extension), honoring filter and depth options
+func (lb *ListBuilder) EntryNames(subfolder string) ([]string, error) {
+	root, err := lb.loadTree(subfolder)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	lb.collectAllEntries(root, &entries)
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.IsDirectory {
+			name := trimExtension(filepath.ToSlash(filepath.Join(subfolder, entry.Path)))
+			names = append(names, name)
+		}
+	}
+	return names, nil
+}
+
+// WritePaths writes the full name of every password under subfolder, one
+// per line and sorted, for PathsOnly. Names that the shell or xargs would
+// split or interpret are quoted with ShellQuote, so the output can be fed
+// back to chowkidaar. Nothing is written when there are no passwords.
+func (lb *ListBuilder) WritePaths(subfolder string, w io.Writer) error {
+	names, err := lb.EntryNames(subfolder)
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	out := bufio.NewWriter(w)
+	for _, name := range names {
+		fmt.Fprintln(out, ShellQuote(name))
+	}
+	return out.Flush()
+}
+
+// ShellQuote returns name unchanged when it only has characters that need
+// no quoting, and otherwise in single quotes, which both sh and xargs read
+// back as the original name
+func ShellQuote(name string) string {
+	safe := name != ""
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-./@%+=:,", r)) {
+			safe = false
+			break
+		}
+	}
+	if safe {
+		return name
+	}
+	return "'" + strings.ReplaceAll(name, "'", `'\''`) + "'"
+}
+
+// Count returns the number of passwords and folders under subfolder,
+// honoring filter and depth options
+func (lb *ListBuilder) Count(subfolder string) (int, int, error) {
+	root, err := lb.loadTree(subfolder)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var entries []*Entry
+	lb.collectAllEntries(root, &entries)
+
+	passwords, folders := 0, 0
+	for _, entry := range entries {
+		if entry.IsDirectory {
+			folders++
+		} else {
+			passwords++
+		}
+	}
+	return passwords, folders, nil
+}
+
+// loadTree builds the entry tree rooted at subfolder
+func (lb *ListBuilder) loadTree(subfolder string) (*Entry, error) {
+	if lb.files != nil {
+		return lb.buildIndexedTree(subfolder)
+	}
+
+	searchDir := lb.baseDir
+	if subfolder != "" {
+		searchDir = filepath.Join(lb.baseDir, subfolder)
+	}
+
+	if _, err := os.Stat(searchDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("directory does not exist: %s", searchDir)
+	}
+
+	root, err := lb.buildTree(searchDir, "", 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build directory tree: %w", err)
+	}
+	return root, nil
+}
+
 // buildTree recursively builds the entry tree
 func (lb *ListBuilder) buildTree(dir, relativePath string, depth int) (*Entry, error) {
 	info, err := os.Stat(dir)
@@ -128,8 +252,17 @@ func (lb *ListBuilder) buildTree(dir, relativePath string, depth int) (*Entry, e
 		Depth:       depth,
 	}
 
+	if info.IsDir() && lb.options.Descriptions != nil {
+		if rel, err := filepath.Rel(lb.baseDir, dir); err == nil {
+			entry.Description = lb.options.Descriptions[filepath.ToSlash(rel)]
+		}
+	}
+
 	// Stop if we've reached max depth
 	if lb.options.MaxDepth >= 0 && depth >= lb.options.MaxDepth {
+		if info.IsDir() && lb.filtersAge() {
+			entry.agedBeyondDepth = lb.holdsAgedPassword(dir)
+		}
 		return entry, nil
 	}
 
@@ -149,7 +282,7 @@ func (lb *ListBuilder) buildTree(dir, relativePath string, depth int) (*Entry, e
 
 		for _, childEntry := range entries {
 			// Skip hidden files and directories starting with .
-			if strings.HasPrefix(childEntry.Name(), ".") {
+			if strings.HasPrefix(childEntry.Name(), ".") || shadowedGPG(dir, childEntry.Name()) {
 				continue
 			}
 
@@ -162,19 +295,127 @@ func (lb *ListBuilder) buildTree(dir, relativePath string, depth int) (*Entry, e
 			}
 
 			// Apply search filter if specified
-			if lb.options.SearchFilter != "" {
-				if lb.matchesFilter(child) {
-					entry.Children = append(entry.Children, child)
-				}
-			} else {
-				entry.Children = append(entry.Children, child)
+			if lb.options.SearchFilter != "" && !lb.matchesFilter(child) {
+				continue
+			}
+
+			// Apply age filters if specified
+			if lb.filtersAge() && !lb.matchesAge(child) {
+				continue
 			}
+
+			entry.Children = append(entry.Children, child)
 		}
 	}
 
 	return entry, nil
 }
 
+// buildIndexedTree builds the entry tree from the name index of a store
+// that hides its structure. Folders exist only in the index.
+func (lb *ListBuilder) buildIndexedTree(subfolder string) (*Entry, error) {
+	prefix := strings.Trim(filepath.ToSlash(subfolder), "/")
+
+	root := &Entry{Name: filepath.Base(lb.baseDir), IsDirectory: true}
+	if prefix != "" {
+		root.Name = path.Base(prefix)
+	}
+
+	found := prefix == ""
+	for name, file := range lb.files {
+		rel := name
+		if prefix != "" {
+			if !strings.HasPrefix(name, prefix+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(name, prefix+"/")
+		}
+		found = true
+
+		parts := strings.Split(rel, "/")
+		parent := root
+		for i := range parts[:len(parts)-1] {
+			parent = indexedDir(parent, parts[:i+1])
+		}
+
+		leaf := &Entry{
+			Name:  parts[len(parts)-1] + ".enc",
+			Path:  filepath.FromSlash(rel) + ".enc",
+			Depth: len(parts),
+		}
+		if info, err := os.Stat(filepath.Join(lb.baseDir, file)); err == nil {
+			leaf.Size = info.Size()
+			leaf.ModTime = info.ModTime()
+		}
+		parent.Children = append(parent.Children, leaf)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("directory does not exist: %s", filepath.Join(lb.baseDir, subfolder))
+	}
+
+	lb.finishIndexedTree(root)
+	return root, nil
+}
+
+// indexedDir returns the folder entry for parts below parent, creating it if needed
+func indexedDir(parent *Entry, parts []string) *Entry {
+	name := parts[len(parts)-1]
+	for _, child := range parent.Children {
+		if child.IsDirectory && child.Name == name {
+			return child
+		}
+	}
+
+	dir := &Entry{
+		Name:        name,
+		Path:        filepath.Join(parts...),
+		IsDirectory: true,
+		Depth:       len(parts),
+	}
+	parent.Children = append(parent.Children, dir)
+	return dir
+}
+
+// finishIndexedTree applies the depth limit, filters and ordering that
+// buildTree applies while walking the filesystem
+func (lb *ListBuilder) finishIndexedTree(entry *Entry) {
+	if lb.options.MaxDepth >= 0 && entry.Depth >= lb.options.MaxDepth {
+		if entry.IsDirectory && lb.filtersAge() {
+			entry.agedBeyondDepth = lb.anyMatchesAge(entry.Children)
+		}
+		entry.Children = nil
+		return
+	}
+
+	var children []*Entry
+	for _, child := range entry.Children {
+		lb.finishIndexedTree(child)
+
+		// Folders have no file of their own, so use their newest entry
+		if child.ModTime.After(entry.ModTime) {
+			entry.ModTime = child.ModTime
+		}
+
+		if lb.options.SearchFilter != "" && !lb.matchesFilter(child) {
+			continue
+		}
+		if lb.filtersAge() && !lb.matchesAge(child) {
+			continue
+		}
+		children = append(children, child)
+	}
+
+	// Sort entries: directories first, then files, both alphabetically
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].IsDirectory != children[j].IsDirectory {
+			return children[i].IsDirectory
+		}
+		return children[i].Name < children[j].Name
+	})
+	entry.Children = children
+}
+
 // matchesFilter checks if an entry matches the search filter
 func (lb *ListBuilder) matchesFilter(entry *Entry) bool {
 	filter := strings.ToLower(lb.options.SearchFilter)
@@ -194,12 +435,72 @@ func (lb *ListBuilder) matchesFilter(entry *Entry) bool {
 	return false
 }
 
+// filtersAge reports whether OlderThan or Since is set
+func (lb *ListBuilder) filtersAge() bool {
+	return lb.options.OlderThan > 0 || !lb.options.Since.IsZero()
+}
+
+// matchesAge checks if an entry passes the OlderThan and Since filters.
+// Only passwords are age-filtered; directories match if any child does,
+// including those beyond the depth limit that are not listed.
+func (lb *ListBuilder) matchesAge(entry *Entry) bool {
+	if !entry.IsDirectory {
+		if lb.options.OlderThan > 0 && time.Since(entry.ModTime) < lb.options.OlderThan {
+			return false
+		}
+		return lb.options.Since.IsZero() || entry.ModTime.After(lb.options.Since)
+	}
+
+	return len(entry.Children) > 0 || entry.agedBeyondDepth
+}
+
+// anyMatchesAge reports whether any password among entries or below them
+// passes the age filters
+func (lb *ListBuilder) anyMatchesAge(entries []*Entry) bool {
+	for _, entry := range entries {
+		if entry.IsDirectory {
+			if lb.anyMatchesAge(entry.Children) {
+				return true
+			}
+		} else if lb.matchesAge(entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// holdsAgedPassword reports whether any password below dir passes the age
+// filters, for a folder at the depth limit whose children are not read
+func (lb *ListBuilder) holdsAgedPassword(dir string) bool {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return false
+	}
+	for _, childEntry := range entries {
+		if strings.HasPrefix(childEntry.Name(), ".") || shadowedGPG(dir, childEntry.Name()) {
+			continue
+		}
+		childPath := filepath.Join(dir, childEntry.Name())
+		if childEntry.IsDir() {
+			if lb.holdsAgedPassword(childPath) {
+				return true
+			}
+			continue
+		}
+		if info, err := childEntry.Info(); err == nil && lb.matchesAge(&Entry{ModTime: info.ModTime()}) {
+			return true
+		}
+	}
+	return false
+}
+
 // displayTree displays entries in tree format
 func (lb *ListBuilder) displayTree(root *Entry) error {
 	if root.Depth == 0 {
 		// Don't show root directory itself, just its children
-		for i, child := range root.Children {
-			isLast := i == len(root.Children)-1
+		children := lb.visibleChildren(root)
+		for i, child := range children {
+			isLast := i == len(children)-1
 			lb.printEntryWithLast(child, "", isLast)
 		}
 	} else {
@@ -209,35 +510,161 @@ func (lb *ListBuilder) displayTree(root *Entry) error {
 }
 
 // displayFlat displays entries in flat list format
-func (lb *ListBuilder) displayFlat(root *Entry) error {
+func (lb *ListBuilder) displayFlat(root *Entry, w io.Writer) error {
 	var entries []*Entry
 	lb.collectAllEntries(root, &entries)
 
 	if len(entries) == 0 {
-		fmt.Println("No passwords found.")
+		fmt.Fprintln(w, "No passwords found.")
 		return nil
 	}
 
-	// Print header if showing details
-	if lb.options.ShowDetails {
-		fmt.Printf("%-40s %10s %s\n", "Name", "Modified", "Path")
-		fmt.Println(strings.Repeat("─", 70))
+	lb.printFlatHeader(w)
+
+	if lb.options.DirsOnly {
+		for _, entry := range entries {
+			if entry.IsDirectory {
+				// Show the full path since there is no tree to give context
+				dir := *entry
+				dir.Name = filepath.ToSlash(entry.Path)
+				fmt.Fprintf(w, "%s%s %s\n", lb.formatEntryName(&dir), lb.formatDescription(entry), lb.formatCount(entry))
+			}
+		}
+		return nil
 	}
 
 	for _, entry := range entries {
 		if !entry.IsDirectory {
-			if lb.options.ShowDetails {
-				modTime := entry.ModTime.Format("2006-01-02")
-				name := strings.TrimSuffix(entry.Name, ".enc")
-				fmt.Printf("%-40s %10s %s\n", name, modTime, entry.Path)
-			} else {
-				fmt.Println(lb.formatEntryName(entry))
-			}
+			lb.printFlatEntry(w, entry)
 		}
 	}
 	return nil
 }
 
+// printFlatHeader prints the column header of a flat listing with details
+func (lb *ListBuilder) printFlatHeader(w io.Writer) {
+	if !lb.options.ShowDetails {
+		return
+	}
+	if lb.options.ShowAge {
+		fmt.Fprintf(w, "%-40s %10s %6s %s\n", "Name", "Modified", "Age", "Path")
+		fmt.Fprintln(w, strings.Repeat("─", 77))
+	} else {
+		fmt.Fprintf(w, "%-40s %10s %s\n", "Name", "Modified", "Path")
+		fmt.Fprintln(w, strings.Repeat("─", 70))
+	}
+}
+
+// printFlatEntry prints one password of a flat listing
+func (lb *ListBuilder) printFlatEntry(w io.Writer, entry *Entry) {
+	if lb.options.ShowDetails {
+		modTime := entry.ModTime.Format("2006-01-02")
+		name := trimExtension(entry.Name)
+		if lb.options.ShowAge {
+			fmt.Fprintf(w, "%-40s %10s %6s %s\n", name, modTime, FormatAge(entry.ModTime), entry.Path)
+		} else {
+			fmt.Fprintf(w, "%-40s %10s %s\n", name, modTime, entry.Path)
+		}
+	} else if lb.options.ShowAge {
+		fmt.Fprintf(w, "%s %s\n", lb.formatEntryName(entry), lb.formatAge(entry))
+	} else {
+		fmt.Fprintln(w, lb.formatEntryName(entry))
+	}
+}
+
+// StreamFlat prints the flat listing of subfolder to w while walking the
+// store, instead of building the whole entry tree first, so large stores
+// start printing at once and only one folder is held in memory at a time.
+// Passwords come out in the same order, with the same filter, age and depth
+// options, as a flat Generate. Folder listings (DirsOnly) need the counts of
+// whole subtrees and stores with hidden names are listed from their index,
+// so both fall back to building the tree.
+func (lb *ListBuilder) StreamFlat(subfolder string, w io.Writer) error {
+	if lb.files != nil || lb.options.DirsOnly {
+		root, err := lb.loadTree(subfolder)
+		if err != nil {
+			return err
+		}
+		return lb.displayFlat(root, w)
+	}
+
+	searchDir := lb.baseDir
+	if subfolder != "" {
+		searchDir = filepath.Join(lb.baseDir, subfolder)
+	}
+	if _, err := os.Stat(searchDir); os.IsNotExist(err) {
+		return fmt.Errorf("directory does not exist: %s", searchDir)
+	}
+
+	out := bufio.NewWriter(w)
+	printed := 0
+	lb.streamDir(searchDir, "", 0, func(entry *Entry) {
+		if printed == 0 {
+			lb.printFlatHeader(out)
+		}
+		lb.printFlatEntry(out, entry)
+		printed++
+	})
+	if printed == 0 {
+		fmt.Fprintln(out, "No passwords found.")
+	}
+	return out.Flush()
+}
+
+// streamDir walks dir in the order of buildTree, directories first and then
+// files, and passes every password that buildTree would keep to emit. A
+// password is kept when its own name matches the search filter, as in a
+// flat Generate, where a matching folder only keeps matching passwords.
+func (lb *ListBuilder) streamDir(dir, relativePath string, depth int, emit func(*Entry)) {
+	if lb.options.MaxDepth >= 0 && depth >= lb.options.MaxDepth {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return // Don't fail completely, just skip this directory
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].IsDir() != entries[j].IsDir() {
+			return entries[i].IsDir()
+		}
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	for _, childEntry := range entries {
+		// Skip hidden files and directories starting with .
+		if strings.HasPrefix(childEntry.Name(), ".") || shadowedGPG(dir, childEntry.Name()) {
+			continue
+		}
+
+		childPath := filepath.Join(dir, childEntry.Name())
+		childRelativePath := filepath.Join(relativePath, childEntry.Name())
+		info, err := os.Stat(childPath)
+		if err != nil {
+			continue // Skip problematic entries
+		}
+		if info.IsDir() {
+			lb.streamDir(childPath, childRelativePath, depth+1, emit)
+			continue
+		}
+
+		entry := &Entry{
+			Name:    childEntry.Name(),
+			Path:    childRelativePath,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+			Depth:   depth + 1,
+		}
+		if lb.options.SearchFilter != "" && !lb.matchesFilter(entry) {
+			continue
+		}
+		if lb.filtersAge() && !lb.matchesAge(entry) {
+			continue
+		}
+		emit(entry)
+	}
+}
+
 // collectAllEntries recursively collects all entries for flat display
 func (lb *ListBuilder) collectAllEntries(entry *Entry, entries *[]*Entry) {
 	if entry.Depth > 0 { // Skip root
@@ -256,9 +683,10 @@ func (lb *ListBuilder) printEntryWithLast(entry *Entry, prefix string, isLast bo
 	fmt.Println(line)
 
 	// Print children if it's a directory
-	if entry.IsDirectory && len(entry.Children) > 0 {
-		for i, child := range entry.Children {
-			childIsLast := i == len(entry.Children)-1
+	children := lb.visibleChildren(entry)
+	if entry.IsDirectory && len(children) > 0 {
+		for i, child := range children {
+			childIsLast := i == len(children)-1
 			var newPrefix string
 
 			if isLast {
@@ -290,6 +718,7 @@ func (lb *ListBuilder) formatTreeLine(entry *Entry, prefix string, isLast bool)
 
 	// Add icon and name
 	line.WriteString(lb.formatEntryName(entry))
+	line.WriteString(lb.formatDescription(entry))
 
 	// Add details if requested
 	if lb.options.ShowDetails && !entry.IsDirectory {
@@ -301,9 +730,77 @@ func (lb *ListBuilder) formatTreeLine(entry *Entry, prefix string, isLast bool)
 		}
 	}
 
+	// Add relative age if requested
+	if lb.options.ShowAge && !entry.IsDirectory {
+		line.WriteString(" " + lb.formatAge(entry))
+	}
+
+	// Directories-only view summarizes what each folder holds
+	if lb.options.DirsOnly && entry.IsDirectory {
+		line.WriteString(" " + lb.formatCount(entry))
+	}
+
 	return line.String()
 }
 
+// visibleChildren returns the children of an entry that should be displayed
+func (lb *ListBuilder) visibleChildren(entry *Entry) []*Entry {
+	if !lb.options.DirsOnly {
+		return entry.Children
+	}
+
+	var dirs []*Entry
+	for _, child := range entry.Children {
+		if child.IsDirectory {
+			dirs = append(dirs, child)
+		}
+	}
+	return dirs
+}
+
+// countPasswords counts the passwords below an entry
+func countPasswords(entry *Entry) int {
+	if !entry.IsDirectory {
+		return 1
+	}
+
+	count := 0
+	for _, child := range entry.Children {
+		count += countPasswords(child)
+	}
+	return count
+}
+
+// formatCount formats the number of passwords inside a directory
+func (lb *ListBuilder) formatCount(entry *Entry) string {
+	count := fmt.Sprintf("(%d)", countPasswords(entry))
+	if lb.options.ShowColors {
+		return fmt.Sprintf("\033[90m%s\033[0m", count)
+	}
+	return count
+}
+
+// formatDescription formats a folder's description, with a leading space,
+// or returns an empty string if it has none
+func (lb *ListBuilder) formatDescription(entry *Entry) string {
+	if entry.Description == "" {
+		return ""
+	}
+	if lb.options.ShowColors {
+		return fmt.Sprintf(" \033[90m(%s)\033[0m", entry.Description)
+	}
+	return fmt.Sprintf(" (%s)", entry.Description)
+}
+
+// formatAge formats the relative age of an entry for display
+func (lb *ListBuilder) formatAge(entry *Entry) string {
+	age := FormatAge(entry.ModTime)
+	if lb.options.ShowColors {
+		return fmt.Sprintf("\033[90m[%s]\033[0m", age)
+	}
+	return fmt.Sprintf("[%s]", age)
+}
+
 // formatEntryName formats the entry name with icons and colors
 func (lb *ListBuilder) formatEntryName(entry *Entry) string {
 	var name strings.Builder
@@ -311,7 +808,7 @@ func (lb *ListBuilder) formatEntryName(entry *Entry) string {
 	// Add icon
 	if lb.options.ShowIcons {
 		if entry.IsDirectory {
-			if len(entry.Children) > 0 {
+			if len(lb.visibleChildren(entry)) > 0 {
 				name.WriteString("📂 ") // Open folder icon
 			} else {
 				name.WriteString("📁 ") // Closed folder icon
@@ -328,10 +825,10 @@ func (lb *ListBuilder) formatEntryName(entry *Entry) string {
 		}
 	}
 
-	// Clean up name (remove .enc extension)
+	// Clean up name (remove .enc or .gpg extension)
 	displayName := entry.Name
 	if !entry.IsDirectory {
-		displayName = strings.TrimSuffix(displayName, ".enc")
+		displayName = trimExtension(displayName)
 	}
 
 	// Add color coding
@@ -348,8 +845,102 @@ func (lb *ListBuilder) formatEntryName(entry *Entry) string {
 	return name.String()
 }
 
+// trimExtension removes the .enc extension of an entry file, or the .gpg
+// extension of one left by pass in a store being migrated
+func trimExtension(name string) string {
+	if strings.HasSuffix(name, ".gpg") {
+		return strings.TrimSuffix(name, ".gpg")
+	}
+	return strings.TrimSuffix(name, ".enc")
+}
+
+// shadowedGPG reports whether a file in dir is a .gpg entry that has been
+// migrated: the .enc file next to it is the one that is read
+func shadowedGPG(dir, name string) bool {
+	if !strings.HasSuffix(name, ".gpg") {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(dir, strings.TrimSuffix(name, ".gpg")+".enc"))
+	return err == nil
+}
+
 // GenerateWithOptions provides a convenient way to generate listings with custom options
 func GenerateWithOptions(baseDir, subfolder string, options *ListOptions) error {
 	builder := NewListBuilder(baseDir, options)
 	return builder.Generate(subfolder)
 }
+
+// FormatAge returns a short relative age such as "3d" or "2mo"
+func FormatAge(t time.Time) string {
+	age := time.Since(t)
+	switch {
+	case age < time.Hour:
+		return fmt.Sprintf("%dm", int(age.Minutes()))
+	case age < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(age.Hours()))
+	case age < 30*24*time.Hour:
+		return fmt.Sprintf("%dd", int(age.Hours()/24))
+	case age < 365*24*time.Hour:
+		return fmt.Sprintf("%dmo", int(age.Hours()/(24*30)))
+	default:
+		return fmt.Sprintf("%dy", int(age.Hours()/(24*365)))
+	}
+}
+
+// ParseAge parses a human duration such as "90d", "2w", "6mo" or "1y".
+// Standard Go durations like "36h" are accepted as well.
+func ParseAge(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	units := []struct {
+		suffix string
+		unit   time.Duration
+	}{
+		{"mo", 30 * 24 * time.Hour},
+		{"y", 365 * 24 * time.Hour},
+		{"w", 7 * 24 * time.Hour},
+		{"d", 24 * time.Hour},
+	}
+
+	for _, u := range units {
+		if strings.HasSuffix(s, u.suffix) {
+			n, err := strconv.Atoi(strings.TrimSuffix(s, u.suffix))
+			if err != nil || n < 0 {
+				return 0, fmt.Errorf("invalid age: %s", s)
+			}
+			return time.Duration(n) * u.unit, nil
+		}
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid age: %s (use e.g. 90d, 2w, 6mo, 1y)", s)
+	}
+	return d, nil
+}
+
+// ParseSince parses a point in time: "2024-05-01", "2024-05-01 14:30", an
+// RFC 3339 timestamp, or an age such as "7d" meaning that long before now.
+// Dates without a zone are local time.
+func ParseSince(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	if age, err := ParseAge(s); err == nil {
+		return now.Add(-age), nil
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time: %s (use e.g. 2024-05-01, \"2024-05-01 14:30\" or 7d)", s)
+}
+
+// IsTerminal reports whether the file is an interactive terminal
+func IsTerminal(f *os.File) bool {
+	return term.IsTerminal(int(f.Fd()))
+}
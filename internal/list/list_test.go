@@ -0,0 +1,159 @@
+package list
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{in: "2024-05-01", want: time.Date(2024, 5, 1, 0, 0, 0, 0, time.Local)},
+		{in: "2024-05-01 14:30", want: time.Date(2024, 5, 1, 14, 30, 0, 0, time.Local)},
+		{in: "2024-05-01T14:30:00Z", want: time.Date(2024, 5, 1, 14, 30, 0, 0, time.UTC)},
+		{in: "7d", want: now.AddDate(0, 0, -7)},
+		{in: "2w", want: now.AddDate(0, 0, -14)},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseSince(tt.in, now)
+		if err != nil || !got.Equal(tt.want) {
+			t.Errorf("ParseSince(%q) = %v, %v, want %v", tt.in, got, err, tt.want)
+		}
+	}
+
+	if _, err := ParseSince("yesterday", now); err == nil {
+		t.Error("ParseSince(\"yesterday\") should fail")
+	}
+}
+
+func TestStreamFlat(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().AddDate(0, 0, -90)
+	for _, name := range []string{"bank.enc", "Email/gmail.enc", "Email/work.enc", "Email/Old/yahoo.enc", "Work/vpn.enc", ".git/config"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("secret"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Chtimes(filepath.Join(dir, "Email", "work.enc"), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		options ListOptions
+	}{
+		{name: "all", options: ListOptions{MaxDepth: -1}},
+		{name: "filter", options: ListOptions{MaxDepth: -1, SearchFilter: "mail"}},
+		{name: "depth", options: ListOptions{MaxDepth: 2}},
+		{name: "older than", options: ListOptions{MaxDepth: -1, OlderThan: 30 * 24 * time.Hour}},
+		{name: "details", options: ListOptions{MaxDepth: -1, ShowDetails: true}},
+		{name: "no match", options: ListOptions{MaxDepth: -1, SearchFilter: "nothing"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.options.Flat = true
+			lb := NewListBuilder(dir, &tt.options)
+
+			// Streaming must print exactly what the tree-based listing does
+			root, err := lb.loadTree("")
+			if err != nil {
+				t.Fatal(err)
+			}
+			var want, got bytes.Buffer
+			if err := lb.displayFlat(root, &want); err != nil {
+				t.Fatal(err)
+			}
+			if err := lb.StreamFlat("", &got); err != nil {
+				t.Fatalf("StreamFlat: %v", err)
+			}
+			if got.String() != want.String() {
+				t.Errorf("StreamFlat printed\n%s\nwant\n%s", got.String(), want.String())
+			}
+		})
+	}
+
+	if err := NewListBuilder(dir, &ListOptions{Flat: true, MaxDepth: -1}).StreamFlat("Missing", &bytes.Buffer{}); err == nil {
+		t.Error("StreamFlat of a missing folder succeeded")
+	}
+}
+
+func TestAgeFilterAtDepthLimit(t *testing.T) {
+	dir := t.TempDir()
+	old := time.Now().AddDate(0, 0, -90)
+	files := map[string]string{}
+	for _, name := range []string{"Email/Old/yahoo", "Email/gmail", "Work/vpn"} {
+		file := filepath.FromSlash(name) + ".enc"
+		files[name] = file
+		path := filepath.Join(dir, file)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("secret"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	yahoo := filepath.Join(dir, "Email", "Old", "yahoo.enc")
+	if err := os.Chtimes(yahoo, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	// Email only holds an old password beyond the depth limit, Work none
+	options := ListOptions{MaxDepth: 1, OlderThan: 30 * 24 * time.Hour}
+	for name, lb := range map[string]*ListBuilder{
+		"filesystem": NewListBuilder(dir, &options),
+		"indexed":    NewIndexedListBuilder(dir, files, &options),
+	} {
+		root, err := lb.loadTree("")
+		if err != nil {
+			t.Fatalf("%s: loadTree: %v", name, err)
+		}
+		var got []string
+		for _, child := range root.Children {
+			got = append(got, child.Name)
+		}
+		if len(got) != 1 || got[0] != "Email" {
+			t.Errorf("%s: folders at the depth limit = %v, want [Email]", name, got)
+		}
+	}
+}
+
+func TestWritePaths(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"bank.enc", "Email/gmail.enc", "Email/my work.enc", "Email/it's.enc", "Work/vpn.enc", ".git/config"} {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("secret"), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+	lb := NewListBuilder(dir, &ListOptions{MaxDepth: -1, PathsOnly: true, ShowIcons: true, ShowColors: true})
+	if err := lb.WritePaths("", &out); err != nil {
+		t.Fatalf("WritePaths: %v", err)
+	}
+	want := "Email/gmail\n'Email/it'\\''s'\n'Email/my work'\nWork/vpn\nbank\n"
+	if out.String() != want {
+		t.Errorf("WritePaths =\n%s\nwant\n%s", out.String(), want)
+	}
+
+	out.Reset()
+	if err := lb.WritePaths("Work", &out); err != nil || out.String() != "Work/vpn\n" {
+		t.Errorf("WritePaths(Work) = %q, %v", out.String(), err)
+	}
+}
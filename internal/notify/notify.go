@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"context"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// timeout is how long a notifier may take before it is given up on
+const timeout = 10 * time.Second
+
+// notifyCommand returns the program and arguments that show a notification
+// on the current platform, or false when no notifier is installed
+func notifyCommand(title, body string) (string, []string, bool) {
+	var candidates [][]string
+	switch runtime.GOOS {
+	case "darwin":
+		script := "display notification " + appleScriptString(body) + " with title " + appleScriptString(title)
+		candidates = [][]string{{"osascript", "-e", script}}
+	case "windows":
+		candidates = [][]string{{"powershell.exe", "-NoProfile", "-Command", toastScript(title, body)}}
+	default:
+		candidates = [][]string{
+			{"notify-send", "--app-name=chowkidaar", title, body},
+			{"powershell.exe", "-NoProfile", "-Command", toastScript(title, body)}, // WSL
+		}
+	}
+
+	for _, candidate := range candidates {
+		if path, err := exec.LookPath(candidate[0]); err == nil {
+			return path, candidate[1:], true
+		}
+	}
+	return "", nil, false
+}
+
+// appleScriptString quotes text as an AppleScript string literal
+func appleScriptString(text string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(text) + `"`
+}
+
+// toastScript returns a PowerShell script that shows a Windows toast
+func toastScript(title, body string) string {
+	quote := func(text string) string {
+		return "'" + strings.ReplaceAll(text, "'", "''") + "'"
+	}
+	return strings.Join([]string{
+		"[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] > $null",
+		"$template = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)",
+		"$text = $template.GetElementsByTagName('text')",
+		"$text.Item(0).AppendChild($template.CreateTextNode(" + quote(title) + ")) > $null",
+		"$text.Item(1).AppendChild($template.CreateTextNode(" + quote(body) + ")) > $null",
+		"[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier('chowkidaar').Show([Windows.UI.Notifications.ToastNotification]::new($template))",
+	}, "; ")
+}
+
+// Notify shows a desktop notification with notify-send, osascript or a
+// Windows toast. It is best effort: without a notifier, or when showing one
+// fails, nothing happens, so callers never fail because of it. Callers must
+// not pass secrets, as other processes can read the arguments.
+func Notify(title, body string) {
+	name, args, ok := notifyCommand(title, body)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_ = exec.CommandContext(ctx, name, args...).Run()
+}
@@ -0,0 +1,47 @@
+package notify
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestNotify(t *testing.T) {
+	if runtime.GOOS == "darwin" || runtime.GOOS == "windows" {
+		t.Skip("uses a fake notify-send")
+	}
+
+	// A stand-in notify-send that records its arguments
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := "#!/bin/sh\nfor arg in \"$@\"; do echo \"$arg\" >> " + argsFile + "; done\n"
+	if err := os.WriteFile(filepath.Join(dir, "notify-send"), []byte(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	Notify("Password store synced", "2 new, 1 updated")
+
+	args, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "--app-name=chowkidaar\nPassword store synced\n2 new, 1 updated\n"; string(args) != want {
+		t.Errorf("notify-send was run with %q, want %q", args, want)
+	}
+
+	// Without a notifier it does nothing
+	t.Setenv("PATH", t.TempDir())
+	Notify("Password store synced", "2 new")
+}
+
+func TestQuoting(t *testing.T) {
+	if got, want := appleScriptString(`say "hi" \ bye`), `"say \"hi\" \\ bye"`; got != want {
+		t.Errorf("appleScriptString = %s, want %s", got, want)
+	}
+	if got := toastScript("it's", "x"); !strings.Contains(got, "CreateTextNode('it''s')") {
+		t.Errorf("toastScript did not double the quote: %s", got)
+	}
+}
@@ -0,0 +1,573 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"chowkidaar/internal/hooks"
+)
+
+const backupDirName = ".backups"
+
+// ProgressFunc is told how many of total steps a bulk operation has done
+type ProgressFunc func(done, total int)
+
+// SetProgress sets the function bulk operations report their progress to
+func (s *Store) SetProgress(progress ProgressFunc) {
+	s.progress = progress
+}
+
+// reportProgress forwards progress to the configured ProgressFunc, if any
+func (s *Store) reportProgress(done, total int) {
+	if s.progress != nil {
+		s.progress(done, total)
+	}
+}
+
+// EntryFiles returns the paths of all password files in the store,
+// relative to the store root. Shared copies and history are not included.
+func (s *Store) EntryFiles() ([]string, error) {
+	var files []string
+	err := filepath.Walk(s.baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip hidden directories like .git, .cache and .backups
+		if info.IsDir() && path != s.baseDir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".enc") {
+			relPath, err := filepath.Rel(s.baseDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, relPath)
+		}
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk password store: %w", err)
+	}
+
+	return files, nil
+}
+
+// Backup archives every encrypted entry and history version in the store
+// into a timestamped tar.gz under .backups/ and returns its path. Files stay
+// encrypted, so the backup is only as readable as the store itself.
+func (s *Store) Backup() (string, error) {
+	if err := s.requireWritable(); err != nil {
+		return "", err
+	}
+
+	files, err := s.EntryFiles()
+	if err != nil {
+		return "", err
+	}
+	history, err := s.historyFiles()
+	if err != nil {
+		return "", err
+	}
+	files = append(files, history...)
+
+	backupDir := filepath.Join(s.baseDir, backupDirName)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	backupPath := filepath.Join(backupDir, fmt.Sprintf("backup-%s.tar.gz", time.Now().Format("20060102-150405")))
+	if err := s.writeArchive(backupPath, files); err != nil {
+		return "", fmt.Errorf("failed to write backup: %w", err)
+	}
+
+	return backupPath, nil
+}
+
+// ChangeMasterPassword re-encrypts every entry in the store, and its history,
+// with a new master password and returns the number of entries re-encrypted.
+// Everything is decrypted and re-encrypted in memory before the first file
+// is written, so a failure or a cancelled ctx leaves the store untouched;
+// only the final, quick write phase ignores cancellation. Each file is
+// replaced atomically.
+func (s *Store) ChangeMasterPassword(ctx context.Context, oldPassword, newPassword string) (int, error) {
+	if err := s.requireWritable(); err != nil {
+		return 0, err
+	}
+
+	unlock, err := s.lockStore()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if err := s.validatePasswordIfNeeded(oldPassword); err != nil {
+		return 0, fmt.Errorf("password validation failed: %w", err)
+	}
+
+	files, err := s.EntryFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	history, err := s.historyFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	// Decrypt everything first so a bad entry aborts before any rewrite
+	total := 2 * (len(files) + len(history))
+	step := 0
+	plaintexts := make(map[string][]byte, len(files)+len(history))
+	for _, relPath := range files {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		decrypted, err := s.decryptFile(relPath, oldPassword)
+		if err != nil {
+			return 0, err
+		}
+		plaintexts[relPath] = decrypted
+		step++
+		s.reportProgress(step, total)
+	}
+
+	// History written under an even older password cannot be recovered
+	// with this one; leave it as it is rather than block the change
+	var versions []string
+	for _, relPath := range history {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		decrypted, err := s.decryptFile(relPath, oldPassword)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: skipping history version %s: %v\n", relPath, err)
+			total -= 2
+			s.reportProgress(step, total)
+			continue
+		}
+		plaintexts[relPath] = decrypted
+		versions = append(versions, relPath)
+		step++
+		s.reportProgress(step, total)
+	}
+
+	// Encrypting is as slow as decrypting, so it still happens in memory
+	ciphertexts := make(map[string][]byte, len(plaintexts))
+	for _, relPath := range append(append([]string{}, files...), versions...) {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		encrypted, err := s.crypto.Encrypt(plaintexts[relPath], newPassword)
+		if err != nil {
+			return 0, fmt.Errorf("failed to encrypt %s: %w", relPath, err)
+		}
+		ciphertexts[relPath] = encrypted
+		step++
+		s.reportProgress(step, total)
+	}
+
+	count := 0
+	for _, relPath := range files {
+		if err := s.writeFile(relPath, ciphertexts[relPath]); err != nil {
+			return count, err
+		}
+		count++
+	}
+
+	for _, relPath := range versions {
+		if err := s.writeFile(relPath, ciphertexts[relPath]); err != nil {
+			return count, err
+		}
+	}
+
+	// The old password is no longer valid
+	s.crypto.ClearPasswordCache()
+	s.crypto.CachePassword(newPassword)
+
+	// Auto-commit to Git if enabled
+	if err := s.autoCommit("rekey", "", "Change master password"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+	}
+
+	return count, nil
+}
+
+// Reencrypt re-encrypts the entries in subfolder (the whole store when
+// empty) whose blobs were written with an older KDF or older parameters,
+// keeping the master password. Entries already at the current settings are
+// skipped. It returns how many entries were upgraded out of how many were
+// checked. Each file is replaced atomically, so stopping early through ctx
+// leaves every entry readable; the upgraded ones are still committed.
+func (s *Store) Reencrypt(ctx context.Context, subfolder, masterPassword string) (upgraded, checked int, err error) {
+	if err := s.requireWritable(); err != nil {
+		return 0, 0, err
+	}
+
+	unlock, err := s.lockStore()
+	if err != nil {
+		return 0, 0, err
+	}
+	defer unlock()
+
+	if err := s.validatePasswordIfNeeded(masterPassword); err != nil {
+		return 0, 0, fmt.Errorf("password validation failed: %w", err)
+	}
+
+	prefix := ""
+	if subfolder != "" {
+		if prefix, err = s.entryName(subfolder); err != nil {
+			return 0, 0, err
+		}
+	} else if err := s.requireNameKey(); err != nil {
+		return 0, 0, err
+	}
+
+	names, err := s.entryNames()
+	if err != nil {
+		return 0, 0, err
+	}
+	var selected []string
+	for _, name := range names {
+		if prefix == "" || name == prefix || strings.HasPrefix(name, prefix+"/") {
+			selected = append(selected, name)
+		}
+	}
+	if prefix != "" && len(selected) == 0 {
+		return 0, 0, fmt.Errorf("'%s' %w", prefix, ErrNotFound)
+	}
+
+	for i, name := range selected {
+		s.reportProgress(i, len(selected))
+		if err = ctx.Err(); err != nil {
+			break
+		}
+
+		relPath := s.diskName(name) + ".enc"
+		encrypted, readErr := os.ReadFile(filepath.Join(s.baseDir, relPath))
+		if readErr != nil {
+			err = fmt.Errorf("failed to read %s: %w", name, readErr)
+			break
+		}
+		checked++
+		if s.crypto.IsCurrent(encrypted) {
+			continue
+		}
+
+		decrypted, decryptErr := s.crypto.Decrypt(encrypted, masterPassword)
+		if decryptErr != nil {
+			err = fmt.Errorf("failed to decrypt %s: %w", name, decryptErr)
+			break
+		}
+		reencrypted, encryptErr := s.crypto.Encrypt(decrypted, masterPassword)
+		if encryptErr != nil {
+			err = fmt.Errorf("failed to encrypt %s: %w", name, encryptErr)
+			break
+		}
+		if err = s.writeFile(relPath, reencrypted); err != nil {
+			break
+		}
+		upgraded++
+	}
+	if err == nil {
+		s.reportProgress(len(selected), len(selected))
+	}
+
+	if upgraded > 0 {
+		if commitErr := s.autoCommit("reencrypt", fmt.Sprintf("%d passwords", upgraded), fmt.Sprintf("Re-encrypt %d passwords", upgraded)); commitErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", commitErr)
+		}
+	}
+
+	return upgraded, checked, err
+}
+
+// decryptFile reads and decrypts a file relative to the store root
+func (s *Store) decryptFile(relPath, masterPassword string) ([]byte, error) {
+	encrypted, err := os.ReadFile(filepath.Join(s.baseDir, relPath))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", relPath, err)
+	}
+
+	decrypted, err := s.crypto.Decrypt(encrypted, masterPassword)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+	}
+	return decrypted, nil
+}
+
+// writeFile atomically replaces a file relative to the store root
+func (s *Store) writeFile(relPath string, encrypted []byte) error {
+	if err := WriteFileAtomic(filepath.Join(s.baseDir, relPath), encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", relPath, err)
+	}
+	s.syncMirror(relPath, false)
+	return nil
+}
+
+// BatchEntry is a single password to store with InsertBatch
+type BatchEntry struct {
+	Name     string
+	Password string
+	Notes    string // Stored on the lines after the password
+	Line     int    // Source line, for error reporting
+}
+
+// BatchFailure records why a batch entry was not stored
+type BatchFailure struct {
+	Entry BatchEntry
+	Err   error
+}
+
+// BatchError is returned by InsertBatch when some entries were not stored
+type BatchError struct {
+	Failures []BatchFailure
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("%d entries could not be stored", len(e.Failures))
+}
+
+// InsertBatch encrypts and stores many entries with one master password.
+// A bad entry is recorded in the returned *BatchError without stopping the
+// rest, and all stored entries are committed together at the end. When ctx
+// is cancelled, the entries stored so far are committed and ctx's error is
+// returned.
+func (s *Store) InsertBatch(ctx context.Context, entries []BatchEntry, masterPassword string, overwrite bool) (int, error) {
+	return s.insertBatch(ctx, entries, masterPassword, overwrite, "import", "Import %d passwords")
+}
+
+// insertBatch implements InsertBatch, committing as action with message
+// formatted with the number of stored entries
+func (s *Store) insertBatch(ctx context.Context, entries []BatchEntry, masterPassword string, overwrite bool, action, message string) (int, error) {
+	if err := s.requireWritable(); err != nil {
+		return 0, err
+	}
+
+	unlock, err := s.lockStore()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if err := s.validatePasswordIfNeeded(masterPassword); err != nil {
+		return 0, fmt.Errorf("password validation failed: %w", err)
+	}
+
+	batchErr := &BatchError{}
+	seen := make(map[string]bool, len(entries))
+	var stored []string
+
+	var cancelled error
+	for i, entry := range entries {
+		s.reportProgress(i, len(entries))
+		if cancelled = ctx.Err(); cancelled != nil {
+			break
+		}
+
+		name, err := s.entryName(entry.Name)
+		if err == nil && seen[name] {
+			err = fmt.Errorf("duplicate entry '%s' in batch", name)
+		}
+		if err == nil && !overwrite && s.Exists(name) {
+			err = fmt.Errorf("password '%s' %w", name, ErrExists)
+		}
+		if err == nil && entry.Password == "" {
+			err = fmt.Errorf("password for '%s' is empty", name)
+		}
+		if err == nil {
+			seen[name] = true
+			err = s.writeBatchEntry(name, entry, masterPassword)
+		}
+
+		if err != nil {
+			batchErr.Failures = append(batchErr.Failures, BatchFailure{Entry: entry, Err: err})
+			continue
+		}
+		stored = append(stored, name)
+	}
+	if cancelled == nil {
+		s.reportProgress(len(entries), len(entries))
+	}
+
+	// Record all new names with a single index write
+	if err := s.addToIndex(stored...); err != nil {
+		return len(stored), fmt.Errorf("failed to record entry names: %w", err)
+	}
+
+	if len(stored) > 0 {
+		// Cache the validated master password (encryption succeeded)
+		s.crypto.CachePassword(masterPassword)
+
+		if err := s.autoCommit(action, fmt.Sprintf("%d passwords", len(stored)), fmt.Sprintf(message, len(stored))); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+		}
+
+		hooks.Run(s.hooks, hooks.PostInsert, stored...)
+	}
+
+	if cancelled != nil {
+		return len(stored), cancelled
+	}
+	if len(batchErr.Failures) > 0 {
+		return len(stored), batchErr
+	}
+	return len(stored), nil
+}
+
+// GenerateOptions controls the passwords made by GenerateBatch
+type GenerateOptions struct {
+	Length       int
+	NoSymbols    bool
+	SkipExisting bool // Leave existing entries alone instead of failing
+}
+
+// GenerateBatch generates and stores a random password for each name with
+// one master password and a single commit. Every password is drawn
+// separately from crypto/rand. An existing entry fails the batch before
+// anything is stored, unless opts.SkipExisting is set. The stored entries
+// are returned in order with their passwords; when some could not be
+// stored, the error is a *BatchError listing them.
+func (s *Store) GenerateBatch(names []string, opts GenerateOptions, masterPassword string) ([]BatchEntry, error) {
+	if err := s.requireWritable(); err != nil {
+		return nil, err
+	}
+	if opts.Length <= 0 {
+		return nil, fmt.Errorf("password length must be positive")
+	}
+	charset := defaultCharset
+	if !opts.NoSymbols {
+		charset += symbolCharset
+	}
+
+	seen := make(map[string]bool, len(names))
+	var entries []BatchEntry
+	for _, name := range names {
+		name, err := s.entryName(name)
+		if err != nil {
+			return nil, err
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate entry '%s' in batch", name)
+		}
+		seen[name] = true
+		if s.Exists(name) {
+			if opts.SkipExisting {
+				continue
+			}
+			return nil, fmt.Errorf("password '%s' %w", name, ErrExists)
+		}
+
+		password, err := generatePassword(opts.Length, charset)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate password: %w", err)
+		}
+		entries = append(entries, BatchEntry{Name: name, Password: password})
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+
+	_, err := s.insertBatch(context.Background(), entries, masterPassword, false, "generate", "Generate %d passwords")
+	var batchErr *BatchError
+	if err != nil && !errors.As(err, &batchErr) {
+		return nil, err
+	}
+	if batchErr != nil {
+		failed := make(map[string]bool, len(batchErr.Failures))
+		for _, failure := range batchErr.Failures {
+			failed[failure.Entry.Name] = true
+		}
+		entries = slices.DeleteFunc(entries, func(entry BatchEntry) bool { return failed[entry.Name] })
+		return entries, batchErr
+	}
+	return entries, nil
+}
+
+// RemoveBatch deletes many entries with a single commit and hook run. It
+// stops at the first entry that cannot be removed; the entries removed
+// before it are still committed and returned. keepEmptyDirs is as for Remove.
+func (s *Store) RemoveBatch(names []string, keepEmptyDirs bool) ([]string, error) {
+	if err := s.requireWritable(); err != nil {
+		return nil, err
+	}
+
+	unlock, err := s.lockStore()
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	normalized := make([]string, 0, len(names))
+	for _, name := range names {
+		name, err := s.entryName(name)
+		if err != nil {
+			return nil, err
+		}
+		normalized = append(normalized, name)
+	}
+
+	var removed []string
+	var removeErr error
+	for _, name := range normalized {
+		if err := s.removeEntryFiles(name, keepEmptyDirs); err != nil {
+			removeErr = fmt.Errorf("failed to remove password '%s' after removing %d: %w", name, len(removed), err)
+			break
+		}
+		removed = append(removed, name)
+	}
+
+	if err := s.removeFromIndex(removed...); err != nil {
+		return removed, err
+	}
+
+	if len(removed) > 0 {
+		if err := s.autoCommit("remove", fmt.Sprintf("%d passwords", len(removed)), fmt.Sprintf("Remove %d passwords", len(removed))); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+		}
+
+		hooks.Run(s.hooks, hooks.PostRemove, removed...)
+	}
+
+	return removed, removeErr
+}
+
+// writeBatchEntry encrypts and writes a single batch entry
+func (s *Store) writeBatchEntry(name string, entry BatchEntry, masterPassword string) error {
+	content := entry.Password
+	if entry.Notes != "" {
+		content += "\n" + entry.Notes
+	}
+
+	if err := s.checkNewFolder(name); err != nil {
+		return err
+	}
+	filePath := s.getPasswordFilePath(name)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	encrypted, err := s.crypto.Encrypt([]byte(content), masterPassword)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt password: %w", err)
+	}
+
+	// Keep the previous version when overwriting
+	if err := s.saveHistory(name); err != nil {
+		return fmt.Errorf("failed to save password history: %w", err)
+	}
+
+	if err := WriteFileAtomic(filePath, encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to write password file: %w", err)
+	}
+
+	s.removeStaleShare(name)
+	s.syncMirrorEntry(s.diskName(name), false)
+	return nil
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// dirDescriptionFile holds the one-line description of the folder it is in.
+// It is a plain file tracked in Git and never taken for an entry, which all
+// end in .enc.
+const dirDescriptionFile = ".desc"
+
+// SetDirDescription describes an existing folder, e.g. "corporate accounts".
+// An empty description removes it. Descriptions are not encrypted, so they
+// are refused in stores that hide their names.
+func (s *Store) SetDirDescription(dir, description string) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+
+	path, dir, err := s.descriptionPath(dir)
+	if err != nil {
+		return err
+	}
+	description = strings.TrimSpace(description)
+	if strings.ContainsAny(description, "\r\n") {
+		return fmt.Errorf("a folder description must be a single line")
+	}
+
+	message := fmt.Sprintf("Describe folder %s", dir)
+	if description == "" {
+		if err := os.Remove(path); err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return fmt.Errorf("failed to remove description: %w", err)
+		}
+		message = fmt.Sprintf("Remove description of folder %s", dir)
+	} else if err := WriteFileAtomic(path, []byte(description+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write description: %w", err)
+	}
+
+	if err := s.autoCommit("describe", dir, message); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+	}
+	return nil
+}
+
+// GetDirDescription returns the description of a folder, or an empty string
+// if it has none
+func (s *Store) GetDirDescription(dir string) (string, error) {
+	path, _, err := s.descriptionPath(dir)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read description: %w", err)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// DirDescriptions returns the description of every described folder, keyed
+// by its slash-separated path relative to the store root
+func (s *Store) DirDescriptions() (map[string]string, error) {
+	descriptions := make(map[string]string)
+	if s.HiddenNames() {
+		return descriptions, nil
+	}
+
+	err := filepath.WalkDir(s.baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip .git, .history and the other hidden directories
+		if d.IsDir() && path != s.baseDir && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || d.Name() != dirDescriptionFile || filepath.Dir(path) == s.baseDir {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.baseDir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		descriptions[filepath.ToSlash(rel)] = strings.TrimSpace(string(data))
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read folder descriptions: %w", err)
+	}
+	return descriptions, nil
+}
+
+// descriptionPath returns the description file of an existing folder and the
+// folder's normalized name
+func (s *Store) descriptionPath(dir string) (string, string, error) {
+	if s.HiddenNames() {
+		return "", "", fmt.Errorf("folder descriptions are not available when names are hidden, since they are stored unencrypted")
+	}
+
+	dir, err := NormalizeName(dir)
+	if err != nil {
+		return "", "", err
+	}
+	dirPath := filepath.Join(s.baseDir, filepath.FromSlash(dir))
+	if info, err := os.Stat(dirPath); err != nil || !info.IsDir() {
+		return "", "", fmt.Errorf("folder '%s' does not exist", dir)
+	}
+	return filepath.Join(dirPath, dirDescriptionFile), dir, nil
+}
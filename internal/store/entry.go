@@ -0,0 +1,213 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonEntryMarker starts the plaintext of entries stored as JSON documents.
+// It cannot be typed at a password prompt, so a plain entry whose password
+// happens to begin with "{" is never mistaken for one.
+const jsonEntryMarker = "\x1e"
+
+// Entry is the structured form of a password entry
+type Entry struct {
+	Password string            `json:"password"`
+	Username string            `json:"username,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+}
+
+// IsJSONEntry reports whether decrypted content is a JSON entry
+func IsJSONEntry(content string) bool {
+	return strings.HasPrefix(content, jsonEntryMarker)
+}
+
+// ParseEntry reads decrypted content of either format. For plain entries the
+// first line is the password, "username:" (or "user:", "login:") lines fill
+// Username, other "key: value" lines become fields and the remaining lines
+// are kept as the "notes" field.
+func ParseEntry(content string) (*Entry, error) {
+	if IsJSONEntry(content) {
+		return ParseJSONEntry(strings.TrimPrefix(content, jsonEntryMarker))
+	}
+
+	entry := &Entry{Password: TrimSecret(content), Fields: map[string]string{}}
+	_, rest, _ := strings.Cut(content, "\n")
+
+	var notes []string
+	for _, line := range strings.Split(strings.TrimSuffix(rest, "\n"), "\n") {
+		line = strings.TrimRight(line, " \t\r")
+		key, value, ok := strings.Cut(line, ":")
+		// "https://..." is a note, not a field called https
+		if !ok || key == "" || strings.ContainsAny(key, " \t") || (value != "" && value[0] != ' ' && value[0] != '\t') {
+			if line != "" {
+				notes = append(notes, line)
+			}
+			continue
+		}
+
+		value = strings.TrimSpace(value)
+		switch strings.ToLower(key) {
+		case "username", "user", "login":
+			if entry.Username == "" {
+				entry.Username = value
+				continue
+			}
+		}
+		if _, exists := entry.Fields[key]; !exists {
+			entry.Fields[key] = value
+		}
+	}
+	if len(notes) > 0 {
+		entry.Fields["notes"] = strings.Join(notes, "\n")
+	}
+	if len(entry.Fields) == 0 {
+		entry.Fields = nil
+	}
+
+	return entry, nil
+}
+
+// ParseJSONEntry reads an entry from a JSON document such as
+// {"password":"...","username":"...","fields":{"url":"..."}}. Unknown keys
+// are rejected so a typo is not silently dropped.
+func ParseJSONEntry(data string) (*Entry, error) {
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	var entry Entry
+	if err := decoder.Decode(&entry); err != nil {
+		return nil, fmt.Errorf("invalid JSON entry: %w", err)
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("invalid JSON entry: unexpected data after the document")
+	}
+	if entry.Password == "" {
+		return nil, fmt.Errorf("invalid JSON entry: password is missing")
+	}
+	return &entry, nil
+}
+
+// Encode returns the entry as the plaintext of a JSON entry
+func (e *Entry) Encode() (string, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(e); err != nil {
+		return "", err
+	}
+	return jsonEntryMarker + buf.String(), nil
+}
+
+// Field returns a named value of the entry: "password", "username" or one of
+// its fields
+func (e *Entry) Field(name string) (string, bool) {
+	switch name {
+	case "password":
+		return e.Password, true
+	case "username":
+		return e.Username, e.Username != ""
+	}
+	value, ok := e.Fields[name]
+	return value, ok
+}
+
+// Sensitive reports whether the entry is marked with a "sensitive: true"
+// field, asking for stricter handling: the master password is asked for
+// again instead of taken from the cache, and revealing or copying it needs
+// confirmation
+func (e *Entry) Sensitive() bool {
+	value, ok := e.Fields[sensitiveField]
+	sensitive, err := strconv.ParseBool(value)
+	return ok && err == nil && sensitive
+}
+
+// sensitiveField is the field that marks an entry as sensitive
+const sensitiveField = "sensitive"
+
+// IsSensitiveEntry reports whether decrypted content is marked sensitive,
+// see Entry.Sensitive
+func IsSensitiveEntry(content string) bool {
+	entry, err := ParseEntry(content)
+	return err == nil && entry.Sensitive()
+}
+
+// FieldNames returns the names Field accepts for this entry
+func (e *Entry) FieldNames() []string {
+	names := []string{"password"}
+	if e.Username != "" {
+		names = append(names, "username")
+	}
+	fields := make([]string, 0, len(e.Fields))
+	for name := range e.Fields {
+		fields = append(fields, name)
+	}
+	sort.Strings(fields)
+	return append(names, fields...)
+}
+
+// EntryText returns decrypted content as it is shown to the user: JSON
+// entries without their marker, plain entries unchanged
+func EntryText(content string) string {
+	return strings.TrimPrefix(content, jsonEntryMarker)
+}
+
+// IsSensitive decrypts an entry and reports whether it is marked sensitive,
+// see Entry.Sensitive. The mark is inside the encrypted entry, so it takes
+// the master password like Show.
+func (s *Store) IsSensitive(name, masterPassword string) (bool, error) {
+	content, err := s.Show(name, masterPassword)
+	if err != nil {
+		return false, err
+	}
+	return IsSensitiveEntry(content), nil
+}
+
+// ShowField decrypts an entry and returns one of its values, see Entry.Field
+func (s *Store) ShowField(name, field, masterPassword string) (string, error) {
+	content, err := s.Show(name, masterPassword)
+	if err != nil {
+		return "", err
+	}
+
+	entry, err := ParseEntry(content)
+	if err != nil {
+		return "", err
+	}
+	value, ok := entry.Field(field)
+	if !ok {
+		return "", fmt.Errorf("'%s' has no field '%s' (available: %s)", name, field, strings.Join(entry.FieldNames(), ", "))
+	}
+	return value, nil
+}
+
+// ConvertToJSON rewrites a plain entry as a JSON entry, see ParseEntry for how
+// its lines are mapped. The plain version is kept in the history.
+func (s *Store) ConvertToJSON(name, masterPassword string) error {
+	content, err := s.Show(name, masterPassword)
+	if err != nil {
+		return err
+	}
+	if IsJSONEntry(content) {
+		return fmt.Errorf("'%s' is already a JSON entry", name)
+	}
+
+	entry, err := ParseEntry(content)
+	if err != nil {
+		return err
+	}
+	if entry.Password == "" {
+		return fmt.Errorf("'%s' has an empty first line, so there is no password to convert", name)
+	}
+	encoded, err := entry.Encode()
+	if err != nil {
+		return fmt.Errorf("failed to encode entry: %w", err)
+	}
+
+	return s.Update(name, encoded, masterPassword)
+}
@@ -0,0 +1,352 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Export formats. Only ExportArchive keeps the entries encrypted.
+const (
+	ExportArchive    = "archive"
+	ExportJSON       = "json"
+	ExportKeePassCSV = "keepass-csv"
+)
+
+// ExportFormats lists the formats accepted by export, the default first
+var ExportFormats = []string{ExportArchive, ExportJSON, ExportKeePassCSV}
+
+// ExportedEntry is one decrypted entry of a plaintext export
+type ExportedEntry struct {
+	Name     string            `json:"name"`
+	Password string            `json:"password"`
+	Username string            `json:"username,omitempty"`
+	Fields   map[string]string `json:"fields,omitempty"`
+
+	Size     int64 `json:"-"` // Decrypted length in bytes
+	DiskSize int64 `json:"-"` // Size of the encrypted file
+}
+
+// FolderSize adds up the sizes of the entries directly in one folder
+type FolderSize struct {
+	Folder   string `json:"folder"` // Empty for the top level
+	Entries  int    `json:"entries"`
+	Size     int64  `json:"decrypted_bytes"`
+	DiskSize int64  `json:"encrypted_bytes"`
+}
+
+// ExportEntries decrypts every entry in the store, sorted by name, and
+// splits it into fields as ParseEntry does. A cancelled ctx stops it early.
+func (s *Store) ExportEntries(ctx context.Context, masterPassword string) ([]ExportedEntry, error) {
+	names, err := s.entryNames()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	entries := make([]ExportedEntry, 0, len(names))
+	for i, name := range names {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		content, err := s.Show(name, masterPassword)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		entry, err := ParseEntry(content)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		entries = append(entries, ExportedEntry{
+			Name:     name,
+			Password: entry.Password,
+			Username: entry.Username,
+			Fields:   entry.Fields,
+			Size:     int64(len(content)),
+			DiskSize: s.entryFileSize(name),
+		})
+		s.reportProgress(i+1, len(names))
+	}
+	return entries, nil
+}
+
+// entryFileSize returns the size of an entry's encrypted file, or 0 when it
+// cannot be read
+func (s *Store) entryFileSize(name string) int64 {
+	filePath := s.getPasswordFilePath(name)
+	if s.isGPGEntry(name) {
+		filePath = s.gpgFilePath(name)
+	}
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
+
+// FolderSizes groups exported entries by the folder they are directly in,
+// largest decrypted size first, to show where a large store's bytes are
+func FolderSizes(entries []ExportedEntry) []FolderSize {
+	byFolder := make(map[string]*FolderSize)
+	for _, entry := range entries {
+		folder := path.Dir(entry.Name)
+		if folder == "." {
+			folder = ""
+		}
+		size, ok := byFolder[folder]
+		if !ok {
+			size = &FolderSize{Folder: folder}
+			byFolder[folder] = size
+		}
+		size.Entries++
+		size.Size += entry.Size
+		size.DiskSize += entry.DiskSize
+	}
+
+	sizes := make([]FolderSize, 0, len(byFolder))
+	for _, size := range byFolder {
+		sizes = append(sizes, *size)
+	}
+	sort.Slice(sizes, func(i, j int) bool {
+		if sizes[i].Size != sizes[j].Size {
+			return sizes[i].Size > sizes[j].Size
+		}
+		return sizes[i].Folder < sizes[j].Folder
+	})
+	return sizes
+}
+
+// WriteExportJSON writes entries as an indented JSON array
+func WriteExportJSON(w io.Writer, entries []ExportedEntry) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(entries)
+}
+
+// WriteKeePassCSV writes entries in the column layout KeePassXC imports:
+// the entry's folder becomes its group, its url field the URL, and the notes
+// and any other fields, as "key: value" lines, its notes.
+func WriteKeePassCSV(w io.Writer, entries []ExportedEntry) error {
+	writer := csv.NewWriter(w)
+	if err := writer.Write([]string{"Group", "Title", "Username", "Password", "URL", "Notes"}); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		group := path.Dir(entry.Name)
+		if group == "." {
+			group = ""
+		}
+
+		var notes []string
+		if value, ok := entry.Fields["notes"]; ok {
+			notes = append(notes, value)
+		}
+		keys := make([]string, 0, len(entry.Fields))
+		for key := range entry.Fields {
+			if key != "notes" && key != "url" {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			notes = append(notes, key+": "+entry.Fields[key])
+		}
+
+		record := []string{group, path.Base(entry.Name), entry.Username, entry.Password, entry.Fields["url"], strings.Join(notes, "\n")}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// ExportArchive writes every encrypted entry and history version, and the
+// name index of a store with hidden names, to a new tar.gz at archivePath and
+// returns the number of entries. Like Backup it needs no master password:
+// the archive is only as readable as the store itself.
+func (s *Store) ExportArchive(archivePath string) (int, error) {
+	files, err := s.EntryFiles()
+	if err != nil {
+		return 0, err
+	}
+	count := len(files)
+
+	history, err := s.historyFiles()
+	if err != nil {
+		return 0, err
+	}
+	files = append(files, history...)
+	if s.HiddenNames() {
+		files = append(files, nameIndexFile)
+	}
+
+	if err := s.writeArchive(archivePath, files); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// ImportArchive stores the entries of an archive written by ExportArchive,
+// decrypting each with archivePassword and encrypting it again with
+// masterPassword, which may be the same. The archive must come from a store
+// with the same keyfile, or without one if this store has none. Every entry
+// is decrypted before anything is written, so a wrong archivePassword fails
+// with crypto.ErrAuthFailed and leaves the store untouched. History versions
+// in the archive are not imported.
+func (s *Store) ImportArchive(ctx context.Context, archivePath, archivePassword, masterPassword string) (int, error) {
+	files, err := readArchive(archivePath)
+	if err != nil {
+		return 0, err
+	}
+
+	// Stores with hidden names archive their entries under hashed names
+	diskNames := make(map[string]string)
+	if encrypted, ok := files[nameIndexFile]; ok {
+		data, err := s.crypto.DecryptWithKeyFile(encrypted)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt name index: %w", err)
+		}
+		index := &nameIndex{}
+		if err := json.Unmarshal(data, index); err != nil {
+			return 0, fmt.Errorf("failed to parse name index: %w", err)
+		}
+		for name, disk := range index.Entries {
+			diskNames[disk] = name
+		}
+	}
+
+	var entries []BatchEntry
+	for relPath, encrypted := range files {
+		if strings.HasPrefix(relPath, ".") || !strings.HasSuffix(relPath, ".enc") {
+			continue // History versions and the name index
+		}
+		name := strings.TrimSuffix(relPath, ".enc")
+		if real, ok := diskNames[name]; ok {
+			name = real
+		}
+
+		content, err := s.crypto.Decrypt(encrypted, archivePassword)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt '%s': %w", name, err)
+		}
+		entries = append(entries, BatchEntry{Name: name, Password: string(content)})
+	}
+	if len(entries) == 0 {
+		return 0, fmt.Errorf("no passwords in %s", archivePath)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	// The archive's folders are restored, not typed, so they need no asking
+	s.AllowNewFolders()
+	return s.insertBatch(ctx, entries, masterPassword, false, "import", "Import %d passwords")
+}
+
+// readArchive reads the regular files of a tar.gz written by writeArchive,
+// keyed by their slash-separated path within the store
+func readArchive(archivePath string) (map[string][]byte, error) {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	gzipReader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archive: %w", err)
+	}
+	defer gzipReader.Close()
+
+	files := make(map[string][]byte)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read archive: %w", err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		data, err := io.ReadAll(tarReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s from archive: %w", header.Name, err)
+		}
+		files[path.Clean(header.Name)] = data
+	}
+	return files, nil
+}
+
+// writeArchive writes the given store files, relative to the store root, to
+// a new tar.gz. A partly written archive is removed on failure.
+func (s *Store) writeArchive(archivePath string, files []string) error {
+	out, err := os.OpenFile(archivePath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer out.Close()
+
+	gzipWriter := gzip.NewWriter(out)
+	tarWriter := tar.NewWriter(gzipWriter)
+
+	for _, relPath := range files {
+		if err := addFileToTar(tarWriter, filepath.Join(s.baseDir, relPath), relPath); err != nil {
+			os.Remove(archivePath)
+			return fmt.Errorf("failed to archive %s: %w", relPath, err)
+		}
+	}
+
+	if err := tarWriter.Close(); err != nil {
+		os.Remove(archivePath)
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	if err := gzipWriter.Close(); err != nil {
+		os.Remove(archivePath)
+		return fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	return nil
+}
+
+// addFileToTar writes a single file into a tar archive under the given name
+func addFileToTar(tarWriter *tar.Writer, filePath, name string) error {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	header, err := tar.FileInfoHeader(info, "")
+	if err != nil {
+		return err
+	}
+	header.Name = filepath.ToSlash(name)
+
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return err
+	}
+
+	_, err = io.Copy(tarWriter, file)
+	return err
+}
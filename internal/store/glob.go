@@ -0,0 +1,99 @@
+package store
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// IsGlob reports whether a name contains glob metacharacters
+func IsGlob(name string) bool {
+	return strings.ContainsAny(name, "*?[")
+}
+
+// Glob returns the sorted names of entries matching pattern. Each path
+// component is matched with path.Match, and a "**" component matches any
+// number of components, so "Old/*" matches the entries directly in Old and
+// "Old/**" matches everything below it. As in git, a trailing "**" needs at
+// least one component, so "Old/**" does not match an entry named Old.
+func (s *Store) Glob(pattern string) ([]string, error) {
+	segments := strings.Split(strings.Trim(strings.TrimSpace(pattern), "/"), "/")
+	for _, segment := range segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern '%s': %w", pattern, err)
+		}
+	}
+
+	names, err := s.entryNames()
+	if err != nil {
+		return nil, err
+	}
+
+	var matches []string
+	for _, name := range names {
+		if matchSegments(segments, strings.Split(name, "/")) {
+			matches = append(matches, name)
+		}
+	}
+	sort.Strings(matches)
+	return matches, nil
+}
+
+// entryNames returns the names of all entries in the store
+func (s *Store) entryNames() ([]string, error) {
+	if s.HiddenNames() {
+		files, err := s.NameMap()
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(files))
+		for name := range files {
+			names = append(names, name)
+		}
+		return names, nil
+	}
+
+	files, err := s.EntryFiles()
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.ToSlash(file), ".enc"))
+	}
+	if s.gpgReadable() {
+		gpgNames, err := s.GPGEntryNames()
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, gpgNames...)
+	}
+	return names, nil
+}
+
+// matchSegments matches name components against pattern components
+func matchSegments(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+
+	if pattern[0] == "**" {
+		if len(pattern) == 1 {
+			return len(name) > 0
+		}
+		for i := 0; i <= len(name); i++ {
+			if matchSegments(pattern[1:], name[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(name) == 0 {
+		return false
+	}
+	matched, _ := path.Match(pattern[0], name[0])
+	return matched && matchSegments(pattern[1:], name[1:])
+}
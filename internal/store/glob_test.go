@@ -0,0 +1,42 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchSegments(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{pattern: "*", name: "gmail", want: true},
+		{pattern: "*", name: "Email/gmail", want: false},
+		{pattern: "Email/*", name: "Email/gmail", want: true},
+		{pattern: "Email/*", name: "Email/Work/gmail", want: false},
+		{pattern: "Email/g*", name: "Email/gmail", want: true},
+		{pattern: "Email/?mail", name: "Email/gmail", want: true},
+		{pattern: "**", name: "gmail", want: true},
+		{pattern: "**", name: "Email/Work/gmail", want: true},
+		{pattern: "**/gmail", name: "gmail", want: true},
+		{pattern: "**/gmail", name: "Email/Work/gmail", want: true},
+		{pattern: "Email/**/gmail", name: "Email/gmail", want: true},
+		{pattern: "Email/**", name: "Email/gmail", want: true},
+		{pattern: "Email/**", name: "Email/Work/gmail", want: true},
+		{pattern: "Email/**", name: "Email", want: false},
+		{pattern: "Email/**", name: "Mail/gmail", want: false},
+		{pattern: "Email/*", name: "Mail/gmail", want: false},
+		{pattern: "Email/gmail", name: "Email/yahoo", want: false},
+		{pattern: "Email/Work/*", name: "Email/gmail", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			got := matchSegments(strings.Split(tt.pattern, "/"), strings.Split(tt.name, "/"))
+			if got != tt.want {
+				t.Errorf("matchSegments(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// gpgExtension marks entries encrypted by pass, which gpg decrypts
+const gpgExtension = ".gpg"
+
+// SetGPGRead lets Show read entries that only exist as .gpg files, as left
+// by pass in a store that is being migrated, by running gpg. Entries are
+// still written as native .enc files, which then take precedence.
+func (s *Store) SetGPGRead(enabled bool) {
+	s.gpgRead = enabled
+}
+
+// GPGAvailable reports whether the gpg binary is on the PATH
+func GPGAvailable() bool {
+	_, err := exec.LookPath("gpg")
+	return err == nil
+}
+
+// gpgReadable reports whether .gpg entries are read. Stores that hide their
+// names have no readable paths for pass to have written to.
+func (s *Store) gpgReadable() bool {
+	return s.gpgRead && !s.HiddenNames()
+}
+
+// gpgFilePath returns where pass would keep an entry
+func (s *Store) gpgFilePath(name string) string {
+	return filepath.Join(s.baseDir, name+gpgExtension)
+}
+
+// isGPGEntry reports whether an entry exists only as a .gpg file
+func (s *Store) isGPGEntry(name string) bool {
+	if _, err := os.Stat(s.getPasswordFilePath(name)); !os.IsNotExist(err) {
+		return false
+	}
+	_, err := os.Stat(s.gpgFilePath(name))
+	return err == nil
+}
+
+// GPGEntryNames returns the names of entries that exist only as .gpg files,
+// i.e. that are still to be migrated
+func (s *Store) GPGEntryNames() ([]string, error) {
+	var names []string
+	err := filepath.Walk(s.baseDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && path != s.baseDir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || !strings.HasSuffix(info.Name(), gpgExtension) {
+			return nil
+		}
+		relPath, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			return err
+		}
+		name := strings.TrimSuffix(filepath.ToSlash(relPath), gpgExtension)
+		if s.isGPGEntry(name) {
+			names = append(names, name)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk password store: %w", err)
+	}
+	return names, nil
+}
+
+// decryptGPG decrypts a .gpg entry with gpg. The terminal stays connected
+// so that gpg-agent can ask for the passphrase of the key.
+func decryptGPG(path string) ([]byte, error) {
+	gpgPath, err := exec.LookPath("gpg")
+	if err != nil {
+		return nil, fmt.Errorf("%s needs gpg, which was not found on the PATH", filepath.Base(path))
+	}
+
+	var stdout bytes.Buffer
+	cmd := exec.Command(gpgPath, "--quiet", "--yes", "--decrypt", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = &stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("gpg failed: %w", err)
+	}
+	return stdout.Bytes(), nil
+}
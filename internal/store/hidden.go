@@ -0,0 +1,341 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// nameIndexFile holds the encrypted mapping from entry names to on-disk
+// names in stores that hide their structure. Its presence turns the mode on.
+const nameIndexFile = ".names.idx"
+
+// nameIndex maps display names to on-disk file names (without .enc)
+type nameIndex struct {
+	Entries map[string]string `json:"entries"`
+}
+
+// HiddenNames reports whether the store keeps entry names out of the filesystem
+func (s *Store) HiddenNames() bool {
+	return s.hiddenNames
+}
+
+// detectHiddenNames turns on hidden-names mode if the store has a name
+// index. The name key is derived later, so commands that never touch entry
+// names still work without the keyfile.
+func (s *Store) detectHiddenNames() {
+	_, err := os.Stat(filepath.Join(s.baseDir, nameIndexFile))
+	s.hiddenNames = err == nil
+}
+
+// requireNameKey derives the name key on first use in hidden-names mode
+func (s *Store) requireNameKey() error {
+	if !s.hiddenNames || s.nameKey != nil {
+		return nil
+	}
+
+	key, err := s.crypto.NameKey()
+	if err != nil {
+		return fmt.Errorf("failed to derive name key: %w", err)
+	}
+	s.nameKey = key
+	return nil
+}
+
+// entryName normalizes an entry name and makes sure it can be mapped to its
+// file in hidden-names mode
+func (s *Store) entryName(name string) (string, error) {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return "", err
+	}
+	if err := s.requireNameKey(); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// hashName returns the on-disk name of an entry in hidden-names mode. The
+// HMAC is keyed by the keyfile, so the name cannot be guessed from the repo.
+func (s *Store) hashName(name string) string {
+	mac := hmac.New(sha256.New, s.nameKey)
+	mac.Write([]byte(name))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// diskName returns the store-relative path an entry is kept under
+func (s *Store) diskName(name string) string {
+	if s.HiddenNames() {
+		return s.hashName(name)
+	}
+	return name
+}
+
+// EntryFile returns the file of an entry relative to the store root, with
+// slashes, e.g. for looking up its Git history. The entry need not exist, so
+// the history of a removed one can still be found.
+func (s *Store) EntryFile(name string) (string, error) {
+	name, err := s.entryName(name)
+	if err != nil {
+		return "", err
+	}
+	return s.diskName(name) + ".enc", nil
+}
+
+// NameMap returns every entry name mapped to its file path relative to the
+// store root. It is only meaningful in hidden-names mode.
+func (s *Store) NameMap() (map[string]string, error) {
+	index, err := s.loadIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]string, len(index.Entries))
+	for name, disk := range index.Entries {
+		files[name] = disk + ".enc"
+	}
+	return files, nil
+}
+
+// HideNames migrates a store to hidden-names mode: every entry is moved to a
+// flat, HMAC-derived file name and the real names are recorded in an index
+// encrypted with the keyfile. Running it again resumes an interrupted
+// migration. It returns the number of entries migrated.
+func (s *Store) HideNames() (int, error) {
+	if err := s.requireWritable(); err != nil {
+		return 0, err
+	}
+
+	unlock, err := s.lockStore()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	index := &nameIndex{Entries: make(map[string]string)}
+	if s.HiddenNames() {
+		existing, err := s.loadIndex()
+		if err != nil {
+			return 0, err
+		}
+		index = existing
+	} else {
+		key, err := s.crypto.NameKey()
+		if err != nil {
+			return 0, fmt.Errorf("failed to derive name key: %w", err)
+		}
+		s.nameKey = key
+		s.hiddenNames = true
+	}
+
+	files, err := s.EntryFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	hashed := make(map[string]bool, len(index.Entries))
+	for _, disk := range index.Entries {
+		hashed[disk] = true
+	}
+
+	var names []string
+	for _, relPath := range files {
+		name := strings.TrimSuffix(filepath.ToSlash(relPath), ".enc")
+		if hashed[name] {
+			continue // Already migrated
+		}
+		names = append(names, name)
+		index.Entries[name] = s.hashName(name)
+	}
+
+	if len(names) == 0 && len(hashed) > 0 {
+		return 0, fmt.Errorf("entry names are already hidden")
+	}
+
+	// Record the names before moving anything, so an interrupted migration
+	// never leaves a hashed file without its name
+	if err := s.saveIndex(index); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, name := range names {
+		if err := s.moveEntryFiles(name, index.Entries[name]); err != nil {
+			return count, fmt.Errorf("failed to move %s: %w", name, err)
+		}
+		count++
+	}
+
+	if err := s.autoCommit("hide-names", "", "Hide entry names"); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+	}
+
+	return count, nil
+}
+
+// moveEntryFiles moves an entry, its shared copy and its history from the
+// plaintext layout under name to the hidden layout under disk
+func (s *Store) moveEntryFiles(name, disk string) error {
+	oldPath := filepath.Join(s.baseDir, name+".enc")
+	if err := os.Rename(oldPath, filepath.Join(s.baseDir, disk+".enc")); err != nil {
+		return err
+	}
+
+	oldShared := filepath.Join(s.baseDir, sharedDirName, name+".enc")
+	if _, err := os.Stat(oldShared); err == nil {
+		if err := os.Rename(oldShared, filepath.Join(s.baseDir, sharedDirName, disk+".enc")); err != nil {
+			return err
+		}
+		s.cleanupEmptyDirs(filepath.Dir(oldShared))
+	}
+
+	oldHistory := filepath.Join(s.baseDir, historyDirName, name)
+	if _, err := os.Stat(oldHistory); err == nil {
+		if err := os.Rename(oldHistory, filepath.Join(s.baseDir, historyDirName, disk)); err != nil {
+			return err
+		}
+		s.cleanupEmptyDirs(filepath.Dir(oldHistory))
+	}
+
+	s.cleanupEmptyDirs(filepath.Dir(oldPath))
+	s.syncMirrorEntry(name, true)
+	s.syncMirrorEntry(disk, false)
+	return nil
+}
+
+// addToIndex records entries in the name index, saving it once
+func (s *Store) addToIndex(names ...string) error {
+	if !s.HiddenNames() {
+		return nil
+	}
+
+	index, err := s.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	changed := false
+	for _, name := range names {
+		if _, ok := index.Entries[name]; !ok {
+			index.Entries[name] = s.hashName(name)
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.saveIndex(index)
+}
+
+// removeFromIndex drops entries from the name index, saving it once
+func (s *Store) removeFromIndex(names ...string) error {
+	if !s.HiddenNames() || len(names) == 0 {
+		return nil
+	}
+
+	index, err := s.loadIndex()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		delete(index.Entries, name)
+	}
+	return s.saveIndex(index)
+}
+
+// renameHidden renames an entry, or every entry under a folder, in
+// hidden-names mode. Folders only exist in the index, so only it and the
+// hashed files change.
+func (s *Store) renameHidden(oldName, newName string) error {
+	index, err := s.loadIndex()
+	if err != nil {
+		return err
+	}
+
+	renames := make(map[string]string)
+	if _, ok := index.Entries[oldName]; ok {
+		renames[oldName] = newName
+	} else {
+		for name := range index.Entries {
+			if strings.HasPrefix(name, oldName+"/") {
+				renames[name] = newName + strings.TrimPrefix(name, oldName)
+			}
+		}
+	}
+	if len(renames) == 0 {
+		return fmt.Errorf("password '%s' %w", oldName, ErrNotFound)
+	}
+
+	for _, to := range renames {
+		if _, ok := index.Entries[to]; ok {
+			return fmt.Errorf("password '%s' %w", to, ErrExists)
+		}
+	}
+
+	for from, to := range renames {
+		oldDisk, newDisk := index.Entries[from], s.hashName(to)
+
+		if err := os.Rename(filepath.Join(s.baseDir, oldDisk+".enc"), filepath.Join(s.baseDir, newDisk+".enc")); err != nil {
+			return fmt.Errorf("failed to rename: %w", err)
+		}
+		if _, err := os.Stat(filepath.Join(s.baseDir, sharedDirName, oldDisk+".enc")); err == nil {
+			os.Rename(filepath.Join(s.baseDir, sharedDirName, oldDisk+".enc"), filepath.Join(s.baseDir, sharedDirName, newDisk+".enc"))
+		}
+		if _, err := os.Stat(filepath.Join(s.baseDir, historyDirName, oldDisk)); err == nil {
+			os.Rename(filepath.Join(s.baseDir, historyDirName, oldDisk), filepath.Join(s.baseDir, historyDirName, newDisk))
+		}
+		s.syncMirrorEntry(oldDisk, true)
+		s.syncMirrorEntry(newDisk, false)
+
+		delete(index.Entries, from)
+		index.Entries[to] = newDisk
+	}
+
+	return s.saveIndex(index)
+}
+
+// loadIndex reads and decrypts the name index
+func (s *Store) loadIndex() (*nameIndex, error) {
+	encrypted, err := os.ReadFile(filepath.Join(s.baseDir, nameIndexFile))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read name index: %w", err)
+	}
+
+	data, err := s.crypto.DecryptWithKeyFile(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt name index: %w", err)
+	}
+
+	index := &nameIndex{}
+	if err := json.Unmarshal(data, index); err != nil {
+		return nil, fmt.Errorf("failed to parse name index: %w", err)
+	}
+	if index.Entries == nil {
+		index.Entries = make(map[string]string)
+	}
+	return index, nil
+}
+
+// saveIndex encrypts and writes the name index
+func (s *Store) saveIndex(index *nameIndex) error {
+	data, err := json.Marshal(index)
+	if err != nil {
+		return fmt.Errorf("failed to encode name index: %w", err)
+	}
+
+	encrypted, err := s.crypto.EncryptWithKeyFile(data)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt name index: %w", err)
+	}
+
+	if err := WriteFileAtomic(filepath.Join(s.baseDir, nameIndexFile), encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to write name index: %w", err)
+	}
+	s.syncMirror(nameIndexFile, false)
+	return nil
+}
@@ -0,0 +1,253 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const historyDirName = ".history"
+
+// historyTimeFormat names version files so they sort chronologically
+const historyTimeFormat = "20060102-150405.000000000"
+
+// saveHistory copies the current encrypted version of an entry into
+// .history/<name>/ before it is overwritten, then trims the entry's history
+// to the configured depth. Versions stay encrypted with the key they were
+// written with.
+func (s *Store) saveHistory(name string) error {
+	if s.historyDepth <= 0 {
+		return nil
+	}
+
+	current, err := os.ReadFile(s.getPasswordFilePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil // Nothing to keep yet
+		}
+		return err
+	}
+
+	historyDir := s.getHistoryDir(name)
+	if err := os.MkdirAll(historyDir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+
+	versionPath := filepath.Join(historyDir, time.Now().UTC().Format(historyTimeFormat)+".enc")
+	if err := WriteFileAtomic(versionPath, current, 0600); err != nil {
+		return err
+	}
+
+	_, err = s.pruneHistoryDir(historyDir, s.historyDepth)
+	return err
+}
+
+// Versions returns the stored history versions of an entry, oldest first
+func (s *Store) Versions(name string) ([]string, error) {
+	name, err := s.entryName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return listVersions(s.getHistoryDir(name))
+}
+
+// PruneHistory trims the history of a single entry to the configured depth
+// and returns the number of versions removed
+func (s *Store) PruneHistory(name string) (int, error) {
+	if err := s.requireWritable(); err != nil {
+		return 0, err
+	}
+
+	name, err := s.entryName(name)
+	if err != nil {
+		return 0, err
+	}
+
+	return s.pruneHistoryDir(s.getHistoryDir(name), s.historyDepth)
+}
+
+// PruneAllHistory trims the history of every entry to the configured depth
+// and returns the number of versions removed
+func (s *Store) PruneAllHistory() (int, error) {
+	if err := s.requireWritable(); err != nil {
+		return 0, err
+	}
+
+	root := filepath.Join(s.baseDir, historyDirName)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return 0, nil
+	}
+
+	// Version files live directly in a per-entry directory, so every
+	// directory holding .enc files is one entry's history
+	historyDirs := make(map[string]bool)
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".enc") {
+			historyDirs[filepath.Dir(path)] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to walk history: %w", err)
+	}
+
+	removed := 0
+	for dir := range historyDirs {
+		count, err := s.pruneHistoryDir(dir, s.historyDepth)
+		removed += count
+		if err != nil {
+			return removed, err
+		}
+	}
+	return removed, nil
+}
+
+// pruneHistoryDir removes all but the newest depth versions in a history directory
+func (s *Store) pruneHistoryDir(dir string, depth int) (int, error) {
+	versions, err := listVersions(dir)
+	if err != nil {
+		return 0, err
+	}
+	if len(versions) <= depth {
+		return 0, nil
+	}
+
+	removed := 0
+	for _, version := range versions[:len(versions)-depth] {
+		if err := os.Remove(filepath.Join(dir, version+".enc")); err != nil {
+			return removed, fmt.Errorf("failed to remove history version %s: %w", version, err)
+		}
+		removed++
+	}
+
+	// Drop the directory once it is empty; errors just mean it isn't
+	os.Remove(dir)
+
+	return removed, nil
+}
+
+// listVersions returns the version names in a history directory, oldest first
+func listVersions(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	var versions []string
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".enc") {
+			versions = append(versions, strings.TrimSuffix(entry.Name(), ".enc"))
+		}
+	}
+	sort.Strings(versions)
+	return versions, nil
+}
+
+// renameHistory moves the history of an entry, or of every entry in a
+// folder, along with a rename
+func (s *Store) renameHistory(oldName, newName string, isDir, caseOnly bool) error {
+	if !isDir {
+		return s.moveVersions(oldName, newName)
+	}
+
+	oldDir := filepath.Join(s.baseDir, historyDirName, oldName)
+	newDir := filepath.Join(s.baseDir, historyDirName, newName)
+	if _, err := os.Stat(oldDir); err != nil {
+		return nil // No history
+	}
+	if err := os.MkdirAll(filepath.Dir(newDir), 0700); err != nil {
+		return err
+	}
+	if err := renamePath(oldDir, newDir, caseOnly); err != nil {
+		return err
+	}
+	if !caseOnly {
+		s.cleanupEmptyDirs(filepath.Dir(oldDir))
+	}
+	return nil
+}
+
+// moveVersions moves the versions of a single entry to a new name. Only
+// version files move: a folder with the same name as the entry keeps the
+// history of its own entries in subdirectories.
+func (s *Store) moveVersions(oldName, newName string) error {
+	oldDir, newDir := s.getHistoryDir(oldName), s.getHistoryDir(newName)
+	versions, err := listVersions(oldDir)
+	if err != nil || len(versions) == 0 {
+		return err
+	}
+
+	if err := os.MkdirAll(newDir, 0700); err != nil {
+		return fmt.Errorf("failed to create history directory: %w", err)
+	}
+	for _, version := range versions {
+		if err := os.Rename(filepath.Join(oldDir, version+".enc"), filepath.Join(newDir, version+".enc")); err != nil {
+			return fmt.Errorf("failed to move history version %s: %w", version, err)
+		}
+	}
+
+	s.cleanupEmptyDirs(oldDir)
+	return nil
+}
+
+// removeHistory deletes the versions of an entry, so a new entry created
+// later under the same name does not inherit them
+func (s *Store) removeHistory(name string) error {
+	dir := s.getHistoryDir(name)
+	versions, err := listVersions(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, version := range versions {
+		if err := os.Remove(filepath.Join(dir, version+".enc")); err != nil {
+			return fmt.Errorf("failed to remove history version %s: %w", version, err)
+		}
+	}
+
+	s.cleanupEmptyDirs(dir)
+	return nil
+}
+
+// historyFiles returns the paths of all history versions, relative to the
+// store root
+func (s *Store) historyFiles() ([]string, error) {
+	root := filepath.Join(s.baseDir, historyDirName)
+	if _, err := os.Stat(root); os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	var files []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && strings.HasSuffix(info.Name(), ".enc") {
+			relPath, err := filepath.Rel(s.baseDir, path)
+			if err != nil {
+				return err
+			}
+			files = append(files, relPath)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to walk history: %w", err)
+	}
+	return files, nil
+}
+
+// getHistoryDir returns the directory holding the history of an entry
+func (s *Store) getHistoryDir(name string) string {
+	return filepath.Join(s.baseDir, historyDirName, s.diskName(name))
+}
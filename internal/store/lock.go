@@ -0,0 +1,74 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// storeLockFile is held by bulk operations and Git commits, so two
+// chowkidaar processes never rewrite the store at the same time. It is
+// separate from the cache lock and lives in the git-ignored .cache directory.
+const storeLockFile = ".cache/store.lock"
+
+// ErrStoreLocked is returned when another process holds the store lock
+var ErrStoreLocked = errors.New("store is locked by another process")
+
+// lockStore takes the store lock without waiting and returns the function
+// that releases it. Nested calls, such as a bulk operation's own commit,
+// share the lock. The lock also ends with the process, however it exits.
+func (s *Store) lockStore() (func(), error) {
+	if s.storeLocks > 0 {
+		s.storeLocks++
+		return func() { s.storeLocks-- }, nil
+	}
+
+	path := filepath.Join(s.baseDir, storeLockFile)
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open store lock: %w", err)
+	}
+
+	locked, err := tryLock(file)
+	if err != nil {
+		file.Close()
+		return nil, fmt.Errorf("failed to lock store: %w", err)
+	}
+	if !locked {
+		holder, _ := io.ReadAll(file)
+		file.Close()
+		if pid := strings.TrimSpace(string(holder)); pid != "" {
+			return nil, fmt.Errorf("%w (pid %s); if it is no longer running, retry with --force-unlock", ErrStoreLocked, pid)
+		}
+		return nil, fmt.Errorf("%w; if it is no longer running, retry with --force-unlock", ErrStoreLocked)
+	}
+
+	// Record the holder for the error other processes report
+	file.Truncate(0)
+	file.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+
+	s.storeLocks = 1
+	return func() {
+		s.storeLocks--
+		if s.storeLocks == 0 {
+			file.Truncate(0)
+			file.Close()
+		}
+	}, nil
+}
+
+// ForceUnlock removes the store lock file, so a lock still held by a hung
+// process no longer blocks this one. It is a no-op without a lock file.
+func (s *Store) ForceUnlock() error {
+	if err := os.Remove(filepath.Join(s.baseDir, storeLockFile)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove store lock: %w", err)
+	}
+	return nil
+}
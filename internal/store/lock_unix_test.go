@@ -0,0 +1,57 @@
+//go:build unix
+
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStoreLock(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "a", "b")
+
+	// Nested locks within one process share it
+	unlock, err := s.lockStore()
+	if err != nil {
+		t.Fatalf("lockStore: %v", err)
+	}
+	unlockNested, err := s.lockStore()
+	if err != nil {
+		t.Fatalf("nested lockStore: %v", err)
+	}
+	unlockNested()
+	unlock()
+
+	// Another process holding the lock, through its own open file
+	path := filepath.Join(s.baseDir, storeLockFile)
+	other, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+	if locked, err := tryLock(other); err != nil || !locked {
+		t.Fatalf("tryLock = %v, %v", locked, err)
+	}
+	other.WriteString("4242\n")
+
+	_, err = s.RemoveBatch([]string{"a"}, false)
+	if !errors.Is(err, ErrStoreLocked) {
+		t.Fatalf("RemoveBatch while locked = %v, want ErrStoreLocked", err)
+	}
+	if got := err.Error(); got != "store is locked by another process (pid 4242); if it is no longer running, retry with --force-unlock" {
+		t.Errorf("error = %q", got)
+	}
+	if !s.Exists("a") {
+		t.Error("RemoveBatch removed an entry while the store was locked")
+	}
+
+	if err := s.ForceUnlock(); err != nil {
+		t.Fatalf("ForceUnlock: %v", err)
+	}
+	if _, err := s.RemoveBatch([]string{"a"}, false); err != nil {
+		t.Fatalf("RemoveBatch after ForceUnlock: %v", err)
+	}
+}
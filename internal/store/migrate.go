@@ -0,0 +1,195 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// storeVersionFile records the on-disk format version of a store. Stores
+// from before it existed are at version 1.
+const storeVersionFile = ".store-version"
+
+// CurrentStoreVersion is the format version written by this chowkidaar
+const CurrentStoreVersion = 2
+
+// Migration upgrades a store from the version before it to Version. Every
+// step skips what is already done, so an interrupted one can be run again.
+type Migration struct {
+	Version     int    `json:"version"`
+	Description string `json:"description"`
+
+	apply func(s *Store, ctx context.Context, masterPassword string) (int, error)
+}
+
+// migrations lists every format change in order
+var migrations = []Migration{
+	{
+		Version:     2,
+		Description: "add encryption headers to entries and history written before headers existed",
+		apply:       (*Store).migrateHeaders,
+	},
+}
+
+// WriteStoreVersion records that a new store at storeDir is in the current
+// format, so that it never needs migrating
+func WriteStoreVersion(storeDir string) error {
+	return writeStoreVersion(storeDir, CurrentStoreVersion)
+}
+
+// writeStoreVersion records the format version of the store at storeDir
+func writeStoreVersion(storeDir string, version int) error {
+	path := filepath.Join(storeDir, storeVersionFile)
+	if err := WriteFileAtomic(path, []byte(strconv.Itoa(version)+"\n"), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", storeVersionFile, err)
+	}
+	return nil
+}
+
+// StoreVersion returns the on-disk format version of the store
+func (s *Store) StoreVersion() (int, error) {
+	data, err := os.ReadFile(filepath.Join(s.baseDir, storeVersionFile))
+	if os.IsNotExist(err) {
+		return 1, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read %s: %w", storeVersionFile, err)
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil || version < 1 {
+		return 0, fmt.Errorf("%s holds an invalid version %q", storeVersionFile, strings.TrimSpace(string(data)))
+	}
+	return version, nil
+}
+
+// PendingMigrations returns the migrations the store still needs, in the
+// order Migrate applies them. A store written by a newer chowkidaar is an
+// error rather than something to downgrade.
+func (s *Store) PendingMigrations() ([]Migration, error) {
+	version, err := s.StoreVersion()
+	if err != nil {
+		return nil, err
+	}
+	if version > CurrentStoreVersion {
+		return nil, fmt.Errorf("store format version %d is newer than the %d this chowkidaar supports; upgrade chowkidaar", version, CurrentStoreVersion)
+	}
+
+	var pending []Migration
+	for _, migration := range migrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies the pending migrations in order and bumps the recorded
+// version after each, so that stopping early through ctx or an error keeps
+// the steps already done. It returns the migrations applied and the number
+// of files they rewrote, which are committed together.
+func (s *Store) Migrate(ctx context.Context, masterPassword string) ([]Migration, int, error) {
+	if err := s.requireWritable(); err != nil {
+		return nil, 0, err
+	}
+
+	unlock, err := s.lockStore()
+	if err != nil {
+		return nil, 0, err
+	}
+	defer unlock()
+
+	pending, err := s.PendingMigrations()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(pending) == 0 {
+		return nil, 0, nil
+	}
+
+	if err := s.validatePasswordIfNeeded(masterPassword); err != nil {
+		return nil, 0, fmt.Errorf("password validation failed: %w", err)
+	}
+
+	var applied []Migration
+	rewritten := 0
+	target := pending[0].Version
+	for _, migration := range pending {
+		target = migration.Version
+		count, applyErr := migration.apply(s, ctx, masterPassword)
+		rewritten += count
+		if applyErr == nil {
+			applyErr = writeStoreVersion(s.baseDir, migration.Version)
+		}
+		if applyErr != nil {
+			err = fmt.Errorf("migration to version %d: %w", migration.Version, applyErr)
+			break
+		}
+		applied = append(applied, migration)
+	}
+
+	if len(applied) > 0 || rewritten > 0 {
+		message := fmt.Sprintf("Migrate store to format version %d", target)
+		if err != nil {
+			message = fmt.Sprintf("Partly migrate store to format version %d", target)
+		}
+		if commitErr := s.autoCommit("migrate", fmt.Sprintf("version %d", target), message); commitErr != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", commitErr)
+		}
+	}
+
+	return applied, rewritten, err
+}
+
+// migrateHeaders re-encrypts the entries and history versions that have no
+// header, keeping the master password. History written under an older
+// master password is left alone, as ChangeMasterPassword does.
+func (s *Store) migrateHeaders(ctx context.Context, masterPassword string) (int, error) {
+	files, err := s.EntryFiles()
+	if err != nil {
+		return 0, err
+	}
+	history, err := s.historyFiles()
+	if err != nil {
+		return 0, err
+	}
+
+	total := len(files) + len(history)
+	rewritten := 0
+	for i, relPath := range append(files, history...) {
+		s.reportProgress(i, total)
+		if err := ctx.Err(); err != nil {
+			return rewritten, err
+		}
+
+		encrypted, err := os.ReadFile(filepath.Join(s.baseDir, relPath))
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to read %s: %w", relPath, err)
+		}
+		if s.crypto.HasHeader(encrypted) {
+			continue
+		}
+
+		decrypted, err := s.crypto.Decrypt(encrypted, masterPassword)
+		if err != nil && i >= len(files) {
+			fmt.Fprintf(os.Stderr, "Warning: skipping history version %s: %v\n", relPath, err)
+			continue
+		}
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to decrypt %s: %w", relPath, err)
+		}
+		reencrypted, err := s.crypto.Encrypt(decrypted, masterPassword)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to encrypt %s: %w", relPath, err)
+		}
+		if err := s.writeFile(relPath, reencrypted); err != nil {
+			return rewritten, err
+		}
+		rewritten++
+	}
+	s.reportProgress(total, total)
+	return rewritten, nil
+}
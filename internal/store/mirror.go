@@ -0,0 +1,113 @@
+package store
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SetMirrorDir makes every change to an entry also update its ciphertext
+// under dir, laid out as in the store. The mirror must not be inside the
+// store, where its copies would be taken for entries.
+func (s *Store) SetMirrorDir(dir string) error {
+	if dir == "" {
+		s.mirrorDir = ""
+		return nil
+	}
+
+	absMirror, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	absStore, err := filepath.Abs(s.baseDir)
+	if err != nil {
+		return err
+	}
+	if rel, err := filepath.Rel(absStore, absMirror); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return fmt.Errorf("mirror directory %s must be outside the store", dir)
+	}
+
+	s.mirrorDir = absMirror
+	return nil
+}
+
+// syncMirror copies a file or folder, relative to the store root, to the
+// mirror directory, or deletes it there when removed is set or it no longer
+// exists in the store. Only entries, shared copies and the name index are
+// mirrored. A failure is printed as a warning, since the change itself has
+// been made.
+func (s *Store) syncMirror(relPath string, removed bool) {
+	if s.mirrorDir == "" || !isMirrored(relPath) {
+		return
+	}
+
+	if err := s.mirrorPath(relPath, removed); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to mirror %s to %s: %v\n", filepath.ToSlash(relPath), s.mirrorDir, err)
+	}
+}
+
+// syncMirrorEntry mirrors the entry file and shared copy stored under disk
+func (s *Store) syncMirrorEntry(disk string, removed bool) {
+	s.syncMirror(disk+".enc", removed)
+	s.syncMirror(filepath.Join(sharedDirName, disk+".enc"), removed)
+}
+
+// isMirrored reports whether a path relative to the store root holds
+// ciphertext worth mirroring, rather than history, Git or cache files
+func isMirrored(relPath string) bool {
+	first := strings.SplitN(filepath.ToSlash(relPath), "/", 2)[0]
+	return first == sharedDirName || first == nameIndexFile || !strings.HasPrefix(first, ".")
+}
+
+// mirrorPath brings the mirror's copy of relPath in line with the store
+func (s *Store) mirrorPath(relPath string, removed bool) error {
+	target := filepath.Join(s.mirrorDir, relPath)
+	source := filepath.Join(s.baseDir, relPath)
+
+	if _, err := os.Stat(source); os.IsNotExist(err) {
+		removed = true
+	}
+	if removed {
+		if err := os.RemoveAll(target); err != nil {
+			return err
+		}
+		s.cleanupMirrorDirs(filepath.Dir(target))
+		return nil
+	}
+
+	return filepath.WalkDir(source, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(s.baseDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || (!strings.HasSuffix(d.Name(), ".enc") && rel != nameIndexFile) {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		dest := filepath.Join(s.mirrorDir, rel)
+		if err := os.MkdirAll(filepath.Dir(dest), 0700); err != nil {
+			return err
+		}
+		return WriteFileAtomic(dest, data, 0600)
+	})
+}
+
+// cleanupMirrorDirs removes dir and its parents inside the mirror while they
+// are empty
+func (s *Store) cleanupMirrorDirs(dir string) {
+	for dir != s.mirrorDir && strings.HasPrefix(dir, s.mirrorDir+string(filepath.Separator)) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+		dir = filepath.Dir(dir)
+	}
+}
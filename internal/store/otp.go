@@ -0,0 +1,296 @@
+package store
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// otpField is the entry field holding an otpauth:// URI, as in the login template
+const otpField = "otp"
+
+// TOTP algorithms accepted in otpauth:// URIs and by otp import --algo
+const (
+	TOTPSHA1   = "SHA1"
+	TOTPSHA256 = "SHA256"
+	TOTPSHA512 = "SHA512"
+)
+
+// TOTPOptions are the otpauth:// parameters besides the secret. The zero
+// value means the defaults nearly every service uses: SHA1, 6 digits and a
+// 30 second period.
+type TOTPOptions struct {
+	Algorithm string // TOTPSHA1, TOTPSHA256 or TOTPSHA512
+	Digits    int    // Length of a code, 6 to 8
+	Period    int    // Seconds each code is valid
+}
+
+// normalize fills in defaults and validates the options
+func (o TOTPOptions) normalize() (TOTPOptions, error) {
+	o.Algorithm = strings.ToUpper(strings.ReplaceAll(o.Algorithm, "-", ""))
+	switch o.Algorithm {
+	case "":
+		o.Algorithm = TOTPSHA1
+	case TOTPSHA1, TOTPSHA256, TOTPSHA512:
+	default:
+		return o, fmt.Errorf("unknown TOTP algorithm '%s' (use SHA1, SHA256 or SHA512)", o.Algorithm)
+	}
+
+	if o.Digits == 0 {
+		o.Digits = 6
+	}
+	if o.Digits < 6 || o.Digits > 8 {
+		return o, fmt.Errorf("TOTP codes must have 6 to 8 digits, not %d", o.Digits)
+	}
+
+	if o.Period == 0 {
+		o.Period = 30
+	}
+	if o.Period < 0 {
+		return o, fmt.Errorf("invalid TOTP period %d", o.Period)
+	}
+	return o, nil
+}
+
+// Validate reports invalid options, e.g. 9 digits
+func (o TOTPOptions) Validate() error {
+	_, err := o.normalize()
+	return err
+}
+
+// hash returns the HMAC hash function of the algorithm
+func (o TOTPOptions) hash() func() hash.Hash {
+	switch o.Algorithm {
+	case TOTPSHA256:
+		return sha256.New
+	case TOTPSHA512:
+		return sha512.New
+	default:
+		return sha1.New
+	}
+}
+
+// NormalizeTOTPSecret checks that secret is base32, as authenticator apps
+// show it, and returns it uppercased without spaces, dashes or padding
+func NormalizeTOTPSecret(secret string) (string, error) {
+	normalized := strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(secret))
+	if normalized == "" {
+		return "", fmt.Errorf("TOTP secret is empty")
+	}
+	decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(normalized)
+	if err != nil || len(decoded) == 0 {
+		return "", fmt.Errorf("TOTP secret is not valid base32")
+	}
+	return normalized, nil
+}
+
+// TOTPURI builds an otpauth://totp/ URI for a base32 secret. The label is
+// "issuer:account", or just the account without an issuer. Options other
+// than the defaults are recorded as algorithm, digits and period.
+func TOTPURI(secret, issuer, account string, opts TOTPOptions) (string, error) {
+	secret, err := NormalizeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	if opts, err = opts.normalize(); err != nil {
+		return "", err
+	}
+
+	label := account
+	if issuer != "" {
+		label = issuer + ":" + account
+	}
+	query := url.Values{"secret": {secret}}
+	if issuer != "" {
+		query.Set("issuer", issuer)
+	}
+	if opts.Algorithm != TOTPSHA1 {
+		query.Set("algorithm", opts.Algorithm)
+	}
+	if opts.Digits != 6 {
+		query.Set("digits", strconv.Itoa(opts.Digits))
+	}
+	if opts.Period != 30 {
+		query.Set("period", strconv.Itoa(opts.Period))
+	}
+	return "otpauth://totp/" + url.PathEscape(label) + "?" + query.Encode(), nil
+}
+
+// ParseTOTP reads the value of an otp field: an otpauth://totp/ URI, or a
+// bare base32 secret that uses the default options. It returns the decoded
+// secret and the normalized options.
+func ParseTOTP(value string) ([]byte, TOTPOptions, error) {
+	secret := value
+	var opts TOTPOptions
+
+	if strings.HasPrefix(value, "otpauth://") {
+		uri, err := url.Parse(value)
+		if err != nil {
+			return nil, opts, fmt.Errorf("invalid otpauth URI: %w", err)
+		}
+		if uri.Host != "totp" {
+			return nil, opts, fmt.Errorf("unsupported OTP type '%s', only totp is supported", uri.Host)
+		}
+		query := uri.Query()
+		secret = query.Get("secret")
+		opts.Algorithm = query.Get("algorithm")
+		for key, target := range map[string]*int{"digits": &opts.Digits, "period": &opts.Period} {
+			if raw := query.Get(key); raw != "" {
+				if *target, err = strconv.Atoi(raw); err != nil {
+					return nil, opts, fmt.Errorf("invalid %s '%s' in otpauth URI", key, raw)
+				}
+			}
+		}
+	}
+
+	secret, err := NormalizeTOTPSecret(secret)
+	if err != nil {
+		return nil, opts, err
+	}
+	if opts, err = opts.normalize(); err != nil {
+		return nil, opts, err
+	}
+	key, _ := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(secret)
+	return key, opts, nil
+}
+
+// TOTPCode computes the RFC 6238 code for key at time at, and how long it
+// stays valid
+func TOTPCode(key []byte, opts TOTPOptions, at time.Time) (string, time.Duration, error) {
+	opts, err := opts.normalize()
+	if err != nil {
+		return "", 0, err
+	}
+
+	period := int64(opts.Period)
+	counter := at.Unix() / period
+	remaining := time.Duration(period-at.Unix()%period) * time.Second
+	return hotp(key, uint64(counter), opts), remaining, nil
+}
+
+// hotp computes the RFC 4226 code of a counter with dynamic truncation
+func hotp(key []byte, counter uint64, opts TOTPOptions) string {
+	mac := hmac.New(opts.hash(), key)
+	binary.Write(mac, binary.BigEndian, counter)
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulus := uint32(1)
+	for i := 0; i < opts.Digits; i++ {
+		modulus *= 10
+	}
+	return fmt.Sprintf("%0*d", opts.Digits, value%modulus)
+}
+
+// ShowTOTP computes the current code of the TOTP secret in an entry's otp
+// field
+func (s *Store) ShowTOTP(name, masterPassword string) (string, time.Duration, error) {
+	value, err := s.ShowField(name, otpField, masterPassword)
+	if err != nil {
+		return "", 0, err
+	}
+	if value == "" {
+		return "", 0, fmt.Errorf("'%s' has no TOTP secret", name)
+	}
+
+	key, opts, err := ParseTOTP(value)
+	if err != nil {
+		return "", 0, fmt.Errorf("'%s': %w", name, err)
+	}
+	return TOTPCode(key, opts, time.Now())
+}
+
+// SetField sets one value of an entry: a field of a JSON entry, or the
+// "key: value" line of a plain entry, which is replaced or appended. The
+// previous version is kept in the history.
+func (s *Store) SetField(name, field, value, masterPassword string) error {
+	content, err := s.Show(name, masterPassword)
+	if err != nil {
+		return err
+	}
+
+	if IsJSONEntry(content) {
+		entry, err := ParseEntry(content)
+		if err != nil {
+			return err
+		}
+		if entry.Fields == nil {
+			entry.Fields = map[string]string{}
+		}
+		entry.Fields[field] = value
+		if content, err = entry.Encode(); err != nil {
+			return fmt.Errorf("failed to encode entry: %w", err)
+		}
+		return s.Update(name, content, masterPassword)
+	}
+
+	// The first line is the password and never a field
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	replaced := false
+	for i := 1; i < len(lines); i++ {
+		if key, _, ok := strings.Cut(lines[i], ":"); ok && key == field {
+			lines[i] = field + ": " + value
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		lines = append(lines, field+": "+value)
+	}
+	return s.Update(name, strings.Join(lines, "\n")+"\n", masterPassword)
+}
+
+// ImportTOTP validates a base32 secret and stores it in an existing entry as
+// an otpauth:// URI in its "otp" field. The account in the URI is the entry
+// name.
+func (s *Store) ImportTOTP(name, secret, issuer string, opts TOTPOptions, masterPassword string) error {
+	if !s.Exists(name) {
+		return fmt.Errorf("password '%s' %w", name, ErrNotFound)
+	}
+
+	uri, err := TOTPURI(secret, issuer, name, opts)
+	if err != nil {
+		return err
+	}
+	return s.SetField(name, otpField, uri, masterPassword)
+}
+
+// BareTOTPSecrets returns the entries whose "otp" field holds a bare base32
+// secret instead of an otpauth:// URI, mapped to that secret
+func (s *Store) BareTOTPSecrets(masterPassword string) (map[string]string, error) {
+	names, err := s.entryNames()
+	if err != nil {
+		return nil, err
+	}
+
+	bare := make(map[string]string)
+	for _, name := range names {
+		content, err := s.Show(name, masterPassword)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		entry, err := ParseEntry(content)
+		if err != nil {
+			continue
+		}
+		value, ok := entry.Field(otpField)
+		if !ok || value == "" || strings.HasPrefix(value, "otpauth://") {
+			continue
+		}
+		if _, err := NormalizeTOTPSecret(value); err == nil {
+			bare[name] = value
+		}
+	}
+	return bare, nil
+}
@@ -0,0 +1,140 @@
+package store
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions caps the "did you mean" list of a NotFoundError
+const maxSuggestions = 3
+
+// AmbiguousNameError is returned by Resolve when a partial name matches more
+// than one entry
+type AmbiguousNameError struct {
+	Name    string
+	Matches []string
+}
+
+func (e *AmbiguousNameError) Error() string {
+	return fmt.Sprintf("'%s' matches %d passwords: %s", e.Name, len(e.Matches), strings.Join(e.Matches, ", "))
+}
+
+// NotFoundError is returned by Resolve when nothing matches a name. It
+// carries the entries whose names are close to it, if any.
+type NotFoundError struct {
+	Name        string
+	Suggestions []string
+}
+
+func (e *NotFoundError) Error() string {
+	if len(e.Suggestions) == 0 {
+		return fmt.Sprintf("password '%s' does not exist", e.Name)
+	}
+	return fmt.Sprintf("password '%s' does not exist, did you mean %s?", e.Name, strings.Join(e.Suggestions, ", "))
+}
+
+// Unwrap makes a NotFoundError match ErrNotFound
+func (e *NotFoundError) Unwrap() error {
+	return ErrNotFound
+}
+
+// Resolve turns a possibly partial name into the name of an existing entry.
+// An exact match always wins. Otherwise names whose full path or last
+// component starts with name are tried, and then names containing it, all
+// ignoring case; "gm" resolves to "Email/gmail" if no other entry matches.
+func (s *Store) Resolve(name string) (string, error) {
+	normalized, err := NormalizeName(name)
+	if err != nil {
+		return "", err
+	}
+	// A .gpg entry resolves even when unreadable, so that Show can say why
+	if s.Exists(normalized) || (!s.HiddenNames() && s.isGPGEntry(normalized)) {
+		return normalized, nil
+	}
+
+	names, err := s.entryNames()
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(names)
+
+	query := strings.ToLower(normalized)
+	var prefixed, contained []string
+	for _, candidate := range names {
+		lower := strings.ToLower(candidate)
+		switch {
+		case strings.HasPrefix(lower, query) || strings.HasPrefix(path.Base(lower), query):
+			prefixed = append(prefixed, candidate)
+		case strings.Contains(lower, query):
+			contained = append(contained, candidate)
+		}
+	}
+
+	for _, matches := range [][]string{prefixed, contained} {
+		switch len(matches) {
+		case 0:
+			continue
+		case 1:
+			return matches[0], nil
+		default:
+			return "", &AmbiguousNameError{Name: normalized, Matches: matches}
+		}
+	}
+	return "", &NotFoundError{Name: normalized, Suggestions: suggestNames(query, names)}
+}
+
+// suggestNames returns the names whose full path or last component is within
+// a small edit distance of query, closest first
+func suggestNames(query string, names []string) []string {
+	maxDistance := 2
+	if len(query) < 3 {
+		maxDistance = 1
+	}
+
+	type suggestion struct {
+		name     string
+		distance int
+	}
+	var suggestions []suggestion
+	for _, name := range names {
+		lower := strings.ToLower(name)
+		distance := min(editDistance(query, lower), editDistance(query, path.Base(lower)))
+		if distance <= maxDistance {
+			suggestions = append(suggestions, suggestion{name, distance})
+		}
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].distance < suggestions[j].distance
+	})
+
+	var result []string
+	for i := 0; i < len(suggestions) && i < maxSuggestions; i++ {
+		result = append(result, suggestions[i].name)
+	}
+	return result
+}
+
+// editDistance returns the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	ar, br := []rune(a), []rune(b)
+	previous := make([]int, len(br)+1)
+	current := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(ar); i++ {
+		current[0] = i
+		for j := 1; j <= len(br); j++ {
+			cost := 1
+			if ar[i-1] == br[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(br)]
+}
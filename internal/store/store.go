@@ -2,21 +2,38 @@ package store
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
+	"io/fs"
 	"math/big"
 	"os"
 	"os/exec"
+	"path"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
+	"chowkidaar/internal/cache"
+	"chowkidaar/internal/config"
 	"chowkidaar/internal/crypto"
 	"chowkidaar/internal/gitsync"
+	"chowkidaar/internal/hooks"
 )
 
 const (
 	defaultCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	symbolCharset  = "!@#$%^&*()_+-=[]{}|;:,.<>?"
+
+	// Length of the password generated for {{password}} in templates
+	templatePasswordLength = 20
+
+	// sharedDirName holds the copies of entries encrypted under a shared
+	// secret, laid out like the entries themselves. Names cannot start with
+	// a dot, so it never collides with an entry.
+	sharedDirName = ".shared"
 )
 
 // Store represents a password store
@@ -25,8 +42,42 @@ type Store struct {
 	crypto   *crypto.Crypto
 	gitSync  *gitsync.GitSync
 	autoSync bool
+	hooks    map[string]string
+
+	historyDepth int
+	hiddenNames  bool   // Set when the store has a name index, see hidden.go
+	nameKey      []byte // Derived from the keyfile on first use
+
+	progress ProgressFunc // Told how far bulk operations are, see bulk.go
+
+	readOnlyOnce sync.Once
+	readOnly     bool // See IsReadOnly
+
+	masterPrompt string // See MasterPrompt
+
+	storeLocks int // Nesting depth of the held store lock, see lock.go
+
+	mirrorDir string // Receives a copy of every changed entry, see mirror.go
+
+	commitTemplate string // Auto-commit message template, see autoCommit
+
+	gpgRead bool // Show reads .gpg entries left by pass, see gpg.go
+
+	createDirs        string // See SetCreateDirs
+	newFoldersAllowed bool   // See AllowNewFolders
 }
 
+// ErrReadOnly is returned by operations that would write to a read-only store
+var ErrReadOnly = errors.New("store is read-only")
+
+// ErrNotFound is wrapped by errors about an entry that does not exist, and
+// ErrExists by errors about one that is in the way. Their text completes a
+// message, as in "password 'x' does not exist".
+var (
+	ErrNotFound = errors.New("does not exist")
+	ErrExists   = errors.New("already exists")
+)
+
 // New creates a new password store instance
 func New(baseDir string) (*Store, error) {
 	return NewWithConfig(baseDir, 5) // Default 5 minutes timeout
@@ -58,12 +109,50 @@ func NewWithGitConfig(baseDir string, cacheTimeoutMinutes int, gitURL string, au
 		gitSync = gitsync.NewGitSync(baseDir, gitURL)
 	}
 
-	return &Store{
+	s := &Store{
 		baseDir:  baseDir,
 		crypto:   cryptoHandler,
 		gitSync:  gitSync,
 		autoSync: autoSync,
-	}, nil
+	}
+
+	s.detectHiddenNames()
+
+	return s, nil
+}
+
+// NewFromConfig creates a new password store instance from the loaded configuration
+func NewFromConfig(cfg *config.Config) (*Store, error) {
+	s, err := NewWithGitConfig(cfg.StoreDir, cfg.CacheTimeout, cfg.GitURL, cfg.GitAutoSync)
+	if err != nil {
+		return nil, err
+	}
+
+	s.hooks = cfg.Hooks
+	if s.gitSync != nil {
+		s.gitSync.SetSigningKey(cfg.GitSignKey, cfg.GitSignStrict)
+		s.gitSync.SetTimeout(cfg.GitTimeout)
+	}
+	s.historyDepth = cfg.HistoryDepth
+	s.masterPrompt = cfg.MasterPrompt
+	s.commitTemplate = cfg.GitCommitTemplate
+	s.gpgRead = cfg.GPGRead
+	s.SetCreateDirs(cfg.CreateDirs)
+	if err := s.SetMirrorDir(cfg.MirrorDir); err != nil {
+		return nil, err
+	}
+	s.crypto.SetBanner(cfg.BannerTitle, cfg.BannerSubtitle)
+	s.crypto.SetBannerTemplate(cfg.BannerTemplate)
+	s.crypto.SetHideAsterisks(cfg.HideAsterisks)
+	return s, nil
+}
+
+// MasterPrompt returns the prompt to ask for this store's master password with
+func (s *Store) MasterPrompt() string {
+	if s.masterPrompt == "" {
+		return "Enter master password: "
+	}
+	return s.masterPrompt
 }
 
 // PromptMasterPassword prompts for the master password
@@ -71,8 +160,34 @@ func (s *Store) PromptMasterPassword(prompt string) (string, error) {
 	return s.crypto.PromptMasterPassword(prompt)
 }
 
+// PromptMasterPasswordNoCache prompts for the master password even when one
+// is cached, and keeps it out of the cache
+func (s *Store) PromptMasterPasswordNoCache(prompt string) (string, error) {
+	return s.crypto.PromptMasterPasswordNoCache(prompt)
+}
+
+// DisableCache keeps the master password out of the cache for the rest of
+// this process
+func (s *Store) DisableCache() {
+	s.crypto.DisableCache()
+}
+
+// VerifyMasterPassword checks the master password against the store and caches it on success
+func (s *Store) VerifyMasterPassword(masterPassword string) error {
+	return s.validatePasswordIfNeeded(masterPassword)
+}
+
 // Insert stores a new password
 func (s *Store) Insert(name, password, masterPassword string) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+
+	name, err := s.entryName(name)
+	if err != nil {
+		return err
+	}
+
 	// Validate password against existing encrypted files (if any)
 	if err := s.validatePasswordIfNeeded(masterPassword); err != nil {
 		return fmt.Errorf("password validation failed: %w", err)
@@ -80,12 +195,15 @@ func (s *Store) Insert(name, password, masterPassword string) error {
 
 	// Check if password already exists
 	if s.Exists(name) {
-		return fmt.Errorf("password '%s' already exists", name)
+		return fmt.Errorf("password '%s' %w", name, ErrExists)
 	}
 
 	filePath := s.getPasswordFilePath(name)
 
 	// Create directory if it doesn't exist
+	if err := s.checkNewFolder(name); err != nil {
+		return err
+	}
 	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
@@ -97,23 +215,39 @@ func (s *Store) Insert(name, password, masterPassword string) error {
 	}
 
 	// Write encrypted password to file
-	if err := os.WriteFile(filePath, encrypted, 0600); err != nil {
+	if err := WriteFileAtomic(filePath, encrypted, 0600); err != nil {
 		return fmt.Errorf("failed to write password file: %w", err)
 	}
+	s.syncMirror(s.diskName(name)+".enc", false)
+
+	if err := s.addToIndex(name); err != nil {
+		return err
+	}
 
 	// Cache the validated master password (encryption succeeded)
 	s.crypto.CachePassword(masterPassword)
 
 	// Auto-commit to Git if enabled
-	if err := s.autoCommit(fmt.Sprintf("Add password for %s", name)); err != nil {
-		fmt.Printf("Warning: failed to commit changes to Git: %v\n", err)
+	if err := s.autoCommit("add", s.diskName(name), fmt.Sprintf("Add password for %s", s.diskName(name))); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
 	}
 
+	hooks.Run(s.hooks, hooks.PostInsert, name)
+
 	return nil
 }
 
 // Update updates an existing password or creates a new one if it doesn't exist
 func (s *Store) Update(name, password, masterPassword string) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+
+	name, err := s.entryName(name)
+	if err != nil {
+		return err
+	}
+
 	// Validate password against existing encrypted files (if any)
 	if err := s.validatePasswordIfNeeded(masterPassword); err != nil {
 		return fmt.Errorf("password validation failed: %w", err)
@@ -122,6 +256,9 @@ func (s *Store) Update(name, password, masterPassword string) error {
 	filePath := s.getPasswordFilePath(name)
 
 	// Create directory if it doesn't exist
+	if err := s.checkNewFolder(name); err != nil {
+		return err
+	}
 	if err := os.MkdirAll(filepath.Dir(filePath), 0700); err != nil {
 		return fmt.Errorf("failed to create directory: %w", err)
 	}
@@ -132,31 +269,64 @@ func (s *Store) Update(name, password, masterPassword string) error {
 		return fmt.Errorf("failed to encrypt password: %w", err)
 	}
 
+	// Keep the previous version before overwriting it
+	if err := s.saveHistory(name); err != nil {
+		return fmt.Errorf("failed to save password history: %w", err)
+	}
+
 	// Write encrypted password to file (overwrite if exists)
-	if err := os.WriteFile(filePath, encrypted, 0600); err != nil {
+	if err := WriteFileAtomic(filePath, encrypted, 0600); err != nil {
 		return fmt.Errorf("failed to write password file: %w", err)
 	}
 
+	if err := s.addToIndex(name); err != nil {
+		return err
+	}
+
+	s.removeStaleShare(name)
+	s.syncMirrorEntry(s.diskName(name), false)
+
 	// Cache the validated master password (encryption succeeded)
 	s.crypto.CachePassword(masterPassword)
 
 	// Auto-commit to Git if enabled
-	if err := s.autoCommit(fmt.Sprintf("Update password for %s", name)); err != nil {
-		fmt.Printf("Warning: failed to commit changes to Git: %v\n", err)
+	if err := s.autoCommit("update", s.diskName(name), fmt.Sprintf("Update password for %s", s.diskName(name))); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
 	}
 
+	hooks.Run(s.hooks, hooks.PostInsert, name)
+
 	return nil
 }
 
-// Show retrieves and decrypts a password
+// Show retrieves and decrypts a password. The content is returned exactly as
+// stored, including any trailing newline an editor may have added; use
+// TrimSecret when the value is consumed by scripts.
 func (s *Store) Show(name, masterPassword string) (string, error) {
+	name, err := s.entryName(name)
+	if err != nil {
+		return "", err
+	}
+
 	filePath := s.getPasswordFilePath(name)
 
 	// Read encrypted password
 	encrypted, err := os.ReadFile(filePath)
 	if err != nil {
+		if os.IsNotExist(err) && !s.HiddenNames() {
+			if _, gpgErr := os.Stat(s.gpgFilePath(name)); gpgErr == nil {
+				if !s.gpgRead {
+					return "", fmt.Errorf("'%s' is a .gpg entry; set PASSWORD_STORE_GPG_READ=true to read it with gpg", name)
+				}
+				decrypted, err := decryptGPG(s.gpgFilePath(name))
+				if err != nil {
+					return "", fmt.Errorf("failed to decrypt password: %w", err)
+				}
+				return string(decrypted), nil
+			}
+		}
 		if os.IsNotExist(err) {
-			return "", fmt.Errorf("password '%s' does not exist", name)
+			return "", fmt.Errorf("password '%s' %w", name, ErrNotFound)
 		}
 		return "", fmt.Errorf("failed to read password file: %w", err)
 	}
@@ -173,6 +343,74 @@ func (s *Store) Show(name, masterPassword string) (string, error) {
 	return string(decrypted), nil
 }
 
+// ShareEntry writes a parallel copy of an entry encrypted with a shared secret
+// (.shared/name.enc) so others can read it without the owner's master password
+// or keyfile. The owner-encrypted entry is left untouched.
+func (s *Store) ShareEntry(name, sharedSecret, masterPassword string) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+
+	name, err := s.entryName(name)
+	if err != nil {
+		return err
+	}
+
+	if sharedSecret == "" {
+		return fmt.Errorf("shared secret cannot be empty")
+	}
+
+	password, err := s.Show(name, masterPassword)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := s.crypto.EncryptWithSecret([]byte(password), sharedSecret)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt shared copy: %w", err)
+	}
+
+	sharedPath := s.getSharedFilePath(name)
+	if err := os.MkdirAll(filepath.Dir(sharedPath), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := WriteFileAtomic(sharedPath, encrypted, 0600); err != nil {
+		return fmt.Errorf("failed to write shared file: %w", err)
+	}
+	s.syncMirror(filepath.Join(sharedDirName, s.diskName(name)+".enc"), false)
+
+	// Auto-commit to Git if enabled
+	if err := s.autoCommit("share", s.diskName(name), fmt.Sprintf("Share password for %s", s.diskName(name))); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+	}
+
+	return nil
+}
+
+// ShowShared decrypts the shared copy of an entry using the shared secret
+func (s *Store) ShowShared(name, sharedSecret string) (string, error) {
+	name, err := s.entryName(name)
+	if err != nil {
+		return "", err
+	}
+
+	encrypted, err := os.ReadFile(s.getSharedFilePath(name))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return "", fmt.Errorf("password '%s' has not been shared", name)
+		}
+		return "", fmt.Errorf("failed to read shared file: %w", err)
+	}
+
+	decrypted, err := s.crypto.DecryptWithSecret(encrypted, sharedSecret)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt shared password: %w", err)
+	}
+
+	return string(decrypted), nil
+}
+
 // Generate creates and stores a new random password
 func (s *Store) Generate(name string, length int, noSymbols bool, inPlace bool, masterPassword string) (string, error) {
 	charset := defaultCharset
@@ -220,31 +458,325 @@ func (s *Store) List(subfolder string) error {
 
 // Exists checks if a password exists
 func (s *Store) Exists(name string) bool {
+	name, err := s.entryName(name)
+	if err != nil {
+		return false
+	}
+
 	filePath := s.getPasswordFilePath(name)
-	_, err := os.Stat(filePath)
+	_, err = os.Stat(filePath)
 	return !os.IsNotExist(err)
 }
 
-// Remove deletes a password
-func (s *Store) Remove(name string) error {
+// ModTime returns when an entry was last written
+func (s *Store) ModTime(name string) (time.Time, error) {
+	name, err := s.entryName(name)
+	if err != nil {
+		return time.Time{}, err
+	}
+	info, err := os.Stat(s.getPasswordFilePath(name))
+	if os.IsNotExist(err) {
+		return time.Time{}, fmt.Errorf("password '%s' %w", name, ErrNotFound)
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	return info.ModTime(), nil
+}
+
+// MissingFolder returns the first folder on the way to an entry that does
+// not exist yet, e.g. "Emial" for "Emial/gmail", or "" when the entry goes
+// into existing folders
+func (s *Store) MissingFolder(name string) (string, error) {
+	name, err := NormalizeName(name)
+	if err != nil {
+		return "", err
+	}
+	dir := path.Dir(name)
+	if dir == "." {
+		return "", nil
+	}
+
+	// Folders of a store with hidden names only exist in the name index
+	var names []string
+	if s.HiddenNames() {
+		if names, err = s.entryNames(); err != nil {
+			return "", err
+		}
+	}
+
+	parts := strings.Split(dir, "/")
+	for i := range parts {
+		folder := strings.Join(parts[:i+1], "/")
+		if s.HiddenNames() {
+			if !slices.ContainsFunc(names, func(n string) bool { return strings.HasPrefix(n, folder+"/") }) {
+				return folder, nil
+			}
+		} else if info, err := os.Stat(filepath.Join(s.baseDir, filepath.FromSlash(folder))); err != nil || !info.IsDir() {
+			return folder, nil
+		}
+	}
+	return "", nil
+}
+
+// SetCreateDirs sets whether writing an entry may create missing folders,
+// one of the config.CreateDirs values. Under confirm and never a new folder
+// is refused unless AllowNewFolders was called, e.g. after asking the user.
+func (s *Store) SetCreateDirs(mode string) {
+	s.createDirs = mode
+}
+
+// AllowNewFolders lets the following writes create missing folders whatever
+// SetCreateDirs says
+func (s *Store) AllowNewFolders() {
+	s.newFoldersAllowed = true
+}
+
+// checkNewFolder refuses to write an entry into a folder that does not exist
+// yet, unless folder creation is allowed
+func (s *Store) checkNewFolder(name string) error {
+	if s.createDirs == "" || s.createDirs == config.CreateDirsAlways || s.newFoldersAllowed {
+		return nil
+	}
+	folder, err := s.MissingFolder(name)
+	if err != nil || folder == "" {
+		return err
+	}
+	return fmt.Errorf("folder '%s' does not exist and PASSWORD_STORE_CREATE_DIRS is %s", folder, s.createDirs)
+}
+
+// Remove deletes a password. Folders left empty by the removal are deleted
+// as well unless keepEmptyDirs is set.
+func (s *Store) Remove(name string, keepEmptyDirs bool) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+
+	name, err := s.entryName(name)
+	if err != nil {
+		return err
+	}
+
+	if err := s.removeEntryFiles(name, keepEmptyDirs); err != nil {
+		return err
+	}
+
+	if err := s.removeFromIndex(name); err != nil {
+		return err
+	}
+
+	// Auto-commit to Git if enabled
+	if err := s.autoCommit("remove", s.diskName(name), fmt.Sprintf("Remove password for %s", s.diskName(name))); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+	}
+
+	hooks.Run(s.hooks, hooks.PostRemove, name)
+
+	return nil
+}
+
+// removeEntryFiles deletes an entry with its shared copy and history. The
+// name index is left to the caller. keepEmptyDirs only applies to the
+// entry's own folders; emptied .shared and .history folders always go.
+func (s *Store) removeEntryFiles(name string, keepEmptyDirs bool) error {
 	filePath := s.getPasswordFilePath(name)
 
 	if _, err := os.Stat(filePath); os.IsNotExist(err) {
-		return fmt.Errorf("password '%s' does not exist", name)
+		return fmt.Errorf("password '%s' %w", name, ErrNotFound)
 	}
 
 	if err := os.Remove(filePath); err != nil {
 		return fmt.Errorf("failed to remove password file: %w", err)
 	}
 
-	// Remove empty directories
-	s.cleanupEmptyDirs(filepath.Dir(filePath))
+	// Remove the shared copy too, if there is one
+	if err := os.Remove(s.getSharedFilePath(name)); err == nil {
+		s.cleanupEmptyDirs(filepath.Dir(s.getSharedFilePath(name)))
+	}
+
+	if err := s.removeHistory(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove password history: %v\n", err)
+	}
+	s.syncMirrorEntry(s.diskName(name), true)
+
+	if !keepEmptyDirs {
+		s.cleanupEmptyDirs(filepath.Dir(filePath))
+	}
+	return nil
+}
+
+// Rename moves a password or a whole directory of passwords to a new name.
+// Case-only renames (Email -> email) go through a temporary name so they also
+// work on case-insensitive filesystems, where both names refer to the same path.
+func (s *Store) Rename(oldName, newName string) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+
+	oldName, err := s.entryName(oldName)
+	if err != nil {
+		return err
+	}
+	newName, err = s.entryName(newName)
+	if err != nil {
+		return err
+	}
+
+	if s.HiddenNames() {
+		if err := s.renameHidden(oldName, newName); err != nil {
+			return err
+		}
+		if err := s.autoCommit("rename", "", "Rename entries"); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+		}
+		s.runRenameHook(newName)
+		return nil
+	}
+
+	oldPath := s.getPasswordFilePath(oldName)
+	newPath := s.getPasswordFilePath(newName)
+	isDir := false
+
+	if _, err := os.Stat(oldPath); os.IsNotExist(err) {
+		// Not a password, try a directory
+		dirPath := filepath.Join(s.baseDir, oldName)
+		if info, err := os.Stat(dirPath); err != nil || !info.IsDir() {
+			return fmt.Errorf("password '%s' %w", oldName, ErrNotFound)
+		}
+		oldPath = dirPath
+		newPath = filepath.Join(s.baseDir, newName)
+		isDir = true
+	}
+
+	if filepath.Clean(oldPath) == filepath.Clean(newPath) {
+		return fmt.Errorf("'%s' and '%s' are the same", oldName, newName)
+	}
+
+	// On case-sensitive filesystems the new name may be a different, existing entry
+	caseOnly := strings.EqualFold(filepath.Clean(oldPath), filepath.Clean(newPath))
+	if newInfo, err := os.Stat(newPath); err == nil {
+		oldInfo, err := os.Stat(oldPath)
+		if !caseOnly || err != nil || !os.SameFile(oldInfo, newInfo) {
+			return fmt.Errorf("password '%s' %w", newName, ErrExists)
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// A single entry goes through the Git index so its history follows it
+	if s.gitSync != nil && s.gitSync.IsGitEnabled() && !isDir && !caseOnly {
+		err = s.gitSync.MoveFile(oldPath, newPath)
+	} else {
+		err = renamePath(oldPath, newPath, caseOnly)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to rename: %w", err)
+	}
+
+	// Keep shared copies and history with their entries
+	if err := s.renameShared(oldName, newName, isDir, caseOnly); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to move shared copy: %v\n", err)
+	}
+	if err := s.renameHistory(oldName, newName, isDir, caseOnly); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to move password history: %v\n", err)
+	}
+	if oldRel, err := filepath.Rel(s.baseDir, oldPath); err == nil {
+		newRel, _ := filepath.Rel(s.baseDir, newPath)
+		s.syncMirror(oldRel, true)
+		s.syncMirror(filepath.Join(sharedDirName, oldRel), true)
+		s.syncMirror(newRel, false)
+		s.syncMirror(filepath.Join(sharedDirName, newRel), false)
+	}
+
+	if !caseOnly {
+		s.cleanupEmptyDirs(filepath.Dir(oldPath))
+	}
 
 	// Auto-commit to Git if enabled
-	if err := s.autoCommit(fmt.Sprintf("Remove password for %s", name)); err != nil {
-		fmt.Printf("Warning: failed to commit changes to Git: %v\n", err)
+	if err := s.autoCommit("rename", oldName+" to "+newName, fmt.Sprintf("Rename %s to %s", oldName, newName)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
 	}
 
+	s.runRenameHook(newName)
+
+	return nil
+}
+
+// runRenameHook runs the post_insert hook for the entries now at newName,
+// which is an entry or a folder
+func (s *Store) runRenameHook(newName string) {
+	if s.hooks[hooks.PostInsert] == "" {
+		return
+	}
+	names, err := s.entryNames()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s hook not run: %v\n", hooks.PostInsert, err)
+		return
+	}
+
+	var renamed []string
+	for _, name := range names {
+		if name == newName || strings.HasPrefix(name, newName+"/") {
+			renamed = append(renamed, name)
+		}
+	}
+	if len(renamed) > 0 {
+		slices.Sort(renamed)
+		hooks.Run(s.hooks, hooks.PostInsert, renamed...)
+	}
+}
+
+// IsReadOnly reports whether the store directory cannot be written to, e.g.
+// because it is on a read-only mount. Reads keep working; writes fail with
+// ErrReadOnly. The check creates and removes a probe file, once.
+func (s *Store) IsReadOnly() bool {
+	s.readOnlyOnce.Do(func() {
+		probe, err := os.CreateTemp(s.baseDir, ".write-probe-*")
+		if err != nil {
+			s.readOnly = errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS)
+			return
+		}
+		probe.Close()
+		os.Remove(probe.Name())
+	})
+	return s.readOnly
+}
+
+// requireWritable fails with ErrReadOnly before a write to a read-only store
+// can fail halfway with an obscure filesystem error
+func (s *Store) requireWritable() error {
+	if s.IsReadOnly() {
+		return fmt.Errorf("%w: cannot write to %s", ErrReadOnly, s.baseDir)
+	}
+	return nil
+}
+
+// MoveTo moves an entry into another store, which has its own keyfile and
+// master password: it is decrypted here, encrypted into dest as newName and
+// only then removed from this store, so a failure never loses it. Saved
+// history is not carried over.
+func (s *Store) MoveTo(dest *Store, name, newName, masterPassword, destMasterPassword string) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+	if err := dest.requireWritable(); err != nil {
+		return fmt.Errorf("destination %w", err)
+	}
+
+	content, err := s.Show(name, masterPassword)
+	if err != nil {
+		return err
+	}
+
+	if err := dest.Insert(newName, content, destMasterPassword); err != nil {
+		return fmt.Errorf("failed to store in destination: %w", err)
+	}
+
+	if err := s.Remove(name, false); err != nil {
+		return fmt.Errorf("stored in destination, but failed to remove from this store: %w", err)
+	}
 	return nil
 }
 
@@ -253,6 +785,12 @@ func (s *Store) ClearPasswordCache() {
 	s.crypto.ClearPasswordCache()
 }
 
+// RefreshCache extends a valid cached master password to a full timeout
+// without asking for it. It returns false when nothing valid is cached.
+func (s *Store) RefreshCache() bool {
+	return s.crypto.RefreshCache()
+}
+
 // SetCacheTimeout sets the cache timeout duration
 func (s *Store) SetCacheTimeout(timeout time.Duration) {
 	s.crypto.SetCacheTimeout(timeout)
@@ -265,13 +803,45 @@ func (s *Store) GetCacheStatus() (bool, time.Duration) {
 	return isValid, remaining
 }
 
-// Edit opens a password for editing using the specified editor
+// CacheDiagnostics describes the password cache in detail, for debugging
+// unexpected master password prompts
+func (s *Store) CacheDiagnostics() cache.Diagnostics {
+	return s.crypto.CacheDiagnostics()
+}
+
+// UsesKeyFile reports whether the store combines the master password with a keyfile
+func (s *Store) UsesKeyFile() bool {
+	return s.crypto.UsesKeyFile()
+}
+
+// HasKeyFile reports whether the store's keyfile is present
+func (s *Store) HasKeyFile() bool {
+	return s.crypto.HasKeyFile()
+}
+
+// KeyFileFingerprint returns a short fingerprint identifying the keyfile
+func (s *Store) KeyFileFingerprint() (string, error) {
+	return s.crypto.KeyFileFingerprint()
+}
+
+// Edit opens a password for editing using the specified editor command
 func (s *Store) Edit(name, masterPassword, editor string) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+
+	name, err := s.entryName(name)
+	if err != nil {
+		return err
+	}
+
 	filePath := s.getPasswordFilePath(name)
 
 	// Check if password exists, if not create a new one
 	var currentContent string
+	exists := false
 	if _, err := os.Stat(filePath); err == nil {
+		exists = true
 		// File exists, decrypt current content
 		decrypted, err := s.Show(name, masterPassword)
 		if err != nil {
@@ -283,56 +853,126 @@ func (s *Store) Edit(name, masterPassword, editor string) error {
 	}
 	// If file doesn't exist, currentContent remains empty string
 
-	// Create temporary file for editing
-	tmpFile, err := os.CreateTemp("", "chowkidaar-edit-*.tmp")
+	// JSON entries are edited as JSON, without their marker, and must still
+	// parse afterwards
+	isJSON := IsJSONEntry(currentContent)
+	newPassword, err := s.runEditor(EntryText(currentContent), editor)
+	if err != nil {
+		return err
+	}
+	if isJSON {
+		if _, err := ParseJSONEntry(newPassword); err != nil {
+			return fmt.Errorf("'%s' not saved: %w", name, err)
+		}
+		newPassword = jsonEntryMarker + newPassword
+	}
+
+	// An empty new entry is most likely an aborted edit
+	if !exists && strings.TrimSpace(newPassword) == "" {
+		return fmt.Errorf("entry is empty, '%s' not created", name)
+	}
+
+	// Check if content was changed
+	if newPassword == currentContent {
+		fmt.Fprintf(os.Stderr, "No changes made to '%s'\n", name)
+		return nil
+	}
+
+	// Save the new password (use Update to allow overwriting existing passwords)
+	if err := s.Update(name, newPassword, masterPassword); err != nil {
+		return fmt.Errorf("failed to save edited password: %w", err)
+	}
+
+	return nil
+}
+
+// InsertTemplate creates a new entry by opening the editor on a template.
+// {{password}} in the template is replaced with a generated password first.
+func (s *Store) InsertTemplate(name, template, masterPassword, editor string) error {
+	if err := s.requireWritable(); err != nil {
+		return err
+	}
+
+	name, err := s.entryName(name)
+	if err != nil {
+		return err
+	}
+
+	if s.Exists(name) {
+		return fmt.Errorf("password '%s' %w", name, ErrExists)
+	}
+
+	password, err := generatePassword(templatePasswordLength, defaultCharset+symbolCharset)
+	if err != nil {
+		return fmt.Errorf("failed to generate password: %w", err)
+	}
+
+	content, err := s.runEditor(strings.ReplaceAll(template, "{{password}}", password), editor)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(content) == "" {
+		return fmt.Errorf("entry is empty, nothing saved")
+	}
+
+	return s.Insert(name, content, masterPassword)
+}
+
+// runEditor lets the user edit content in a wiped temporary file and returns
+// the result without the trailing newline editors add
+func (s *Store) runEditor(content, editor string) (string, error) {
+	if strings.TrimSpace(editor) == "" {
+		return "", fmt.Errorf("no editor configured")
+	}
+
+	// Create temporary file for editing, preferring memory-backed storage
+	tmpFile, err := os.CreateTemp(s.secureTempDir(), "chowkidaar-edit-*.tmp")
 	if err != nil {
-		return fmt.Errorf("failed to create temporary file: %w", err)
+		return "", fmt.Errorf("failed to create temporary file: %w", err)
 	}
 	tmpPath := tmpFile.Name()
 
-	// Ensure temporary file is removed after editing
+	// Ensure temporary file is wiped and removed after editing
 	defer func() {
+		wipeFile(tmpPath)
 		os.Remove(tmpPath)
 	}()
 
+	if err := tmpFile.Chmod(0600); err != nil {
+		tmpFile.Close()
+		return "", fmt.Errorf("failed to secure temporary file: %w", err)
+	}
+
 	// Write current content to temporary file
-	if _, err := tmpFile.WriteString(currentContent); err != nil {
+	if _, err := tmpFile.WriteString(content); err != nil {
 		tmpFile.Close()
-		return fmt.Errorf("failed to write to temporary file: %w", err)
+		return "", fmt.Errorf("failed to write to temporary file: %w", err)
 	}
 	tmpFile.Close()
 
-	// Open editor
-	cmd := exec.Command(editor, tmpPath)
+	// Open editor. An unquoted path with spaces is run as is; anything else
+	// goes through the shell like git does, so it may carry arguments
+	// ("code --wait") or be a quoted path
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, editor, tmpPath)
+	if info, err := os.Stat(editor); err == nil && !info.IsDir() {
+		cmd = exec.Command(editor, tmpPath)
+	}
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 
 	if err := cmd.Run(); err != nil {
-		return fmt.Errorf("editor exited with error: %w", err)
+		return "", fmt.Errorf("editor exited with error: %w", err)
 	}
 
 	// Read the edited content
 	editedContent, err := os.ReadFile(tmpPath)
 	if err != nil {
-		return fmt.Errorf("failed to read edited content: %w", err)
+		return "", fmt.Errorf("failed to read edited content: %w", err)
 	}
 
 	// Remove trailing newline if it exists (common with editors)
-	newPassword := strings.TrimSuffix(string(editedContent), "\n")
-
-	// Check if content was changed
-	if newPassword == currentContent {
-		fmt.Printf("No changes made to '%s'\n", name)
-		return nil
-	}
-
-	// Save the new password (use Update to allow overwriting existing passwords)
-	if err := s.Update(name, newPassword, masterPassword); err != nil {
-		return fmt.Errorf("failed to save edited password: %w", err)
-	}
-
-	return nil
+	return strings.TrimSuffix(string(editedContent), "\n"), nil
 }
 
 // Helper methods
@@ -346,6 +986,10 @@ func (s *Store) validatePasswordIfNeeded(masterPassword string) error {
 		if err != nil {
 			return err
 		}
+		// Skip .git, .history, .shared and other hidden directories
+		if info.IsDir() && path != s.baseDir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
 		if !info.IsDir() && strings.HasSuffix(info.Name(), ".enc") {
 			testFile = path
 			return filepath.SkipAll // Stop after finding first .enc file
@@ -369,8 +1013,11 @@ func (s *Store) validatePasswordIfNeeded(masterPassword string) error {
 	}
 
 	_, err = s.crypto.Decrypt(encrypted, masterPassword)
+	if errors.Is(err, crypto.ErrKeyFileChanged) {
+		return err
+	}
 	if err != nil {
-		return fmt.Errorf("incorrect master password")
+		return fmt.Errorf("incorrect master password (%w)", crypto.ErrAuthFailed)
 	}
 
 	// Password is valid, cache it
@@ -379,12 +1026,127 @@ func (s *Store) validatePasswordIfNeeded(masterPassword string) error {
 	return nil
 }
 
+// TrimSecret returns the first line of decrypted content without trailing
+// whitespace, which is the secret itself for entries that keep notes below it.
+// For JSON entries it returns the password.
+func TrimSecret(content string) string {
+	if IsJSONEntry(content) {
+		if entry, err := ParseEntry(content); err == nil {
+			return entry.Password
+		}
+	}
+	firstLine, _, _ := strings.Cut(content, "\n")
+	return strings.TrimRight(firstLine, " \t\r")
+}
+
+// maskedSecret stands in for the password line; its length is fixed so the
+// real password's length is not revealed either
+const maskedSecret = "********"
+
+// MaskSecret replaces the first line of decrypted content, the secret itself,
+// with asterisks and keeps the metadata lines below it (username:, url:, ...)
+// as they are. JSON entries are shown with their password masked.
+func MaskSecret(content string) string {
+	if content == "" {
+		return ""
+	}
+	if IsJSONEntry(content) {
+		entry, err := ParseEntry(content)
+		if err != nil {
+			return maskedSecret
+		}
+		entry.Password = maskedSecret
+		masked, err := entry.Encode()
+		if err != nil {
+			return maskedSecret
+		}
+		return EntryText(masked)
+	}
+	_, rest, found := strings.Cut(content, "\n")
+	if !found {
+		return maskedSecret
+	}
+	return maskedSecret + "\n" + rest
+}
+
+// NormalizeName canonicalizes a user-supplied entry name: surrounding
+// whitespace and slashes are trimmed, repeated separators collapsed and a
+// trailing .enc typed by the user is dropped, so "Email//gmail.enc/" and
+// "Email/gmail" refer to the same entry. Names that would escape the store,
+// point at hidden files or still end in .enc are rejected.
+func NormalizeName(name string) (string, error) {
+	name = strings.TrimSpace(filepath.ToSlash(name))
+	name = strings.Trim(name, "/")
+
+	var parts []string
+	for _, part := range strings.Split(name, "/") {
+		switch {
+		case part == "" || part == ".":
+			continue
+		case part == "..":
+			return "", fmt.Errorf("invalid password name '%s': '..' is not allowed", name)
+		case strings.HasPrefix(part, "."):
+			return "", fmt.Errorf("invalid password name '%s': names cannot start with '.'", name)
+		}
+		parts = append(parts, part)
+	}
+
+	normalized := strings.TrimSuffix(strings.Join(parts, "/"), ".enc")
+	if normalized == "" {
+		return "", fmt.Errorf("password name cannot be empty")
+	}
+	if strings.HasSuffix(normalized, ".enc") {
+		return "", fmt.Errorf("invalid password name '%s': names cannot end in .enc", name)
+	}
+
+	return normalized, nil
+}
+
 func (s *Store) getPasswordFilePath(name string) string {
-	// Ensure the name ends with .enc extension
-	if !strings.HasSuffix(name, ".enc") {
-		name += ".enc"
+	// Accept names with or without the .enc extension
+	name = strings.TrimSuffix(name, ".enc")
+	return filepath.Join(s.baseDir, s.diskName(name)+".enc")
+}
+
+func (s *Store) getSharedFilePath(name string) string {
+	name = strings.TrimSuffix(name, ".enc")
+	return filepath.Join(s.baseDir, sharedDirName, s.diskName(name)+".enc")
+}
+
+// renameShared moves the shared copy of an entry, or the shared copies of
+// every entry in a folder, along with a rename
+func (s *Store) renameShared(oldName, newName string, isDir, caseOnly bool) error {
+	oldPath, newPath := s.getSharedFilePath(oldName), s.getSharedFilePath(newName)
+	if isDir {
+		oldPath = filepath.Join(s.baseDir, sharedDirName, oldName)
+		newPath = filepath.Join(s.baseDir, sharedDirName, newName)
+	}
+
+	if _, err := os.Stat(oldPath); err != nil {
+		return nil // Nothing shared
+	}
+	if err := os.MkdirAll(filepath.Dir(newPath), 0700); err != nil {
+		return err
+	}
+	if err := renamePath(oldPath, newPath, caseOnly); err != nil {
+		return err
+	}
+	if !caseOnly {
+		s.cleanupEmptyDirs(filepath.Dir(oldPath))
 	}
-	return filepath.Join(s.baseDir, name)
+	return nil
+}
+
+// removeStaleShare deletes the shared copy of an entry whose content
+// changed. Only the shared secret could re-encrypt it, so it is dropped
+// rather than left readable with the old content.
+func (s *Store) removeStaleShare(name string) {
+	sharedPath := s.getSharedFilePath(name)
+	if err := os.Remove(sharedPath); err != nil {
+		return
+	}
+	s.cleanupEmptyDirs(filepath.Dir(sharedPath))
+	fmt.Fprintf(os.Stderr, "Removed the outdated shared copy of '%s'; run 'chowkidaar share %s' to share it again\n", name, name)
 }
 
 func (s *Store) listDirectory(dir, prefix string) error {
@@ -426,6 +1188,9 @@ func (s *Store) listDirectory(dir, prefix string) error {
 	return nil
 }
 
+// cleanupEmptyDirs removes dir and then its parents for as long as they are
+// empty. Hidden files count: a folder holding only a .desc description is
+// kept, even though list shows it without entries.
 func (s *Store) cleanupEmptyDirs(dir string) {
 	// Don't remove the base directory
 	if dir == s.baseDir {
@@ -444,6 +1209,172 @@ func (s *Store) cleanupEmptyDirs(dir string) {
 	}
 }
 
+// PruneEmptyDirs removes every empty directory in the store, e.g. ones left
+// behind by manual git operations or failed syncs, and returns how many were
+// removed. .git and .cache are left alone.
+func (s *Store) PruneEmptyDirs() (int, error) {
+	if err := s.requireWritable(); err != nil {
+		return 0, err
+	}
+
+	var dirs []string
+	err := filepath.WalkDir(s.baseDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() || path == s.baseDir {
+			return nil
+		}
+		if d.Name() == ".git" || d.Name() == ".cache" {
+			return filepath.SkipDir
+		}
+		dirs = append(dirs, path)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to walk password store: %w", err)
+	}
+
+	// Deepest first, so a parent holding only empty directories goes too
+	removed := 0
+	for i := len(dirs) - 1; i >= 0; i-- {
+		entries, err := os.ReadDir(dirs[i])
+		if err != nil || len(entries) > 0 {
+			continue
+		}
+		if err := os.Remove(dirs[i]); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", dirs[i], err)
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		if err := s.autoCommit("prune", fmt.Sprintf("%d empty directories", removed), fmt.Sprintf("Remove %d empty directories", removed)); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to commit changes to Git: %v\n", err)
+		}
+	}
+	return removed, nil
+}
+
+// renamePath renames oldPath to newPath. For case-only renames it goes through
+// a temporary name, since a direct rename can be a no-op or fail on
+// case-insensitive filesystems.
+func renamePath(oldPath, newPath string, caseOnly bool) error {
+	if !caseOnly {
+		return os.Rename(oldPath, newPath)
+	}
+
+	tmpPath := fmt.Sprintf("%s.rename-%d.tmp", oldPath, time.Now().UnixNano())
+	if err := os.Rename(oldPath, tmpPath); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, newPath); err != nil {
+		// Put things back the way they were
+		os.Rename(tmpPath, oldPath)
+		return err
+	}
+
+	return nil
+}
+
+// WriteFileAtomic writes data to a temporary file in the target directory
+// and renames it into place, so readers never observe a partial file
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmpFile, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmpFile.Name()
+
+	if err := tmpFile.Chmod(perm); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := tmpFile.Sync(); err != nil {
+		tmpFile.Close()
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := tmpFile.Close(); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+
+	return nil
+}
+
+// WriteSecretFile writes decrypted content to a file outside the store with 0600 permissions.
+// Missing parent directories are created private; existing ones keep their permissions.
+// An existing file is only replaced when overwrite is true.
+func WriteSecretFile(path string, data []byte, overwrite bool) error {
+	if _, err := os.Stat(path); err == nil && !overwrite {
+		return fmt.Errorf("file '%s' already exists (use --force to overwrite)", path)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	if err := WriteFileAtomic(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write file: %w", err)
+	}
+
+	return nil
+}
+
+// secureTempDir returns a directory for temporary plaintext files.
+// /dev/shm is preferred so plaintext never reaches a persistent disk;
+// otherwise a private directory inside the store's git-ignored .cache is used.
+// An empty string falls back to the system temp directory.
+func (s *Store) secureTempDir() string {
+	if info, err := os.Stat("/dev/shm"); err == nil && info.IsDir() {
+		if f, err := os.CreateTemp("/dev/shm", "chowkidaar-probe-*"); err == nil {
+			f.Close()
+			os.Remove(f.Name())
+			return "/dev/shm"
+		}
+	}
+
+	dir := filepath.Join(s.baseDir, ".cache", "tmp")
+	if err := os.MkdirAll(dir, 0700); err == nil {
+		return dir
+	}
+
+	return ""
+}
+
+// wipeFile overwrites a file's contents with zeros before it is removed
+func wipeFile(path string) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	f.Write(make([]byte, info.Size()))
+	f.Sync()
+}
+
 func generatePassword(length int, charset string) (string, error) {
 	password := make([]byte, length)
 	charsetLength := big.NewInt(int64(len(charset)))
@@ -459,11 +1390,71 @@ func generatePassword(length int, charset string) (string, error) {
 	return string(password), nil
 }
 
-// autoCommit commits changes to Git if auto-sync is enabled
-func (s *Store) autoCommit(message string) error {
+// autoCommit commits changes to Git if auto-sync is enabled. The commit
+// message is message, or the configured template filled in with action, a
+// lowercase verb such as "add", and name, the entry or folder concerned or a
+// count like "3 passwords" for bulk operations.
+func (s *Store) autoCommit(action, name, message string) error {
 	if s.gitSync == nil || !s.autoSync {
 		return nil
 	}
+	if s.commitTemplate != "" {
+		message = commitMessage(s.commitTemplate, action, name)
+	}
+
+	unlock, err := s.lockStore()
+	if err != nil {
+		return err
+	}
+	defer unlock()
 
 	return s.gitSync.Commit(message)
 }
+
+// DisableAutoCommit stops write operations from committing for the rest of
+// this process, so changes stay pending until CommitPending
+func (s *Store) DisableAutoCommit() {
+	s.autoSync = false
+}
+
+// AllowPlaintextCommits lets commits made by this process include files
+// that are not encrypted, which are refused by default
+func (s *Store) AllowPlaintextCommits() {
+	if s.gitSync != nil {
+		s.gitSync.SetAllowPlaintext(true)
+	}
+}
+
+// CommitPending commits every pending change in the store with message,
+// whether or not auto-sync is enabled, and returns how many paths changed.
+// Nothing is pushed.
+func (s *Store) CommitPending(message string) (int, error) {
+	if s.gitSync == nil || !s.gitSync.IsGitEnabled() {
+		return 0, fmt.Errorf("Git is not initialized for this password store")
+	}
+
+	unlock, err := s.lockStore()
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	status, err := s.gitSync.Status()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get Git status: %w", err)
+	}
+	if len(status) == 0 {
+		return 0, nil
+	}
+	if err := s.gitSync.Commit(message); err != nil {
+		return 0, err
+	}
+	return len(status), nil
+}
+
+// commitMessage fills in a commit message template, replacing {action} and
+// {name}. Whitespace is collapsed, so an empty name leaves no gap behind.
+func commitMessage(template, action, name string) string {
+	message := strings.NewReplacer("{action}", action, "{name}", name).Replace(template)
+	return strings.Join(strings.Fields(message), " ")
+}
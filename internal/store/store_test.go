@@ -0,0 +1,1433 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"chowkidaar/internal/config"
+	"chowkidaar/internal/crypto"
+	"chowkidaar/internal/gitsync"
+	"chowkidaar/internal/hooks"
+
+	gogit "github.com/go-git/go-git/v5"
+)
+
+const testMasterPassword = "correct horse battery staple"
+
+// newTestStore creates an initialized store with a fresh keyfile in a
+// temporary directory
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+
+	dir := t.TempDir()
+	cryptoHandler := crypto.New(dir)
+	mnemonic, err := cryptoHandler.GenerateMnemonic()
+	if err != nil {
+		t.Fatalf("GenerateMnemonic: %v", err)
+	}
+	if err := cryptoHandler.CreateKeyFileFromMnemonic(mnemonic); err != nil {
+		t.Fatalf("CreateKeyFileFromMnemonic: %v", err)
+	}
+
+	s, err := New(dir)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	return s
+}
+
+// insertEntries stores each name with its own name as the password
+func insertEntries(t *testing.T, s *Store, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		if err := s.Insert(name, name, testMasterPassword); err != nil {
+			t.Fatalf("Insert(%q): %v", name, err)
+		}
+	}
+}
+
+// entryNames lists the entries in the store, sorted
+func entryNames(t *testing.T, s *Store) []string {
+	t.Helper()
+
+	files, err := s.EntryFiles()
+	if err != nil {
+		t.Fatalf("EntryFiles: %v", err)
+	}
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.ToSlash(file), ".enc"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRename(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []string
+		from, to string
+		want     []string
+		wantErr  string
+	}{
+		{
+			name:    "new path",
+			entries: []string{"gmail"},
+			from:    "gmail", to: "google",
+			want: []string{"google"},
+		},
+		{
+			name:    "creates nested directories",
+			entries: []string{"gmail"},
+			from:    "gmail", to: "Email/Personal/gmail",
+			want: []string{"Email/Personal/gmail"},
+		},
+		{
+			name:    "moves a directory",
+			entries: []string{"Email/gmail", "Email/work"},
+			from:    "Email", to: "Mail",
+			want: []string{"Mail/gmail", "Mail/work"},
+		},
+		{
+			name:    "case-only rename",
+			entries: []string{"Email/gmail"},
+			from:    "Email", to: "email",
+			want: []string{"email/gmail"},
+		},
+		{
+			name:    "target exists",
+			entries: []string{"gmail", "google"},
+			from:    "gmail", to: "google",
+			want:    []string{"gmail", "google"},
+			wantErr: "already exists",
+		},
+		{
+			name:    "missing source",
+			entries: []string{"gmail"},
+			from:    "yahoo", to: "google",
+			want:    []string{"gmail"},
+			wantErr: "does not exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			insertEntries(t, s, tt.entries...)
+
+			err := s.Rename(tt.from, tt.to)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Rename(%q, %q) error = %v, want %q", tt.from, tt.to, err, tt.wantErr)
+				}
+			} else if err != nil {
+				t.Fatalf("Rename(%q, %q): %v", tt.from, tt.to, err)
+			}
+
+			if got := entryNames(t, s); strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("entries = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameKeepsContent(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "gmail")
+
+	if err := s.Rename("gmail", "Email/gmail"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+
+	got, err := s.Show("Email/gmail", testMasterPassword)
+	if err != nil {
+		t.Fatalf("Show: %v", err)
+	}
+	if got != "gmail" {
+		t.Errorf("Show = %q, want %q", got, "gmail")
+	}
+}
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    string
+		wantErr string
+	}{
+		{input: "gmail", want: "gmail"},
+		{input: "Email/gmail", want: "Email/gmail"},
+		{input: "/Email/gmail", want: "Email/gmail"},
+		{input: "Email/", want: "Email"},
+		{input: "  /Email//gmail/ ", want: "Email/gmail"},
+		{input: "Email/./gmail", want: "Email/gmail"},
+		{input: "gmail.enc", want: "gmail"},
+		{input: "Email/gmail.enc/", want: "Email/gmail"},
+		{input: "gmail.enc.enc", wantErr: "cannot end in .enc"},
+		{input: "..", wantErr: "'..' is not allowed"},
+		{input: "../outside", wantErr: "'..' is not allowed"},
+		{input: "Email/../../outside", wantErr: "'..' is not allowed"},
+		{input: "", wantErr: "cannot be empty"},
+		{input: " / ", wantErr: "cannot be empty"},
+		{input: ".enc", wantErr: "cannot start with '.'"},
+		{input: ".git/config", wantErr: "cannot start with '.'"},
+		{input: ".history/gmail", wantErr: "cannot start with '.'"},
+		{input: "Email/.shared", wantErr: "cannot start with '.'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := NormalizeName(tt.input)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("NormalizeName(%q) = %q, %v, want error %q", tt.input, got, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("NormalizeName(%q) = %q, %v, want %q", tt.input, got, err, tt.want)
+			}
+		})
+	}
+}
+
+// updateEntry overwrites an entry so its previous content is kept as history
+func updateEntry(t *testing.T, s *Store, name, password string) {
+	t.Helper()
+
+	if err := s.Update(name, password, testMasterPassword); err != nil {
+		t.Fatalf("Update(%q): %v", name, err)
+	}
+}
+
+func TestRenameMovesHistory(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to string
+		entry    string // entry whose history is checked after the rename
+	}{
+		{name: "entry", from: "Email/gmail", to: "google", entry: "google"},
+		{name: "directory", from: "Email", to: "Mail", entry: "Mail/gmail"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			s.historyDepth = 5
+			insertEntries(t, s, "Email/gmail")
+			updateEntry(t, s, "Email/gmail", "new")
+
+			if err := s.Rename(tt.from, tt.to); err != nil {
+				t.Fatalf("Rename: %v", err)
+			}
+
+			if versions, err := s.Versions(tt.entry); err != nil || len(versions) != 1 {
+				t.Errorf("Versions(%q) = %v, %v, want 1 version", tt.entry, versions, err)
+			}
+			if versions, _ := s.Versions("Email/gmail"); len(versions) != 0 {
+				t.Errorf("Versions(%q) = %v, want none", "Email/gmail", versions)
+			}
+		})
+	}
+}
+
+func TestRemoveDeletesHistory(t *testing.T) {
+	s := newTestStore(t)
+	s.historyDepth = 5
+	insertEntries(t, s, "Email/gmail")
+	updateEntry(t, s, "Email/gmail", "new")
+
+	if err := s.Remove("Email/gmail", false); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	if versions, _ := s.Versions("Email/gmail"); len(versions) != 0 {
+		t.Errorf("Versions after Remove = %v, want none", versions)
+	}
+	files, err := s.historyFiles()
+	if err != nil || len(files) != 0 {
+		t.Errorf("historyFiles after Remove = %v, %v, want none", files, err)
+	}
+
+	// A new entry with the same name starts without history
+	insertEntries(t, s, "Email/gmail")
+	if versions, _ := s.Versions("Email/gmail"); len(versions) != 0 {
+		t.Errorf("Versions of re-created entry = %v, want none", versions)
+	}
+}
+
+func TestHiddenNamesInsertBatch(t *testing.T) {
+	s := newTestStore(t)
+	if _, err := s.HideNames(); err != nil {
+		t.Fatalf("HideNames: %v", err)
+	}
+
+	entries := []BatchEntry{{Name: "Email/gmail", Password: "a"}, {Name: "Email/work", Password: "b"}}
+	if _, err := s.InsertBatch(context.Background(), entries, testMasterPassword, false); err != nil {
+		t.Fatalf("InsertBatch: %v", err)
+	}
+
+	names, err := s.entryNames()
+	if err != nil {
+		t.Fatalf("entryNames: %v", err)
+	}
+	sort.Strings(names)
+	if got := strings.Join(names, ","); got != "Email/gmail,Email/work" {
+		t.Errorf("names = %s, want Email/gmail,Email/work", got)
+	}
+}
+
+func TestHiddenNamesWithoutKeyFile(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "gmail")
+	if _, err := s.HideNames(); err != nil {
+		t.Fatalf("HideNames: %v", err)
+	}
+	if err := os.Remove(filepath.Join(s.baseDir, ".keyfile")); err != nil {
+		t.Fatal(err)
+	}
+
+	// Opening the store must not need the keyfile
+	s, err := New(s.baseDir)
+	if err != nil {
+		t.Fatalf("New without keyfile: %v", err)
+	}
+	if !s.HiddenNames() {
+		t.Error("HiddenNames = false, want true")
+	}
+	if _, err := s.Show("gmail", testMasterPassword); err == nil || !strings.Contains(err.Error(), "name key") {
+		t.Errorf("Show error = %v, want name key error", err)
+	}
+}
+
+func TestTrimSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "no newline", content: "hunter2", want: "hunter2"},
+		{name: "trailing newline", content: "hunter2\n", want: "hunter2"},
+		{name: "CRLF", content: "hunter2\r\n", want: "hunter2"},
+		{name: "trailing spaces", content: "hunter2 \t\n", want: "hunter2"},
+		{name: "multi-line keeps first line", content: "hunter2\nuser: alice\nurl: example.com\n", want: "hunter2"},
+		{name: "multi-line CRLF", content: "hunter2\r\nuser: alice\r\n", want: "hunter2"},
+		{name: "leading spaces kept", content: "  hunter2\n", want: "  hunter2"},
+		{name: "empty", content: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := TrimSecret(tt.content); got != tt.want {
+				t.Errorf("TrimSecret(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMaskSecret(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{name: "password only", content: "hunter2", want: "********"},
+		{name: "trailing newline", content: "hunter2\n", want: "********\n"},
+		{name: "metadata kept", content: "hunter2\nuser: alice\nurl: example.com\n", want: "********\nuser: alice\nurl: example.com\n"},
+		{name: "length hidden", content: "correct horse battery staple\nuser: bob", want: "********\nuser: bob"},
+		{name: "empty", content: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MaskSecret(tt.content); got != tt.want {
+				t.Errorf("MaskSecret(%q) = %q, want %q", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditEditorCommand(t *testing.T) {
+	// The fake editor writes how many arguments it got into the last one
+	script := filepath.Join(t.TempDir(), "my editors", "fake editor")
+	if err := os.MkdirAll(filepath.Dir(script), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(script, []byte("#!/bin/sh\neval \"last=\\${$#}\"\nprintf 'args %s' \"$#\" > \"$last\"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		editor string
+		want   string
+	}{
+		{name: "unquoted path with spaces", editor: script, want: "args 1"},
+		{name: "quoted path with argument", editor: `"` + script + `" --wait`, want: "args 2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestStore(t)
+			insertEntries(t, s, "gmail")
+
+			if err := s.Edit("gmail", testMasterPassword, tt.editor); err != nil {
+				t.Fatalf("Edit: %v", err)
+			}
+			if got, _ := s.Show("gmail", testMasterPassword); got != tt.want {
+				t.Errorf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEditEmptyNewEntry(t *testing.T) {
+	s := newTestStore(t)
+
+	// The editor leaves only whitespace in the file
+	script := filepath.Join(t.TempDir(), "editor")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nprintf '\\n  \\n' > \"$1\"\n"), 0700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Edit("gmail", testMasterPassword, script); err == nil {
+		t.Fatal("Edit of an empty new entry succeeded")
+	}
+	if s.Exists("gmail") {
+		t.Error("empty new entry was created")
+	}
+}
+
+func TestRemoveBatch(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/gmail", "Email/work", "bank")
+
+	removed, err := s.RemoveBatch([]string{"Email/gmail", "Email/work"}, false)
+	if err != nil {
+		t.Fatalf("RemoveBatch: %v", err)
+	}
+	if got := strings.Join(removed, ","); got != "Email/gmail,Email/work" {
+		t.Errorf("removed = %s, want Email/gmail,Email/work", got)
+	}
+	if got := entryNames(t, s); strings.Join(got, ",") != "bank" {
+		t.Errorf("entries = %v, want [bank]", got)
+	}
+
+	// A missing entry stops the batch but keeps what was removed before it
+	removed, err = s.RemoveBatch([]string{"bank", "missing"}, false)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Fatalf("RemoveBatch error = %v, want does not exist", err)
+	}
+	if len(removed) != 1 || removed[0] != "bank" {
+		t.Errorf("removed = %v, want [bank]", removed)
+	}
+}
+
+func TestRemoveEmptyDirs(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Old/a/gmail", "Kept/bank", "Described/vpn", "Social/twitter", "Social/mastodon")
+	if err := s.SetDirDescription("Described", "only a description"); err != nil {
+		t.Fatalf("SetDirDescription: %v", err)
+	}
+
+	for _, name := range []string{"Old/a/gmail", "Described/vpn"} {
+		if err := s.Remove(name, false); err != nil {
+			t.Fatalf("Remove(%q): %v", name, err)
+		}
+	}
+	if err := s.Remove("Kept/bank", true); err != nil {
+		t.Fatalf("Remove(%q, true): %v", "Kept/bank", err)
+	}
+	if _, err := s.RemoveBatch([]string{"Social/twitter", "Social/mastodon"}, true); err != nil {
+		t.Fatalf("RemoveBatch: %v", err)
+	}
+
+	// Old and Old/a are emptied and removed, while a folder holding only a
+	// hidden .desc file is not empty
+	if _, err := os.Stat(filepath.Join(s.baseDir, "Old")); !os.IsNotExist(err) {
+		t.Errorf("Old still exists")
+	}
+	for _, dir := range []string{"Kept", "Social", "Described"} {
+		if info, err := os.Stat(filepath.Join(s.baseDir, dir)); err != nil || !info.IsDir() {
+			t.Errorf("%s was removed: %v", dir, err)
+		}
+	}
+}
+
+func TestGenerateBatch(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "svc/existing")
+
+	opts := GenerateOptions{Length: 24, NoSymbols: true}
+	if _, err := s.GenerateBatch([]string{"svc/a", "svc/existing"}, opts, testMasterPassword); err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("GenerateBatch with an existing entry = %v, want already exists", err)
+	}
+	if s.Exists("svc/a") {
+		t.Error("svc/a was stored although the batch failed")
+	}
+
+	opts.SkipExisting = true
+	generated, err := s.GenerateBatch([]string{"svc/a", "svc/existing", "svc/b"}, opts, testMasterPassword)
+	if err != nil {
+		t.Fatalf("GenerateBatch: %v", err)
+	}
+	if len(generated) != 2 || generated[0].Name != "svc/a" || generated[1].Name != "svc/b" {
+		t.Fatalf("generated = %v, want svc/a and svc/b", generated)
+	}
+	if generated[0].Password == generated[1].Password {
+		t.Error("both entries got the same password")
+	}
+	for _, entry := range generated {
+		if len(entry.Password) != 24 || strings.ContainsAny(entry.Password, symbolCharset) {
+			t.Errorf("password %q is not 24 letters and digits", entry.Password)
+		}
+		if got, _ := s.Show(entry.Name, testMasterPassword); got != entry.Password {
+			t.Errorf("%s = %q, want %q", entry.Name, got, entry.Password)
+		}
+	}
+
+	if _, err := s.GenerateBatch([]string{"svc/c", "svc/c"}, opts, testMasterPassword); err == nil || !strings.Contains(err.Error(), "duplicate") {
+		t.Errorf("GenerateBatch with a duplicate = %v, want duplicate", err)
+	}
+}
+
+func TestCommitMessage(t *testing.T) {
+	tests := []struct {
+		template, action, name, want string
+	}{
+		{template: "chore(pass): {action} {name}", action: "add", name: "Email/gmail", want: "chore(pass): add Email/gmail"},
+		{template: "chore(pass): {action} {name}", action: "rekey", want: "chore(pass): rekey"},
+		{template: "Update password store", action: "remove", name: "bank", want: "Update password store"},
+	}
+
+	for _, tt := range tests {
+		if got := commitMessage(tt.template, tt.action, tt.name); got != tt.want {
+			t.Errorf("commitMessage(%q, %q, %q) = %q, want %q", tt.template, tt.action, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCommitPending(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(home, ".config"))
+	if err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte("[user]\n\tname = Test\n\temail = test@example.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	s := newTestStore(t)
+	if _, err := gogit.PlainInit(s.baseDir, false); err != nil {
+		t.Fatal(err)
+	}
+	s.gitSync = gitsync.NewGitSync(s.baseDir, "")
+	s.autoSync = true
+	s.DisableAutoCommit()
+
+	insertEntries(t, s, "Email/gmail", "bank")
+	if head, err := s.gitSync.HeadCommit(); err == nil {
+		t.Fatalf("insert with auto-commit disabled committed %s", head)
+	}
+
+	count, err := s.CommitPending("Add accounts")
+	if err != nil || count == 0 {
+		t.Fatalf("CommitPending = %d, %v, want pending changes committed", count, err)
+	}
+	if status, _ := s.gitSync.Status(); len(status) != 0 {
+		t.Errorf("changes left after CommitPending: %v", status)
+	}
+	if count, err := s.CommitPending("Nothing"); err != nil || count != 0 {
+		t.Errorf("second CommitPending = %d, %v, want 0, nil", count, err)
+	}
+}
+
+func TestInsertTemplate(t *testing.T) {
+	s := newTestStore(t)
+
+	// "true" leaves the rendered template untouched
+	if err := s.InsertTemplate("gmail", "{{password}}\nusername: \n", testMasterPassword, "true"); err != nil {
+		t.Fatalf("InsertTemplate: %v", err)
+	}
+
+	got, err := s.Show("gmail", testMasterPassword)
+	if err != nil {
+		t.Fatalf("Show: %v", err)
+	}
+	password, rest, _ := strings.Cut(got, "\n")
+	if len(password) != templatePasswordLength || rest != "username: " {
+		t.Errorf("content = %q, want a %d character password and the username stub", got, templatePasswordLength)
+	}
+
+	err = s.InsertTemplate("gmail", "{{password}}\n", testMasterPassword, "true")
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Errorf("InsertTemplate on existing entry error = %v, want already exists", err)
+	}
+}
+
+func TestChangeMasterPasswordCancelled(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/gmail", "bank")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := s.ChangeMasterPassword(ctx, testMasterPassword, "new password"); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ChangeMasterPassword error = %v, want context.Canceled", err)
+	}
+
+	// Nothing was rewritten, so the old password still opens every entry
+	for _, name := range []string{"Email/gmail", "bank"} {
+		if got, err := s.Show(name, testMasterPassword); err != nil || got != name {
+			t.Errorf("Show(%q) = %q, %v, want %q", name, got, err, name)
+		}
+	}
+}
+
+func TestInsertBatchCancelled(t *testing.T) {
+	s := newTestStore(t)
+
+	var stored int
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	s.SetProgress(func(done, total int) {
+		stored = done
+		if done == 1 {
+			cancel()
+		}
+	})
+
+	entries := []BatchEntry{{Name: "a", Password: "a"}, {Name: "b", Password: "b"}, {Name: "c", Password: "c"}}
+	count, err := s.InsertBatch(ctx, entries, testMasterPassword, false)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("InsertBatch error = %v, want context.Canceled", err)
+	}
+	if count != 1 || stored != 1 {
+		t.Errorf("count = %d, progress = %d, want 1", count, stored)
+	}
+	if got := entryNames(t, s); strings.Join(got, ",") != "a" {
+		t.Errorf("entries = %v, want [a]", got)
+	}
+}
+
+func TestReencrypt(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/gmail", "Email/work", "bank")
+
+	// Switching the KDF leaves the existing entries behind
+	if err := s.crypto.SetKDF(crypto.KDFScrypt); err != nil {
+		t.Fatalf("SetKDF: %v", err)
+	}
+
+	upgraded, checked, err := s.Reencrypt(context.Background(), "Email", testMasterPassword)
+	if err != nil {
+		t.Fatalf("Reencrypt(Email): %v", err)
+	}
+	if upgraded != 2 || checked != 2 {
+		t.Errorf("Reencrypt(Email) = %d of %d, want 2 of 2", upgraded, checked)
+	}
+
+	// Only bank is left to upgrade
+	upgraded, checked, err = s.Reencrypt(context.Background(), "", testMasterPassword)
+	if err != nil {
+		t.Fatalf("Reencrypt: %v", err)
+	}
+	if upgraded != 1 || checked != 3 {
+		t.Errorf("Reencrypt = %d of %d, want 1 of 3", upgraded, checked)
+	}
+
+	for _, name := range []string{"Email/gmail", "Email/work", "bank"} {
+		if got, err := s.Show(name, testMasterPassword); err != nil || got != name {
+			t.Errorf("Show(%q) = %q, %v, want %q", name, got, err, name)
+		}
+	}
+
+	if _, _, err := s.Reencrypt(context.Background(), "Missing", testMasterPassword); err == nil {
+		t.Error("Reencrypt of a missing folder succeeded")
+	}
+}
+
+func TestReadOnlyStore(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "bank")
+
+	if os.Geteuid() != 0 {
+		if s.IsReadOnly() {
+			t.Fatal("IsReadOnly on a writable store")
+		}
+		readOnly, err := New(s.baseDir)
+		if err != nil {
+			t.Fatalf("New: %v", err)
+		}
+		os.Chmod(s.baseDir, 0500)
+		t.Cleanup(func() { os.Chmod(s.baseDir, 0700) })
+		if !readOnly.IsReadOnly() {
+			t.Error("IsReadOnly = false on a directory without write permission")
+		}
+	}
+
+	// Force the result so the rest also runs as root, which can write anywhere
+	s.readOnlyOnce.Do(func() {})
+	s.readOnly = true
+
+	if got, err := s.Show("bank", testMasterPassword); err != nil || got != "bank" {
+		t.Errorf("Show = %q, %v, want bank", got, err)
+	}
+	if err := s.Insert("new", "new", testMasterPassword); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Insert error = %v, want ErrReadOnly", err)
+	}
+	if err := s.Remove("bank", false); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("Remove error = %v, want ErrReadOnly", err)
+	}
+	if _, err := s.ChangeMasterPassword(context.Background(), testMasterPassword, "new"); !errors.Is(err, ErrReadOnly) {
+		t.Errorf("ChangeMasterPassword error = %v, want ErrReadOnly", err)
+	}
+}
+
+func TestMoveTo(t *testing.T) {
+	source, dest := newTestStore(t), newTestStore(t)
+	insertEntries(t, source, "gmail", "bank")
+	insertEntries(t, dest, "bank")
+
+	if err := source.MoveTo(dest, "gmail", "Personal/gmail", testMasterPassword, testMasterPassword); err != nil {
+		t.Fatalf("MoveTo: %v", err)
+	}
+	if source.Exists("gmail") {
+		t.Error("gmail is still in the source store")
+	}
+	// The destination has its own keyfile, so this proves it was re-encrypted
+	if got, err := dest.Show("Personal/gmail", testMasterPassword); err != nil || got != "gmail" {
+		t.Errorf("Show in destination = %q, %v, want gmail", got, err)
+	}
+
+	// A clash in the destination leaves the source untouched
+	err := source.MoveTo(dest, "bank", "bank", testMasterPassword, testMasterPassword)
+	if err == nil || !strings.Contains(err.Error(), "already exists") {
+		t.Fatalf("MoveTo onto an existing entry error = %v, want already exists", err)
+	}
+	if !source.Exists("bank") {
+		t.Error("bank was removed from the source after a failed move")
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	entry, err := ParseEntry("hunter2\nuser: alice\nurl: https://example.com\nhttps://example.com/reset\n")
+	if err != nil {
+		t.Fatalf("ParseEntry: %v", err)
+	}
+	if entry.Password != "hunter2" || entry.Username != "alice" {
+		t.Errorf("ParseEntry = %+v, want password hunter2 and username alice", entry)
+	}
+	if entry.Fields["url"] != "https://example.com" || entry.Fields["notes"] != "https://example.com/reset" {
+		t.Errorf("ParseEntry fields = %v", entry.Fields)
+	}
+
+	// A plain password that looks like JSON stays a plain password
+	if entry, err := ParseEntry(`{"password":"x"}`); err != nil || entry.Password != `{"password":"x"}` {
+		t.Errorf("ParseEntry of a JSON-looking password = %+v, %v", entry, err)
+	}
+
+	for _, bad := range []string{`{"password":""}`, `{"password":"x","pasword":"y"}`, `{"password":"x"} {}`, `not json`} {
+		if _, err := ParseJSONEntry(bad); err == nil {
+			t.Errorf("ParseJSONEntry(%q) should fail", bad)
+		}
+	}
+}
+
+func TestJSONEntry(t *testing.T) {
+	s := newTestStore(t)
+	entry := &Entry{Password: "hunter2", Username: "alice", Fields: map[string]string{"url": "example.com"}}
+	encoded, err := entry.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Insert("gmail", encoded, testMasterPassword); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	content, err := s.Show("gmail", testMasterPassword)
+	if err != nil || !IsJSONEntry(content) {
+		t.Fatalf("Show = %q, %v, want a JSON entry", content, err)
+	}
+	if got := TrimSecret(content); got != "hunter2" {
+		t.Errorf("TrimSecret = %q, want hunter2", got)
+	}
+	if got := MaskSecret(content); strings.Contains(got, "hunter2") || !strings.Contains(got, `"username": "alice"`) {
+		t.Errorf("MaskSecret = %q, want the password hidden and the username shown", got)
+	}
+
+	for field, want := range map[string]string{"password": "hunter2", "username": "alice", "url": "example.com"} {
+		if got, err := s.ShowField("gmail", field, testMasterPassword); err != nil || got != want {
+			t.Errorf("ShowField(%s) = %q, %v, want %q", field, got, err, want)
+		}
+	}
+	if _, err := s.ShowField("gmail", "pin", testMasterPassword); err == nil {
+		t.Error("ShowField of a missing field should fail")
+	}
+}
+
+func TestIsSensitive(t *testing.T) {
+	s := newTestStore(t)
+	sensitive, err := (&Entry{Password: "hunter2", Fields: map[string]string{"sensitive": "true"}}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"bank":  "hunter2\nsensitive: true\n",
+		"mail":  "hunter2\nsensitive: no\n",
+		"forum": "hunter2\n",
+		"vault": sensitive,
+	} {
+		if err := s.Insert(name, content, testMasterPassword); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string]bool{"bank": true, "mail": false, "forum": false, "vault": true} {
+		if got, err := s.IsSensitive(name, testMasterPassword); err != nil || got != want {
+			t.Errorf("IsSensitive(%s) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := s.IsSensitive("bank", "wrong"); err == nil {
+		t.Error("IsSensitive with a wrong master password should fail")
+	}
+}
+
+func TestGPGRead(t *testing.T) {
+	// A stand-in for gpg that "decrypts" by printing the file
+	bin := t.TempDir()
+	script := "#!/bin/sh\nfor last; do :; done\ncat \"$last\"\n"
+	if err := os.WriteFile(filepath.Join(bin, "gpg"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	s := newTestStore(t)
+	if err := os.MkdirAll(filepath.Join(s.baseDir, "Email"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{"Email/old.gpg": "from pass\n", "both.gpg": "stale\n"} {
+		if err := os.WriteFile(filepath.Join(s.baseDir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Insert("both", "migrated", testMasterPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Show("Email/old", testMasterPassword); err == nil || !strings.Contains(err.Error(), "PASSWORD_STORE_GPG_READ") {
+		t.Errorf("Show of a .gpg entry without GPG read = %v, want a hint to enable it", err)
+	}
+
+	s.SetGPGRead(true)
+	if got, err := s.Show("Email/old", testMasterPassword); err != nil || got != "from pass\n" {
+		t.Errorf("Show of a .gpg entry = %q, %v", got, err)
+	}
+	// The native entry wins over a .gpg file of the same name
+	if got, err := s.Show("both", testMasterPassword); err != nil || got != "migrated" {
+		t.Errorf("Show of a migrated entry = %q, %v", got, err)
+	}
+	// Only native entries exist for writes, so inserting one migrates it
+	if s.Exists("Email/old") {
+		t.Error("Exists of a .gpg entry = true")
+	}
+	if got, err := s.Resolve("Email/old"); err != nil || got != "Email/old" {
+		t.Errorf("Resolve of a .gpg entry = %q, %v", got, err)
+	}
+	if names, err := s.Glob("**"); err != nil || strings.Join(names, ",") != "Email/old,both" {
+		t.Errorf("Glob = %v, %v, want Email/old and both once", names, err)
+	}
+	if names, err := s.GPGEntryNames(); err != nil || len(names) != 1 || names[0] != "Email/old" {
+		t.Errorf("GPGEntryNames = %v, %v", names, err)
+	}
+}
+
+func TestErrorSentinels(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Insert("gmail", "hunter2", testMasterPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Show("nope", testMasterPassword); !errors.Is(err, ErrNotFound) || err.Error() != "password 'nope' does not exist" {
+		t.Errorf("Show of a missing entry = %v, want ErrNotFound", err)
+	}
+	if _, err := s.Resolve("nope"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Resolve of a missing entry = %v, want ErrNotFound", err)
+	}
+	if err := s.Insert("gmail", "other", testMasterPassword); !errors.Is(err, ErrExists) {
+		t.Errorf("Insert over an entry = %v, want ErrExists", err)
+	}
+	if _, err := s.Show("gmail", "wrong"); !errors.Is(err, crypto.ErrAuthFailed) {
+		t.Errorf("Show with a wrong master password = %v, want crypto.ErrAuthFailed", err)
+	}
+	if err := s.Insert("other", "x", "wrong"); !errors.Is(err, crypto.ErrAuthFailed) {
+		t.Errorf("Insert with a wrong master password = %v, want crypto.ErrAuthFailed", err)
+	}
+}
+
+func TestConvertToJSON(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Insert("bank", "hunter2\nlogin: bob\npin: 1234\n", testMasterPassword); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.ConvertToJSON("bank", testMasterPassword); err != nil {
+		t.Fatalf("ConvertToJSON: %v", err)
+	}
+	content, err := s.Show("bank", testMasterPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry, err := ParseEntry(content)
+	if err != nil || !IsJSONEntry(content) {
+		t.Fatalf("converted entry = %q, %v", content, err)
+	}
+	if entry.Password != "hunter2" || entry.Username != "bob" || entry.Fields["pin"] != "1234" {
+		t.Errorf("converted entry = %+v", entry)
+	}
+
+	if err := s.ConvertToJSON("bank", testMasterPassword); err == nil {
+		t.Error("converting a JSON entry again should fail")
+	}
+}
+
+func TestPruneEmptyDirs(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/gmail")
+	for _, dir := range []string{"Old/a/b", "Old/c", "Email/empty", ".cache/keep", ".git/refs/keep"} {
+		if err := os.MkdirAll(filepath.Join(s.baseDir, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := s.PruneEmptyDirs()
+	if err != nil {
+		t.Fatalf("PruneEmptyDirs: %v", err)
+	}
+	// Old/a/b, Old/a, Old/c, Old and Email/empty
+	if removed != 5 {
+		t.Errorf("PruneEmptyDirs removed %d directories, want 5", removed)
+	}
+	for _, dir := range []string{"Old", "Email/empty"} {
+		if _, err := os.Stat(filepath.Join(s.baseDir, dir)); !os.IsNotExist(err) {
+			t.Errorf("%s still exists", dir)
+		}
+	}
+	for _, dir := range []string{"Email", ".cache/keep", ".git/refs/keep"} {
+		if _, err := os.Stat(filepath.Join(s.baseDir, dir)); err != nil {
+			t.Errorf("%s was removed: %v", dir, err)
+		}
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	got, err := TOTPURI("jbsw y3dp ehpk 3pxp", "Google", "Email/gmail", TOTPOptions{})
+	want := "otpauth://totp/Google:Email%2Fgmail?issuer=Google&secret=JBSWY3DPEHPK3PXP"
+	if err != nil || got != want {
+		t.Errorf("TOTPURI = %q, %v, want %q", got, err, want)
+	}
+
+	got, err = TOTPURI("JBSWY3DPEHPK3PXP", "", "aws", TOTPOptions{Algorithm: "sha-256", Digits: 8})
+	want = "otpauth://totp/aws?algorithm=SHA256&digits=8&secret=JBSWY3DPEHPK3PXP"
+	if err != nil || got != want {
+		t.Errorf("TOTPURI = %q, %v, want %q", got, err, want)
+	}
+	if _, err := TOTPURI("JBSWY3DPEHPK3PXP", "", "aws", TOTPOptions{Digits: 9}); err == nil {
+		t.Error("TOTPURI accepted 9 digits")
+	}
+	if _, err := TOTPURI("JBSWY3DPEHPK3PXP", "", "aws", TOTPOptions{Algorithm: "MD5"}); err == nil {
+		t.Error("TOTPURI accepted MD5")
+	}
+
+	for _, bad := range []string{"", "not base32!", "JBSWY3DPEHPK3PX1"} {
+		if _, err := NormalizeTOTPSecret(bad); err == nil {
+			t.Errorf("NormalizeTOTPSecret(%q) should fail", bad)
+		}
+	}
+}
+
+// TestTOTPCode checks the test vectors of RFC 6238 appendix B
+func TestTOTPCode(t *testing.T) {
+	seeds := map[string][]byte{
+		TOTPSHA1:   []byte("12345678901234567890"),
+		TOTPSHA256: []byte("12345678901234567890123456789012"),
+		TOTPSHA512: []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+	}
+	tests := []struct {
+		unix  int64
+		codes map[string]string
+	}{
+		{59, map[string]string{TOTPSHA1: "94287082", TOTPSHA256: "46119246", TOTPSHA512: "90693936"}},
+		{1111111109, map[string]string{TOTPSHA1: "07081804", TOTPSHA256: "68084774", TOTPSHA512: "25091201"}},
+		{1111111111, map[string]string{TOTPSHA1: "14050471", TOTPSHA256: "67062674", TOTPSHA512: "99943326"}},
+		{1234567890, map[string]string{TOTPSHA1: "89005924", TOTPSHA256: "91819424", TOTPSHA512: "93441116"}},
+		{2000000000, map[string]string{TOTPSHA1: "69279037", TOTPSHA256: "90698825", TOTPSHA512: "38618901"}},
+		{20000000000, map[string]string{TOTPSHA1: "65353130", TOTPSHA256: "77737706", TOTPSHA512: "47863826"}},
+	}
+
+	for _, tt := range tests {
+		for algorithm, want := range tt.codes {
+			got, _, err := TOTPCode(seeds[algorithm], TOTPOptions{Algorithm: algorithm, Digits: 8}, time.Unix(tt.unix, 0))
+			if err != nil || got != want {
+				t.Errorf("TOTPCode(%s, %d) = %q, %v, want %s", algorithm, tt.unix, got, err, want)
+			}
+		}
+	}
+
+	// Six digits keep the low digits of the same value
+	got, remaining, err := TOTPCode(seeds[TOTPSHA1], TOTPOptions{}, time.Unix(59, 0))
+	if err != nil || got != "287082" || remaining != time.Second {
+		t.Errorf("TOTPCode with defaults = %q, %v, %v, want 287082 valid for 1s", got, remaining, err)
+	}
+}
+
+func TestParseTOTP(t *testing.T) {
+	key, opts, err := ParseTOTP("otpauth://totp/aws?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&algorithm=SHA256&digits=8&period=60")
+	if err != nil || string(key) != "12345678901234567890" || opts != (TOTPOptions{Algorithm: TOTPSHA256, Digits: 8, Period: 60}) {
+		t.Errorf("ParseTOTP = %q, %+v, %v", key, opts, err)
+	}
+
+	// A bare secret uses the defaults
+	if _, opts, err := ParseTOTP("GEZDGNBVGY3TQOJQ"); err != nil || opts != (TOTPOptions{Algorithm: TOTPSHA1, Digits: 6, Period: 30}) {
+		t.Errorf("ParseTOTP(bare) = %+v, %v", opts, err)
+	}
+
+	for _, bad := range []string{
+		"otpauth://hotp/x?secret=GEZDGNBVGY3TQOJQ&counter=1",
+		"otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ&digits=ten",
+		"otpauth://totp/x?secret=GEZDGNBVGY3TQOJQ&algorithm=MD5",
+		"otpauth://totp/x",
+	} {
+		if _, _, err := ParseTOTP(bad); err == nil {
+			t.Errorf("ParseTOTP(%q) should fail", bad)
+		}
+	}
+}
+
+func TestImportTOTP(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.Insert("gmail", "hunter2\nusername: alice\notp: JBSWY3DPEHPK3PXP\n", testMasterPassword); err != nil {
+		t.Fatal(err)
+	}
+	insertEntries(t, s, "bank")
+
+	bare, err := s.BareTOTPSecrets(testMasterPassword)
+	if err != nil || len(bare) != 1 || bare["gmail"] != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("BareTOTPSecrets = %v, %v, want gmail", bare, err)
+	}
+
+	if err := s.ImportTOTP("gmail", bare["gmail"], "", TOTPOptions{}, testMasterPassword); err != nil {
+		t.Fatalf("ImportTOTP: %v", err)
+	}
+	want := "hunter2\nusername: alice\notp: otpauth://totp/gmail?secret=JBSWY3DPEHPK3PXP\n"
+	if got, _ := s.Show("gmail", testMasterPassword); got != want {
+		t.Errorf("after ImportTOTP = %q, want %q", got, want)
+	}
+	if bare, _ := s.BareTOTPSecrets(testMasterPassword); len(bare) != 0 {
+		t.Errorf("BareTOTPSecrets after migration = %v, want none", bare)
+	}
+
+	// Plain entries get the line appended
+	if err := s.ImportTOTP("bank", "JBSWY3DPEHPK3PXP", "Bank", TOTPOptions{}, testMasterPassword); err != nil {
+		t.Fatalf("ImportTOTP: %v", err)
+	}
+	if got, _ := s.ShowField("bank", "otp", testMasterPassword); got != "otpauth://totp/Bank:bank?issuer=Bank&secret=JBSWY3DPEHPK3PXP" {
+		t.Errorf("otp field of bank = %q", got)
+	}
+
+	if code, _, err := s.ShowTOTP("bank", testMasterPassword); err != nil || len(code) != 6 {
+		t.Errorf("ShowTOTP = %q, %v, want a 6-digit code", code, err)
+	}
+
+	if err := s.ImportTOTP("missing", "JBSWY3DPEHPK3PXP", "", TOTPOptions{}, testMasterPassword); err == nil {
+		t.Error("ImportTOTP into a missing entry should fail")
+	}
+}
+
+func TestExportEntries(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "top")
+	if err := s.Insert("Email/gmail", "hunter2\nusername: alice\nurl: https://mail.google.com\npin: 1234\nrecovery codes below", testMasterPassword); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+
+	entries, err := s.ExportEntries(context.Background(), testMasterPassword)
+	if err != nil {
+		t.Fatalf("ExportEntries: %v", err)
+	}
+	if len(entries) != 2 || entries[0].Name != "Email/gmail" || entries[1].Name != "top" {
+		t.Fatalf("ExportEntries = %+v, want Email/gmail and top", entries)
+	}
+	if entries[0].Username != "alice" || entries[0].Fields["url"] != "https://mail.google.com" {
+		t.Errorf("ExportEntries[0] = %+v, want username and url split out", entries[0])
+	}
+	if entries[1].Size != int64(len("top")) || entries[1].DiskSize <= entries[1].Size {
+		t.Errorf("ExportEntries[1] sizes = %d, %d, want 3 and the encrypted file size", entries[1].Size, entries[1].DiskSize)
+	}
+
+	sizes := FolderSizes(entries)
+	if len(sizes) != 2 || sizes[0].Folder != "Email" || sizes[0].Entries != 1 || sizes[0].Size != entries[0].Size || sizes[1].Folder != "" {
+		t.Errorf("FolderSizes = %+v, want Email then the top level", sizes)
+	}
+
+	var out bytes.Buffer
+	if err := WriteKeePassCSV(&out, entries); err != nil {
+		t.Fatalf("WriteKeePassCSV: %v", err)
+	}
+	want := `Group,Title,Username,Password,URL,Notes
+Email,gmail,alice,hunter2,https://mail.google.com,"recovery codes below
+pin: 1234"
+,top,,top,,
+`
+	if out.String() != want {
+		t.Errorf("WriteKeePassCSV =\n%s\nwant\n%s", out.String(), want)
+	}
+}
+
+func TestExportArchive(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "a", "Folder/b")
+	updateEntry(t, s, "a", "changed")
+
+	archivePath := filepath.Join(t.TempDir(), "export.tar.gz")
+	count, err := s.ExportArchive(archivePath)
+	if err != nil {
+		t.Fatalf("ExportArchive: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("ExportArchive = %d, want 2", count)
+	}
+	if info, err := os.Stat(archivePath); err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("archive mode = %v, %v, want 0600", info, err)
+	}
+
+	if _, err := s.ExportArchive(archivePath); err == nil {
+		t.Error("ExportArchive overwrote an existing file")
+	}
+}
+
+func TestImportArchive(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "a", "Folder/b")
+	updateEntry(t, s, "a", "changed\nuser: bob")
+
+	archivePath := filepath.Join(t.TempDir(), "export.tar.gz")
+	if _, err := s.ExportArchive(archivePath); err != nil {
+		t.Fatalf("ExportArchive: %v", err)
+	}
+
+	// A new device with the same keyfile, from the recovery phrase, and a
+	// new master password
+	dir := t.TempDir()
+	keyFile, err := os.ReadFile(filepath.Join(s.baseDir, ".keyfile"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ".keyfile"), keyFile, 0600); err != nil {
+		t.Fatal(err)
+	}
+	imported, err := New(dir)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	const newPassword = "a new master password"
+
+	if _, err := imported.ImportArchive(context.Background(), archivePath, newPassword, newPassword); !errors.Is(err, crypto.ErrAuthFailed) {
+		t.Fatalf("ImportArchive with the wrong archive password = %v, want ErrAuthFailed", err)
+	}
+	if got := entryNames(t, imported); len(got) != 0 {
+		t.Fatalf("failed import stored %v", got)
+	}
+
+	count, err := imported.ImportArchive(context.Background(), archivePath, testMasterPassword, newPassword)
+	if err != nil || count != 2 {
+		t.Fatalf("ImportArchive = %d, %v, want 2", count, err)
+	}
+	if got, err := imported.Show("a", newPassword); err != nil || got != "changed\nuser: bob" {
+		t.Errorf("imported a = %q, %v", got, err)
+	}
+	if got, err := imported.Show("Folder/b", newPassword); err != nil || got != "Folder/b" {
+		t.Errorf("imported Folder/b = %q, %v", got, err)
+	}
+}
+
+func TestDirDescriptions(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Work/vpn", "Work/Team/wiki", "top")
+
+	if err := s.SetDirDescription("Work", "corporate accounts"); err != nil {
+		t.Fatalf("SetDirDescription: %v", err)
+	}
+	if err := s.SetDirDescription("Work/Team/", "  shared  "); err != nil {
+		t.Fatalf("SetDirDescription: %v", err)
+	}
+	if err := s.SetDirDescription("Missing", "x"); err == nil {
+		t.Error("SetDirDescription described a folder that does not exist")
+	}
+	if err := s.SetDirDescription("Work", "two\nlines"); err == nil {
+		t.Error("SetDirDescription accepted a multi-line description")
+	}
+
+	if got, err := s.GetDirDescription("Work"); err != nil || got != "corporate accounts" {
+		t.Errorf("GetDirDescription = %q, %v, want corporate accounts", got, err)
+	}
+	descriptions, err := s.DirDescriptions()
+	if err != nil {
+		t.Fatalf("DirDescriptions: %v", err)
+	}
+	if len(descriptions) != 2 || descriptions["Work/Team"] != "shared" {
+		t.Errorf("DirDescriptions = %v, want Work and Work/Team", descriptions)
+	}
+
+	// Description files are never listed as entries
+	if got := entryNames(t, s); len(got) != 3 {
+		t.Errorf("entries = %v, want 3", got)
+	}
+
+	if err := s.SetDirDescription("Work", ""); err != nil {
+		t.Fatalf("SetDirDescription: %v", err)
+	}
+	if got, err := s.GetDirDescription("Work"); err != nil || got != "" {
+		t.Errorf("GetDirDescription after removal = %q, %v, want empty", got, err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/gmail", "Email/gmx", "Work/GitHub", "Work/vpn", "vpn")
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"vpn", "vpn"}, // exact match wins over Work/vpn
+		{"Email/gmail/", "Email/gmail"},
+		{"gma", "Email/gmail"},    // prefix of the last component
+		{"git", "Work/GitHub"},    // case-insensitive
+		{"work/g", "Work/GitHub"}, // prefix of the full path
+		{"hub", "Work/GitHub"},    // substring
+	}
+	for _, tt := range tests {
+		got, err := s.Resolve(tt.name)
+		if err != nil || got != tt.want {
+			t.Errorf("Resolve(%q) = %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	var ambiguous *AmbiguousNameError
+	if _, err := s.Resolve("gm"); !errors.As(err, &ambiguous) || len(ambiguous.Matches) != 2 {
+		t.Errorf("Resolve(gm) = %v, want two matches", err)
+	}
+
+	var notFound *NotFoundError
+	_, err := s.Resolve("gmial")
+	if !errors.As(err, &notFound) || len(notFound.Suggestions) != 1 || notFound.Suggestions[0] != "Email/gmail" {
+		t.Errorf("Resolve(gmial) = %v, want a suggestion of Email/gmail", err)
+	}
+	if _, err := s.Resolve("nothing-like-it"); !errors.As(err, &notFound) || len(notFound.Suggestions) != 0 {
+		t.Errorf("Resolve(nothing-like-it) = %v, want no suggestions", err)
+	}
+}
+
+func TestMirror(t *testing.T) {
+	s := newTestStore(t)
+	if err := s.SetMirrorDir(filepath.Join(s.baseDir, "mirror")); err == nil {
+		t.Error("SetMirrorDir accepted a directory inside the store")
+	}
+	mirror := t.TempDir()
+	if err := s.SetMirrorDir(mirror); err != nil {
+		t.Fatalf("SetMirrorDir: %v", err)
+	}
+
+	mirrored := func() []string {
+		var files []string
+		filepath.WalkDir(mirror, func(path string, d os.DirEntry, err error) error {
+			if err == nil && !d.IsDir() {
+				rel, _ := filepath.Rel(mirror, path)
+				files = append(files, filepath.ToSlash(rel))
+			}
+			return err
+		})
+		sort.Strings(files)
+		return files
+	}
+
+	insertEntries(t, s, "Email/gmail", "Email/gmx", "vpn")
+	updateEntry(t, s, "vpn", "changed")
+	data, err := os.ReadFile(filepath.Join(mirror, "vpn.enc"))
+	if err != nil {
+		t.Fatalf("reading mirrored vpn: %v", err)
+	}
+	if stored, _ := os.ReadFile(filepath.Join(s.baseDir, "vpn.enc")); !bytes.Equal(data, stored) {
+		t.Error("mirrored vpn differs from the stored ciphertext")
+	}
+
+	if err := s.Rename("Email", "Mail"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := s.Remove("vpn", false); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	want := []string{"Mail/gmail.enc", "Mail/gmx.enc"}
+	if got := mirrored(); strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("mirror holds %v, want %v", got, want)
+	}
+}
+
+func TestMissingFolder(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/Work/gmail", "top")
+
+	tests := map[string]string{
+		"new":              "",
+		"Email/outlook":    "",
+		"Email/Work/x":     "",
+		"Emial/gmail":      "Emial",
+		"Email/Home/x":     "Email/Home",
+		"Email/Home/Old/x": "Email/Home",
+	}
+	check := func() {
+		t.Helper()
+		for name, want := range tests {
+			if got, err := s.MissingFolder(name); err != nil || got != want {
+				t.Errorf("MissingFolder(%q) = %q, %v, want %q", name, got, err, want)
+			}
+		}
+	}
+	check()
+
+	// With hidden names the folders only exist in the name index
+	if _, err := s.HideNames(); err != nil {
+		t.Fatalf("HideNames: %v", err)
+	}
+	check()
+}
+
+func TestCreateDirsNever(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/gmail")
+	s.SetCreateDirs(config.CreateDirsNever)
+
+	if err := s.Insert("Emial/outlook", "secret", testMasterPassword); err == nil {
+		t.Error("Insert created a missing folder")
+	}
+	if err := s.Update("Emial/outlook", "secret", testMasterPassword); err == nil {
+		t.Error("Update created a missing folder")
+	}
+	count, err := s.InsertBatch(context.Background(), []BatchEntry{
+		{Name: "Email/outlook", Password: "one"},
+		{Name: "Emial/yahoo", Password: "two"},
+	}, testMasterPassword, false)
+	var batchErr *BatchError
+	if count != 1 || !errors.As(err, &batchErr) || len(batchErr.Failures) != 1 || batchErr.Failures[0].Entry.Name != "Emial/yahoo" {
+		t.Errorf("InsertBatch = %d, %v, want only Emial/yahoo refused", count, err)
+	}
+	if _, err := os.Stat(filepath.Join(s.baseDir, "Emial")); !os.IsNotExist(err) {
+		t.Errorf("folder Emial was created: %v", err)
+	}
+
+	// Once allowed, e.g. after asking, the folder is created
+	s.AllowNewFolders()
+	if err := s.Insert("Emial/outlook", "secret", testMasterPassword); err != nil {
+		t.Errorf("Insert after AllowNewFolders: %v", err)
+	}
+}
+
+func TestPostInsertHook(t *testing.T) {
+	s := newTestStore(t)
+	argsFile := filepath.Join(t.TempDir(), "args")
+	s.hooks = map[string]string{hooks.PostInsert: "sh -c 'echo \"$*\" >> \"$0\"' " + argsFile}
+
+	insertEntries(t, s, "Email/gmail", "Email/outlook")
+	if err := s.Update("Email/gmail", "changed", testMasterPassword); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if err := s.Rename("Email/outlook", "Email/hotmail"); err != nil {
+		t.Fatalf("Rename: %v", err)
+	}
+	if err := s.Rename("Email", "Mail"); err != nil {
+		t.Fatalf("Rename folder: %v", err)
+	}
+
+	data, err := os.ReadFile(argsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "Email/gmail\nEmail/outlook\nEmail/gmail\nEmail/hotmail\nMail/gmail Mail/hotmail\n"
+	if string(data) != want {
+		t.Errorf("post_insert hook was run with %q, want %q", data, want)
+	}
+}
+
+func TestModTime(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/gmail")
+
+	before := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(s.getPasswordFilePath("Email/gmail"), before, before); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+	got, err := s.ModTime("Email/gmail")
+	if err != nil {
+		t.Fatalf("ModTime: %v", err)
+	}
+	if !got.Equal(before) {
+		t.Errorf("ModTime = %v, want %v", got, before)
+	}
+
+	if err := s.Update("Email/gmail", "new", testMasterPassword); err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if got, err := s.ModTime("Email/gmail"); err != nil || !got.After(before) {
+		t.Errorf("ModTime after Update = %v, %v, want after %v", got, err, before)
+	}
+
+	if _, err := s.ModTime("missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("ModTime(missing) error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := newTestStore(t)
+	insertEntries(t, s, "Email/gmail", "bank")
+	updateEntry(t, s, "bank", "changed")
+
+	// A store without .store-version predates it
+	if version, err := s.StoreVersion(); err != nil || version != 1 {
+		t.Fatalf("StoreVersion = %d, %v, want 1", version, err)
+	}
+	pending, err := s.PendingMigrations()
+	if err != nil || len(pending) != 1 || pending[0].Version != CurrentStoreVersion {
+		t.Fatalf("PendingMigrations = %+v, %v, want the step to version %d", pending, err, CurrentStoreVersion)
+	}
+
+	// Entries written now already have headers
+	applied, rewritten, err := s.Migrate(context.Background(), testMasterPassword)
+	if err != nil || len(applied) != 1 || rewritten != 0 {
+		t.Fatalf("Migrate = %+v, %d, %v, want one step and no rewrites", applied, rewritten, err)
+	}
+	if version, err := s.StoreVersion(); err != nil || version != CurrentStoreVersion {
+		t.Errorf("StoreVersion after Migrate = %d, %v, want %d", version, err, CurrentStoreVersion)
+	}
+	if got, err := s.Show("bank", testMasterPassword); err != nil || got != "changed" {
+		t.Errorf("Show(bank) = %q, %v, want changed", got, err)
+	}
+
+	// Running it again has nothing to do
+	if applied, _, err := s.Migrate(context.Background(), testMasterPassword); err != nil || len(applied) != 0 {
+		t.Errorf("second Migrate = %+v, %v, want nothing applied", applied, err)
+	}
+
+	if err := writeStoreVersion(s.baseDir, CurrentStoreVersion+1); err != nil {
+		t.Fatalf("writeStoreVersion: %v", err)
+	}
+	if _, err := s.PendingMigrations(); err == nil {
+		t.Error("PendingMigrations on a newer store succeeded")
+	}
+}
@@ -0,0 +1,116 @@
+package termwidth
+
+import "unicode"
+
+// wideRanges are the East Asian Wide and Fullwidth ranges (UAX #11) and the
+// emoji blocks terminals draw two columns wide
+var wideRanges = []struct{ lo, hi rune }{
+	{0x1100, 0x115F},   // Hangul Jamo initials
+	{0x231A, 0x231B},   // Watch, hourglass
+	{0x2329, 0x232A},   // Angle brackets
+	{0x23E9, 0x23EC},   // Media controls
+	{0x23F0, 0x23F0},   // Alarm clock
+	{0x23F3, 0x23F3},   // Hourglass with flowing sand
+	{0x25FD, 0x25FE},   // Small squares
+	{0x2614, 0x2615},   // Umbrella, hot beverage
+	{0x2648, 0x2653},   // Zodiac
+	{0x267F, 0x267F},   // Wheelchair
+	{0x2693, 0x2693},   // Anchor
+	{0x26A1, 0x26A1},   // High voltage
+	{0x26AA, 0x26AB},   // Circles
+	{0x26BD, 0x26BE},   // Balls
+	{0x26C4, 0x26C5},   // Snowman, sun behind cloud
+	{0x26CE, 0x26CE},   // Ophiuchus
+	{0x26D4, 0x26D4},   // No entry
+	{0x26EA, 0x26EA},   // Church
+	{0x26F2, 0x26F3},   // Fountain, golf
+	{0x26F5, 0x26F5},   // Sailboat
+	{0x26FA, 0x26FA},   // Tent
+	{0x26FD, 0x26FD},   // Fuel pump
+	{0x2705, 0x2705},   // Check mark
+	{0x270A, 0x270B},   // Fists
+	{0x2728, 0x2728},   // Sparkles
+	{0x274C, 0x274C},   // Cross mark
+	{0x274E, 0x274E},   // Cross mark button
+	{0x2753, 0x2755},   // Question and exclamation marks
+	{0x2757, 0x2757},   // Exclamation mark
+	{0x2795, 0x2797},   // Plus, minus, divide
+	{0x27B0, 0x27B0},   // Curly loop
+	{0x27BF, 0x27BF},   // Double curly loop
+	{0x2B1B, 0x2B1C},   // Large squares
+	{0x2B50, 0x2B50},   // Star
+	{0x2B55, 0x2B55},   // Circle
+	{0x2E80, 0x303E},   // CJK radicals, Kangxi, CJK symbols and punctuation
+	{0x3041, 0x33FF},   // Hiragana, Katakana, Bopomofo, Hangul compatibility, CJK compatibility
+	{0x3400, 0x4DBF},   // CJK extension A
+	{0x4E00, 0x9FFF},   // CJK unified ideographs
+	{0xA000, 0xA4CF},   // Yi
+	{0xA960, 0xA97F},   // Hangul Jamo extended A
+	{0xAC00, 0xD7A3},   // Hangul syllables
+	{0xF900, 0xFAFF},   // CJK compatibility ideographs
+	{0xFE10, 0xFE19},   // Vertical forms
+	{0xFE30, 0xFE6F},   // CJK compatibility forms, small form variants
+	{0xFF00, 0xFF60},   // Fullwidth forms
+	{0xFFE0, 0xFFE6},   // Fullwidth signs
+	{0x16FE0, 0x16FE4}, // Ideographic symbols
+	{0x17000, 0x18CFF}, // Tangut
+	{0x1B000, 0x1B2FF}, // Kana supplement and extensions, Nushu
+	{0x1F004, 0x1F004}, // Mahjong tile
+	{0x1F0CF, 0x1F0CF}, // Playing card
+	{0x1F18E, 0x1F18E}, // AB button
+	{0x1F191, 0x1F19A}, // Squared words
+	{0x1F200, 0x1F2FF}, // Enclosed ideographic supplement
+	{0x1F300, 0x1F64F}, // Pictographs, emoticons
+	{0x1F680, 0x1F6FF}, // Transport and map symbols
+	{0x1F7E0, 0x1F7EB}, // Colored circles and squares
+	{0x1F90C, 0x1F9FF}, // Supplemental symbols and pictographs
+	{0x1FA70, 0x1FAFF}, // Symbols and pictographs extended A
+	{0x20000, 0x2FFFD}, // CJK extensions B-F
+	{0x30000, 0x3FFFD}, // CJK extension G and beyond
+}
+
+// Rune returns how many terminal columns r occupies. Ambiguous-width
+// characters count as one column, so the result does not depend on the locale.
+func Rune(r rune) int {
+	switch {
+	case r == 0, r < 32, r >= 0x7F && r < 0xA0:
+		return 0
+	case unicode.In(r, unicode.Mn, unicode.Me, unicode.Cf), r == 0x200B:
+		return 0 // Combining marks, zero-width space and joiners
+	case r >= 0x1160 && r <= 0x11FF:
+		return 0 // Hangul Jamo medial vowels and finals join the initial
+	}
+
+	for _, wide := range wideRanges {
+		if r < wide.lo {
+			break
+		}
+		if r <= wide.hi {
+			return 2
+		}
+	}
+	return 1
+}
+
+// String returns how many terminal columns text occupies
+func String(text string) int {
+	width := 0
+	for _, r := range text {
+		width += Rune(r)
+	}
+	return width
+}
+
+// Truncate cuts text to at most width terminal columns without
+// splitting a character
+func Truncate(text string, width int) string {
+	used := 0
+	for i, r := range text {
+		w := Rune(r)
+		if used+w > width {
+			return text[:i]
+		}
+		used += w
+	}
+	return text
+}
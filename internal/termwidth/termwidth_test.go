@@ -0,0 +1,46 @@
+package termwidth
+
+import "testing"
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		text string
+		want int
+	}{
+		{text: "Enter master password: ", want: 23},
+		{text: "マスターパスワード", want: 18},
+		{text: "주 비밀번호", want: 11},
+		{text: "Contraseña maestra", want: 18},
+		{text: "Contrasen\u0303a", want: 10}, // Combining tilde
+		{text: "🔒 vault", want: 8},
+		{text: "ｆｕｌｌ", want: 8},
+		{text: "", want: 0},
+	}
+
+	for _, tt := range tests {
+		if got := String(tt.text); got != tt.want {
+			t.Errorf("termwidth.String(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		text  string
+		width int
+		want  string
+	}{
+		{text: "Email/gmail", width: 5, want: "Email"},
+		{text: "密码/银行", width: 5, want: "密码/"},
+		{text: "密码/银行", width: 4, want: "密码"},
+		{text: "🔒 vault", width: 1, want: ""},
+		{text: "short", width: 10, want: "short"},
+		{text: "short", width: 0, want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := Truncate(tt.text, tt.width); got != tt.want {
+			t.Errorf("Truncate(%q, %d) = %q, want %q", tt.text, tt.width, got, tt.want)
+		}
+	}
+}